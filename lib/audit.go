@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a record of the audit log: who did what with which report
+// and when.
+type AuditEntry struct {
+	// Time is when the entry was recorded. It is set by AuditLog.Record.
+	Time time.Time `json:"time"`
+	// Actor identifies who requested the action, e.g. the client name of the
+	// server quota or the user name.
+	Actor string `json:"actor,omitempty"`
+	// Action is what was done, e.g. "ParseAndAggregate" or "export".
+	Action string `json:"action"`
+	// ReportID is Report.ID, when known.
+	ReportID string `json:"reportId,omitempty"`
+	// Fingerprints is the fingerprint of each bucket of the report.
+	Fingerprints []string `json:"fingerprints,omitempty"`
+	// Destination is where the report was exported to, e.g. a URL or a path.
+	Destination string `json:"destination,omitempty"`
+	// Prev is the SHA-256 of the previous line of the log, empty for the first
+	// entry. It chains the entries so an entry edited or removed in the middle
+	// of the log is detected by VerifyAuditLog. It is set by AuditLog.Record.
+	Prev string `json:"prev,omitempty"`
+}
+
+// AuditLog is an append-only audit log of the reports produced and exported,
+// stored as one JSON AuditEntry per line.
+//
+// The chain is not keyed: it detects accidental corruption and naive edits,
+// but whoever can write the file can also remove the last entries or rewrite
+// the whole log with a valid chain. To detect this, save Head regularly
+// outside of the host; a saved value must be the Prev of a later entry or the
+// Head of the reopened log.
+//
+// It is safe for concurrent use.
+type AuditLog struct {
+	clock Clock
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	prev string
+}
+
+// OpenAuditLog opens the audit log at path, creating it if needed. The new
+// entries are appended.
+//
+// An incomplete last line, left by a crash while recording an entry, is
+// removed.
+//
+// clock provides the time of the entries. Defaults to SystemClock.
+func OpenAuditLog(path string, clock Clock) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	prev, size, err := lastAuditHash(f)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &AuditLog{clock: clockOr(clock), f: f, size: size, prev: prev}, nil
+}
+
+// Record appends the entry to the log, setting its Time and Prev.
+func (a *AuditLog) Record(e AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return os.ErrClosed
+	}
+	e.Time = a.clock.Now().UTC()
+	e.Prev = a.prev
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if _, err := a.f.Write(b); err != nil {
+		// Remove the partial line, otherwise the next entry would be appended
+		// to it and the log wouldn't verify anymore.
+		_ = a.f.Truncate(a.size)
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		return err
+	}
+	a.size += int64(len(b))
+	a.prev = auditHash(b[:len(b)-1])
+	return nil
+}
+
+// Head returns the SHA-256 of the last entry of the log, the value of Prev
+// of the next entry. It is empty if the log is empty.
+func (a *AuditLog) Head() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.prev
+}
+
+// Close closes the log.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return os.ErrClosed
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// VerifyAuditLog reads an audit log and verifies the chain of entries.
+//
+// It returns the entries read and an error identifying the first entry that
+// doesn't follow the previous one, e.g. because an entry in the middle was
+// edited or removed. An incomplete last line is ignored.
+//
+// It cannot detect that the last entries were removed or that the log was
+// rewritten entirely, see AuditLog.
+func VerifyAuditLog(r io.Reader) ([]AuditEntry, error) {
+	var out []AuditEntry
+	prev := ""
+	s := newAuditScanner(r)
+	for n := 1; s.Scan(); n++ {
+		var e AuditEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return out, fmt.Errorf("line %d: %v", n, err)
+		}
+		if e.Prev != prev {
+			return out, fmt.Errorf("line %d: broken chain", n)
+		}
+		out = append(out, e)
+		prev = auditHash(s.Bytes())
+	}
+	return out, s.Err()
+}
+
+// Private stuff.
+
+func auditHash(line []byte) string {
+	h := sha256.Sum256(line)
+	return hex.EncodeToString(h[:])
+}
+
+// lastAuditHash returns the hash of the last complete line of the log and the
+// offset following it.
+func lastAuditHash(r io.Reader) (string, int64, error) {
+	var last []byte
+	var size int64
+	s := newAuditScanner(r)
+	for s.Scan() {
+		last = append(last[:0], s.Bytes()...)
+		size += int64(len(last)) + 1
+	}
+	if err := s.Err(); err != nil || last == nil {
+		return "", size, err
+	}
+	return auditHash(last), size, nil
+}
+
+// newAuditScanner returns a scanner of the complete lines of the log, i.e.
+// terminated by a newline.
+func newAuditScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16<<20)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			// Skip the incomplete last line.
+			return len(data), nil, nil
+		}
+		return 0, nil, nil
+	})
+	return s
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "audit.log")
+	clock := NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	a, err := OpenAuditLog(p, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(AuditEntry{Actor: "alice", Action: "create", ReportID: "1", Fingerprints: []string{"abc"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(AuditEntry{Action: "create"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Reopening continues the chain.
+	clock.Advance(time.Second)
+	if a, err = OpenAuditLog(p, clock); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(AuditEntry{Actor: "bob", Action: "export", ReportID: "1", Destination: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := VerifyAuditLog(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Prev != "" || entries[1].Prev == "" || entries[1].Actor != "bob" || !entries[1].Time.Equal(time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)) {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+
+	// Editing an entry breaks the chain.
+	b = bytes.Replace(b, []byte("alice"), []byte("carol"), 1)
+	if entries, err = VerifyAuditLog(bytes.NewReader(b)); err == nil || len(entries) != 1 {
+		t.Fatalf("expected error, got %v, %d entries", err, len(entries))
+	}
+}
+
+func TestAuditLogTornLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "audit.log")
+	a, err := OpenAuditLog(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(AuditEntry{Action: "create"}); err != nil {
+		t.Fatal(err)
+	}
+	head := a.Head()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash while writing the second entry.
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"time":"2020-01-02T03:04:05Z","act`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := VerifyAuditLog(bytes.NewReader(b)); err != nil || len(entries) != 1 {
+		t.Fatalf("unexpected %d entries: %v", len(entries), err)
+	}
+
+	// Reopening removes the incomplete line and continues the chain.
+	if a, err = OpenAuditLog(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if a.Head() != head {
+		t.Fatalf("want head %q, got %q", head, a.Head())
+	}
+	if err := a.Record(AuditEntry{Action: "export"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ioutil.ReadFile(p); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := VerifyAuditLog(bytes.NewReader(b))
+	if err != nil || len(entries) != 2 || entries[1].Prev != head {
+		t.Fatalf("unexpected entries %#v: %v", entries, err)
+	}
+}
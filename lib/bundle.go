@@ -0,0 +1,319 @@
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
+)
+
+// BundleVersion is the version of the crash bundle format written by
+// WriteBundle.
+const BundleVersion = 1
+
+// Names of the files in a crash bundle.
+const (
+	BundleManifestFile = "manifest.json"
+	BundleDumpFile     = "dump.txt"
+	BundleReportFile   = "report.json"
+	BundleTextFile     = "report.txt"
+	BundleSnippetsFile = "snippets.json"
+	BundleHTMLFile     = "report.html"
+)
+
+// BundleOptions controls WriteBundle.
+//
+// The zero value is valid.
+type BundleOptions struct {
+	// Metadata is free form data stored in the manifest, e.g. the host name,
+	// the build ID or the incident ticket.
+	Metadata map[string]string
+	// Analyzers are run on the parsed dump. Their findings are stored in the
+	// report and lead the text report.
+	Analyzers []analysis.Analyzer
+	// SnippetContext is the number of source lines stored before and after
+	// each call. Defaults to 5.
+	SnippetContext int
+	// Clock provides the creation time and the ID of the bundle. Defaults to
+	// SystemClock.
+	Clock Clock
+}
+
+// BundleManifest describes a crash bundle. It is the first file of the
+// archive.
+type BundleManifest struct {
+	// Version is the version of the format. See BundleVersion.
+	Version int `json:"version"`
+	// Producer identifies the code that wrote the bundle. See
+	// Report.Producer.
+	Producer string `json:"producer,omitempty"`
+	// ID uniquely identifies the bundle. It is also the ID of the report.
+	ID string `json:"id,omitempty"`
+	// Created is when the bundle was written.
+	Created time.Time `json:"created"`
+	// Crash is why the dump was printed, e.g. "panic".
+	Crash string `json:"crash"`
+	// Goroutines is the number of goroutines in the dump.
+	Goroutines int `json:"goroutines"`
+	// Metadata is BundleOptions.Metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Files is the other files of the archive, in order.
+	Files []string `json:"files"`
+}
+
+// Snippet is the source lines around a call, so the bundle can be read
+// without the sources.
+type Snippet struct {
+	// Location is the module relative source path and line, e.g.
+	// "github.com/foo/bar/baz.go:12".
+	Location string `json:"location"`
+	// Func is the function name, e.g. "bar.Baz".
+	Func string `json:"func"`
+	// Lines is the source lines.
+	Lines []SourceLine `json:"lines"`
+}
+
+// Bundle is the decoded content of a crash bundle.
+type Bundle struct {
+	Manifest BundleManifest
+	// Dump is the raw stack dump, verbatim.
+	Dump []byte
+	// Report is the parsed dump.
+	Report *Report
+	// Text is the dump rendered as by ParsePanicStringOpts.
+	Text string
+	// Snippets is the source lines around the calls whose source was found
+	// locally, excluding the standard library.
+	Snippets []Snippet
+	// HTML is the self contained HTML report, as served by NewBundleViewer.
+	// It is empty for bundles written before it was added.
+	HTML string
+}
+
+// WriteBundle writes a crash bundle: a .tar.gz archive containing the
+// manifest, the raw dump, the report as JSON, as text and as HTML, and the
+// source
+// snippets of the calls.
+//
+// The dump is parsed with guesspaths to find the local sources.
+//
+// opts can be nil.
+func WriteBundle(w io.Writer, dump []byte, opts *BundleOptions) error {
+	if opts == nil {
+		opts = &BundleOptions{}
+	}
+	c, err := stack.ParseDump(bytes.NewReader(dump), nil, true)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("no stack dump found")
+	}
+	clock := clockOr(opts.Clock)
+	created := clock.Now().UTC().Truncate(time.Second)
+	r := &Report{ID: clock.NewID(), Created: &created, Panic: c.Panic, Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}
+	if len(opts.Analyzers) != 0 {
+		r.Findings = analysis.Run(c, opts.Analyzers)
+	}
+	var report bytes.Buffer
+	if err := WriteReport(&report, r); err != nil {
+		return err
+	}
+	text, err := ParsePanicStringOpts(string(dump), nil)
+	if err != nil {
+		return err
+	}
+	if len(r.Findings) != 0 {
+		// The conclusions lead the report.
+		text = append([]string{FormatFindings(r.Findings)}, text...)
+	}
+	n := opts.SnippetContext
+	if n <= 0 {
+		n = 5
+	}
+	b := &Bundle{
+		Manifest: BundleManifest{
+			Version:    BundleVersion,
+			Producer:   producer(),
+			ID:         r.ID,
+			Created:    created,
+			Crash:      c.Crash.String(),
+			Goroutines: len(c.Goroutines),
+			Metadata:   opts.Metadata,
+		},
+		Dump:     dump,
+		Report:   r,
+		Text:     strings.Join(text, "\n"),
+		Snippets: bundleSnippets(r.Buckets, n),
+	}
+	snippets, err := json.MarshalIndent(b.Snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The page links to the raw dump extracted next to it.
+	var page bytes.Buffer
+	if err := bundleTmpl.Execute(&page, &bundlePage{Bundle: b, DumpURL: BundleDumpFile}); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{BundleDumpFile, dump},
+		{BundleReportFile, report.Bytes()},
+		{BundleTextFile, []byte(b.Text)},
+		{BundleSnippetsFile, snippets},
+		{BundleHTMLFile, page.Bytes()},
+	}
+	m := b.Manifest
+	for _, f := range files {
+		m.Files = append(m.Files, f.name)
+	}
+	manifest, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, BundleManifestFile, manifest, m.Created); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeTarFile(tw, f.name, f.data, m.Created); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadBundle reads a crash bundle written by WriteBundle by this version or
+// any prior version.
+//
+// Unknown files are ignored, so bundles can be extended by hand. Since the
+// bundles are attached to tickets and may come from anywhere, a file larger
+// than 64MiB or files totaling more than 256MiB are rejected.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	out := &Bundle{}
+	found := false
+	tr := tar.NewReader(gz)
+	left := maxBundleSize
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		max := maxBundleFileSize
+		if left < max {
+			max = left
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(tr, max+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(b)) > max {
+			if max == maxBundleFileSize {
+				return nil, fmt.Errorf("%s: larger than %d bytes", h.Name, maxBundleFileSize)
+			}
+			return nil, fmt.Errorf("bundle larger than %d bytes", maxBundleSize)
+		}
+		left -= int64(len(b))
+		switch h.Name {
+		case BundleManifestFile:
+			if err := json.Unmarshal(b, &out.Manifest); err != nil {
+				return nil, fmt.Errorf("%s: %v", h.Name, err)
+			}
+			if out.Manifest.Version > BundleVersion {
+				return nil, fmt.Errorf("unsupported bundle version %d; supported up to %d", out.Manifest.Version, BundleVersion)
+			}
+			found = true
+		case BundleDumpFile:
+			out.Dump = b
+		case BundleReportFile:
+			if out.Report, err = ReadJSON(bytes.NewReader(b)); err != nil {
+				return nil, fmt.Errorf("%s: %v", h.Name, err)
+			}
+		case BundleTextFile:
+			out.Text = string(b)
+		case BundleSnippetsFile:
+			if err := json.Unmarshal(b, &out.Snippets); err != nil {
+				return nil, fmt.Errorf("%s: %v", h.Name, err)
+			}
+		case BundleHTMLFile:
+			out.HTML = string(b)
+		}
+	}
+	if !found {
+		return nil, errors.New("not a crash bundle: no " + BundleManifestFile)
+	}
+	return out, nil
+}
+
+// Private stuff.
+
+// The limits of ReadBundle. They are variables so the tests can lower them.
+var (
+	maxBundleFileSize int64 = 64 << 20
+	maxBundleSize     int64 = 256 << 20
+)
+
+// writeTarFile adds a regular file to the archive.
+func writeTarFile(tw *tar.Writer, name string, data []byte, t time.Time) error {
+	h := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: t, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// bundleSnippets returns the snippets of the calls, once per location.
+//
+// Only the Go source files found locally by guesspaths are read; the paths in
+// the dump are untrusted and must not pull arbitrary files in the bundle.
+func bundleSnippets(buckets []*stack.Bucket, context int) []Snippet {
+	out := []Snippet{}
+	seen := map[string]bool{}
+	add := func(c *stack.Call) {
+		if c.IsStdlib || c.LocalSrcPath == "" || !strings.HasSuffix(c.LocalSrcPath, ".go") {
+			return
+		}
+		loc := fmt.Sprintf("%s:%d", c.ModuleSrcPath(), c.Line)
+		if seen[loc] {
+			return
+		}
+		seen[loc] = true
+		if l := readSnippet(c.LocalSrcPath, c.Line, context); len(l) != 0 {
+			out = append(out, Snippet{Location: loc, Func: c.Func.PkgDotName(), Lines: l})
+		}
+	}
+	for _, b := range buckets {
+		for i := range b.Stack.Calls {
+			add(&b.Stack.Calls[i])
+		}
+		if b.CreatedBy.Func.Raw != "" {
+			add(&b.CreatedBy)
+		}
+	}
+	return out
+}
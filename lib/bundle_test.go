@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The snippets are only read from the sources found in GOPATH.
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "src", "foo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "src", "foo", "main.go")
+	if err := ioutil.WriteFile(src, []byte("package main\n\nfunc main() {\n\tpanic(\"boom\")\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dump := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t" + filepath.ToSlash(src) + ":4 +0x25\nexit status 2\n"
+	var b bytes.Buffer
+	if err := WriteBundle(&b, []byte(dump), &BundleOptions{Metadata: map[string]string{"host": "foo"}, SnippetContext: 1, Clock: NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBundle(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got.Manifest
+	if m.Version != BundleVersion || m.Crash != "panic" || m.Goroutines != 1 || m.Metadata["host"] != "foo" || !m.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) || m.ID != "00000000000000000000000000000001" {
+		t.Fatalf("unexpected manifest %#v", m)
+	}
+	if want := []string{BundleDumpFile, BundleReportFile, BundleTextFile, BundleSnippetsFile, BundleHTMLFile}; strings.Join(m.Files, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected files %v", m.Files)
+	}
+	if string(got.Dump) != dump {
+		t.Fatalf("unexpected dump %q", got.Dump)
+	}
+	if got.Report == nil || len(got.Report.Buckets) != 1 || got.Report.Panic == nil || got.Report.Panic.Message != "boom" {
+		t.Fatalf("unexpected report %#v", got.Report)
+	}
+	if !strings.Contains(got.Text, "main.go:4") {
+		t.Fatalf("unexpected text %q", got.Text)
+	}
+	if !strings.Contains(got.HTML, "main.go:4") || !strings.Contains(got.HTML, `<a href="dump.txt">`) {
+		t.Fatalf("unexpected HTML %q", got.HTML)
+	}
+	want := []SourceLine{{Number: 3, Text: "func main() {"}, {Number: 4, Text: "\tpanic(\"boom\")", Current: true}, {Number: 5, Text: "}"}}
+	if len(got.Snippets) != 1 || got.Snippets[0].Func != "main.main" {
+		t.Fatalf("unexpected snippets %#v", got.Snippets)
+	}
+	if !reflect.DeepEqual(want, got.Snippets[0].Lines) {
+		t.Fatalf("want %#v, got %#v", want, got.Snippets[0].Lines)
+	}
+}
+
+func TestBundleSnippetsUntrustedPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", filepath.Join(dir, "gopath"))
+	// A file outside GOPATH, as referenced by a crafted dump.
+	secret := filepath.Join(dir, "secret.go")
+	if err := ioutil.WriteFile(secret, []byte("package main\n\nvar password = \"hunter2\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dump := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t" + filepath.ToSlash(secret) + ":3 +0x25\nexit status 2\n"
+	var b bytes.Buffer
+	if err := WriteBundle(&b, []byte(dump), &BundleOptions{SnippetContext: 1}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBundle(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Snippets) != 0 {
+		t.Fatalf("unexpected snippets %#v", got.Snippets)
+	}
+}
+
+func TestReadBundleInvalid(t *testing.T) {
+	if _, err := ReadBundle(strings.NewReader("nope")); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := WriteBundle(&bytes.Buffer{}, []byte("no dump here\n"), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadBundleTooLarge(t *testing.T) {
+	defer func(f, b int64) {
+		maxBundleFileSize, maxBundleSize = f, b
+	}(maxBundleFileSize, maxBundleSize)
+	var b bytes.Buffer
+	dump := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:4 +0x25\n"
+	if err := WriteBundle(&b, []byte(dump), nil); err != nil {
+		t.Fatal(err)
+	}
+	maxBundleFileSize, maxBundleSize = int64(len(dump)-1), 1<<20
+	if _, err := ReadBundle(bytes.NewReader(b.Bytes())); err == nil || err.Error() != fmt.Sprintf("manifest.json: larger than %d bytes", len(dump)-1) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	maxBundleFileSize, maxBundleSize = 1<<20, int64(len(dump))
+	if _, err := ReadBundle(bytes.NewReader(b.Bytes())); err == nil || err.Error() != fmt.Sprintf("bundle larger than %d bytes", len(dump)) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	maxBundleFileSize, maxBundleSize = 1<<20, 1<<20
+	if _, err := ReadBundle(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
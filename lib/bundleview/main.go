@@ -0,0 +1,56 @@
+// Command bundleview serves a crash bundle written by lib.WriteBundle
+// locally, to explore an incident long after the logs and the sources are
+// gone.
+//
+// Usage:
+//
+//	go run ./lib/bundleview [-http localhost:8080] crash.tar.gz
+//
+// Then browse to the printed URL. The API used by other front ends is
+// documented on lib.NewBundleViewer.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Tchinmai7/panicparse/lib"
+)
+
+func main() {
+	if err := mainImpl(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "bundleview: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Private stuff.
+
+func mainImpl(args []string) error {
+	fs := flag.NewFlagSet("bundleview", flag.ContinueOnError)
+	addr := fs.String("http", "localhost:8080", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("specify exactly one crash bundle")
+	}
+	b, err := loadBundle(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving %s on http://%s/\n", fs.Arg(0), *addr)
+	return http.ListenAndServe(*addr, lib.NewBundleViewer(b))
+}
+
+func loadBundle(p string) (*lib.Bundle, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lib.ReadBundle(f)
+}
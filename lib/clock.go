@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the timestamps and the IDs recorded in reports and
+// bundles.
+//
+// Use FakeClock to make them deterministic in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewID returns a new unique ID, as 32 hexadecimal characters.
+	NewID() string
+}
+
+// SystemClock is the Clock used by default. It returns time.Now() and random
+// IDs.
+var SystemClock Clock = systemClock{}
+
+// FakeClock is a deterministic Clock for tests.
+//
+// The time only changes with Advance, so the result doesn't depend on how
+// many times the code under test calls Now.
+//
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	ids uint64
+}
+
+// NewFakeClock returns a FakeClock stopped at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the fake time by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// NewID returns sequential IDs, starting with
+// "00000000000000000000000000000001".
+func (c *FakeClock) NewID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids++
+	return fmt.Sprintf("%032x", c.ids)
+}
+
+// Private stuff.
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// The IDs only need to be unique in practice.
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// clockOr returns c, or SystemClock if c is nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewFakeClock(start)
+	for i := 0; i < 2; i++ {
+		if got := c.Now(); !got.Equal(start) {
+			t.Fatalf("want %s, got %s", start, got)
+		}
+	}
+	c.Advance(time.Minute)
+	if got, want := c.Now(), start.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+	if got := c.NewID(); got != "00000000000000000000000000000001" {
+		t.Fatalf("unexpected ID %q", got)
+	}
+	if got := c.NewID(); got != "00000000000000000000000000000002" {
+		t.Fatalf("unexpected ID %q", got)
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	a, b := SystemClock.NewID(), SystemClock.NewID()
+	if len(a) != 32 || a == b {
+		t.Fatalf("unexpected IDs %q, %q", a, b)
+	}
+}
@@ -0,0 +1,84 @@
+// Command cshared exposes the parser through a C ABI, to embed it in programs
+// not written in Go without spawning a subprocess.
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o libpanicparse.so ./lib/cshared
+//
+// which also writes libpanicparse.h. From Python:
+//
+//	lib = ctypes.CDLL("./libpanicparse.so")
+//	lib.ParseToJSON.restype = ctypes.c_void_p
+//	p = lib.ParseToJSON(data, len(data))
+//	report = json.loads(ctypes.string_at(p))
+//	lib.FreeString(p)
+//
+// The result is a lib.Report as written by lib.WriteJSON, or an object with a
+// single "error" field. Parsing never touches the file system, the standard
+// output or the process state, so it is safe to call concurrently from
+// multiple threads of the host.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/Tchinmai7/panicparse/lib"
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ParseToJSON parses the stack dump of length bytes at input and returns the
+// aggregated goroutines as a NUL terminated JSON document. The caller must
+// release it with FreeString.
+//
+//export ParseToJSON
+func ParseToJSON(input *C.char, length C.int) *C.char {
+	b := parseToJSON(C.GoBytes(unsafe.Pointer(input), length))
+	return C.CString(string(b))
+}
+
+// FreeString releases a string returned by ParseToJSON.
+//
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
+
+// Private stuff.
+
+// parseToJSON is the implementation of ParseToJSON. It never fails nor
+// panics, since a Go panic would abort the host process.
+func parseToJSON(input []byte) (out []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = errorJSON(fmt.Errorf("internal error: %v", r))
+		}
+	}()
+	c, err := stack.ParseDumpOpts(bytes.NewReader(input), nil, &stack.Opts{})
+	if err != nil {
+		return errorJSON(err)
+	}
+	if c == nil {
+		return errorJSON(errors.New("no stack dump found"))
+	}
+	var buf bytes.Buffer
+	if err := lib.WriteJSON(&buf, stack.Aggregate(c.Goroutines, stack.AnyPointer)); err != nil {
+		return errorJSON(err)
+	}
+	return buf.Bytes()
+}
+
+func errorJSON(err error) []byte {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return b
+}
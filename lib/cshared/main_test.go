@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/lib"
+)
+
+func TestParseToJSON(t *testing.T) {
+	out := parseToJSON([]byte("panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"))
+	r, err := lib.ReadJSON(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Buckets) != 1 || r.Buckets[0].Stack.Calls[0].Line != 10 {
+		t.Fatalf("unexpected report: %s", out)
+	}
+}
+
+func TestParseToJSONError(t *testing.T) {
+	var r struct{ Error string }
+	if err := json.Unmarshal(parseToJSON([]byte("hello")), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Error != "no stack dump found" {
+		t.Fatalf("unexpected error %q", r.Error)
+	}
+}
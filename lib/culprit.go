@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Culprit is the goroutine that crashed the process.
+type Culprit struct {
+	// Crash is why the dump was printed.
+	Crash stack.CrashKind
+	// Headline is the crash message as printed by the runtime, e.g.
+	// "panic: oh no". Nested panics are on the following lines. It is empty
+	// if no message was found.
+	Headline string
+	// Panic is the decoded value of the panic that crashed the process, the
+	// last one of a chain of recovered panics. It is nil if the crash is not
+	// a panic.
+	Panic *stack.PanicValue
+	// Stack is the formatted call stack, with the same format as
+	// ParsePanicStringOpts.
+	Stack string
+}
+
+// String returns the headline followed by the call stack.
+func (c *Culprit) String() string {
+	if c.Headline == "" {
+		return c.Stack
+	}
+	return c.Headline + "\n\n" + c.Stack
+}
+
+// FormatCulprit formats only the goroutine that crashed, normally the first
+// one printed.
+//
+// It is meant for latency sensitive callers, e.g. to enrich an error report
+// inline: parsing stops as soon as this goroutine is complete and no source
+// file is read, so the arguments are not augmented.
+func FormatCulprit(stackTrace string) (*Culprit, error) {
+	return formatCulprit(strings.NewReader(stackTrace))
+}
+
+// Private stuff.
+
+// reSignal matches the first line printed on a fatal signal, e.g.
+// "SIGSEGV: segmentation violation".
+var reSignal = regexp.MustCompile(`^SIG[A-Z0-9]+: `)
+
+func formatCulprit(r io.Reader) (*Culprit, error) {
+	var culprit *stack.Goroutine
+	sr := &stopReader{r: r}
+	c, err := stack.ParseDumpOpts(sr, nil, &stack.Opts{
+		OnGoroutine: func(g *stack.Goroutine) {
+			// The system stack printed by runtime.throw precedes the goroutine
+			// that threw and is never First.
+			if culprit == nil && g.First {
+				culprit = g
+				sr.stop = true
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || culprit == nil {
+		return nil, errors.New("no goroutine found")
+	}
+	b := stack.Aggregate([]*stack.Goroutine{culprit}, stack.AnyPointer)[0]
+	srcLen, pkgLen := calcLengths([]*stack.Bucket{b}, 0)
+	return &Culprit{
+		Crash:    c.Crash,
+		Headline: headline(c.Segments),
+		Panic:    c.Panic,
+		Stack:    parseBucketHeader(b, false, false) + stackLines(&b.Signature, srcLen, pkgLen, &Options{}),
+	}, nil
+}
+
+// stopReader returns io.EOF once stop is set.
+type stopReader struct {
+	r    io.Reader
+	stop bool
+}
+
+func (s *stopReader) Read(p []byte) (int, error) {
+	if s.stop {
+		return 0, io.EOF
+	}
+	return s.r.Read(p)
+}
+
+// headline returns the first crash message found in segments, with the
+// nested panics that follow it.
+func headline(segments []stack.Segment) string {
+	for _, s := range segments {
+		lines := strings.Split(s.Text, "\n")
+		for i, l := range lines {
+			l = strings.TrimRight(l, "\r")
+			if !strings.HasPrefix(l, "panic: ") && !strings.HasPrefix(l, "fatal error: ") && !reSignal.MatchString(l) {
+				continue
+			}
+			out := []string{l}
+			for _, n := range lines[i+1:] {
+				n = strings.TrimRight(n, "\r")
+				if !strings.HasPrefix(n, "\tpanic: ") && !strings.HasPrefix(n, "[signal ") {
+					break
+				}
+				out = append(out, n)
+			}
+			return strings.Join(out, "\n")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestFormatCulprit(t *testing.T) {
+	data := "log line\npanic: boom [recovered]\n\tpanic: again\n\ngoroutine 1 [running]:\nmain.crash(0x1)\n\t/home/user/src/foo/main.go:12 +0x45\nmain.main()\n\t/home/user/src/foo/main.go:20 +0x25\n\ngoroutine 6 [chan receive]:\nmain.worker()\n\t/home/user/src/foo/main.go:30 +0x12\n"
+	c, err := FormatCulprit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Crash != stack.CrashPanic || c.Headline != "panic: boom [recovered]\n\tpanic: again" || c.Panic == nil || c.Panic.Message != "again" || c.Panic.Recovered {
+		t.Fatalf("unexpected culprit: %#v", c)
+	}
+	want := "panic: boom [recovered]\n\tpanic: again\n\n1: running\nmain main.go:12 crash(1)\nmain main.go:20 main()\n"
+	if got := c.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestFormatCulpritRuntimeStack(t *testing.T) {
+	data := "fatal error: unexpected signal during runtime execution\n\nruntime stack:\nruntime.throw({0x4b1234, 0x2a})\n\t/goroot/src/runtime/panic.go:1047 +0x5d\n\ngoroutine 1 [syscall]:\nmain.main()\n\t/home/user/src/foo/main.go:20 +0x25\n"
+	c, err := FormatCulprit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Crash != stack.CrashFatal || c.Headline != "fatal error: unexpected signal during runtime execution" || !strings.HasPrefix(c.Stack, "1: syscall\n") {
+		t.Fatalf("unexpected culprit: %#v", c)
+	}
+}
+
+func TestFormatCulpritStops(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("panic: boom\n\n")
+	for i := 1; i < 10000; i++ {
+		fmt.Fprintf(&b, "goroutine %d [running]:\nmain.main()\n\t/home/user/src/foo/main.go:20 +0x25\n\n", i)
+	}
+	r := &countingReader{r: strings.NewReader(b.String())}
+	if _, err := formatCulprit(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.n > 64*1024 {
+		t.Fatalf("read %d bytes out of %d", r.n, b.Len())
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
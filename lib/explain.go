@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// FrameKind classifies the code a frame belongs to.
+type FrameKind string
+
+// Frame kinds.
+const (
+	FrameRuntime    FrameKind = "runtime"
+	FrameStdlib     FrameKind = "stdlib"
+	FrameMain       FrameKind = "main"
+	FrameThirdParty FrameKind = "third-party"
+)
+
+// FrameExplanation is everything known about a single frame of a stack trace.
+type FrameExplanation struct {
+	// Func is the function name, e.g. "bytes.(*Buffer).Write".
+	Func string
+	// Location is the module relative source path and line, e.g.
+	// "github.com/foo/bar/baz.go:12".
+	Location string
+	// Args is the decoded arguments, using the types from the sources when the
+	// call was augmented.
+	Args string
+	// Kind is the kind of code.
+	Kind FrameKind
+	// Exported is true if the function is exported.
+	Exported bool
+	// Snippet is the source lines around the call, when the source file is
+	// available locally.
+	Snippet []SourceLine
+	// URL is a link to the source line on the code forge, when it can be
+	// determined from the module path.
+	URL string
+}
+
+// SourceLine is a line in a source file.
+type SourceLine struct {
+	// Number is the 1-based line number.
+	Number int
+	// Text is the content without the line terminator.
+	Text string
+	// Current is true for the line of the call.
+	Current bool
+}
+
+// ExplainFrame returns a description of a single frame.
+//
+// The source snippet is only available when the stack trace was parsed with
+// guesspaths, and the call argument types only after augmentation.
+func ExplainFrame(c *stack.Call) *FrameExplanation {
+	e := &FrameExplanation{
+		Func:     c.Func.PkgDotName(),
+		Location: fmt.Sprintf("%s:%d", c.ModuleSrcPath(), c.Line),
+		Args:     c.Args.String(),
+		Kind:     frameKind(c),
+		Exported: c.Func.IsExported(),
+		URL:      forgeURL(c),
+	}
+	if c.LocalSrcPath != "" {
+		e.Snippet = readSnippet(c.LocalSrcPath, c.Line, snippetContext)
+	}
+	return e
+}
+
+// String returns a multi-line human readable explanation.
+func (e *FrameExplanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s(%s)\n  at %s [%s]\n", e.Func, e.Args, e.Location, e.Kind)
+	for _, l := range e.Snippet {
+		marker := " "
+		if l.Current {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "  %s %4d | %s\n", marker, l.Number, l.Text)
+	}
+	if e.URL != "" {
+		fmt.Fprintf(&b, "  %s\n", e.URL)
+	}
+	return b.String()
+}
+
+// Private stuff.
+
+// snippetContext is the number of lines shown before and after the call.
+const snippetContext = 2
+
+func frameKind(c *stack.Call) FrameKind {
+	switch {
+	case c.Func.PkgName() == "runtime":
+		return FrameRuntime
+	case c.IsPkgMain():
+		return FrameMain
+	case c.IsStdlib || !strings.Contains(strings.SplitN(c.Func.String(), "/", 2)[0], "."):
+		return FrameStdlib
+	default:
+		return FrameThirdParty
+	}
+}
+
+// readSnippet returns the lines around line in the file, ignoring errors.
+func readSnippet(file string, line, context int) []SourceLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []SourceLine
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan() && n <= line+context; n++ {
+		if n >= line-context {
+			out = append(out, SourceLine{Number: n, Text: s.Text(), Current: n == line})
+		}
+	}
+	return out
+}
+
+// reModVersion matches the version in a module cache path, e.g.
+// "/pkg/mod/github.com/foo/bar@v1.2.3/".
+var reModVersion = regexp.MustCompile(`@(v[^/]+)/`)
+
+// forgeURL returns the URL to browse the source line on GitHub or GitLab.
+func forgeURL(c *stack.Call) string {
+	p := c.ModuleSrcPath()
+	parts := strings.SplitN(p, "/", 4)
+	if len(parts) != 4 {
+		return ""
+	}
+	ref := "HEAD"
+	if m := reModVersion.FindStringSubmatch(strings.Replace(c.SrcPath, "\\", "/", -1)); m != nil {
+		ref = strings.TrimSuffix(m[1], "+incompatible")
+		// Pseudo versions end with the commit hash, e.g.
+		// v0.0.0-20200101000000-0123456789ab.
+		if i := strings.LastIndexByte(ref, '-'); i != -1 && len(ref)-i-1 == 12 {
+			ref = ref[i+1:]
+		}
+	}
+	switch parts[0] {
+	case "github.com":
+		return fmt.Sprintf("https://github.com/%s/%s/blob/%s/%s#L%d", parts[1], parts[2], ref, parts[3], c.Line)
+	case "gitlab.com":
+		return fmt.Sprintf("https://gitlab.com/%s/%s/-/blob/%s/%s#L%d", parts[1], parts[2], ref, parts[3], c.Line)
+	default:
+		return ""
+	}
+}
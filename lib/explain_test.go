@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestExplainFrame(t *testing.T) {
+	c := &stack.Call{
+		Func:    stack.NewFunc("github.com/foo/bar/baz.(*Server).Serve"),
+		Args:    stack.Args{Values: []stack.Arg{{Value: 1}}},
+		SrcPath: "/home/user/go/pkg/mod/github.com/foo/bar@v0.0.0-20200101000000-0123456789ab/baz/server.go",
+		Line:    42,
+	}
+	e := ExplainFrame(c)
+	if e.Func != "baz.(*Server).Serve" || e.Kind != FrameThirdParty || !e.Exported || e.Args != "1" {
+		t.Fatalf("unexpected explanation: %#v", e)
+	}
+	if e.Location != "github.com/foo/bar/baz/server.go:42" {
+		t.Fatalf("unexpected location %q", e.Location)
+	}
+	if want := "https://github.com/foo/bar/blob/0123456789ab/baz/server.go#L42"; e.URL != want {
+		t.Fatalf("want %q, got %q", want, e.URL)
+	}
+	r := ExplainFrame(&stack.Call{Func: stack.NewFunc("runtime.gopark"), SrcPath: "/goroot/src/runtime/proc.go", Line: 10})
+	if r.Kind != FrameRuntime || r.URL != "" {
+		t.Fatalf("unexpected explanation: %#v", r)
+	}
+}
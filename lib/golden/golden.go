@@ -0,0 +1,88 @@
+// Package golden compares the output of formatters against golden files.
+//
+// The golden files are stored in the testdata directory of the package under
+// test, as "testdata/<name>.golden". Run the tests with the environment
+// variable UpdateEnv set to 1 to write the current output to the golden
+// files, then review the diff before committing:
+//
+//	PANICPARSE_UPDATE_GOLDEN=1 go test ./...
+//
+// An environment variable is used instead of a flag so the package doesn't
+// register a flag in the test binaries of its importers, which may define
+// their own -update.
+//
+// It is used to test the formatters of package lib and can be used to test
+// custom formatters the same way.
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Dir is the directory containing the golden files, relative to the
+// directory of the package under test.
+const Dir = "testdata"
+
+// UpdateEnv is the environment variable that, when set to 1, makes Check
+// write the golden files.
+const UpdateEnv = "PANICPARSE_UPDATE_GOLDEN"
+
+// Update returns true if the golden files are to be written, i.e. UpdateEnv
+// is set to 1.
+func Update() bool {
+	return os.Getenv(UpdateEnv) == "1"
+}
+
+// Check compares got with the content of the golden file name.
+//
+// With UpdateEnv set, the golden file is written instead. Otherwise the test fails
+// with the first differing line if the content differs or if the golden file
+// doesn't exist.
+func Check(t testing.TB, name string, got []byte) {
+	t.Helper()
+	p := filepath.Join(Dir, name+".golden")
+	if Update() {
+		if err := os.MkdirAll(Dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatalf("%v; run with %s=1 to create it", err, UpdateEnv)
+		return
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("%s mismatch; run with %s=1 to accept the change\n%s", p, UpdateEnv, firstDiff(string(want), string(got)))
+	}
+}
+
+// Private stuff.
+
+// firstDiff describes the first differing line.
+func firstDiff(want, got string) string {
+	w := strings.Split(want, "\n")
+	g := strings.Split(got, "\n")
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i >= len(w) || i >= len(g) || wl != gl {
+			return fmt.Sprintf("line %d:\n- %q\n+ %q", i+1, wl, gl)
+		}
+	}
+	return ""
+}
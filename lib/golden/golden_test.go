@@ -0,0 +1,30 @@
+package golden
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	f := &fakeTB{TB: t}
+	Check(f, "hello", []byte("hello\nworld\n"))
+	if f.msg != "" {
+		t.Fatalf("unexpected failure: %s", f.msg)
+	}
+	Check(f, "hello", []byte("hello\nthere\n"))
+	want := "testdata/hello.golden mismatch; run with PANICPARSE_UPDATE_GOLDEN=1 to accept the change\nline 2:\n- \"world\"\n+ \"there\""
+	if f.msg != want {
+		t.Fatalf("unexpected message:\n%s", f.msg)
+	}
+}
+
+type fakeTB struct {
+	testing.TB
+	msg string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.msg = fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/lib/golden"
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// goldenDump is the input of the formatter golden tests.
+const goldenDump = `panic: oh no
+
+goroutine 1 [running]:
+main.crash(0xc000010000, 0x3)
+	/home/user/go/src/example.com/foo/main.go:12 +0x45
+main.main()
+	/home/user/go/src/example.com/foo/main.go:20 +0x25
+
+goroutine 6 [chan receive, 5 minutes]:
+example.com/foo/worker.(*Pool).run(0xc000020000)
+	/home/user/go/src/example.com/foo/worker/pool.go:40 +0x10
+created by example.com/foo/worker.New
+	/home/user/go/src/example.com/foo/worker/pool.go:20 +0x30
+
+goroutine 7 [chan receive, 5 minutes]:
+example.com/foo/worker.(*Pool).run(0xc000020008)
+	/home/user/go/src/example.com/foo/worker/pool.go:40 +0x10
+created by example.com/foo/worker.New
+	/home/user/go/src/example.com/foo/worker/pool.go:20 +0x30
+`
+
+func TestGoldenText(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"text":         {},
+		"text_color":   {Color: true, Highlight: []*regexp.Regexp{regexp.MustCompile(`worker`)}},
+		"text_depth":   {Sort: SortDepth, ShowDepth: true},
+		"text_args":    {MaxArgs: 1, CountElidedArgs: true},
+		"text_align":   {AlignPerBucket: true},
+		"text_cap":     {MaxColumnWidth: 4},
+		"text_recv":    {GroupByReceiver: true},
+		"text_ids":     {ShowIDs: true, MaxIDsWidth: 10},
+		"text_palette": {Palette: &DefaultPalette, Highlight: []*regexp.Regexp{regexp.MustCompile(`crash`)}},
+	} {
+		out, err := ParsePanicStringOpts(goldenDump, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden.Check(t, name, []byte(strings.Join(out, "\n")))
+	}
+}
+
+func TestGoldenJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, goldenBuckets(t)); err != nil {
+		t.Fatal(err)
+	}
+	// The producer depends on how the test is built.
+	got := reProducer.ReplaceAll(buf.Bytes(), []byte(`"producer": "panicparse"`))
+	golden.Check(t, "json", got)
+}
+
+func TestGoldenJumpList(t *testing.T) {
+	jumps := JumpList(goldenBuckets(t))
+	var buf bytes.Buffer
+	if err := WriteQuickfix(&buf, jumps); err != nil {
+		t.Fatal(err)
+	}
+	golden.Check(t, "quickfix", buf.Bytes())
+	buf.Reset()
+	if err := WriteJumpJSON(&buf, jumps); err != nil {
+		t.Fatal(err)
+	}
+	golden.Check(t, "jumps_json", buf.Bytes())
+}
+
+func TestGoldenExplain(t *testing.T) {
+	b := goldenBuckets(t)[0]
+	var out []string
+	for i := range b.Stack.Calls {
+		out = append(out, ExplainFrame(&b.Stack.Calls[i]).String())
+	}
+	golden.Check(t, "explain", []byte(strings.Join(out, "")))
+}
+
+var reProducer = regexp.MustCompile(`"producer": "[^"]*"`)
+
+func goldenBuckets(t *testing.T) []*stack.Bucket {
+	c, err := stack.ParseDump(strings.NewReader(goldenDump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stack.Aggregate(c.Goroutines, stack.AnyPointer)
+}
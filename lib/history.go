@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SignatureHistory is what is known about a signature from the previous
+// reports, e.g. from an archive, to tell a new crash from a known one.
+type SignatureHistory struct {
+	// Fingerprint is stack.Signature.Fingerprint().
+	Fingerprint string
+	// Count is the number of previous reports containing the signature.
+	Count int
+	// FirstSeen and LastSeen are the creation time of the oldest and newest
+	// previous reports containing the signature.
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// FirstBuild is Report.Build of the oldest previous report containing the
+	// signature. It is empty if unknown.
+	FirstBuild string
+}
+
+// FormatHistory summarizes the history of a signature relative to now, e.g.
+// "first seen in build 1.4.2, 37 prior occurrences, last seen 2d ago".
+func FormatHistory(h *SignatureHistory, now time.Time) string {
+	if h.Count == 0 {
+		return "first occurrence"
+	}
+	parts := make([]string, 0, 3)
+	if h.FirstBuild != "" {
+		parts = append(parts, "first seen in build "+h.FirstBuild)
+	} else {
+		parts = append(parts, "first seen "+formatAge(now.Sub(h.FirstSeen)))
+	}
+	if h.Count == 1 {
+		parts = append(parts, "1 prior occurrence")
+	} else {
+		parts = append(parts, fmt.Sprintf("%d prior occurrences", h.Count))
+	}
+	parts = append(parts, "last seen "+formatAge(now.Sub(h.LastSeen)))
+	return strings.Join(parts, ", ")
+}
+
+// Private stuff.
+
+// formatAge renders a duration in the past with its largest unit, e.g.
+// "2d ago".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dd ago", d/(24*time.Hour))
+	}
+}
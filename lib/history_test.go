@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatHistory(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	data := []struct {
+		h    SignatureHistory
+		want string
+	}{
+		{SignatureHistory{}, "first occurrence"},
+		{
+			SignatureHistory{Count: 37, FirstSeen: now.Add(-200 * time.Hour), LastSeen: now.Add(-49 * time.Hour), FirstBuild: "1.4.2"},
+			"first seen in build 1.4.2, 37 prior occurrences, last seen 2d ago",
+		},
+		{
+			SignatureHistory{Count: 1, FirstSeen: now.Add(-3 * time.Hour), LastSeen: now.Add(-5 * time.Minute)},
+			"first seen 3h ago, 1 prior occurrence, last seen 5m ago",
+		},
+		{
+			SignatureHistory{Count: 2, FirstSeen: now.Add(-time.Second), LastSeen: now},
+			"first seen just now, 2 prior occurrences, last seen just now",
+		},
+	}
+	for i, line := range data {
+		if got := FormatHistory(&line.h, now); got != line.want {
+			t.Errorf("#%d: want %q, got %q", i, line.want, got)
+		}
+	}
+}
+
+func TestParsePanicStringOptsHistory(t *testing.T) {
+	p, err := ParsePanic(goldenDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	f := p.Buckets[0].Fingerprint()
+	opts := &Options{
+		FirstOnly: true,
+		History:   map[string]SignatureHistory{f: {Fingerprint: f, Count: 3, FirstSeen: now.Add(-72 * time.Hour), LastSeen: now.Add(-time.Hour), FirstBuild: "1.4.2"}},
+		Clock:     NewFakeClock(now),
+	}
+	out, err := p.Render(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1: running\n  first seen in build 1.4.2, 3 prior occurrences, last seen 1h ago\n"
+	if !strings.HasPrefix(out[0], want) {
+		t.Fatalf("want prefix %q, got %q", want, out[0])
+	}
+}
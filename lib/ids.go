@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatIDs renders goroutine IDs compactly, with consecutive IDs as a
+// range, e.g. "12, 15, 100–180". Duplicates are ignored.
+//
+// When max is positive, the ranges that would make the result longer than
+// max characters are replaced with the number of IDs left, e.g.
+// "1, 3–5, … (+20)".
+func FormatIDs(ids []int, max int) string {
+	s := make([]int, len(ids))
+	copy(s, ids)
+	sort.Ints(s)
+	var parts []string
+	// counts is the number of IDs in each part.
+	var counts []int
+	for i := 0; i < len(s); {
+		j := i
+		n := 1
+		for j+1 < len(s) && s[j+1]-s[j] <= 1 {
+			if s[j+1] != s[j] {
+				n++
+			}
+			j++
+		}
+		if n == 1 {
+			parts = append(parts, strconv.Itoa(s[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(s[i])+"–"+strconv.Itoa(s[j]))
+		}
+		counts = append(counts, n)
+		i = j + 1
+	}
+	out := strings.Join(parts, ", ")
+	if max <= 0 || utf8.RuneCountInString(out) <= max {
+		return out
+	}
+	left := 0
+	for _, c := range counts {
+		left += c
+	}
+	// Keep the first parts that fit along with the number of IDs left.
+	w := 0
+	for i, p := range parts {
+		w += utf8.RuneCountInString(p) + len(", ")
+		if w+utf8.RuneCountInString(moreIDs(left-counts[i])) > max {
+			return strings.Join(append(parts[:i:i], moreIDs(left)), ", ")
+		}
+		left -= counts[i]
+	}
+	return out
+}
+
+// Private stuff.
+
+// moreIDs renders the number of IDs not rendered.
+func moreIDs(n int) string {
+	return fmt.Sprintf("… (+%d)", n)
+}
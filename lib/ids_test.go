@@ -0,0 +1,24 @@
+package lib
+
+import "testing"
+
+func TestFormatIDs(t *testing.T) {
+	data := []struct {
+		ids  []int
+		max  int
+		want string
+	}{
+		{nil, 0, ""},
+		{[]int{1}, 0, "1"},
+		{[]int{15, 12, 100, 101, 102, 180}, 0, "12, 15, 100–102, 180"},
+		{[]int{3, 1, 2, 2, 5}, 0, "1–3, 5"},
+		{[]int{1, 3, 4, 5, 7, 9, 10, 11, 12}, 100, "1, 3–5, 7, 9–12"},
+		{[]int{1, 3, 4, 5, 7, 9, 10, 11, 12}, 14, "1, 3–5, … (+5)"},
+		{[]int{1, 3, 4, 5, 7, 9, 10, 11, 12}, 3, "… (+9)"},
+	}
+	for i, l := range data {
+		if got := FormatIDs(l.ids, l.max); got != l.want {
+			t.Errorf("#%d: want %q, got %q", i, l.want, got)
+		}
+	}
+}
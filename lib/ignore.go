@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// IgnoreFileName is the name of the file listing the per-project ignore
+// rules.
+//
+// Each line is an action followed by a regular expression matched against the
+// fully qualified function names of the frames, e.g.:
+//
+//	# Connection pool workers.
+//	collapse ^github\.com/foo/pool\.
+//	hide ^github\.com/foo/metrics\.(*Reporter)\.loop$
+//
+// Empty lines and lines starting with "#" are ignored.
+const IgnoreFileName = ".panicparseignore"
+
+// IgnoreAction is what to do with the buckets matching an IgnoreRule.
+type IgnoreAction int
+
+const (
+	// IgnoreHide skips the matching buckets. They are counted in the hidden
+	// summary line.
+	IgnoreHide IgnoreAction = iota
+	// IgnoreCollapse summarizes the matching buckets on a single line.
+	IgnoreCollapse
+)
+
+// IgnoreRule hides or collapses the buckets with any frame where the fully
+// qualified function name matches.
+type IgnoreRule struct {
+	Action IgnoreAction
+	Match  *regexp.Regexp
+}
+
+// ParseIgnoreFile parses rules in the IgnoreFileName format.
+func ParseIgnoreFile(r io.Reader) ([]IgnoreRule, error) {
+	var out []IgnoreRule
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<action> <regexp>\", got %q", n, l)
+		}
+		var a IgnoreAction
+		switch parts[0] {
+		case "hide":
+			a = IgnoreHide
+		case "collapse":
+			a = IgnoreCollapse
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", n, parts[0])
+		}
+		re, err := regexp.Compile(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		out = append(out, IgnoreRule{Action: a, Match: re})
+	}
+	return out, s.Err()
+}
+
+// FindIgnoreFile returns the path of the IgnoreFileName closest to the local
+// source files of the goroutines, looking in each parent directory.
+//
+// It requires the stack trace to be parsed with guesspaths. Returns "" if
+// none is found.
+func FindIgnoreFile(goroutines []*stack.Goroutine) string {
+	seen := map[string]bool{}
+	for _, g := range goroutines {
+		for _, c := range g.Stack.Calls {
+			if c.LocalSrcPath == "" || c.IsStdlib {
+				continue
+			}
+			for d := filepath.Dir(c.LocalSrcPath); !seen[d]; d = filepath.Dir(d) {
+				seen[d] = true
+				p := filepath.Join(d, IgnoreFileName)
+				if _, err := os.Stat(p); err == nil {
+					return p
+				}
+				if filepath.Dir(d) == d {
+					break
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Private stuff.
+
+// loadIgnoreFile parses the rules from the file at p.
+func loadIgnoreFile(p string) ([]IgnoreRule, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseIgnoreFile(f)
+}
+
+// applyIgnoreRules splits the buckets per action, in new slices.
+func applyIgnoreRules(buckets []*stack.Bucket, rules []IgnoreRule) (kept, hidden, collapsed []*stack.Bucket) {
+	if len(rules) == 0 {
+		return append([]*stack.Bucket(nil), buckets...), nil, nil
+	}
+	for _, b := range buckets {
+		switch a, ok := matchRules(&b.Signature, rules); {
+		case !ok:
+			kept = append(kept, b)
+		case a == IgnoreHide:
+			hidden = append(hidden, b)
+		default:
+			collapsed = append(collapsed, b)
+		}
+	}
+	return kept, hidden, collapsed
+}
+
+// matchRules returns the action of the first rule matching s.
+func matchRules(s *stack.Signature, rules []IgnoreRule) (IgnoreAction, bool) {
+	for _, r := range rules {
+		if matchesAny(s, []*regexp.Regexp{r.Match}) {
+			return r.Action, true
+		}
+	}
+	return 0, false
+}
+
+// collapsedSummary returns a single line summarizing the collapsed buckets.
+func collapsedSummary(collapsed []*stack.Bucket) string {
+	n := 0
+	for _, b := range collapsed {
+		n += b.Size()
+	}
+	return fmt.Sprintf("%d: collapsed by %s [%d buckets]\n", n, IgnoreFileName, len(collapsed))
+}
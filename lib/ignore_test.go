@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestParseIgnoreFile(t *testing.T) {
+	rules, err := ParseIgnoreFile(strings.NewReader("# comment\n\ncollapse ^main\\.worker$\nhide ^github\\.com/foo/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Action != IgnoreCollapse || rules[1].Action != IgnoreHide || !rules[1].Match.MatchString("github.com/foo/bar.f") {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+	for _, in := range []string{"hide", "drop foo", "hide (", "hide foo bar\nnope"} {
+		if _, err := ParseIgnoreFile(strings.NewReader(in)); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestFindIgnoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "cmd", "foo")
+	if err := os.MkdirAll(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, IgnoreFileName)
+	if err := ioutil.WriteFile(p, []byte("hide ^main\\.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	g := []*stack.Goroutine{{Signature: stack.Signature{Stack: stack.Stack{Calls: []stack.Call{{LocalSrcPath: filepath.Join(sub, "main.go")}}}}}}
+	if got := FindIgnoreFile(g); got != p {
+		t.Fatalf("want %q, got %q", p, got)
+	}
+}
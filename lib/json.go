@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime/debug"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
+)
+
+// SchemaVersion is the version of the JSON format written by WriteJSON.
+//
+// Version 0 is a bare JSON array of buckets, i.e. json.Marshal() of a
+// []*stack.Bucket. Version 1 wraps the buckets in a Report.
+const SchemaVersion = 1
+
+// Report is the serialized form of a parsed stack dump.
+type Report struct {
+	// SchemaVersion is the version of the format. See SchemaVersion.
+	SchemaVersion int `json:"schemaVersion"`
+	// Producer identifies the code that wrote the report, e.g.
+	// "github.com/Tchinmai7/panicparse@v1.5.0".
+	Producer string `json:"producer,omitempty"`
+	// ID uniquely identifies the report, when set, e.g. by RecoverHandler.
+	ID string `json:"id,omitempty"`
+	// Created is when the report was created, when set, e.g. by
+	// RecoverHandler.
+	Created *time.Time `json:"created,omitempty"`
+	// Build is the version of the program that crashed, e.g. "1.4.2", when
+	// known. It is set by the caller.
+	Build string `json:"build,omitempty"`
+	// Findings is the problems found by the analyzers, if they were run, the
+	// most severe first. They precede the buckets so the conclusions lead the
+	// report.
+	Findings []analysis.Finding `json:"findings,omitempty"`
+	// Panic is the value of the panic, when known, e.g. when reported by
+	// RecoverHandler.
+	Panic *stack.PanicValue `json:"panic,omitempty"`
+	// Request is the HTTP request being served when the panic happened, when
+	// reported by RecoverHandler.
+	Request *RequestMeta `json:"request,omitempty"`
+	// Buckets is the aggregated goroutines.
+	Buckets []*stack.Bucket `json:"buckets"`
+}
+
+// WriteJSON writes the buckets as a Report in the current SchemaVersion.
+func WriteJSON(w io.Writer, buckets []*stack.Bucket) error {
+	return WriteReport(w, &Report{Buckets: buckets})
+}
+
+// WriteReport writes the report in the current SchemaVersion.
+//
+// SchemaVersion and Producer are set by this function.
+func WriteReport(w io.Writer, r *Report) error {
+	out := *r
+	out.SchemaVersion = SchemaVersion
+	out.Producer = producer()
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(&out)
+}
+
+// ReadJSON reads a report written by WriteJSON by this version or any prior
+// version, converting it to the current SchemaVersion.
+func ReadJSON(r io.Reader) (*Report, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, errors.New("empty report")
+	}
+	if b[0] == '[' {
+		return readJSONv0(b)
+	}
+	var v struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	switch v.SchemaVersion {
+	case 1:
+		out := &Report{}
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported schema version %d; supported up to %d", v.SchemaVersion, SchemaVersion)
+	}
+}
+
+// Private stuff.
+
+// readJSONv0 converts a bare array of buckets.
+func readJSONv0(b []byte) (*Report, error) {
+	out := &Report{SchemaVersion: SchemaVersion}
+	if err := json.Unmarshal(b, &out.Buckets); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// producer returns the module path and version of panicparse linked in.
+func producer() string {
+	const path = "github.com/Tchinmai7/panicparse"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == path {
+			return path + "@" + info.Main.Version
+		}
+		for _, m := range info.Deps {
+			if m.Path == path {
+				return path + "@" + m.Version
+			}
+		}
+	}
+	return path
+}
@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestJSON(t *testing.T) {
+	buckets := []*stack.Bucket{
+		{
+			Signature: stack.Signature{
+				State: "chan receive",
+				Stack: stack.Stack{Calls: []stack.Call{{Func: stack.NewFunc("main.main"), SrcPath: "/src/main.go", Line: 10}}},
+			},
+			IDs:   []int{1, 2},
+			First: true,
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, buckets); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"schemaVersion": 1`) {
+		t.Fatalf("missing schemaVersion: %s", buf.String())
+	}
+	r, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SchemaVersion != SchemaVersion || r.Producer == "" || len(r.Buckets) != 1 || r.Buckets[0].Stack.Calls[0].Line != 10 {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+}
+
+func TestJSONPanic(t *testing.T) {
+	p := stack.ParsePanicValue(`main.MyErr{Code:5} [recovered]`)
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, &Report{Panic: p}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"raw": "main.MyErr{Code:5}"`, `"kind": "error"`, `"type": "main.MyErr"`, `"name": "Code"`, `"recovered": true`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in %s", want, buf.String())
+		}
+	}
+	r, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Panic == nil || r.Panic.Kind != stack.PanicError || r.Panic.Type != "main.MyErr" || len(r.Panic.Fields) != 1 || r.Panic.Fields[0].Value != "5" || !r.Panic.Recovered {
+		t.Fatalf("unexpected panic: %#v", r.Panic)
+	}
+	if _, err := ReadJSON(strings.NewReader(`{"schemaVersion":1,"panic":{"kind":"bogus"},"buckets":[]}`)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadJSONv0(t *testing.T) {
+	r, err := ReadJSON(strings.NewReader(`[{"State":"running","IDs":[1],"First":true}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SchemaVersion != SchemaVersion || len(r.Buckets) != 1 || r.Buckets[0].State != "running" {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+}
+
+func TestReadJSONFuture(t *testing.T) {
+	if _, err := ReadJSON(strings.NewReader(`{"schemaVersion":1000}`)); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Jump is a location an editor can open.
+type Jump struct {
+	// File is the local path of the source file when found, otherwise the
+	// path as printed in the stack trace.
+	File string `json:"file"`
+	// Line is the 1-based line number.
+	Line int `json:"line"`
+	// Message is the call, e.g. "main.crash(0x1)".
+	Message string `json:"message"`
+}
+
+// JumpList returns the frames of the bucket that crashed, in the order they
+// were printed, i.e. innermost first.
+//
+// It uses the bucket with First set, falling back on the first bucket.
+// Returns nil if there is no bucket.
+func JumpList(buckets []*stack.Bucket) []Jump {
+	if len(buckets) == 0 {
+		return nil
+	}
+	b := buckets[0]
+	for _, c := range buckets {
+		if c.First {
+			b = c
+			break
+		}
+	}
+	out := make([]Jump, 0, len(b.Stack.Calls))
+	for _, c := range b.Stack.Calls {
+		f := c.LocalSrcPath
+		if f == "" {
+			f = c.SrcPath
+		}
+		out = append(out, Jump{File: f, Line: c.Line, Message: fmt.Sprintf("%s(%s)", c.Func.PkgDotName(), &c.Args)})
+	}
+	return out
+}
+
+// WriteQuickfix writes the jumps in the "file:line: message" format,
+// understood by the default 'errorformat' of Vim's quickfix list and by
+// most editors.
+func WriteQuickfix(w io.Writer, jumps []Jump) error {
+	for _, j := range jumps {
+		if _, err := fmt.Fprintf(w, "%s:%d: %s\n", j.File, j.Line, j.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJumpJSON writes the jumps as a JSON array, for editor extensions.
+func WriteJumpJSON(w io.Writer, jumps []Jump) error {
+	if jumps == nil {
+		jumps = []Jump{}
+	}
+	return json.NewEncoder(w).Encode(jumps)
+}
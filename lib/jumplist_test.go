@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestJumpList(t *testing.T) {
+	buckets := []*stack.Bucket{
+		{Signature: stack.Signature{Stack: stack.Stack{Calls: []stack.Call{{Func: stack.NewFunc("main.other"), SrcPath: "/src/other.go", Line: 1}}}}},
+		{
+			Signature: stack.Signature{
+				Stack: stack.Stack{
+					Calls: []stack.Call{
+						{Func: stack.NewFunc("main.crash"), Args: stack.Args{Values: []stack.Arg{{Value: 1}}}, SrcPath: "/build/main.go", LocalSrcPath: "/home/user/main.go", Line: 10},
+						{Func: stack.NewFunc("main.main"), SrcPath: "/build/main.go", Line: 20},
+					},
+				},
+			},
+			First: true,
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteQuickfix(&buf, JumpList(buckets)); err != nil {
+		t.Fatal(err)
+	}
+	want := "/home/user/main.go:10: main.crash(1)\n/build/main.go:20: main.main()\n"
+	if buf.String() != want {
+		t.Fatalf("want %q, got %q", want, buf.String())
+	}
+	buf.Reset()
+	if err := WriteJumpJSON(&buf, JumpList(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Fatalf("unexpected %q", buf.String())
+	}
+}
@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"bytes"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// LeakOptions controls how leaked goroutines are detected.
+type LeakOptions struct {
+	// Ignore skips the goroutines with any call where the fully qualified
+	// function name matches any of the regular expressions, in addition to the
+	// goroutines started by the testing and os/signal packages and the runtime
+	// background workers.
+	Ignore []*regexp.Regexp
+	// IgnoreRules skips the goroutines matching any of the rules, e.g. the
+	// ones loaded from an IgnoreFileName file. Both actions skip them.
+	IgnoreRules []IgnoreRule
+	// Timeout is how long to wait for goroutines to exit on their own before
+	// reporting them. Defaults to one second.
+	Timeout time.Duration
+}
+
+// TestingT is the subset of testing.TB used by VerifyNoLeaks, so this
+// package doesn't depend on the testing package.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// FindLeaks returns the goroutines still running, other than the calling
+// one, aggregated into buckets.
+//
+// It retries until there's no leak or opts.Timeout is reached. opts can be
+// nil.
+func FindLeaks(opts *LeakOptions) ([]*stack.Bucket, error) {
+	if opts == nil {
+		opts = &LeakOptions{}
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	delay := time.Millisecond
+	for {
+		leaks, err := findLeaks(opts)
+		if err != nil || len(leaks) == 0 || time.Now().After(deadline) {
+			if len(leaks) == 0 {
+				return nil, err
+			}
+			return stack.Aggregate(leaks, stack.AnyPointer), err
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// VerifyNoLeaks fails the test if goroutines other than the calling one are
+// still running, listing them with the same formatting as
+// ParsePanicStringOpts.
+//
+// It is meant to be deferred at the start of the test, or called from
+// TestMain after m.Run(). opts can be nil.
+func VerifyNoLeaks(t TestingT, opts *LeakOptions) {
+	t.Helper()
+	buckets, err := FindLeaks(opts)
+	if err != nil {
+		t.Errorf("failed to parse goroutines: %v", err)
+		return
+	}
+	if len(buckets) == 0 {
+		return
+	}
+	n := 0
+	for _, b := range buckets {
+		n += b.Size()
+	}
+	srcLen, pkgLen := calcLengths(buckets, 0)
+	out := make([]string, len(buckets))
+	for i, b := range buckets {
+		out[i] = parseBucketHeader(b, true, false) + stackLines(&b.Signature, srcLen, pkgLen, &Options{})
+	}
+	t.Errorf("found %d leaked goroutines:\n%s", n, strings.Join(out, "\n"))
+}
+
+// Private stuff.
+
+// leakIgnores are the goroutines that are expected to be running.
+var leakIgnores = []IgnoreRule{
+	// The test runner and the parent of the current test.
+	{Action: IgnoreHide, Match: regexp.MustCompile(`^testing\.`)},
+	// signal.Notify() starts a goroutine that never exits.
+	{Action: IgnoreHide, Match: regexp.MustCompile(`^os/signal\.`)},
+	{Action: IgnoreHide, Match: regexp.MustCompile(`^runtime\.ensureSigM`)},
+}
+
+// findLeaks returns the goroutines running except the current one and the
+// ignored ones.
+func findLeaks(opts *LeakOptions) ([]*stack.Goroutine, error) {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	c, err := stack.ParseDump(bytes.NewReader(buf), nil, false)
+	if c == nil {
+		return nil, err
+	}
+	var out []*stack.Goroutine
+	for _, g := range c.Goroutines {
+		// runtime.Stack() prints the current goroutine first.
+		if g.First || g.IsGC() || matchesAny(&g.Signature, opts.Ignore) {
+			continue
+		}
+		if _, ok := matchRules(&g.Signature, leakIgnores); ok {
+			continue
+		}
+		if _, ok := matchRules(&g.Signature, opts.IgnoreRules); ok {
+			continue
+		}
+		out = append(out, g)
+	}
+	return out, err
+}
+
+// matchesAny returns true if any call of s matches any of the regexps.
+func matchesAny(s *stack.Signature, res []*regexp.Regexp) bool {
+	for i := range s.Stack.Calls {
+		for _, re := range res {
+			if re.MatchString(s.Stack.Calls[i].Func.Raw) {
+				return true
+			}
+		}
+	}
+	return false
+}
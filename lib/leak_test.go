@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFindLeaks(t *testing.T) {
+	stop := make(chan struct{})
+	go leakyWorker(stop)
+	b, err := FindLeaks(&LeakOptions{Timeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 1 || b[0].Stack.Calls[0].Func.Name() != "leakyWorker" {
+		t.Fatalf("unexpected leaks: %v", b)
+	}
+	b, err = FindLeaks(&LeakOptions{Timeout: 10 * time.Millisecond, Ignore: []*regexp.Regexp{regexp.MustCompile(`\.leakyWorker$`)}})
+	if err != nil || len(b) != 0 {
+		t.Fatalf("unexpected leaks: %v, %v", b, err)
+	}
+	b, err = FindLeaks(&LeakOptions{Timeout: 10 * time.Millisecond, IgnoreRules: []IgnoreRule{{Action: IgnoreCollapse, Match: regexp.MustCompile(`\.leakyWorker$`)}}})
+	if err != nil || len(b) != 0 {
+		t.Fatalf("unexpected leaks: %v, %v", b, err)
+	}
+	close(stop)
+	VerifyNoLeaks(t, nil)
+}
+
+func leakyWorker(stop chan struct{}) {
+	<-stop
+}
@@ -1,13 +1,14 @@
 package lib
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
 )
 
 func formatCall(c *stack.Call) string {
@@ -23,8 +24,11 @@ func createdByString(s *stack.Signature) string {
 	return created + " @ " + formatCall(&s.CreatedBy)
 }
 
-func parseBucketHeader(bucket *stack.Bucket, multipleBuckets bool) string {
+func parseBucketHeader(bucket *stack.Bucket, multipleBuckets, showDepth bool) string {
 	extra := ""
+	if showDepth {
+		extra += fmt.Sprintf(" [depth %d]", bucket.Stack.Depth())
+	}
 	if s := bucket.SleepString(); s != "" {
 		extra += " [" + s + "]"
 	}
@@ -34,13 +38,42 @@ func parseBucketHeader(bucket *stack.Bucket, multipleBuckets bool) string {
 	if c := createdByString(&bucket.Signature); c != "" {
 		extra += " [Created by " + c + "]"
 	}
-	return fmt.Sprintf("%d: %s%s\n", len(bucket.IDs), bucket.State, extra)
+	return fmt.Sprintf("%d: %s%s\n", bucket.Size(), bucket.State, extra)
 }
 
-func stackLines(signature *stack.Signature, srcLen, pkgLen int) string {
-	out := make([]string, len(signature.Stack.Calls))
-	for i, line := range signature.Stack.Calls {
-		out[i] = fmt.Sprintf("%-*s %-*s %s(%s)", pkgLen, line.Func.PkgName(), srcLen, formatCall(&line), line.Func.Name(), &line.Args)
+func stackLines(signature *stack.Signature, srcLen, pkgLen int, opts *Options) string {
+	out := make([]string, 0, len(signature.Stack.Calls))
+	hidden := 0
+	for _, line := range signature.Stack.Calls {
+		if opts.HideWrappers && line.IsWrapper() {
+			hidden++
+			continue
+		}
+		if hidden != 0 {
+			out = append(out, hiddenWrappers(hidden))
+			hidden = 0
+		}
+		s := fmt.Sprintf("%-*s %-*s %s(%s)", pkgLen, line.Func.PkgName(), srcLen, formatCall(&line), line.Func.Name(), formatArgs(&line.Args, opts))
+		if p := opts.Palette; p != nil {
+			if isHighlighted(&line, opts) {
+				s = p.color(p.Highlight, s)
+			} else {
+				s = fmt.Sprintf("%s %s %s(%s)",
+					p.color(p.Package, fmt.Sprintf("%-*s", pkgLen, line.Func.PkgName())),
+					p.color(p.SrcFile, fmt.Sprintf("%-*s", srcLen, formatCall(&line))),
+					p.color(p.funcColor(&line), line.Func.Name()),
+					p.color(p.Arguments, formatArgs(&line.Args, opts)))
+			}
+		} else if len(opts.Highlight) != 0 {
+			s = highlight(s, &line, opts)
+		}
+		if len(line.SelectCases) != 0 {
+			s += "\n    selecting on: " + strings.Join(line.SelectCases, ", ")
+		}
+		out = append(out, s)
+	}
+	if hidden != 0 {
+		out = append(out, hiddenWrappers(hidden))
 	}
 	if signature.Stack.Elided {
 		out = append(out, "    (...)")
@@ -48,7 +81,101 @@ func stackLines(signature *stack.Signature, srcLen, pkgLen int) string {
 	return strings.Join(out, "\n") + "\n"
 }
 
-func calcLengths(buckets []*stack.Bucket) (int, int) {
+// hiddenWrappers is the marker replacing n wrapper frames.
+func hiddenWrappers(n int) string {
+	if n == 1 {
+		return "    (1 wrapper frame hidden)"
+	}
+	return fmt.Sprintf("    (%d wrapper frames hidden)", n)
+}
+
+// formatArgs renders the arguments, eliding the ones over opts.MaxArgs.
+func formatArgs(a *stack.Args, opts *Options) string {
+	if opts.LabelArgs && len(a.Labeled) != 0 {
+		return formatLabeledArgs(a, opts)
+	}
+	if opts.MaxArgs <= 0 {
+		return a.String()
+	}
+	v := a.Processed
+	if len(v) == 0 {
+		v = make([]string, 0, len(a.Values))
+		for i := range a.Values {
+			v = append(v, a.Values[i].String())
+		}
+	}
+	if len(v) <= opts.MaxArgs {
+		return a.String()
+	}
+	n := len(v) - opts.MaxArgs
+	v = append(v[:opts.MaxArgs:opts.MaxArgs], "...")
+	if opts.CountElidedArgs {
+		v[len(v)-1] = fmt.Sprintf("+%d", n)
+		if a.Elided {
+			v = append(v, "...")
+		}
+	}
+	return strings.Join(v, ", ")
+}
+
+// formatLabeledArgs renders stack.Args.Labeled, eliding the ones over
+// opts.MaxArgs.
+func formatLabeledArgs(a *stack.Args, opts *Options) string {
+	v := make([]string, 0, len(a.Labeled)+1)
+	elided := false
+	for i := range a.Labeled {
+		v = append(v, a.Labeled[i].String())
+		elided = elided || a.Labeled[i].Elided
+	}
+	if opts.MaxArgs > 0 && len(v) > opts.MaxArgs {
+		n := len(v) - opts.MaxArgs
+		v = append(v[:opts.MaxArgs:opts.MaxArgs], "...")
+		if opts.CountElidedArgs {
+			v[len(v)-1] = fmt.Sprintf("+%d", n)
+		}
+	}
+	// The parameters without a value are already rendered as elided.
+	if a.Elided && !elided && v[len(v)-1] != "..." {
+		v = append(v, "...")
+	}
+	return strings.Join(v, ", ")
+}
+
+const (
+	ansiHighlight = "\033[1;33m"
+	ansiReset     = "\033[0m"
+)
+
+// highlight emphasizes s if the function of c matches opts.Highlight.
+//
+// Without color, every line is indented by two characters to keep the
+// alignment.
+func highlight(s string, c *stack.Call, opts *Options) string {
+	if isHighlighted(c, opts) {
+		if opts.Color {
+			return ansiHighlight + s + ansiReset
+		}
+		return "* " + s
+	}
+	if opts.Color {
+		return s
+	}
+	return "  " + s
+}
+
+// isHighlighted returns true if the function of c matches opts.Highlight.
+func isHighlighted(c *stack.Call, opts *Options) bool {
+	for _, re := range opts.Highlight {
+		if re.MatchString(c.Func.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// calcLengths returns the width of the source and package columns, capped
+// to max when it is positive.
+func calcLengths(buckets []*stack.Bucket, max int) (int, int) {
 	srcLen := 0
 	pkgLen := 0
 	for _, bucket := range buckets {
@@ -61,38 +188,305 @@ func calcLengths(buckets []*stack.Bucket) (int, int) {
 			}
 		}
 	}
+	if max > 0 {
+		if srcLen > max {
+			srcLen = max
+		}
+		if pkgLen > max {
+			pkgLen = max
+		}
+	}
 	return srcLen, pkgLen
 }
 
+// ParsePanicString parses a stack trace and renders the bucket containing the
+// goroutine that panicked.
+//
+// One item is returned per bucket, the items for the other buckets are empty.
 func ParsePanicString(stackTrace string) ([]string, error) {
-	r := strings.NewReader(stackTrace)
-	var junk bytes.Buffer
-	writer := bufio.NewWriter(&junk)
+	return ParsePanicStringOpts(stackTrace, &Options{FirstOnly: true})
+}
 
-	//writer would contain Junk after ParseDump
-	ctx, err := stack.ParseDump(r, writer, true)
+// ParsePanicStringOpts parses a stack trace and renders the buckets as
+// specified by opts.
+//
+// opts can be nil.
+func ParsePanicStringOpts(stackTrace string, opts *Options) ([]string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.Unescape {
+		stackTrace = Unescape(stackTrace)
+	}
+	p, err := ParsePanic(stackTrace)
 	if err != nil {
 		return nil, err
 	}
+	return p.Render(opts)
+}
+
+// PanicReport is a parsed stack trace, to both inspect and render it.
+type PanicReport struct {
+	// Context is the parsed stack trace, including the text around the
+	// goroutines in Context.Segments.
+	Context *stack.Context
+	// Buckets is the goroutines aggregated with stack.AnyPointer, before any
+	// filtering done by Render.
+	Buckets []*stack.Bucket
+	// Crash is why the stack trace was printed, e.g. stack.CrashPanic.
+	Crash stack.CrashKind
+	// Reason is why the process died, e.g. "runtime error: index out of range
+	// [3] with length 2" or "concurrent map writes". It is empty when no
+	// message was printed. See Context.Message for the decoded message.
+	Reason string
+	// Goroutines is the number of goroutines.
+	Goroutines int
+}
 
+// ParsePanic parses a stack trace. The paths are guessed to find the local
+// sources.
+func ParsePanic(stackTrace string) (*PanicReport, error) {
+	// The text that is not part of the stack trace is kept in ctx.Segments.
+	ctx, err := stack.ParseDump(strings.NewReader(stackTrace), nil, true)
+	if err != nil {
+		return nil, err
+	}
 	if ctx == nil {
 		return nil, errors.New("ctx is null")
 	}
-	stack.Augment(ctx.Goroutines)
+	out := &PanicReport{
+		Context:    ctx,
+		Buckets:    stack.Aggregate(ctx.Goroutines, stack.AnyPointer),
+		Crash:      ctx.Crash,
+		Goroutines: len(ctx.Goroutines),
+	}
+	if ctx.Message != nil {
+		out.Reason = ctx.Message.Reason()
+	}
+	return out, nil
+}
 
-	buckets := stack.Aggregate(ctx.Goroutines, stack.AnyPointer)
+// Render renders the buckets as specified by opts, like
+// ParsePanicStringOpts. Options.Unescape is ignored since the stack trace was
+// already parsed. The goroutines are aggregated again when
+// Options.Similarity or Options.Match is set.
+//
+// The report is not modified, so it can be rendered more than once,
+// including concurrently. opts can be nil.
+func (p *PanicReport) Render(opts *Options) ([]string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	ctx := p.Context
+	var buckets []*stack.Bucket
+	switch {
+	case opts.Match != nil:
+		buckets = stack.AggregateFunc(ctx.Goroutines, opts.Match)
+	case opts.Similarity != nil:
+		buckets = stack.Aggregate(ctx.Goroutines, *opts.Similarity)
+	default:
+		// Render filters, sorts and augments the buckets, don't modify
+		// p.Buckets so the report can be rendered more than once, including
+		// concurrently.
+		buckets = make([]*stack.Bucket, len(p.Buckets))
+		for i, b := range p.Buckets {
+			c := *b
+			c.Signature = *b.Signature.Clone()
+			buckets[i] = &c
+		}
+	}
+	var gc []*stack.Bucket
+	if opts.GC != stack.GCShow {
+		buckets, gc = stack.SplitGC(buckets)
+	}
+	rules := opts.IgnoreRules
+	if opts.DiscoverIgnoreFile {
+		if path := FindIgnoreFile(ctx.Goroutines); path != "" {
+			r, err := loadIgnoreFile(path)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules[:len(rules):len(rules)], r...)
+		}
+	}
+	var ignored, collapsed []*stack.Bucket
+	buckets, ignored, collapsed = applyIgnoreRules(buckets, rules)
+	buckets = filterDepth(buckets, opts.MinDepth)
+	var hidden []*stack.Bucket
+	buckets, hidden = filterCount(buckets, opts.MinCount, opts.Singletons)
+	hidden = append(hidden, ignored...)
+	if opts.Sort == SortDepth {
+		sort.SliceStable(buckets, func(i, j int) bool {
+			return buckets[i].Stack.Depth() > buckets[j].Stack.Depth()
+		})
+	}
+	var groups map[*stack.Bucket]string
+	if opts.GroupByReceiver {
+		buckets, groups = groupByReceiver(buckets)
+	}
 	multipleBuckets := len(buckets) > 1
 
-	srcLen, pkgLen := calcLengths(buckets)
+	srcLen, pkgLen := calcLengths(buckets, opts.MaxColumnWidth)
 	out := make([]string, len(buckets))
 
+	// Only process the source files for the buckets rendered.
+	a := stack.NewAugmenter(nil)
 	for i, bucket := range buckets {
-		if bucket.First {
-			header := parseBucketHeader(bucket, multipleBuckets)
+		if bucket.First || !opts.FirstOnly {
+			a.Augment(&bucket.Signature)
+			header := parseBucketHeader(bucket, multipleBuckets, opts.ShowDepth)
+			if opts.ShowIDs {
+				header = header[:len(header)-1] + " [ids: " + FormatIDs(bucket.IDs, opts.MaxIDsWidth) + "]\n"
+			}
+			if opts.Palette != nil {
+				header = opts.Palette.header(header, bucket.First)
+			}
+			if h, ok := opts.History[bucket.Fingerprint()]; ok {
+				header += "  " + FormatHistory(&h, clockOr(opts.Clock).Now()) + "\n"
+			}
 
-			out[i] = fmt.Sprintf("%s%s", header, stackLines(&bucket.Signature, srcLen, pkgLen))
+			if opts.AlignPerBucket {
+				srcLen, pkgLen = calcLengths([]*stack.Bucket{bucket}, opts.MaxColumnWidth)
+			}
+			out[i] = fmt.Sprintf("%s%s%s", groups[bucket], header, stackLines(&bucket.Signature, srcLen, pkgLen, opts))
 		}
 	}
-
+	if opts.GC == stack.GCCollapse && len(gc) != 0 {
+		out = append(out, gcSummary(gc))
+	}
+	if len(collapsed) != 0 {
+		out = append(out, collapsedSummary(collapsed))
+	}
+	if len(hidden) != 0 {
+		out = append(out, hiddenSummary(hidden))
+	}
 	return out, nil
 }
+
+// Preamble renders what leads the report, before the buckets returned by
+// Render: why the process died when Options.ShowReason is set, then the
+// findings of Options.Analyzers. It is empty when there is nothing to
+// render. opts can be nil.
+func (p *PanicReport) Preamble(opts *Options) string {
+	if opts == nil {
+		return ""
+	}
+	out := ""
+	if opts.ShowReason && p.Context.Message != nil {
+		out = FormatReason(p.Context.Message)
+	}
+	if len(opts.Analyzers) != 0 {
+		if f := analysis.Run(p.Context, opts.Analyzers); len(f) != 0 {
+			out += FormatFindings(f)
+		}
+	}
+	return out
+}
+
+// FormatReason renders why the process died on a line, e.g.
+// "fatal: concurrent map writes", "signal: SIGSEGV: segmentation violation"
+// or "panic: runtime error: invalid memory address or nil pointer dereference
+// (SIGSEGV addr=0x0)". The recovered panics are counted.
+func FormatReason(m *stack.PanicMessage) string {
+	out := m.Kind.String() + ": " + m.Reason()
+	if m.Kind == stack.MessageSignal && m.Signal != nil {
+		out = m.Kind.String() + ": " + m.Signal.Name + ": " + m.Reason()
+	}
+	if n := len(m.Chain) - 1; n > 0 {
+		out += fmt.Sprintf(" (after %d recovered)", n)
+	}
+	if m.Signal != nil && m.Kind != stack.MessageSignal {
+		out += fmt.Sprintf(" (%s addr=%#x)", m.Signal.Name, m.Signal.Addr)
+	}
+	return out + "\n"
+}
+
+// FormatFindings renders the findings as a section, with the goroutines and
+// the call as evidence.
+func FormatFindings(findings []analysis.Finding) string {
+	var b strings.Builder
+	b.WriteString("Findings:\n")
+	for i := range findings {
+		f := &findings[i]
+		fmt.Fprintf(&b, "  [%s] %s (%s confidence): %s\n", f.Severity, f.Analyzer, f.Confidence, f.Message)
+		if len(f.IDs) != 0 {
+			ids := make([]string, len(f.IDs))
+			for j, id := range f.IDs {
+				ids[j] = strconv.Itoa(id)
+			}
+			fmt.Fprintf(&b, "    goroutines: %s\n", strings.Join(ids, ", "))
+		}
+		if f.Call != nil {
+			fmt.Fprintf(&b, "    at %s %s\n", f.Call.Func.PkgDotName(), formatCall(f.Call))
+		}
+	}
+	return b.String()
+}
+
+// groupByReceiver reorders the buckets by receiver type and returns the
+// header of each group, keyed by the first bucket of the group.
+func groupByReceiver(buckets []*stack.Bucket) ([]*stack.Bucket, map[*stack.Bucket]string) {
+	out := make([]*stack.Bucket, 0, len(buckets))
+	headers := map[*stack.Bucket]string{}
+	for _, g := range stack.GroupByReceiver(buckets) {
+		h := "No receiver"
+		if g.Receiver != "" {
+			h = "Receiver " + g.Receiver
+		}
+		headers[g.Buckets[0]] = fmt.Sprintf("%s: %d goroutine(s)\n", h, g.Goroutines)
+		out = append(out, g.Buckets...)
+	}
+	return out, headers
+}
+
+// filterDepth returns the buckets with at least min stack frames, in a new
+// slice.
+func filterDepth(buckets []*stack.Bucket, min int) []*stack.Bucket {
+	if min <= 0 {
+		return append([]*stack.Bucket(nil), buckets...)
+	}
+	out := make([]*stack.Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Stack.Depth() >= min {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// filterCount splits the buckets with at least min goroutines from the
+// others, in new slices. When singletons is set, only the buckets with exactly
+// one goroutine are kept instead.
+func filterCount(buckets []*stack.Bucket, min int, singletons bool) (kept, hidden []*stack.Bucket) {
+	if min <= 1 && !singletons {
+		return append([]*stack.Bucket(nil), buckets...), nil
+	}
+	for _, b := range buckets {
+		n := b.Size()
+		if (singletons && n != 1) || n < min {
+			hidden = append(hidden, b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	return kept, hidden
+}
+
+// hiddenSummary returns a single line summarizing the buckets that were
+// filtered out.
+func hiddenSummary(hidden []*stack.Bucket) string {
+	n := 0
+	for _, b := range hidden {
+		n += b.Size()
+	}
+	return fmt.Sprintf("%d: hidden [%d buckets]\n", n, len(hidden))
+}
+
+// gcSummary returns a single line summarizing the garbage collector buckets.
+func gcSummary(gc []*stack.Bucket) string {
+	n := 0
+	for _, b := range gc {
+		n += b.Size()
+	}
+	return fmt.Sprintf("%d: runtime background workers [%d buckets collapsed]\n", n, len(gc))
+}
@@ -0,0 +1,457 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
+)
+
+func TestParsePanicStringOptsDeterministic(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("panic: boom\n\n")
+	for _, id := range []int{1, 12, 4, 8, 3} {
+		fmt.Fprintf(&b, "goroutine %d [chan receive]:\nmain.worker(0x%x)\n\t/home/user/go/src/foo/main.go:%d +0x45\n\n", id, id, 10+id%2)
+	}
+	var prev string
+	var prevJSON []byte
+	for i := 0; i < 20; i++ {
+		out, err := ParsePanicStringOpts(b.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(out, "\n")
+		ctx, err := stack.ParseDump(strings.NewReader(b.String()), nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteJSON(&buf, stack.Aggregate(ctx.Goroutines, stack.ExactLines)); err != nil {
+			t.Fatal(err)
+		}
+		if i != 0 && (got != prev || !bytes.Equal(buf.Bytes(), prevJSON)) {
+			t.Fatalf("run %d differs", i)
+		}
+		prev, prevJSON = got, buf.Bytes()
+	}
+}
+
+func TestParsePanic(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("panic: runtime error: index out of range [3] with length 2\n\n")
+	for _, id := range []int{1, 12, 4} {
+		fmt.Fprintf(&b, "goroutine %d [chan receive]:\nmain.worker(0x%x)\n\t/home/user/go/src/foo/main.go:%d +0x45\n\n", id, id, 10+id%2)
+	}
+	p, err := ParsePanic(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Crash != stack.CrashPanic || p.Reason != "runtime error: index out of range [3] with length 2" || p.Goroutines != 3 || len(p.Buckets) != 3 {
+		t.Fatalf("unexpected report: %#v", p)
+	}
+	first := p.Buckets[0]
+	got, err := p.Render(&Options{Sort: SortDepth, MinCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParsePanicStringOpts(b.String(), &Options{Sort: SortDepth, MinCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if len(p.Buckets) != 3 || p.Buckets[0] != first {
+		t.Fatal("Render modified the buckets")
+	}
+}
+
+func TestParsePanicStringRuntimeStack(t *testing.T) {
+	data := strings.Join([]string{
+		"fatal error: unexpected signal during runtime execution",
+		"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x45f2a1]",
+		"",
+		"runtime stack:",
+		"runtime.throw({0x4b9b4e?, 0x0?})",
+		"\t/usr/local/go/src/runtime/panic.go:1047 +0x5d",
+		"runtime.sigpanic()",
+		"\t/usr/local/go/src/runtime/signal_unix.go:819 +0x3e9",
+		"",
+		"goroutine 1 [running]:",
+		"main.crash(...)",
+		"\t/home/user/go/src/foo/main.go:12",
+		"main.main()",
+		"\t/home/user/go/src/foo/main.go:7 +0x1d",
+		"",
+	}, "\n")
+	out, err := ParsePanicString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(out, "")
+	if !strings.Contains(got, "main.go:12") || strings.Contains(got, "runtime") {
+		t.Fatalf("expected only the crashing goroutine, got %q", got)
+	}
+}
+
+func TestPanicReportRenderTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(src, []byte("package main\n\nfunc f(a int) {\n\tpanic(\"boom\")\n}\n\nfunc main() {\n\tf(5)\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePanic("panic: boom\n\ngoroutine 1 [running]:\nmain.f(0x5)\n\t" + filepath.ToSlash(src) + ":4 +0x25\nmain.main()\n\t" + filepath.ToSlash(src) + ":8 +0x1d\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The path is not in a GOPATH, point to the source so it is augmented.
+	for i := range p.Buckets[0].Stack.Calls {
+		p.Buckets[0].Stack.Calls[i].LocalSrcPath = src
+	}
+	before := p.Buckets[0].Signature.Clone()
+	want, err := p.Render(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Render is safe to call concurrently, run with -race.
+	var wg sync.WaitGroup
+	got := make([][]string, 4)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], _ = p.Render(nil)
+		}(i)
+	}
+	wg.Wait()
+	for i := range got {
+		if strings.Join(got[i], "\n") != strings.Join(want, "\n") {
+			t.Fatalf("#%d: want %q, got %q", i, want, got[i])
+		}
+	}
+	if !reflect.DeepEqual(before, &p.Buckets[0].Signature) {
+		t.Fatalf("Render modified the report: want %#v, got %#v", before, &p.Buckets[0].Signature)
+	}
+}
+
+func TestParsePanicStringOptsSimilarity(t *testing.T) {
+	count := func(opts *Options) int {
+		out, err := ParsePanicStringOpts(goldenDump, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(out)
+	}
+	exact := stack.ExactLines
+	// Everything in the same bucket.
+	all := func(a, b *stack.Signature) bool { return true }
+	data := []struct {
+		opts Options
+		want int
+	}{
+		{Options{}, 2},
+		{Options{Similarity: &exact}, 3},
+		{Options{Match: all}, 1},
+		{Options{Similarity: &exact, Match: all}, 1},
+	}
+	for i, line := range data {
+		if got := count(&line.opts); got != line.want {
+			t.Errorf("#%d: want %d buckets, got %d", i, line.want, got)
+		}
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	a := &stack.Args{Values: []stack.Arg{{Value: 1}, {Value: 2}, {Value: 3}, {Value: 4}}}
+	elided := &stack.Args{Values: a.Values, Elided: true}
+	labeled := &stack.Args{
+		Values: a.Values,
+		Labeled: []stack.LabeledArg{
+			{Name: "id", Type: "int", Value: "42"},
+			{Name: "name", Type: "string", Value: "ptr=0x1, len=3"},
+			{Name: "s", Type: "*Server", Elided: true},
+		},
+		Elided: true,
+	}
+	data := []struct {
+		args *stack.Args
+		opts Options
+		want string
+	}{
+		{a, Options{}, "1, 2, 3, 4"},
+		{a, Options{MaxArgs: 4}, "1, 2, 3, 4"},
+		{a, Options{MaxArgs: 2}, "1, 2, ..."},
+		{a, Options{MaxArgs: 1, CountElidedArgs: true}, "1, +3"},
+		{elided, Options{MaxArgs: 2}, "1, 2, ..."},
+		{elided, Options{MaxArgs: 2, CountElidedArgs: true}, "1, 2, +2, ..."},
+		{elided, Options{MaxArgs: 5, CountElidedArgs: true}, "1, 2, 3, 4, ..."},
+		{&stack.Args{Processed: []string{"string(\"a\")", "int(1)"}}, Options{MaxArgs: 1}, "string(\"a\"), ..."},
+		{labeled, Options{}, "1, 2, 3, 4, ..."},
+		{labeled, Options{LabelArgs: true}, "id int=42, name string(ptr=0x1, len=3), s *Server=..."},
+		{labeled, Options{LabelArgs: true, MaxArgs: 1, CountElidedArgs: true}, "id int=42, +2"},
+	}
+	for i, l := range data {
+		if got := formatArgs(l.args, &l.opts); got != l.want {
+			t.Errorf("#%d: want %q, got %q", i, l.want, got)
+		}
+	}
+}
+
+func TestStackLinesSelectCases(t *testing.T) {
+	s := &stack.Signature{
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				{Func: stack.NewFunc("runtime.selectgo"), SrcPath: "/goroot/src/runtime/select.go", Line: 327},
+				{Func: stack.NewFunc("main.f"), SrcPath: "/src/main.go", Line: 5, SelectCases: []string{"<-ctx.Done()", "resCh<-"}},
+			},
+		},
+	}
+	want := "runtime select.go:327 selectgo()\nmain    main.go:5     f()\n    selecting on: <-ctx.Done(), resCh<-\n"
+	if got := stackLines(s, 13, 7, &Options{}); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestStackLinesHideWrappers(t *testing.T) {
+	s := &stack.Signature{
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				{Func: stack.NewFunc("main.f"), SrcPath: "/src/main.go", Line: 5},
+				{Func: stack.NewFunc("main.main.deferwrap1"), SrcPath: "/src/main.go", Line: 9},
+				{Func: stack.NewFunc("main.(*T).M"), SrcPath: "<autogenerated>", Line: 1},
+				{Func: stack.NewFunc("main.main"), SrcPath: "/src/main.go", Line: 10},
+				{Func: stack.NewFunc("runtime.goexit.abi0"), SrcPath: "/goroot/src/runtime/asm_amd64.s", Line: 1598},
+			},
+		},
+	}
+	want := "main main.go:5  f()\n    (2 wrapper frames hidden)\nmain main.go:10 main()\n    (1 wrapper frame hidden)\n"
+	if got := stackLines(s, 10, 4, &Options{HideWrappers: true}); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestPanicReportPreambleAnalyzers(t *testing.T) {
+	data := "fatal error: all goroutines are asleep - deadlock!\n\ngoroutine 1 [chan receive]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	a := analysis.NewAnalyzer("test", func(c *stack.Context) []analysis.Finding {
+		return []analysis.Finding{{Severity: analysis.SeverityWarning, IDs: []int{1}, Message: "stuck"}}
+	})
+	p, err := ParsePanic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Analyzers: []analysis.Analyzer{a}}
+	if got := p.Preamble(opts); got != "Findings:\n  [warning] test (low confidence): stuck\n    goroutines: 1\n" {
+		t.Fatalf("unexpected findings: %q", got)
+	}
+	// The buckets are not shifted.
+	out, err := p.Render(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !strings.HasPrefix(out[0], "1: chan receive") {
+		t.Fatalf("unexpected buckets: %q", out)
+	}
+}
+
+func TestPanicReportPreambleShowReason(t *testing.T) {
+	stack := "\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	data := []struct {
+		in   string
+		want string
+	}{
+		{"fatal error: concurrent map writes\n", "fatal: concurrent map writes\n"},
+		{"SIGSEGV: segmentation violation\nPC=0x7f0 m=0 sigcode=1\n", "signal: SIGSEGV: segmentation violation\n"},
+		{
+			"panic: oh no [recovered]\n\tpanic: runtime error: invalid memory address or nil pointer dereference\n[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x48f0b7]\n",
+			"panic: runtime error: invalid memory address or nil pointer dereference (after 1 recovered) (SIGSEGV addr=0x0)\n",
+		},
+	}
+	opts := &Options{ShowReason: true}
+	for i, line := range data {
+		p, err := ParsePanic(line.in + stack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Preamble(opts); got != line.want {
+			t.Fatalf("#%d: want %q, got %q", i, line.want, got)
+		}
+		// The buckets are not shifted.
+		out, err := p.Render(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || !strings.HasPrefix(out[0], "1: running") {
+			t.Fatalf("#%d: unexpected buckets: %q", i, out)
+		}
+	}
+	p, err := ParsePanic(stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Preamble(opts); got != "" {
+		t.Fatalf("unexpected reason: %q", got)
+	}
+}
+
+func TestBucketSizeSampled(t *testing.T) {
+	// Buckets from stack.TopK only have a sample of the IDs.
+	buckets := []*stack.Bucket{
+		{IDs: []int{1, 2}, Count: 12},
+		{IDs: []int{3}, Count: 1},
+	}
+	kept, hidden := filterCount(buckets, 5, false)
+	if len(kept) != 1 || kept[0] != buckets[0] || len(hidden) != 1 {
+		t.Fatalf("unexpected %v, %v", kept, hidden)
+	}
+	if kept, _ = filterCount(buckets, 0, true); len(kept) != 1 || kept[0] != buckets[1] {
+		t.Fatalf("unexpected %v", kept)
+	}
+	if got, want := gcSummary(buckets), "13: runtime background workers [2 buckets collapsed]\n"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestFilterDepth(t *testing.T) {
+	call := stack.Call{Func: stack.NewFunc("main.f"), SrcPath: "/src/main.go", Line: 1}
+	bucket := func(depth int, elided bool) *stack.Bucket {
+		b := &stack.Bucket{IDs: []int{depth}}
+		b.Stack = stack.Stack{Calls: make([]stack.Call, depth), Elided: elided}
+		for i := range b.Stack.Calls {
+			b.Stack.Calls[i] = call
+		}
+		return b
+	}
+	buckets := []*stack.Bucket{bucket(1, false), bucket(3, false), bucket(2, true), bucket(0, false)}
+	data := []struct {
+		name string
+		min  int
+		want []int
+	}{
+		{"disabled", 0, []int{1, 3, 2, 0}},
+		{"negative", -1, []int{1, 3, 2, 0}},
+		{"one", 1, []int{1, 3, 2}},
+		// An elided stack has at least the frames printed.
+		{"elided", 2, []int{3, 2}},
+		{"exact", 3, []int{3}},
+		{"none", 4, nil},
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			got := filterDepth(buckets, line.min)
+			var ids []int
+			for _, b := range got {
+				ids = append(ids, b.IDs[0])
+			}
+			if !reflect.DeepEqual(line.want, ids) {
+				t.Fatalf("want %v, got %v", line.want, ids)
+			}
+			// The input is never modified.
+			if len(got) != 0 && &got[0] == &buckets[0] {
+				t.Fatal("aliased input")
+			}
+		})
+	}
+}
+
+func TestPanicReportMinDepth(t *testing.T) {
+	data := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n\n" +
+		"goroutine 6 [chan receive]:\nmain.worker()\n\t/home/user/src/foo/main.go:20 +0x12\nmain.run()\n\t/home/user/src/foo/main.go:30 +0x12\n"
+	p, err := ParsePanic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.Render(&Options{MinDepth: 2, ShowDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !strings.HasPrefix(out[0], "1: chan receive [depth 2]") {
+		t.Fatalf("unexpected buckets: %q", out)
+	}
+}
+
+func TestFilterCount(t *testing.T) {
+	bucket := func(id, n int) *stack.Bucket {
+		b := &stack.Bucket{}
+		for i := 0; i < n; i++ {
+			b.IDs = append(b.IDs, id*10+i)
+		}
+		return b
+	}
+	buckets := []*stack.Bucket{bucket(1, 1), bucket(2, 3), bucket(3, 2), bucket(4, 1)}
+	data := []struct {
+		name       string
+		min        int
+		singletons bool
+		kept       []int
+		hidden     []int
+	}{
+		{"disabled", 0, false, []int{10, 20, 30, 40}, nil},
+		{"one", 1, false, []int{10, 20, 30, 40}, nil},
+		{"two", 2, false, []int{20, 30}, []int{10, 40}},
+		{"three", 3, false, []int{20}, []int{10, 30, 40}},
+		{"none", 4, false, nil, []int{10, 20, 30, 40}},
+		{"singletons", 0, true, []int{10, 40}, []int{20, 30}},
+		// Both filters apply.
+		{"singletons_min", 2, true, nil, []int{10, 20, 30, 40}},
+	}
+	ids := func(buckets []*stack.Bucket) []int {
+		var out []int
+		for _, b := range buckets {
+			out = append(out, b.IDs[0])
+		}
+		return out
+	}
+	for _, line := range data {
+		t.Run(line.name, func(t *testing.T) {
+			kept, hidden := filterCount(buckets, line.min, line.singletons)
+			if got := ids(kept); !reflect.DeepEqual(line.kept, got) {
+				t.Fatalf("kept: want %v, got %v", line.kept, got)
+			}
+			if got := ids(hidden); !reflect.DeepEqual(line.hidden, got) {
+				t.Fatalf("hidden: want %v, got %v", line.hidden, got)
+			}
+		})
+	}
+}
+
+func TestHiddenSummary(t *testing.T) {
+	hidden := []*stack.Bucket{{IDs: []int{1, 2}}, {IDs: []int{3}, Count: 5}}
+	if got, want := hiddenSummary(hidden), "7: hidden [2 buckets]\n"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestPanicReportMinCount(t *testing.T) {
+	data := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n\n" +
+		"goroutine 6 [chan receive]:\nmain.worker()\n\t/home/user/src/foo/main.go:20 +0x12\n\n" +
+		"goroutine 7 [chan receive]:\nmain.worker()\n\t/home/user/src/foo/main.go:20 +0x12\n"
+	p, err := ParsePanic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.Render(&Options{MinCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || !strings.HasPrefix(out[0], "2: chan receive") || out[1] != "1: hidden [1 buckets]\n" {
+		t.Fatalf("unexpected buckets: %q", out)
+	}
+	if out, err = p.Render(&Options{Singletons: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || !strings.HasPrefix(out[0], "1: running") || out[1] != "2: hidden [1 buckets]\n" {
+		t.Fatalf("unexpected buckets: %q", out)
+	}
+}
@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"regexp"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
+)
+
+// SortOrder determines the order in which buckets are rendered.
+type SortOrder int
+
+const (
+	// SortDefault keeps the order returned by stack.Aggregate.
+	SortDefault SortOrder = iota
+	// SortDepth renders the deepest stacks first. This helps finding runaway
+	// recursion or deeply nested middlewares.
+	SortDepth
+)
+
+// Options controls how ParsePanicStringOpts renders a stack trace.
+//
+// The zero value renders every bucket.
+type Options struct {
+	// FirstOnly only renders the bucket containing the first goroutine, which
+	// is normally the one that panicked. The other items are left empty. This
+	// is the behavior of ParsePanicString.
+	FirstOnly bool
+	// Similarity is the level at which the goroutines are aggregated into
+	// buckets. nil means stack.AnyPointer.
+	Similarity *stack.Similarity
+	// Match aggregates the goroutines with a custom policy instead of
+	// Similarity, e.g. to ignore the innermost frames. See
+	// stack.AggregateFunc.
+	Match func(a, b *stack.Signature) bool
+	// GC controls how the garbage collector and other runtime background
+	// goroutines are rendered. When collapsed, they are summarized on a single
+	// line appended after the other buckets.
+	GC stack.GCMode
+	// Sort determines the order in which buckets are rendered.
+	Sort SortOrder
+	// MinDepth skips the buckets with fewer stack frames.
+	MinDepth int
+	// ShowDepth adds the stack depth to each bucket header.
+	ShowDepth bool
+	// MinCount skips the buckets with fewer goroutines. A line summarizing
+	// the hidden buckets is appended after the other buckets.
+	MinCount int
+	// Singletons only renders the buckets with a single goroutine, which is
+	// useful to find the odd one out. A line summarizing the hidden buckets is
+	// appended after the other buckets.
+	Singletons bool
+	// Highlight emphasizes the frames where the fully qualified function name
+	// matches any of the regular expressions, e.g.
+	// "github.com/foo/bar.(*Server)".
+	Highlight []*regexp.Regexp
+	// Color uses ANSI escape codes to emphasize highlighted frames. Otherwise
+	// they are prefixed with "*".
+	Color bool
+	// Palette colors each field of the output with ANSI escape codes, e.g.
+	// &DefaultPalette. The header of the bucket containing the goroutine that
+	// panicked and the functions of the standard library and of the main
+	// package are colored differently. It implies Color; the highlighted
+	// frames use Palette.Highlight.
+	Palette *ColorPalette
+	// IgnoreRules hides or collapses the matching buckets.
+	IgnoreRules []IgnoreRule
+	// DiscoverIgnoreFile looks for an IgnoreFileName next to the local
+	// source files and appends its rules to IgnoreRules.
+	DiscoverIgnoreFile bool
+	// Unescape decodes the stack trace with Unescape before parsing it, for
+	// traces copied from JSON logs or web pages.
+	Unescape bool
+	// MaxArgs is the maximum number of argument values rendered per frame;
+	// the others are elided. 0 renders all of them. It is independent of the
+	// arguments elided by the runtime, which are always rendered as "...".
+	MaxArgs int
+	// CountElidedArgs renders the number of arguments elided by MaxArgs, e.g.
+	// "+3", instead of "...".
+	CountElidedArgs bool
+	// LabelArgs renders the arguments labeled with the name and the type of
+	// their parameter, e.g. "id int=42", when the sources were processed. See
+	// stack.Args.Labeled.
+	LabelArgs bool
+	// AlignPerBucket computes the width of the package and source columns
+	// for each bucket instead of across all the buckets, so one bucket with a
+	// long path doesn't widen all the others.
+	AlignPerBucket bool
+	// MaxColumnWidth caps the width of the package and source columns. Longer
+	// values overflow their column. 0 means no cap.
+	MaxColumnWidth int
+	// ShowIDs renders the goroutine IDs of each bucket in its header, with
+	// consecutive IDs as a range, e.g. "[ids: 12, 15, 100–180]".
+	ShowIDs bool
+	// MaxIDsWidth caps the width of the IDs rendered with ShowIDs; the IDs
+	// over the cap are counted instead. 0 means no cap.
+	MaxIDsWidth int
+	// GroupByReceiver keeps the buckets in a method of the same receiver type
+	// together, largest group first, each group starting with a line with the
+	// type and the number of goroutines. See stack.GroupByReceiver.
+	GroupByReceiver bool
+	// HideWrappers removes the wrappers generated by the compiler from the
+	// stacks, as determined by stack.Call.IsWrapper. Each run of hidden frames
+	// is replaced by a line with their count.
+	HideWrappers bool
+	// History is the history of the signatures from the previous reports, by
+	// fingerprint, e.g. as returned by store.Dir.History. It is rendered with
+	// FormatHistory under the header of the matching buckets.
+	History map[string]SignatureHistory
+	// Clock provides the current time to render History. Defaults to
+	// SystemClock.
+	Clock Clock
+	// Analyzers are run on the parsed dump and their findings are rendered
+	// with FormatFindings by PanicReport.Preamble, e.g.
+	// analysis.Analyzers(). They are not part of the buckets.
+	Analyzers []analysis.Analyzer
+	// ShowReason renders why the process died in PanicReport.Preamble, e.g.
+	// "panic: runtime error: index out of range [3] with length 2", from
+	// stack.Context.Message. Nothing is rendered when no message was printed.
+	ShowReason bool
+}
@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// CodeOwners is a parsed CODEOWNERS file, mapping the paths of a repository
+// to their owners.
+type CodeOwners struct {
+	// Module is the import path of the root of the repository, e.g.
+	// "github.com/foo/bar". The source paths of the calls are made relative to
+	// it, see stack.Call.ModuleSrcPath.
+	Module string
+
+	rules []ownerRule
+}
+
+// ParseCodeOwners parses a CODEOWNERS file in the GitHub format for the
+// repository at the import path module.
+//
+// Each line is a pattern followed by the owners, e.g.:
+//
+//	# Default owners.
+//	*          @org/platform
+//	/billing/  @org/payments
+//	*.sql      @org/dba
+//
+// The last matching pattern wins. Empty lines and lines starting with "#"
+// are ignored.
+func ParseCodeOwners(r io.Reader, module string) (*CodeOwners, error) {
+	out := &CodeOwners{Module: strings.TrimSuffix(module, "/")}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(l)
+		re, err := regexp.Compile(ownerPattern(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		out.rules = append(out.rules, ownerRule{match: re, owners: fields[1:]})
+	}
+	return out, s.Err()
+}
+
+// PathOwners returns the owners of the path relative to the root of the
+// repository, e.g. "billing/invoice.go". It returns nil if no pattern
+// matches or if the last matching pattern has no owner.
+func (o *CodeOwners) PathOwners(rel string) []string {
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if o.rules[i].match.MatchString(rel) {
+			return o.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// Owners returns the owners of the source file of the call. It returns nil
+// for the files outside the repository.
+func (o *CodeOwners) Owners(c *stack.Call) []string {
+	p := c.ModuleSrcPath()
+	if o.Module != "" {
+		if !strings.HasPrefix(p, o.Module+"/") {
+			return nil
+		}
+		p = p[len(o.Module)+1:]
+	}
+	return o.PathOwners(p)
+}
+
+// TeamReport is the part of a dump relevant to one owner.
+type TeamReport struct {
+	// Owner is the owner, e.g. "@org/payments". It is empty for the buckets
+	// without an owner.
+	Owner string
+	// Buckets is the buckets with a call owned by Owner, in their original
+	// order.
+	Buckets []*stack.Bucket
+	// Goroutines is the number of goroutines in Buckets.
+	Goroutines int
+	// Context is the bucket containing the goroutine that panicked when it is
+	// not in Buckets, since it explains the crash to every owner. It is nil
+	// otherwise.
+	Context *stack.Bucket
+}
+
+// SplitByOwner splits the buckets of a dump per owner, e.g. to notify each
+// team with only their part of a fleet-wide hang.
+//
+// A bucket is relevant to the owners of any of its calls, so it can be in
+// several reports. The buckets without any owned call are in a report with
+// an empty Owner. The reports are sorted by Owner, the one without owner
+// last.
+func SplitByOwner(buckets []*stack.Bucket, owners *CodeOwners) []TeamReport {
+	var first *stack.Bucket
+	index := map[string]int{}
+	var out []TeamReport
+	add := func(owner string, b *stack.Bucket) {
+		i, ok := index[owner]
+		if !ok {
+			i = len(out)
+			index[owner] = i
+			out = append(out, TeamReport{Owner: owner})
+		}
+		out[i].Buckets = append(out[i].Buckets, b)
+		out[i].Goroutines += b.Size()
+	}
+	for _, b := range buckets {
+		if b.First && first == nil {
+			first = b
+		}
+		seen := map[string]bool{}
+		for i := range b.Stack.Calls {
+			for _, o := range owners.Owners(&b.Stack.Calls[i]) {
+				if !seen[o] {
+					seen[o] = true
+					add(o, b)
+				}
+			}
+		}
+		if len(seen) == 0 {
+			add("", b)
+		}
+	}
+	for i := range out {
+		if first != nil && !containsBucket(out[i].Buckets, first) {
+			out[i].Context = first
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Owner == "") != (out[j].Owner == "") {
+			return out[j].Owner == ""
+		}
+		return out[i].Owner < out[j].Owner
+	})
+	return out
+}
+
+// Private stuff.
+
+// ownerRule is a line of a CODEOWNERS file.
+type ownerRule struct {
+	match  *regexp.Regexp
+	owners []string
+}
+
+// ownerPattern converts a CODEOWNERS pattern to a regexp matching the paths
+// relative to the root of the repository.
+//
+// As with gitignore, a pattern with a "/" at the start or in the middle is
+// relative to the root, otherwise it matches at any depth. A pattern matching
+// a directory matches all the files under it.
+func ownerPattern(p string) string {
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	if dirOnly {
+		b.WriteString("/.*$")
+	} else {
+		b.WriteString("(?:/.*)?$")
+	}
+	return b.String()
+}
+
+func containsBucket(buckets []*stack.Bucket, b *stack.Bucket) bool {
+	for _, x := range buckets {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
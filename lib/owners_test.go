@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeOwnersPathOwners(t *testing.T) {
+	o, err := ParseCodeOwners(strings.NewReader("# comment\n\n*  @org/platform\n/billing/ @org/payments\n*.sql @org/dba\ndocs/**/*.md @org/docs\n/vendor/\n"), "example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		path string
+		want string
+	}{
+		{"main.go", "@org/platform"},
+		{"billing/invoice.go", "@org/payments"},
+		{"billing/sub/tax.go", "@org/payments"},
+		{"sub/billing/x.go", "@org/platform"},
+		{"billing/schema.sql", "@org/dba"},
+		{"docs/a/b/c.md", "@org/docs"},
+		{"docs/c.md", "@org/docs"},
+		{"vendor/x/y.go", ""},
+	}
+	for i, line := range data {
+		if got := strings.Join(o.PathOwners(line.path), ","); got != line.want {
+			t.Errorf("#%d: %s: want %q, got %q", i, line.path, line.want, got)
+		}
+	}
+}
+
+func TestSplitByOwner(t *testing.T) {
+	p, err := ParsePanic(goldenDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := ParseCodeOwners(strings.NewReader("/worker/ @org/workers\n"), "example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := SplitByOwner(p.Buckets, o)
+	if len(got) != 2 {
+		t.Fatalf("unexpected reports: %#v", got)
+	}
+	if r := got[0]; r.Owner != "@org/workers" || len(r.Buckets) != 1 || r.Goroutines != 2 || r.Context != p.Buckets[0] {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+	if r := got[1]; r.Owner != "" || len(r.Buckets) != 1 || !r.Buckets[0].First || r.Context != nil {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ColorPalette is the ANSI escape codes used to color each field of the
+// output, see Options.Palette. A field left empty is not colored.
+type ColorPalette struct {
+	// RoutineFirst is the header of the bucket containing the goroutine that
+	// panicked.
+	RoutineFirst string
+	// Routine is the header of the other buckets.
+	Routine string
+	// Package is the package name column.
+	Package string
+	// SrcFile is the source file and line column.
+	SrcFile string
+	// FuncStdLib is the name of the functions of the standard library.
+	FuncStdLib string
+	// FuncMain is the name of the functions of the main package.
+	FuncMain string
+	// FuncOther is the name of the other functions.
+	FuncOther string
+	// Arguments is the call arguments.
+	Arguments string
+	// Highlight is the frames matching Options.Highlight. The whole line is
+	// colored.
+	Highlight string
+}
+
+// DefaultPalette is a palette readable on both dark and light terminals.
+var DefaultPalette = ColorPalette{
+	RoutineFirst: "\033[1;35m",
+	Routine:      "\033[35m",
+	Package:      "\033[1;33m",
+	SrcFile:      "\033[0m",
+	FuncStdLib:   "\033[32m",
+	FuncMain:     "\033[1;31m",
+	FuncOther:    "\033[1;32m",
+	Arguments:    "\033[0m",
+	Highlight:    ansiHighlight,
+}
+
+// Private stuff.
+
+// color wraps s in the escape code c, if any.
+func (p *ColorPalette) color(c, s string) string {
+	if c == "" || s == "" {
+		return s
+	}
+	return c + s + ansiReset
+}
+
+// header colors a bucket header, keeping its trailing newline.
+func (p *ColorPalette) header(h string, first bool) string {
+	c := p.Routine
+	if first {
+		c = p.RoutineFirst
+	}
+	return p.color(c, strings.TrimSuffix(h, "\n")) + "\n"
+}
+
+// funcColor returns the color of the function name of the call.
+func (p *ColorPalette) funcColor(c *stack.Call) string {
+	switch {
+	case c.IsStdlib:
+		return p.FuncStdLib
+	case c.IsPkgMain():
+		return p.FuncMain
+	default:
+		return p.FuncOther
+	}
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Playground retrieves the output of Go playground snippets.
+//
+// The zero value uses http.DefaultClient and https://play.golang.org.
+type Playground struct {
+	// Client is the HTTP client to use. Defaults to http.DefaultClient.
+	Client *http.Client
+	// BaseURL is the playground server. Defaults to https://play.golang.org.
+	BaseURL string
+}
+
+// Fetch returns the output of a playground snippet, suitable for
+// ParsePanicStringOpts.
+//
+// input is either a share link, e.g. "https://go.dev/play/p/AbCdEf" or
+// "https://play.golang.org/p/AbCdEf", or output copied from the playground.
+// For a link, the snippet is retrieved and compiled and run by the
+// playground. Copied output is returned as-is, minus the playground trailer.
+func (p *Playground) Fetch(ctx context.Context, input string) (string, error) {
+	input = strings.TrimSpace(input)
+	m := rePlaygroundLink.FindStringSubmatch(input)
+	if m == nil {
+		return strings.TrimRightFunc(rePlaygroundTrailer.ReplaceAllString(input, ""), unicode.IsSpace) + "\n", nil
+	}
+	src, err := p.get(ctx, "/p/"+m[1]+".go")
+	if err != nil {
+		return "", err
+	}
+	body := url.Values{"version": {"2"}, "body": {string(src)}}.Encode()
+	resp, err := p.do(ctx, "POST", "/compile", strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Errors string
+		Events []struct {
+			Message string
+			Kind    string
+		}
+	}
+	if err := json.Unmarshal(resp, &out); err != nil {
+		return "", fmt.Errorf("failed to decode playground response: %v", err)
+	}
+	if out.Errors != "" {
+		return "", fmt.Errorf("playground snippet failed to build: %s", out.Errors)
+	}
+	var b strings.Builder
+	for _, e := range out.Events {
+		b.WriteString(e.Message)
+	}
+	return b.String(), nil
+}
+
+// Private stuff.
+
+var (
+	// rePlaygroundLink matches the share links of play.golang.org and go.dev.
+	rePlaygroundLink = regexp.MustCompile(`^(?:https?://)?(?:play\.golang\.org/p/|go\.dev/play/p/)([A-Za-z0-9_-]+)(?:\.go)?/?$`)
+	// rePlaygroundTrailer matches the status line appended by the playground.
+	rePlaygroundTrailer = regexp.MustCompile(`(?m)^(?:Program exited(?::.*)?\.?|\[process exited with non-zero status\])\s*$`)
+)
+
+// maxPlaygroundResponse is the maximum size of a response from the
+// playground. It is a variable so the tests can lower it.
+var maxPlaygroundResponse int64 = 16 << 20
+
+func (p *Playground) get(ctx context.Context, path string) ([]byte, error) {
+	return p.do(ctx, "GET", path, nil)
+}
+
+func (p *Playground) do(ctx context.Context, method, path string, body io.Reader) ([]byte, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = "https://play.golang.org"
+	}
+	req, err := http.NewRequest(method, base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	c := p.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPlaygroundResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxPlaygroundResponse {
+		return nil, fmt.Errorf("%s %s: response larger than %d bytes", method, path, maxPlaygroundResponse)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return b, nil
+}
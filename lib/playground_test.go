@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const playgroundPanic = "panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/sandbox/prog.go:4 +0x25\n"
+
+func TestPlaygroundFetchLink(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/p/AbC_12.go":
+			_, _ = w.Write([]byte("package main\n\nfunc main() {\n\tpanic(\"oh no\")\n}\n"))
+		case "/compile":
+			b, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(b), "panic") {
+				t.Errorf("unexpected body %q", b)
+			}
+			_, _ = w.Write([]byte(`{"Errors":"","Events":[{"Message":"panic: oh no\n\ngoroutine 1 [running]:\n","Kind":"stderr"},{"Message":"main.main()\n\t/tmp/sandbox/prog.go:4 +0x25\n","Kind":"stderr"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	p := &Playground{BaseURL: s.URL}
+	out, err := p.Fetch(context.Background(), "https://go.dev/play/p/AbC_12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != playgroundPanic {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if _, err := p.Fetch(context.Background(), "play.golang.org/p/missing"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPlaygroundFetchOutput(t *testing.T) {
+	out, err := (&Playground{}).Fetch(context.Background(), playgroundPanic+"\nProgram exited: status 2.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != playgroundPanic {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if _, err := ParsePanicString(out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaygroundFetchTooLarge(t *testing.T) {
+	old := maxPlaygroundResponse
+	defer func() { maxPlaygroundResponse = old }()
+	maxPlaygroundResponse = 16
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 17)))
+	}))
+	defer s.Close()
+	p := &Playground{BaseURL: s.URL}
+	_, err := p.Fetch(context.Background(), "https://go.dev/play/p/AbC_12")
+	if err == nil || !strings.Contains(err.Error(), "larger than 16 bytes") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PluginProtocolVersion is the version of the formatter plugin protocol,
+// passed to the plugin in the PANICPARSE_PLUGIN_PROTOCOL environment variable.
+const PluginProtocolVersion = 1
+
+// FormatterPlugin is an external formatter run as a subprocess, so bespoke
+// reports can be written in any language.
+//
+// The protocol is:
+//   - The Report is written as JSON to the standard input of the plugin, as
+//     with WriteReport, then the standard input is closed.
+//   - The plugin writes the formatted report to its standard output and exits
+//     with 0.
+//   - Any other exit code is an error; the standard error of the plugin is
+//     included in the returned error.
+//   - The environment variable PANICPARSE_PLUGIN_PROTOCOL contains
+//     PluginProtocolVersion. The Report.SchemaVersion is in the input.
+type FormatterPlugin struct {
+	// Command is the path to the plugin followed by its arguments.
+	Command []string
+	// Env is appended to the environment of the current process.
+	Env []string
+	// Timeout is the maximum duration of a run, after which the plugin is
+	// killed. Format returns at most a second later, even if a child of the
+	// plugin still holds its output open. Defaults to 30s.
+	Timeout time.Duration
+	// MaxOutput is the maximum size of the output in bytes. Defaults to 16MiB.
+	MaxOutput int
+}
+
+// Format runs the plugin with the report and returns its output.
+func (p *FormatterPlugin) Format(ctx context.Context, r *Report) ([]byte, error) {
+	if len(p.Command) == 0 {
+		return nil, errors.New("plugin: no command")
+	}
+	var in bytes.Buffer
+	if err := WriteReport(&in, r); err != nil {
+		return nil, err
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	max := p.MaxOutput
+	if max <= 0 {
+		max = 16 << 20
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Env = append(append(os.Environ(), fmt.Sprintf("PANICPARSE_PLUGIN_PROTOCOL=%d", PluginProtocolVersion)), p.Env...)
+	cmd.Stdin = &in
+	stdout := &cappedBuffer{max: max}
+	stderr := &cappedBuffer{max: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Killing the plugin doesn't kill its children, which may keep the output
+	// pipes open and Run waiting on them.
+	cmd.WaitDelay = pluginWaitDelay
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s: timed out after %s", p.Command[0], timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return nil, fmt.Errorf("plugin %s: %v: %s", p.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s: %v", p.Command[0], err)
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("plugin %s: output exceeds %d bytes", p.Command[0], max)
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// Private stuff.
+
+// pluginWaitDelay is how long to wait for the output pipes of a plugin to be
+// closed after it was killed or exited, before closing them.
+const pluginWaitDelay = time.Second
+
+// cappedBuffer is a buffer that drops the data over max bytes.
+//
+// It doesn't embed bytes.Buffer so io.Copy can't bypass Write with ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(b []byte) (int, error) {
+	n := len(b)
+	if l := c.max - c.buf.Len(); n > l {
+		b = b[:l]
+		c.truncated = true
+	}
+	_, _ = c.buf.Write(b)
+	return n, nil
+}
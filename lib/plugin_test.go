@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// TestPluginHelper is the plugin run by the tests, as the test binary itself.
+func TestPluginHelper(t *testing.T) {
+	mode := os.Getenv("PANICPARSE_TEST_PLUGIN")
+	if mode == "" {
+		t.Skip("only run as a plugin")
+	}
+	switch mode {
+	case "ok":
+		var r Report
+		b, _ := ioutil.ReadAll(os.Stdin)
+		if err := json.Unmarshal(b, &r); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("v%s schema %d: %d bucket(s)\n", os.Getenv("PANICPARSE_PLUGIN_PROTOCOL"), r.SchemaVersion, len(r.Buckets))
+	case "fail":
+		fmt.Fprintln(os.Stderr, "bad input")
+		os.Exit(3)
+	case "slow":
+		time.Sleep(time.Minute)
+	case "big":
+		fmt.Print(strings.Repeat("x", 100))
+	}
+	os.Exit(0)
+}
+
+func TestFormatterPlugin(t *testing.T) {
+	r := &Report{Buckets: []*stack.Bucket{{IDs: []int{1}}}}
+	plugin := func(mode string) *FormatterPlugin {
+		return &FormatterPlugin{
+			Command: []string{os.Args[0], "-test.run=^TestPluginHelper$"},
+			Env:     []string{"PANICPARSE_TEST_PLUGIN=" + mode},
+		}
+	}
+	out, err := plugin("ok").Format(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != "v1 schema 1: 1 bucket(s)\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	if _, err := plugin("fail").Format(context.Background(), r); err == nil || !strings.Contains(err.Error(), "exit status 3: bad input") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	p := plugin("slow")
+	p.Timeout = 100 * time.Millisecond
+	if _, err := p.Format(context.Background(), r); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := exec.LookPath("sh"); err == nil {
+		// The shell is killed but sleep keeps the standard output open.
+		p = &FormatterPlugin{Command: []string{"sh", "-c", "sleep 5; echo done"}, Timeout: 100 * time.Millisecond}
+		start := time.Now()
+		if _, err := p.Format(context.Background(), r); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if d := time.Since(start); d >= 4*time.Second {
+			t.Fatalf("timeout not enforced: took %s", d)
+		}
+	}
+
+	p = plugin("big")
+	p.MaxOutput = 10
+	if _, err := p.Format(context.Background(), r); err == nil || !strings.Contains(err.Error(), "exceeds 10 bytes") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := (&FormatterPlugin{}).Format(context.Background(), r); err == nil {
+		t.Fatal("expected error")
+	}
+}
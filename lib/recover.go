@@ -0,0 +1,173 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// RequestMeta is the HTTP request being served when a handler panicked.
+//
+// It is part of Report so crash dashboards can correlate crashes with the
+// request logs across services. Fields are only added to this struct, never
+// renamed.
+type RequestMeta struct {
+	// Method is the HTTP method, e.g. "GET".
+	Method string `json:"method"`
+	// Path is the URL path, without the query string which may contain
+	// secrets.
+	Path string `json:"path"`
+	// Route is the route pattern matched by the router, e.g.
+	// "/users/{id}". It is empty if RecoverOptions.Route is not set.
+	Route string `json:"route,omitempty"`
+	// RequestID is the value of the request ID header, if present.
+	RequestID string `json:"requestId,omitempty"`
+	// RemoteAddr is the client IP address. It is masked unless
+	// RecoverOptions.KeepRemoteAddr is set: the last byte of an IPv4 address
+	// and the last 80 bits of an IPv6 address are zeroed. The port is always
+	// removed.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+}
+
+// RecoverOptions controls RecoverHandler.
+//
+// The zero value is valid.
+type RecoverOptions struct {
+	// OnPanic is called with the report of each panic. The report contains
+	// the goroutine that panicked and the request metadata.
+	OnPanic func(r *Report)
+	// RequestIDHeader is the header carrying the request ID. Defaults to
+	// "X-Request-Id".
+	RequestIDHeader string
+	// Route returns the route pattern of the request, e.g. "/users/{id}".
+	Route func(r *http.Request) string
+	// KeepRemoteAddr stores the client IP address unmasked.
+	KeepRemoteAddr bool
+	// Clock provides the creation time and the ID of the reports. Defaults to
+	// SystemClock.
+	Clock Clock
+}
+
+// NewRequestMeta returns the metadata of the request, redacted as specified
+// by opts.
+//
+// opts can be nil.
+func NewRequestMeta(r *http.Request, opts *RecoverOptions) *RequestMeta {
+	if opts == nil {
+		opts = &RecoverOptions{}
+	}
+	h := opts.RequestIDHeader
+	if h == "" {
+		h = "X-Request-Id"
+	}
+	out := &RequestMeta{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		RequestID:  r.Header.Get(h),
+		RemoteAddr: remoteIP(r.RemoteAddr, opts.KeepRemoteAddr),
+	}
+	if opts.Route != nil {
+		out.Route = opts.Route(r)
+	}
+	return out
+}
+
+// RecoverHandler returns a handler calling h that recovers panics, reports
+// them to opts.OnPanic and replies with http.StatusInternalServerError.
+//
+// http.ErrAbortHandler is not reported and is panicked again, so the
+// http.Server aborts the response as intended.
+//
+// opts can be nil.
+func RecoverHandler(h http.Handler, opts *RecoverOptions) http.Handler {
+	if opts == nil {
+		opts = &RecoverOptions{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			if opts.OnPanic != nil {
+				rep := panicReport(v, debug.Stack(), NewRequestMeta(r, opts))
+				clock := clockOr(opts.Clock)
+				now := clock.Now()
+				rep.ID = clock.NewID()
+				rep.Created = &now
+				opts.OnPanic(rep)
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Private stuff.
+
+// panicReport returns the report of a recovered panic, with stack as returned
+// by debug.Stack().
+func panicReport(v interface{}, s []byte, req *RequestMeta) *Report {
+	out := &Report{Panic: panicValue(v), Request: req}
+	if c, _ := stack.ParseDump(bytes.NewReader(s), nil, false); c != nil {
+		out.Buckets = stack.Aggregate(c.Goroutines, stack.AnyPointer)
+	}
+	return out
+}
+
+// panicValue decodes a recovered value the way it would be printed by the
+// runtime had it not been recovered.
+//
+// Values of custom types are printed in Go syntax instead of the type and
+// address printed by the runtime, so their fields are decoded.
+func panicValue(v interface{}) *stack.PanicValue {
+	var p *stack.PanicValue
+	switch t := v.(type) {
+	case error:
+		p = stack.ParsePanicValue(t.Error())
+	case fmt.Stringer:
+		p = stack.ParsePanicValue(t.String())
+	case string:
+		p = stack.ParsePanicValue(t)
+	default:
+		p = stack.ParsePanicValue(fmt.Sprintf("%#v", v))
+	}
+	if p.Type == "" {
+		// Unlike the runtime, the type is known.
+		p.Type = fmt.Sprintf("%T", v)
+		if _, ok := v.(error); ok {
+			p.Kind = stack.PanicError
+		} else if strings.Contains(p.Type, ".") {
+			p.Kind = stack.PanicCustom
+		}
+	}
+	return p
+}
+
+// remoteIP returns the IP address of addr, masked unless keep is set.
+func remoteIP(addr string, keep bool) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Not an IP, e.g. a unix socket. Don't leak it.
+		return ""
+	}
+	if keep {
+		return ip.String()
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
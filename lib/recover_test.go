@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+type customPanic struct {
+	Code int
+}
+
+func TestRecoverHandler(t *testing.T) {
+	var got *Report
+	h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("boom"))
+	}), &RecoverOptions{
+		OnPanic: func(r *Report) { got = r },
+		Route:   func(r *http.Request) string { return "/users/{id}" },
+		Clock:   NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+	})
+	req := httptest.NewRequest("POST", "/users/42?token=secret", nil)
+	req.RemoteAddr = "192.0.2.17:4321"
+	req.Header.Set("X-Request-Id", "abc")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if got == nil {
+		t.Fatal("OnPanic not called")
+	}
+	want := RequestMeta{Method: "POST", Path: "/users/42", Route: "/users/{id}", RequestID: "abc", RemoteAddr: "192.0.2.0"}
+	if *got.Request != want {
+		t.Fatalf("want %#v, got %#v", want, *got.Request)
+	}
+	if got.ID != "00000000000000000000000000000001" || !got.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected ID %q or time %s", got.ID, got.Created)
+	}
+	if p := got.Panic; p.Kind != stack.PanicError || p.Type != "*errors.errorString" || p.Message != "boom" {
+		t.Fatalf("unexpected panic value %#v", p)
+	}
+	found := false
+	for _, b := range got.Buckets {
+		for _, c := range b.Stack.Calls {
+			if strings.HasSuffix(c.Func.Raw, "TestRecoverHandler.func1") {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Fatal("the handler is not in the stack")
+	}
+	var b bytes.Buffer
+	if err := WriteReport(&b, got); err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Request map[string]string `json:"request"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Request["requestId"] != "abc" || v.Request["remoteAddr"] != "192.0.2.0" {
+		t.Fatalf("unexpected JSON %v", v.Request)
+	}
+}
+
+func TestRecoverHandlerAbort(t *testing.T) {
+	called := false
+	h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}), &RecoverOptions{OnPanic: func(r *Report) { called = true }})
+	defer func() {
+		if recover() != http.ErrAbortHandler || called {
+			t.Fatal("ErrAbortHandler must be panicked again without being reported")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestPanicValue(t *testing.T) {
+	data := []struct {
+		v    interface{}
+		kind stack.PanicValueKind
+		typ  string
+		msg  string
+	}{
+		{"oh no", stack.PanicText, "string", "oh no"},
+		{42, stack.PanicText, "int", "42"},
+		{customPanic{Code: 5}, stack.PanicCustom, "lib.customPanic", "lib.customPanic{Code:5}"},
+		{&customPanic{Code: 5}, stack.PanicCustom, "*lib.customPanic", "&lib.customPanic{Code:5}"},
+	}
+	for i, line := range data {
+		p := panicValue(line.v)
+		if p.Kind != line.kind || p.Type != line.typ || p.Message != line.msg {
+			t.Errorf("#%d: unexpected %#v", i, p)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	data := []struct {
+		in   string
+		keep bool
+		want string
+	}{
+		{"192.0.2.17:4321", false, "192.0.2.0"},
+		{"192.0.2.17:4321", true, "192.0.2.17"},
+		{"[2001:db8:1:2::3]:80", false, "2001:db8:1::"},
+		{"@", false, ""},
+	}
+	for i, line := range data {
+		if got := remoteIP(line.in, line.keep); got != line.want {
+			t.Errorf("#%d: remoteIP(%q) = %q, want %q", i, line.in, got, line.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Reloader holds a configuration loaded from a file and reloads it without
+// restarting the process, for long running servers and agents, e.g. the
+// quotas of server.Server.
+//
+// The configuration is reloaded on SIGHUP and when the file changes, see
+// Watch. A configuration that fails to load is ignored and the previous one
+// is kept, so a typo doesn't take the service down.
+type Reloader struct {
+	path string
+	load func(b []byte) (interface{}, error)
+
+	mu   sync.Mutex
+	cur  atomic.Value
+	stat fileStat
+}
+
+// NewReloader loads the file at path with load and returns a Reloader holding
+// the result.
+//
+// load is called with the content of the file and returns the configuration,
+// e.g. ParseIgnoreFile wrapped to accept bytes.
+func NewReloader(path string, load func(b []byte) (interface{}, error)) (*Reloader, error) {
+	r := &Reloader{path: path, load: load}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns the current configuration. It is safe to call concurrently
+// with Reload.
+func (r *Reloader) Config() interface{} {
+	return r.cur.Load().(*reloaded).v
+}
+
+// Reload loads the file again. On error, the current configuration is kept
+// and Watch tries again at the next interval.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := statFile(r.path)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	v, err := r.load(b)
+	if err != nil {
+		return err
+	}
+	r.cur.Store(&reloaded{v: v})
+	r.stat = st
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP and when the modification time
+// or the size of the file changes, checked every interval, until ctx is
+// done. interval defaults to 2s.
+//
+// The reload errors are passed to onError, which can be nil.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		reload := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload = true
+		case <-t.C:
+			st, err := statFile(r.path)
+			r.mu.Lock()
+			reload = err == nil && st != r.stat
+			r.mu.Unlock()
+		}
+		if reload {
+			if err := r.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// LoadIgnoreRules is a loader for NewReloader of a file in the IgnoreFileName
+// format. The configuration is a []IgnoreRule.
+func LoadIgnoreRules(b []byte) (interface{}, error) {
+	return ParseIgnoreFile(bytes.NewReader(b))
+}
+
+// Private stuff.
+
+// reloaded wraps the configuration so atomic.Value always stores the same
+// type.
+type reloaded struct {
+	v interface{}
+}
+
+// fileStat is the metadata used to detect a file change.
+type fileStat struct {
+	mod  time.Time
+	size int64
+}
+
+func statFile(path string) (fileStat, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{mod: fi.ModTime(), size: fi.Size()}, nil
+}
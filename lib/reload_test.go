@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, IgnoreFileName)
+	if err := ioutil.WriteFile(p, []byte("hide ^main\\.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(p, LoadIgnoreRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := r.Config().([]IgnoreRule); len(rules) != 1 {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+
+	// An invalid file keeps the previous configuration.
+	if err := ioutil.WriteFile(p, []byte("drop foo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected error")
+	}
+	if rules := r.Config().([]IgnoreRule); len(rules) != 1 {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+
+	// A change is picked up by Watch.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Watch(ctx, time.Millisecond, nil)
+	}()
+	if err := ioutil.WriteFile(p, []byte("hide ^main\\.\ncollapse ^foo\\.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(p, future, future); err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); len(r.Config().([]IgnoreRule)) != 2; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("configuration not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestReloaderRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, IgnoreFileName)
+	if err := ioutil.WriteFile(p, []byte("hide ^main\\.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(p, LoadIgnoreRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file is fixed without changing its size nor its modification time,
+	// e.g. within the resolution of the file system.
+	mtime := time.Now().Add(time.Hour)
+	for i, content := range []string{"drop 12345678\n", "hide a\nhide b\n"} {
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := r.Reload(); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Watch(ctx, time.Millisecond, nil)
+	}()
+	for start := time.Now(); len(r.Config().([]IgnoreRule)) != 2; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("configuration not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestNewReloaderError(t *testing.T) {
+	if _, err := NewReloader(filepath.Join("does", "not", "exist"), LoadIgnoreRules); err == nil {
+		t.Fatal("expected error")
+	}
+}
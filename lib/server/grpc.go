@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Tchinmai7/panicparse/lib/server/pb"
+)
+
+// RegisterGRPC registers s as the Parse service of g, e.g. a *grpc.Server.
+//
+// The client is identified for the quotas by the ClientHeader metadata key.
+// A rejected request fails with codes.ResourceExhausted and an Error message
+// in the status details. Quota.MaxBytes also limits the streams; limit the
+// size of the unary requests of the other clients with grpc.MaxRecvMsgSize.
+func RegisterGRPC(g grpc.ServiceRegistrar, s *Server) {
+	pb.RegisterParseServer(g, &grpcServer{s: s})
+}
+
+// Private stuff.
+
+// grpcServer adapts Server to pb.ParseServer.
+type grpcServer struct {
+	pb.UnimplementedParseServer
+	s *Server
+}
+
+func (g *grpcServer) ParseDump(ctx context.Context, req *pb.ParseDumpRequest) (*pb.ParseDumpResponse, error) {
+	q, err := g.s.allowGRPC(ctx, int64(len(req.Dump)))
+	if err != nil {
+		return nil, err
+	}
+	start := g.s.clock().Now()
+	resp, err := g.s.ParseDump(ctx, &ParseDumpRequest{Dump: req.Dump})
+	if err = g.s.finishGRPC(q, "ParseDump", start, resp, err); err != nil {
+		return nil, err
+	}
+	out := &pb.ParseDumpResponse{Crash: resp.Crash, Segments: resp.Segments}
+	for _, r := range resp.Goroutines {
+		out.Goroutines = append(out.Goroutines, toPBGoroutine(r))
+	}
+	return out, nil
+}
+
+func (g *grpcServer) ParseAndAggregate(ctx context.Context, req *pb.ParseAndAggregateRequest) (*pb.ParseAndAggregateResponse, error) {
+	q, err := g.s.allowGRPC(ctx, int64(len(req.Dump)))
+	if err != nil {
+		return nil, err
+	}
+	start := g.s.clock().Now()
+	resp, err := g.s.ParseAndAggregate(ctx, &ParseAndAggregateRequest{Dump: req.Dump, Similarity: Similarity(req.Similarity)})
+	if err = g.s.finishGRPC(q, "ParseAndAggregate", start, resp, err); err != nil {
+		return nil, err
+	}
+	return toPBAggregate(resp), nil
+}
+
+func (g *grpcServer) Explain(ctx context.Context, req *pb.ExplainRequest) (*pb.ExplainResponse, error) {
+	q, err := g.s.allowGRPC(ctx, int64(len(req.Dump)))
+	if err != nil {
+		return nil, err
+	}
+	start := g.s.clock().Now()
+	resp, err := g.s.Explain(ctx, &ExplainRequest{Dump: req.Dump})
+	if err = g.s.finishGRPC(q, "Explain", start, resp, err); err != nil {
+		return nil, err
+	}
+	out := &pb.ExplainResponse{Crash: resp.Crash, Deadlock: resp.Deadlock}
+	for _, f := range resp.Frames {
+		out.Frames = append(out.Frames, &pb.Frame{Func: f.Func, Location: f.Location, Args: f.Args, Kind: f.Kind, Url: f.URL})
+	}
+	for _, f := range resp.Findings {
+		ids := make([]int64, len(f.IDs))
+		for i, id := range f.IDs {
+			ids[i] = int64(id)
+		}
+		out.Findings = append(out.Findings, &pb.Finding{Analyzer: f.Analyzer, Ids: ids, Message: f.Message, Severity: f.Severity, Confidence: f.Confidence})
+	}
+	return out, nil
+}
+
+func (g *grpcServer) ParseDumpStream(stream pb.Parse_ParseDumpStreamServer) error {
+	ctx := stream.Context()
+	q, err := g.s.allowGRPC(ctx, 0)
+	if err != nil {
+		return err
+	}
+	r, lr := g.s.streamReader(q, &chunkReader{recv: stream.Recv})
+	start := g.s.clock().Now()
+	err = g.s.ParseDumpStream(ctx, r, func(r *Goroutine) error {
+		return stream.Send(toPBGoroutine(r))
+	})
+	if lr != nil && lr.exceeded {
+		e := tooLarge(q)
+		g.s.m.observe("ParseDumpStream", g.s.since(start), err)
+		g.s.m.reject(e)
+		return e.grpcStatus()
+	}
+	return g.s.finishGRPC(q, "ParseDumpStream", start, nil, err)
+}
+
+func (g *grpcServer) ParseAndAggregateStream(stream pb.Parse_ParseAndAggregateStreamServer) error {
+	ctx := stream.Context()
+	q, err := g.s.allowGRPC(ctx, 0)
+	if err != nil {
+		return err
+	}
+	// The similarity is in the first chunk.
+	cr := &chunkReader{recv: stream.Recv}
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	sim := ExactFlags
+	if first != nil {
+		sim = Similarity(first.Similarity)
+		cr.buf = first.Data
+	} else {
+		cr.recv = func() (*pb.DumpChunk, error) { return nil, io.EOF }
+	}
+	r, lr := g.s.streamReader(q, cr)
+	start := g.s.clock().Now()
+	resp, err := g.s.ParseAndAggregateStream(ctx, r, sim)
+	if lr != nil && lr.exceeded {
+		e := tooLarge(q)
+		g.s.m.observe("ParseAndAggregateStream", g.s.since(start), err)
+		g.s.m.reject(e)
+		return e.grpcStatus()
+	}
+	if err = g.s.finishGRPC(q, "ParseAndAggregateStream", start, resp, err); err != nil {
+		return err
+	}
+	return stream.SendAndClose(toPBAggregate(resp))
+}
+
+// allowGRPC counts the request against the quota of the client. size is the
+// size of a unary request.
+func (s *Server) allowGRPC(ctx context.Context, size int64) (*Quota, error) {
+	k := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(s.clientHeader()); len(v) != 0 {
+			k = v[0]
+		}
+	}
+	q := s.quotaOf(k)
+	if q == nil {
+		return nil, nil
+	}
+	e := s.q.allow(q, s.clock().Now())
+	if e == nil && q.MaxBytes > 0 && size > q.MaxBytes {
+		e = tooLarge(q)
+	}
+	if e != nil {
+		s.m.reject(e)
+		return nil, e.grpcStatus()
+	}
+	return q, nil
+}
+
+// streamReader limits r to the quota of the client, if any.
+func (s *Server) streamReader(q *Quota, r io.Reader) (io.Reader, *limitReader) {
+	if q == nil || q.MaxBytes <= 0 {
+		return r, nil
+	}
+	lr := &limitReader{ReadCloser: ioutil.NopCloser(r), n: q.MaxBytes}
+	return lr, lr
+}
+
+// finishGRPC records the request in the metrics and in the audit log, and
+// converts the error to a status.
+func (s *Server) finishGRPC(q *Quota, method string, start time.Time, resp interface{}, err error) error {
+	s.m.observe(method, s.since(start), err)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.audit(q, method, resp); err != nil {
+		return status.Error(codes.Internal, "audit log failure")
+	}
+	return nil
+}
+
+// grpcStatus returns the status of the rejected request, with e in the
+// details.
+func (e *Error) grpcStatus() error {
+	st := status.New(codes.ResourceExhausted, e.Message)
+	if d, err := st.WithDetails(&pb.Error{Code: e.Code, Reason: e.Reason, Message: e.Message, Client: e.Client, RetryAfterSeconds: int32(e.RetryAfterSeconds)}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// chunkReader reads the data of the chunks of a stream.
+type chunkReader struct {
+	recv func() (*pb.DumpChunk, error)
+	buf  []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		m, err := c.recv()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = m.Data
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func toPBCall(c *Call) *pb.Call {
+	if c == nil {
+		return nil
+	}
+	return &pb.Call{Func: c.Func, SrcPath: c.SrcPath, Line: int32(c.Line), Args: c.Args}
+}
+
+func toPBCalls(calls []Call) []*pb.Call {
+	out := make([]*pb.Call, len(calls))
+	for i := range calls {
+		out[i] = toPBCall(&calls[i])
+	}
+	return out
+}
+
+func toPBGoroutine(g *Goroutine) *pb.Goroutine {
+	return &pb.Goroutine{
+		Id:        int32(g.ID),
+		State:     g.State,
+		First:     g.First,
+		Calls:     toPBCalls(g.Calls),
+		Elided:    g.Elided,
+		CreatedBy: toPBCall(g.CreatedBy),
+		SleepMin:  int32(g.SleepMin),
+		SleepMax:  int32(g.SleepMax),
+		Locked:    g.Locked,
+	}
+}
+
+func toPBAggregate(r *ParseAndAggregateResponse) *pb.ParseAndAggregateResponse {
+	out := &pb.ParseAndAggregateResponse{Crash: r.Crash, Goroutines: int32(r.Goroutines)}
+	for _, b := range r.Buckets {
+		ids := make([]int32, len(b.IDs))
+		for i, id := range b.IDs {
+			ids[i] = int32(id)
+		}
+		out.Buckets = append(out.Buckets, &pb.Bucket{
+			Ids:         ids,
+			State:       b.State,
+			First:       b.First,
+			Calls:       toPBCalls(b.Calls),
+			Elided:      b.Elided,
+			CreatedBy:   toPBCall(b.CreatedBy),
+			SleepMin:    int32(b.SleepMin),
+			SleepMax:    int32(b.SleepMax),
+			Locked:      b.Locked,
+			Fingerprint: b.Fingerprint,
+		})
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Tchinmai7/panicparse/lib/server/pb"
+)
+
+func TestGRPC(t *testing.T) {
+	c := newGRPCClient(t, &Server{})
+	ctx := context.Background()
+
+	pd, err := c.ParseDump(ctx, &pb.ParseDumpRequest{Dump: dump})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.Crash != "panic" || len(pd.Goroutines) != 3 || pd.Goroutines[1].CreatedBy == nil || pd.Goroutines[1].Calls[0].Args != "#1" {
+		t.Fatalf("unexpected response: %v", pd)
+	}
+
+	pa, err := c.ParseAndAggregate(ctx, &pb.ParseAndAggregateRequest{Dump: dump, Similarity: pb.Similarity_ANY_POINTER})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa.Goroutines != 3 || len(pa.Buckets) != 2 || len(pa.Buckets[1].Ids) != 2 || pa.Buckets[1].Fingerprint == "" {
+		t.Fatalf("unexpected response: %v", pa)
+	}
+
+	ex, err := c.Explain(ctx, &pb.ExplainRequest{Dump: dump})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Crash != "panic" || len(ex.Frames) != 1 || ex.Frames[0].Kind != "main" {
+		t.Fatalf("unexpected response: %v", ex)
+	}
+
+	_, err = c.ParseAndAggregate(ctx, &pb.ParseAndAggregateRequest{Dump: dump, Similarity: 42})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGRPCStream(t *testing.T) {
+	c := newGRPCClient(t, &Server{})
+	ctx := context.Background()
+
+	// Split the dump in the middle of a line.
+	chunks := []string{dump[:30], dump[30:100], dump[100:]}
+	ds, err := c.ParseDumpStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range chunks {
+		if err := ds.Send(&pb.DumpChunk{Data: []byte(d)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int32
+	for {
+		g, err := ds.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, g.Id)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 7 {
+		t.Fatalf("unexpected goroutines: %v", ids)
+	}
+
+	as, err := c.ParseAndAggregateStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range chunks {
+		m := &pb.DumpChunk{Data: []byte(d)}
+		if i == 0 {
+			m.Similarity = pb.Similarity_ANY_POINTER
+		}
+		if err := as.Send(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pa, err := as.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa.Goroutines != 3 || len(pa.Buckets) != 2 {
+		t.Fatalf("unexpected response: %v", pa)
+	}
+}
+
+func TestGRPCQuota(t *testing.T) {
+	srv := &Server{
+		Quotas:       map[string]Quota{"secret": {Name: "team-a", Requests: 1, Window: time.Minute}},
+		DefaultQuota: &Quota{MaxBytes: 64},
+	}
+	c := newGRPCClient(t, srv)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret")
+
+	if _, err := c.ParseDump(ctx, &pb.ParseDumpRequest{Dump: dump}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.ParseDump(ctx, &pb.ParseDumpRequest{Dump: dump})
+	if e := grpcError(t, err); e.Reason != ReasonRateLimited || e.Client != "team-a" || e.RetryAfterSeconds != 60 {
+		t.Fatalf("unexpected %#v", e)
+	}
+
+	_, err = c.ParseDump(context.Background(), &pb.ParseDumpRequest{Dump: dump})
+	if e := grpcError(t, err); e.Reason != ReasonTooLarge || e.Client != "default" {
+		t.Fatalf("unexpected %#v", e)
+	}
+
+	as, err := c.ParseAndAggregateStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := as.Send(&pb.DumpChunk{Data: []byte(dump)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = as.CloseAndRecv()
+	if e := grpcError(t, err); e.Reason != ReasonTooLarge || e.Client != "default" {
+		t.Fatalf("unexpected %#v", e)
+	}
+}
+
+// newGRPCClient serves s over an in-memory connection.
+func newGRPCClient(t *testing.T, s *Server) pb.ParseClient {
+	l := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	RegisterGRPC(g, s)
+	go g.Serve(l)
+	t.Cleanup(g.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewParseClient(conn)
+}
+
+// grpcError returns the Error in the details of the status of a rejected
+// request.
+func grpcError(t *testing.T, err error) *pb.Error {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, d := range st.Details() {
+		if e, ok := d.(*pb.Error); ok {
+			return e
+		}
+	}
+	t.Fatalf("no Error in %v", st.Details())
+	return nil
+}
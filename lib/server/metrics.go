@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Private stuff.
+
+// latencyBuckets is the upper bounds in seconds of the parse latency
+// histogram buckets.
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// metrics is the counters exported at /metrics.
+//
+// The zero value is ready to use.
+type metrics struct {
+	mu      sync.Mutex
+	methods map[string]*methodMetrics
+	// rejections is the number of requests rejected by a quota, by client
+	// and reason.
+	rejections map[[2]string]uint64
+	// auditFailures is the number of requests that failed to be recorded in
+	// the audit log, by method.
+	auditFailures map[string]uint64
+}
+
+// methodMetrics is the counters of a single method.
+type methodMetrics struct {
+	requests uint64
+	errors   uint64
+	// buckets is the number of requests per latencyBuckets. The counts are not
+	// cumulative, write() accumulates them.
+	buckets []uint64
+	sum     float64
+}
+
+// observe records a request of method that took d.
+func (m *metrics) observe(method string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.methods == nil {
+		m.methods = map[string]*methodMetrics{}
+	}
+	mm := m.methods[method]
+	if mm == nil {
+		mm = &methodMetrics{buckets: make([]uint64, len(latencyBuckets)+1)}
+		m.methods[method] = mm
+	}
+	mm.requests++
+	if err != nil {
+		mm.errors++
+	}
+	s := d.Seconds()
+	mm.sum += s
+	mm.buckets[sort.SearchFloat64s(latencyBuckets, s)]++
+}
+
+// reject records a request rejected by a quota.
+func (m *metrics) reject(e *Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rejections == nil {
+		m.rejections = map[[2]string]uint64{}
+	}
+	m.rejections[[2]string{e.Client, e.Reason}]++
+}
+
+// auditFailure records a request that failed to be recorded in the audit
+// log.
+func (m *metrics) auditFailure(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.auditFailures == nil {
+		m.auditFailures = map[string]uint64{}
+	}
+	m.auditFailures[method]++
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer, corpusVersion string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.methods))
+	for n := range m.methods {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	ew := &errWriter{w: w}
+	ew.printf("# HELP panicparse_requests_total Number of parse requests.\n# TYPE panicparse_requests_total counter\n")
+	for _, n := range names {
+		ew.printf("panicparse_requests_total{method=%q} %d\n", n, m.methods[n].requests)
+	}
+	ew.printf("# HELP panicparse_errors_total Number of failed parse requests.\n# TYPE panicparse_errors_total counter\n")
+	for _, n := range names {
+		ew.printf("panicparse_errors_total{method=%q} %d\n", n, m.methods[n].errors)
+	}
+	ew.printf("# HELP panicparse_parse_duration_seconds Parse request latency.\n# TYPE panicparse_parse_duration_seconds histogram\n")
+	for _, n := range names {
+		mm := m.methods[n]
+		var c uint64
+		for i, b := range latencyBuckets {
+			c += mm.buckets[i]
+			ew.printf("panicparse_parse_duration_seconds_bucket{method=%q,le=%q} %d\n", n, strconv.FormatFloat(b, 'g', -1, 64), c)
+		}
+		ew.printf("panicparse_parse_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", n, mm.requests)
+		ew.printf("panicparse_parse_duration_seconds_sum{method=%q} %g\n", n, mm.sum)
+		ew.printf("panicparse_parse_duration_seconds_count{method=%q} %d\n", n, mm.requests)
+	}
+	rejected := make([][2]string, 0, len(m.rejections))
+	for k := range m.rejections {
+		rejected = append(rejected, k)
+	}
+	sort.Slice(rejected, func(i, j int) bool {
+		if rejected[i][0] != rejected[j][0] {
+			return rejected[i][0] < rejected[j][0]
+		}
+		return rejected[i][1] < rejected[j][1]
+	})
+	ew.printf("# HELP panicparse_rejections_total Number of requests rejected by a quota.\n# TYPE panicparse_rejections_total counter\n")
+	for _, k := range rejected {
+		ew.printf("panicparse_rejections_total{client=%q,reason=%q} %d\n", k[0], k[1], m.rejections[k])
+	}
+	failed := make([]string, 0, len(m.auditFailures))
+	for n := range m.auditFailures {
+		failed = append(failed, n)
+	}
+	sort.Strings(failed)
+	ew.printf("# HELP panicparse_audit_failures_total Number of requests that failed to be recorded in the audit log.\n# TYPE panicparse_audit_failures_total counter\n")
+	for _, n := range failed {
+		ew.printf("panicparse_audit_failures_total{method=%q} %d\n", n, m.auditFailures[n])
+	}
+	ew.printf("# HELP panicparse_corpus_info Version of the corpus of known signatures.\n# TYPE panicparse_corpus_info gauge\n")
+	ew.printf("panicparse_corpus_info{version=%q} 1\n", corpusVersion)
+	return ew.err
+}
+
+// errWriter keeps the first write error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, a ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, a...)
+	}
+}
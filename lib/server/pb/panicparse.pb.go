@@ -0,0 +1,1184 @@
+// Parse is panicparse as a service.
+//
+// The reference implementation in package server serves the methods over
+// gRPC, see RegisterGRPC, and over HTTP with the protobuf JSON mapping at
+// "/panicparse.v1.Parse/<Method>". The Go bindings in package pb are
+// generated from the root of the repository with:
+//
+//   protoc --go_out=. --go_opt=module=github.com/Tchinmai7/panicparse \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/Tchinmai7/panicparse \
+//     lib/server/panicparse.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: lib/server/panicparse.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Similarity int32
+
+const (
+	Similarity_EXACT_FLAGS Similarity = 0
+	Similarity_EXACT_LINES Similarity = 1
+	Similarity_ANY_POINTER Similarity = 2
+	Similarity_ANY_VALUE   Similarity = 3
+)
+
+// Enum value maps for Similarity.
+var (
+	Similarity_name = map[int32]string{
+		0: "EXACT_FLAGS",
+		1: "EXACT_LINES",
+		2: "ANY_POINTER",
+		3: "ANY_VALUE",
+	}
+	Similarity_value = map[string]int32{
+		"EXACT_FLAGS": 0,
+		"EXACT_LINES": 1,
+		"ANY_POINTER": 2,
+		"ANY_VALUE":   3,
+	}
+)
+
+func (x Similarity) Enum() *Similarity {
+	p := new(Similarity)
+	*p = x
+	return p
+}
+
+func (x Similarity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Similarity) Descriptor() protoreflect.EnumDescriptor {
+	return file_lib_server_panicparse_proto_enumTypes[0].Descriptor()
+}
+
+func (Similarity) Type() protoreflect.EnumType {
+	return &file_lib_server_panicparse_proto_enumTypes[0]
+}
+
+func (x Similarity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Similarity.Descriptor instead.
+func (Similarity) EnumDescriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{0}
+}
+
+type Call struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Func          string                 `protobuf:"bytes,1,opt,name=func,proto3" json:"func,omitempty"`
+	SrcPath       string                 `protobuf:"bytes,2,opt,name=src_path,json=srcPath,proto3" json:"src_path,omitempty"`
+	Line          int32                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Args          string                 `protobuf:"bytes,4,opt,name=args,proto3" json:"args,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Call) Reset() {
+	*x = Call{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Call) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Call) ProtoMessage() {}
+
+func (x *Call) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Call.ProtoReflect.Descriptor instead.
+func (*Call) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Call) GetFunc() string {
+	if x != nil {
+		return x.Func
+	}
+	return ""
+}
+
+func (x *Call) GetSrcPath() string {
+	if x != nil {
+		return x.SrcPath
+	}
+	return ""
+}
+
+func (x *Call) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Call) GetArgs() string {
+	if x != nil {
+		return x.Args
+	}
+	return ""
+}
+
+type Goroutine struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	State string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	// first is true for the goroutine that printed the dump.
+	First         bool    `protobuf:"varint,3,opt,name=first,proto3" json:"first,omitempty"`
+	Calls         []*Call `protobuf:"bytes,4,rep,name=calls,proto3" json:"calls,omitempty"`
+	Elided        bool    `protobuf:"varint,5,opt,name=elided,proto3" json:"elided,omitempty"`
+	CreatedBy     *Call   `protobuf:"bytes,6,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"`
+	SleepMin      int32   `protobuf:"varint,7,opt,name=sleep_min,json=sleepMin,proto3" json:"sleep_min,omitempty"`
+	SleepMax      int32   `protobuf:"varint,8,opt,name=sleep_max,json=sleepMax,proto3" json:"sleep_max,omitempty"`
+	Locked        bool    `protobuf:"varint,9,opt,name=locked,proto3" json:"locked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Goroutine) Reset() {
+	*x = Goroutine{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Goroutine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Goroutine) ProtoMessage() {}
+
+func (x *Goroutine) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Goroutine.ProtoReflect.Descriptor instead.
+func (*Goroutine) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Goroutine) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Goroutine) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Goroutine) GetFirst() bool {
+	if x != nil {
+		return x.First
+	}
+	return false
+}
+
+func (x *Goroutine) GetCalls() []*Call {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+func (x *Goroutine) GetElided() bool {
+	if x != nil {
+		return x.Elided
+	}
+	return false
+}
+
+func (x *Goroutine) GetCreatedBy() *Call {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return nil
+}
+
+func (x *Goroutine) GetSleepMin() int32 {
+	if x != nil {
+		return x.SleepMin
+	}
+	return 0
+}
+
+func (x *Goroutine) GetSleepMax() int32 {
+	if x != nil {
+		return x.SleepMax
+	}
+	return 0
+}
+
+func (x *Goroutine) GetLocked() bool {
+	if x != nil {
+		return x.Locked
+	}
+	return false
+}
+
+type Bucket struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Ids       []int32                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	State     string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	First     bool                   `protobuf:"varint,3,opt,name=first,proto3" json:"first,omitempty"`
+	Calls     []*Call                `protobuf:"bytes,4,rep,name=calls,proto3" json:"calls,omitempty"`
+	Elided    bool                   `protobuf:"varint,5,opt,name=elided,proto3" json:"elided,omitempty"`
+	CreatedBy *Call                  `protobuf:"bytes,6,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"`
+	SleepMin  int32                  `protobuf:"varint,7,opt,name=sleep_min,json=sleepMin,proto3" json:"sleep_min,omitempty"`
+	SleepMax  int32                  `protobuf:"varint,8,opt,name=sleep_max,json=sleepMax,proto3" json:"sleep_max,omitempty"`
+	Locked    bool                   `protobuf:"varint,9,opt,name=locked,proto3" json:"locked,omitempty"`
+	// fingerprint is the stable identifier of the signature.
+	Fingerprint   string `protobuf:"bytes,10,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Bucket) Reset() {
+	*x = Bucket{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Bucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Bucket) ProtoMessage() {}
+
+func (x *Bucket) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Bucket.ProtoReflect.Descriptor instead.
+func (*Bucket) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Bucket) GetIds() []int32 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *Bucket) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Bucket) GetFirst() bool {
+	if x != nil {
+		return x.First
+	}
+	return false
+}
+
+func (x *Bucket) GetCalls() []*Call {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+func (x *Bucket) GetElided() bool {
+	if x != nil {
+		return x.Elided
+	}
+	return false
+}
+
+func (x *Bucket) GetCreatedBy() *Call {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return nil
+}
+
+func (x *Bucket) GetSleepMin() int32 {
+	if x != nil {
+		return x.SleepMin
+	}
+	return 0
+}
+
+func (x *Bucket) GetSleepMax() int32 {
+	if x != nil {
+		return x.SleepMax
+	}
+	return 0
+}
+
+func (x *Bucket) GetLocked() bool {
+	if x != nil {
+		return x.Locked
+	}
+	return false
+}
+
+func (x *Bucket) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+type ParseDumpRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dump          string                 `protobuf:"bytes,1,opt,name=dump,proto3" json:"dump,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseDumpRequest) Reset() {
+	*x = ParseDumpRequest{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseDumpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseDumpRequest) ProtoMessage() {}
+
+func (x *ParseDumpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseDumpRequest.ProtoReflect.Descriptor instead.
+func (*ParseDumpRequest) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ParseDumpRequest) GetDump() string {
+	if x != nil {
+		return x.Dump
+	}
+	return ""
+}
+
+type ParseDumpResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// crash is why the dump was printed, e.g. "panic".
+	Crash      string       `protobuf:"bytes,1,opt,name=crash,proto3" json:"crash,omitempty"`
+	Goroutines []*Goroutine `protobuf:"bytes,2,rep,name=goroutines,proto3" json:"goroutines,omitempty"`
+	// segments is the text that is not part of a goroutine.
+	Segments      []string `protobuf:"bytes,3,rep,name=segments,proto3" json:"segments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseDumpResponse) Reset() {
+	*x = ParseDumpResponse{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseDumpResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseDumpResponse) ProtoMessage() {}
+
+func (x *ParseDumpResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseDumpResponse.ProtoReflect.Descriptor instead.
+func (*ParseDumpResponse) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ParseDumpResponse) GetCrash() string {
+	if x != nil {
+		return x.Crash
+	}
+	return ""
+}
+
+func (x *ParseDumpResponse) GetGoroutines() []*Goroutine {
+	if x != nil {
+		return x.Goroutines
+	}
+	return nil
+}
+
+func (x *ParseDumpResponse) GetSegments() []string {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+type ParseAndAggregateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dump          string                 `protobuf:"bytes,1,opt,name=dump,proto3" json:"dump,omitempty"`
+	Similarity    Similarity             `protobuf:"varint,2,opt,name=similarity,proto3,enum=panicparse.v1.Similarity" json:"similarity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseAndAggregateRequest) Reset() {
+	*x = ParseAndAggregateRequest{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseAndAggregateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseAndAggregateRequest) ProtoMessage() {}
+
+func (x *ParseAndAggregateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseAndAggregateRequest.ProtoReflect.Descriptor instead.
+func (*ParseAndAggregateRequest) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ParseAndAggregateRequest) GetDump() string {
+	if x != nil {
+		return x.Dump
+	}
+	return ""
+}
+
+func (x *ParseAndAggregateRequest) GetSimilarity() Similarity {
+	if x != nil {
+		return x.Similarity
+	}
+	return Similarity_EXACT_FLAGS
+}
+
+type ParseAndAggregateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Crash string                 `protobuf:"bytes,1,opt,name=crash,proto3" json:"crash,omitempty"`
+	// goroutines is the total number of goroutines.
+	Goroutines    int32     `protobuf:"varint,2,opt,name=goroutines,proto3" json:"goroutines,omitempty"`
+	Buckets       []*Bucket `protobuf:"bytes,3,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseAndAggregateResponse) Reset() {
+	*x = ParseAndAggregateResponse{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseAndAggregateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseAndAggregateResponse) ProtoMessage() {}
+
+func (x *ParseAndAggregateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseAndAggregateResponse.ProtoReflect.Descriptor instead.
+func (*ParseAndAggregateResponse) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ParseAndAggregateResponse) GetCrash() string {
+	if x != nil {
+		return x.Crash
+	}
+	return ""
+}
+
+func (x *ParseAndAggregateResponse) GetGoroutines() int32 {
+	if x != nil {
+		return x.Goroutines
+	}
+	return 0
+}
+
+func (x *ParseAndAggregateResponse) GetBuckets() []*Bucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type ExplainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dump          string                 `protobuf:"bytes,1,opt,name=dump,proto3" json:"dump,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainRequest) Reset() {
+	*x = ExplainRequest{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainRequest) ProtoMessage() {}
+
+func (x *ExplainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainRequest.ProtoReflect.Descriptor instead.
+func (*ExplainRequest) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ExplainRequest) GetDump() string {
+	if x != nil {
+		return x.Dump
+	}
+	return ""
+}
+
+type Frame struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Func     string                 `protobuf:"bytes,1,opt,name=func,proto3" json:"func,omitempty"`
+	Location string                 `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	Args     string                 `protobuf:"bytes,3,opt,name=args,proto3" json:"args,omitempty"`
+	// kind is "runtime", "stdlib", "main" or "third-party".
+	Kind          string `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	Url           string `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Frame) GetFunc() string {
+	if x != nil {
+		return x.Func
+	}
+	return ""
+}
+
+func (x *Frame) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Frame) GetArgs() string {
+	if x != nil {
+		return x.Args
+	}
+	return ""
+}
+
+func (x *Frame) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Frame) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type ExplainResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Crash string                 `protobuf:"bytes,1,opt,name=crash,proto3" json:"crash,omitempty"`
+	// deadlock is set when the runtime detected a deadlock.
+	Deadlock string `protobuf:"bytes,2,opt,name=deadlock,proto3" json:"deadlock,omitempty"`
+	// frames is the call stack of the goroutine that printed the dump.
+	Frames []*Frame `protobuf:"bytes,3,rep,name=frames,proto3" json:"frames,omitempty"`
+	// findings is the problems found by the registered analyzers, the most
+	// severe first.
+	Findings      []*Finding `protobuf:"bytes,4,rep,name=findings,proto3" json:"findings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainResponse) Reset() {
+	*x = ExplainResponse{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainResponse) ProtoMessage() {}
+
+func (x *ExplainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainResponse.ProtoReflect.Descriptor instead.
+func (*ExplainResponse) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExplainResponse) GetCrash() string {
+	if x != nil {
+		return x.Crash
+	}
+	return ""
+}
+
+func (x *ExplainResponse) GetDeadlock() string {
+	if x != nil {
+		return x.Deadlock
+	}
+	return ""
+}
+
+func (x *ExplainResponse) GetFrames() []*Frame {
+	if x != nil {
+		return x.Frames
+	}
+	return nil
+}
+
+func (x *ExplainResponse) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+type Finding struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Analyzer string                 `protobuf:"bytes,1,opt,name=analyzer,proto3" json:"analyzer,omitempty"`
+	Ids      []int64                `protobuf:"varint,2,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	Message  string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// severity is "info", "warning" or "critical".
+	Severity string `protobuf:"bytes,4,opt,name=severity,proto3" json:"severity,omitempty"`
+	// confidence is "low", "medium" or "high".
+	Confidence    string `protobuf:"bytes,5,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Finding) GetAnalyzer() string {
+	if x != nil {
+		return x.Analyzer
+	}
+	return ""
+}
+
+func (x *Finding) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *Finding) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Finding) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Finding) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+type DumpChunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Data  []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// similarity is only read from the first chunk.
+	Similarity    Similarity `protobuf:"varint,2,opt,name=similarity,proto3,enum=panicparse.v1.Similarity" json:"similarity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DumpChunk) Reset() {
+	*x = DumpChunk{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DumpChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumpChunk) ProtoMessage() {}
+
+func (x *DumpChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumpChunk.ProtoReflect.Descriptor instead.
+func (*DumpChunk) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DumpChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *DumpChunk) GetSimilarity() Similarity {
+	if x != nil {
+		return x.Similarity
+	}
+	return Similarity_EXACT_FLAGS
+}
+
+// Error is the body of a request rejected by a quota.
+type Error struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// code is the gRPC status code name, "RESOURCE_EXHAUSTED".
+	Code string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// reason is "RATE_LIMITED" or "TOO_LARGE".
+	Reason  string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// client is the name of the quota of the client.
+	Client string `protobuf:"bytes,4,opt,name=client,proto3" json:"client,omitempty"`
+	// retry_after_seconds is set when the request can be retried later.
+	RetryAfterSeconds int32 `protobuf:"varint,5,opt,name=retry_after_seconds,json=retryAfterSeconds,proto3" json:"retry_after_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_lib_server_panicparse_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_lib_server_panicparse_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_lib_server_panicparse_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *Error) GetRetryAfterSeconds() int32 {
+	if x != nil {
+		return x.RetryAfterSeconds
+	}
+	return 0
+}
+
+var File_lib_server_panicparse_proto protoreflect.FileDescriptor
+
+const file_lib_server_panicparse_proto_rawDesc = "" +
+	"\n" +
+	"\x1blib/server/panicparse.proto\x12\rpanicparse.v1\"]\n" +
+	"\x04Call\x12\x12\n" +
+	"\x04func\x18\x01 \x01(\tR\x04func\x12\x19\n" +
+	"\bsrc_path\x18\x02 \x01(\tR\asrcPath\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x12\n" +
+	"\x04args\x18\x04 \x01(\tR\x04args\"\x90\x02\n" +
+	"\tGoroutine\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x14\n" +
+	"\x05first\x18\x03 \x01(\bR\x05first\x12)\n" +
+	"\x05calls\x18\x04 \x03(\v2\x13.panicparse.v1.CallR\x05calls\x12\x16\n" +
+	"\x06elided\x18\x05 \x01(\bR\x06elided\x122\n" +
+	"\n" +
+	"created_by\x18\x06 \x01(\v2\x13.panicparse.v1.CallR\tcreatedBy\x12\x1b\n" +
+	"\tsleep_min\x18\a \x01(\x05R\bsleepMin\x12\x1b\n" +
+	"\tsleep_max\x18\b \x01(\x05R\bsleepMax\x12\x16\n" +
+	"\x06locked\x18\t \x01(\bR\x06locked\"\xb1\x02\n" +
+	"\x06Bucket\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x05R\x03ids\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x14\n" +
+	"\x05first\x18\x03 \x01(\bR\x05first\x12)\n" +
+	"\x05calls\x18\x04 \x03(\v2\x13.panicparse.v1.CallR\x05calls\x12\x16\n" +
+	"\x06elided\x18\x05 \x01(\bR\x06elided\x122\n" +
+	"\n" +
+	"created_by\x18\x06 \x01(\v2\x13.panicparse.v1.CallR\tcreatedBy\x12\x1b\n" +
+	"\tsleep_min\x18\a \x01(\x05R\bsleepMin\x12\x1b\n" +
+	"\tsleep_max\x18\b \x01(\x05R\bsleepMax\x12\x16\n" +
+	"\x06locked\x18\t \x01(\bR\x06locked\x12 \n" +
+	"\vfingerprint\x18\n" +
+	" \x01(\tR\vfingerprint\"&\n" +
+	"\x10ParseDumpRequest\x12\x12\n" +
+	"\x04dump\x18\x01 \x01(\tR\x04dump\"\x7f\n" +
+	"\x11ParseDumpResponse\x12\x14\n" +
+	"\x05crash\x18\x01 \x01(\tR\x05crash\x128\n" +
+	"\n" +
+	"goroutines\x18\x02 \x03(\v2\x18.panicparse.v1.GoroutineR\n" +
+	"goroutines\x12\x1a\n" +
+	"\bsegments\x18\x03 \x03(\tR\bsegments\"i\n" +
+	"\x18ParseAndAggregateRequest\x12\x12\n" +
+	"\x04dump\x18\x01 \x01(\tR\x04dump\x129\n" +
+	"\n" +
+	"similarity\x18\x02 \x01(\x0e2\x19.panicparse.v1.SimilarityR\n" +
+	"similarity\"\x82\x01\n" +
+	"\x19ParseAndAggregateResponse\x12\x14\n" +
+	"\x05crash\x18\x01 \x01(\tR\x05crash\x12\x1e\n" +
+	"\n" +
+	"goroutines\x18\x02 \x01(\x05R\n" +
+	"goroutines\x12/\n" +
+	"\abuckets\x18\x03 \x03(\v2\x15.panicparse.v1.BucketR\abuckets\"$\n" +
+	"\x0eExplainRequest\x12\x12\n" +
+	"\x04dump\x18\x01 \x01(\tR\x04dump\"q\n" +
+	"\x05Frame\x12\x12\n" +
+	"\x04func\x18\x01 \x01(\tR\x04func\x12\x1a\n" +
+	"\blocation\x18\x02 \x01(\tR\blocation\x12\x12\n" +
+	"\x04args\x18\x03 \x01(\tR\x04args\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\"\xa5\x01\n" +
+	"\x0fExplainResponse\x12\x14\n" +
+	"\x05crash\x18\x01 \x01(\tR\x05crash\x12\x1a\n" +
+	"\bdeadlock\x18\x02 \x01(\tR\bdeadlock\x12,\n" +
+	"\x06frames\x18\x03 \x03(\v2\x14.panicparse.v1.FrameR\x06frames\x122\n" +
+	"\bfindings\x18\x04 \x03(\v2\x16.panicparse.v1.FindingR\bfindings\"\x8d\x01\n" +
+	"\aFinding\x12\x1a\n" +
+	"\banalyzer\x18\x01 \x01(\tR\banalyzer\x12\x10\n" +
+	"\x03ids\x18\x02 \x03(\x03R\x03ids\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12\x1a\n" +
+	"\bseverity\x18\x04 \x01(\tR\bseverity\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x05 \x01(\tR\n" +
+	"confidence\"Z\n" +
+	"\tDumpChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x129\n" +
+	"\n" +
+	"similarity\x18\x02 \x01(\x0e2\x19.panicparse.v1.SimilarityR\n" +
+	"similarity\"\x95\x01\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12\x16\n" +
+	"\x06client\x18\x04 \x01(\tR\x06client\x12.\n" +
+	"\x13retry_after_seconds\x18\x05 \x01(\x05R\x11retryAfterSeconds*N\n" +
+	"\n" +
+	"Similarity\x12\x0f\n" +
+	"\vEXACT_FLAGS\x10\x00\x12\x0f\n" +
+	"\vEXACT_LINES\x10\x01\x12\x0f\n" +
+	"\vANY_POINTER\x10\x02\x12\r\n" +
+	"\tANY_VALUE\x10\x032\xb5\x03\n" +
+	"\x05Parse\x12N\n" +
+	"\tParseDump\x12\x1f.panicparse.v1.ParseDumpRequest\x1a .panicparse.v1.ParseDumpResponse\x12f\n" +
+	"\x11ParseAndAggregate\x12'.panicparse.v1.ParseAndAggregateRequest\x1a(.panicparse.v1.ParseAndAggregateResponse\x12H\n" +
+	"\aExplain\x12\x1d.panicparse.v1.ExplainRequest\x1a\x1e.panicparse.v1.ExplainResponse\x12I\n" +
+	"\x0fParseDumpStream\x12\x18.panicparse.v1.DumpChunk\x1a\x18.panicparse.v1.Goroutine(\x010\x01\x12_\n" +
+	"\x17ParseAndAggregateStream\x12\x18.panicparse.v1.DumpChunk\x1a(.panicparse.v1.ParseAndAggregateResponse(\x01B/Z-github.com/Tchinmai7/panicparse/lib/server/pbb\x06proto3"
+
+var (
+	file_lib_server_panicparse_proto_rawDescOnce sync.Once
+	file_lib_server_panicparse_proto_rawDescData []byte
+)
+
+func file_lib_server_panicparse_proto_rawDescGZIP() []byte {
+	file_lib_server_panicparse_proto_rawDescOnce.Do(func() {
+		file_lib_server_panicparse_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_lib_server_panicparse_proto_rawDesc), len(file_lib_server_panicparse_proto_rawDesc)))
+	})
+	return file_lib_server_panicparse_proto_rawDescData
+}
+
+var file_lib_server_panicparse_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_lib_server_panicparse_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_lib_server_panicparse_proto_goTypes = []any{
+	(Similarity)(0),                   // 0: panicparse.v1.Similarity
+	(*Call)(nil),                      // 1: panicparse.v1.Call
+	(*Goroutine)(nil),                 // 2: panicparse.v1.Goroutine
+	(*Bucket)(nil),                    // 3: panicparse.v1.Bucket
+	(*ParseDumpRequest)(nil),          // 4: panicparse.v1.ParseDumpRequest
+	(*ParseDumpResponse)(nil),         // 5: panicparse.v1.ParseDumpResponse
+	(*ParseAndAggregateRequest)(nil),  // 6: panicparse.v1.ParseAndAggregateRequest
+	(*ParseAndAggregateResponse)(nil), // 7: panicparse.v1.ParseAndAggregateResponse
+	(*ExplainRequest)(nil),            // 8: panicparse.v1.ExplainRequest
+	(*Frame)(nil),                     // 9: panicparse.v1.Frame
+	(*ExplainResponse)(nil),           // 10: panicparse.v1.ExplainResponse
+	(*Finding)(nil),                   // 11: panicparse.v1.Finding
+	(*DumpChunk)(nil),                 // 12: panicparse.v1.DumpChunk
+	(*Error)(nil),                     // 13: panicparse.v1.Error
+}
+var file_lib_server_panicparse_proto_depIdxs = []int32{
+	1,  // 0: panicparse.v1.Goroutine.calls:type_name -> panicparse.v1.Call
+	1,  // 1: panicparse.v1.Goroutine.created_by:type_name -> panicparse.v1.Call
+	1,  // 2: panicparse.v1.Bucket.calls:type_name -> panicparse.v1.Call
+	1,  // 3: panicparse.v1.Bucket.created_by:type_name -> panicparse.v1.Call
+	2,  // 4: panicparse.v1.ParseDumpResponse.goroutines:type_name -> panicparse.v1.Goroutine
+	0,  // 5: panicparse.v1.ParseAndAggregateRequest.similarity:type_name -> panicparse.v1.Similarity
+	3,  // 6: panicparse.v1.ParseAndAggregateResponse.buckets:type_name -> panicparse.v1.Bucket
+	9,  // 7: panicparse.v1.ExplainResponse.frames:type_name -> panicparse.v1.Frame
+	11, // 8: panicparse.v1.ExplainResponse.findings:type_name -> panicparse.v1.Finding
+	0,  // 9: panicparse.v1.DumpChunk.similarity:type_name -> panicparse.v1.Similarity
+	4,  // 10: panicparse.v1.Parse.ParseDump:input_type -> panicparse.v1.ParseDumpRequest
+	6,  // 11: panicparse.v1.Parse.ParseAndAggregate:input_type -> panicparse.v1.ParseAndAggregateRequest
+	8,  // 12: panicparse.v1.Parse.Explain:input_type -> panicparse.v1.ExplainRequest
+	12, // 13: panicparse.v1.Parse.ParseDumpStream:input_type -> panicparse.v1.DumpChunk
+	12, // 14: panicparse.v1.Parse.ParseAndAggregateStream:input_type -> panicparse.v1.DumpChunk
+	5,  // 15: panicparse.v1.Parse.ParseDump:output_type -> panicparse.v1.ParseDumpResponse
+	7,  // 16: panicparse.v1.Parse.ParseAndAggregate:output_type -> panicparse.v1.ParseAndAggregateResponse
+	10, // 17: panicparse.v1.Parse.Explain:output_type -> panicparse.v1.ExplainResponse
+	2,  // 18: panicparse.v1.Parse.ParseDumpStream:output_type -> panicparse.v1.Goroutine
+	7,  // 19: panicparse.v1.Parse.ParseAndAggregateStream:output_type -> panicparse.v1.ParseAndAggregateResponse
+	15, // [15:20] is the sub-list for method output_type
+	10, // [10:15] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_lib_server_panicparse_proto_init() }
+func file_lib_server_panicparse_proto_init() {
+	if File_lib_server_panicparse_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_lib_server_panicparse_proto_rawDesc), len(file_lib_server_panicparse_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_lib_server_panicparse_proto_goTypes,
+		DependencyIndexes: file_lib_server_panicparse_proto_depIdxs,
+		EnumInfos:         file_lib_server_panicparse_proto_enumTypes,
+		MessageInfos:      file_lib_server_panicparse_proto_msgTypes,
+	}.Build()
+	File_lib_server_panicparse_proto = out.File
+	file_lib_server_panicparse_proto_goTypes = nil
+	file_lib_server_panicparse_proto_depIdxs = nil
+}
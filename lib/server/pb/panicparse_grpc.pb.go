@@ -0,0 +1,286 @@
+// Parse is panicparse as a service.
+//
+// The reference implementation in package server serves the methods over
+// gRPC, see RegisterGRPC, and over HTTP with the protobuf JSON mapping at
+// "/panicparse.v1.Parse/<Method>". The Go bindings in package pb are
+// generated from the root of the repository with:
+//
+//   protoc --go_out=. --go_opt=module=github.com/Tchinmai7/panicparse \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/Tchinmai7/panicparse \
+//     lib/server/panicparse.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: lib/server/panicparse.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Parse_ParseDump_FullMethodName               = "/panicparse.v1.Parse/ParseDump"
+	Parse_ParseAndAggregate_FullMethodName       = "/panicparse.v1.Parse/ParseAndAggregate"
+	Parse_Explain_FullMethodName                 = "/panicparse.v1.Parse/Explain"
+	Parse_ParseDumpStream_FullMethodName         = "/panicparse.v1.Parse/ParseDumpStream"
+	Parse_ParseAndAggregateStream_FullMethodName = "/panicparse.v1.Parse/ParseAndAggregateStream"
+)
+
+// ParseClient is the client API for Parse service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ParseClient interface {
+	// ParseDump parses a stack dump into its goroutines.
+	ParseDump(ctx context.Context, in *ParseDumpRequest, opts ...grpc.CallOption) (*ParseDumpResponse, error)
+	// ParseAndAggregate parses a stack dump and aggregates similar goroutines.
+	ParseAndAggregate(ctx context.Context, in *ParseAndAggregateRequest, opts ...grpc.CallOption) (*ParseAndAggregateResponse, error)
+	// Explain describes why the process crashed.
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+	// ParseDumpStream parses a stack dump sent in chunks and returns each
+	// goroutine as soon as it is parsed.
+	ParseDumpStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DumpChunk, Goroutine], error)
+	// ParseAndAggregateStream aggregates a stack dump sent in chunks without
+	// holding all the goroutines in memory.
+	ParseAndAggregateStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[DumpChunk, ParseAndAggregateResponse], error)
+}
+
+type parseClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewParseClient(cc grpc.ClientConnInterface) ParseClient {
+	return &parseClient{cc}
+}
+
+func (c *parseClient) ParseDump(ctx context.Context, in *ParseDumpRequest, opts ...grpc.CallOption) (*ParseDumpResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ParseDumpResponse)
+	err := c.cc.Invoke(ctx, Parse_ParseDump_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parseClient) ParseAndAggregate(ctx context.Context, in *ParseAndAggregateRequest, opts ...grpc.CallOption) (*ParseAndAggregateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ParseAndAggregateResponse)
+	err := c.cc.Invoke(ctx, Parse_ParseAndAggregate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parseClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainResponse)
+	err := c.cc.Invoke(ctx, Parse_Explain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parseClient) ParseDumpStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[DumpChunk, Goroutine], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Parse_ServiceDesc.Streams[0], Parse_ParseDumpStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DumpChunk, Goroutine]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parse_ParseDumpStreamClient = grpc.BidiStreamingClient[DumpChunk, Goroutine]
+
+func (c *parseClient) ParseAndAggregateStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[DumpChunk, ParseAndAggregateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Parse_ServiceDesc.Streams[1], Parse_ParseAndAggregateStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DumpChunk, ParseAndAggregateResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parse_ParseAndAggregateStreamClient = grpc.ClientStreamingClient[DumpChunk, ParseAndAggregateResponse]
+
+// ParseServer is the server API for Parse service.
+// All implementations must embed UnimplementedParseServer
+// for forward compatibility.
+type ParseServer interface {
+	// ParseDump parses a stack dump into its goroutines.
+	ParseDump(context.Context, *ParseDumpRequest) (*ParseDumpResponse, error)
+	// ParseAndAggregate parses a stack dump and aggregates similar goroutines.
+	ParseAndAggregate(context.Context, *ParseAndAggregateRequest) (*ParseAndAggregateResponse, error)
+	// Explain describes why the process crashed.
+	Explain(context.Context, *ExplainRequest) (*ExplainResponse, error)
+	// ParseDumpStream parses a stack dump sent in chunks and returns each
+	// goroutine as soon as it is parsed.
+	ParseDumpStream(grpc.BidiStreamingServer[DumpChunk, Goroutine]) error
+	// ParseAndAggregateStream aggregates a stack dump sent in chunks without
+	// holding all the goroutines in memory.
+	ParseAndAggregateStream(grpc.ClientStreamingServer[DumpChunk, ParseAndAggregateResponse]) error
+	mustEmbedUnimplementedParseServer()
+}
+
+// UnimplementedParseServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedParseServer struct{}
+
+func (UnimplementedParseServer) ParseDump(context.Context, *ParseDumpRequest) (*ParseDumpResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ParseDump not implemented")
+}
+func (UnimplementedParseServer) ParseAndAggregate(context.Context, *ParseAndAggregateRequest) (*ParseAndAggregateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ParseAndAggregate not implemented")
+}
+func (UnimplementedParseServer) Explain(context.Context, *ExplainRequest) (*ExplainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
+func (UnimplementedParseServer) ParseDumpStream(grpc.BidiStreamingServer[DumpChunk, Goroutine]) error {
+	return status.Errorf(codes.Unimplemented, "method ParseDumpStream not implemented")
+}
+func (UnimplementedParseServer) ParseAndAggregateStream(grpc.ClientStreamingServer[DumpChunk, ParseAndAggregateResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ParseAndAggregateStream not implemented")
+}
+func (UnimplementedParseServer) mustEmbedUnimplementedParseServer() {}
+func (UnimplementedParseServer) testEmbeddedByValue()               {}
+
+// UnsafeParseServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ParseServer will
+// result in compilation errors.
+type UnsafeParseServer interface {
+	mustEmbedUnimplementedParseServer()
+}
+
+func RegisterParseServer(s grpc.ServiceRegistrar, srv ParseServer) {
+	// If the following call pancis, it indicates UnimplementedParseServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Parse_ServiceDesc, srv)
+}
+
+func _Parse_ParseDump_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseDumpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParseServer).ParseDump(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parse_ParseDump_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParseServer).ParseDump(ctx, req.(*ParseDumpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parse_ParseAndAggregate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseAndAggregateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParseServer).ParseAndAggregate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parse_ParseAndAggregate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParseServer).ParseAndAggregate(ctx, req.(*ParseAndAggregateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parse_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParseServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parse_Explain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParseServer).Explain(ctx, req.(*ExplainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parse_ParseDumpStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ParseServer).ParseDumpStream(&grpc.GenericServerStream[DumpChunk, Goroutine]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parse_ParseDumpStreamServer = grpc.BidiStreamingServer[DumpChunk, Goroutine]
+
+func _Parse_ParseAndAggregateStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ParseServer).ParseAndAggregateStream(&grpc.GenericServerStream[DumpChunk, ParseAndAggregateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parse_ParseAndAggregateStreamServer = grpc.ClientStreamingServer[DumpChunk, ParseAndAggregateResponse]
+
+// Parse_ServiceDesc is the grpc.ServiceDesc for Parse service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Parse_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "panicparse.v1.Parse",
+	HandlerType: (*ParseServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ParseDump",
+			Handler:    _Parse_ParseDump_Handler,
+		},
+		{
+			MethodName: "ParseAndAggregate",
+			Handler:    _Parse_ParseAndAggregate_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _Parse_Explain_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ParseDumpStream",
+			Handler:       _Parse_ParseDumpStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ParseAndAggregateStream",
+			Handler:       _Parse_ParseAndAggregateStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "lib/server/panicparse.proto",
+}
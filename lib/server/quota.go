@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quota limits the requests of a client, so a single client can't starve the
+// others.
+type Quota struct {
+	// Name identifies the client in the errors and the metrics, e.g. the team
+	// name. The client key is not used since it may be a secret. Clients with
+	// the same Name share the quota. Defaults to "default".
+	Name string
+	// Requests is the maximum number of requests per Window. 0 means
+	// unlimited.
+	Requests int
+	// Window is the period over which Requests are counted. Defaults to 1
+	// minute.
+	Window time.Duration
+	// MaxBytes is the maximum size of a request, including the streaming ones.
+	// 0 means unlimited.
+	MaxBytes int64
+}
+
+// QuotaConfig is the quotas of a Server in a file, reloaded with
+// Server.QuotaConfig.
+type QuotaConfig struct {
+	// Quotas is Server.Quotas.
+	Quotas map[string]Quota
+	// DefaultQuota is Server.DefaultQuota.
+	DefaultQuota *Quota
+}
+
+// LoadQuotaConfig is a loader for lib.NewReloader of a JSON file like:
+//
+//	{
+//	  "quotas": {
+//	    "<client key>": {"name": "team-a", "requests": 100, "window": "1m"}
+//	  },
+//	  "defaultQuota": {"maxBytes": 1048576}
+//	}
+//
+// The configuration is a *QuotaConfig.
+func LoadQuotaConfig(b []byte) (interface{}, error) {
+	var raw struct {
+		Quotas       map[string]*quotaJSON `json:"quotas"`
+		DefaultQuota *quotaJSON            `json:"defaultQuota"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	out := &QuotaConfig{Quotas: make(map[string]Quota, len(raw.Quotas))}
+	for k, v := range raw.Quotas {
+		q, err := v.quota()
+		if err != nil {
+			return nil, fmt.Errorf("quota %q: %v", k, err)
+		}
+		out.Quotas[k] = *q
+	}
+	if raw.DefaultQuota != nil {
+		q, err := raw.DefaultQuota.quota()
+		if err != nil {
+			return nil, fmt.Errorf("default quota: %v", err)
+		}
+		out.DefaultQuota = q
+	}
+	return out, nil
+}
+
+// Error is the Error message, the body of a request rejected by a quota.
+type Error struct {
+	Code              string `json:"code"`
+	Reason            string `json:"reason"`
+	Message           string `json:"message,omitempty"`
+	Client            string `json:"client,omitempty"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds,omitempty"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Error reasons.
+const (
+	ReasonRateLimited = "RATE_LIMITED"
+	ReasonTooLarge    = "TOO_LARGE"
+)
+
+// Private stuff.
+
+// quotaState is the requests counted in the current window of each quota.
+type quotaState struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+type quotaWindow struct {
+	start time.Time
+	n     int
+}
+
+// allow counts a request against q. It returns an Error if the quota is
+// exhausted.
+func (s *quotaState) allow(q *Quota, now time.Time) *Error {
+	if q.Requests <= 0 {
+		return nil
+	}
+	window := q.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.windows == nil {
+		s.windows = map[string]*quotaWindow{}
+	}
+	w := s.windows[quotaName(q)]
+	if w == nil || now.Sub(w.start) >= window {
+		w = &quotaWindow{start: now}
+		s.windows[quotaName(q)] = w
+	}
+	if w.n >= q.Requests {
+		retry := int((w.start.Add(window).Sub(now) + time.Second - 1) / time.Second)
+		return &Error{
+			Code:              "RESOURCE_EXHAUSTED",
+			Reason:            ReasonRateLimited,
+			Message:           fmt.Sprintf("quota of %d requests per %s exceeded", q.Requests, window),
+			Client:            quotaName(q),
+			RetryAfterSeconds: retry,
+		}
+	}
+	w.n++
+	return nil
+}
+
+// quota returns the quota of the client of the request, or nil if it is not
+// limited.
+func (s *Server) quota(r *http.Request) *Quota {
+	return s.quotaOf(r.Header.Get(s.clientHeader()))
+}
+
+// quotaOf returns the quota of the client key k, or nil if it is not
+// limited.
+func (s *Server) quotaOf(k string) *Quota {
+	quotas, def := s.Quotas, s.DefaultQuota
+	if s.QuotaConfig != nil {
+		if c, ok := s.QuotaConfig.Config().(*QuotaConfig); ok {
+			quotas, def = c.Quotas, c.DefaultQuota
+		}
+	}
+	if k != "" {
+		if q, ok := quotas[k]; ok {
+			return &q
+		}
+	}
+	return def
+}
+
+func (s *Server) clientHeader() string {
+	if s.ClientHeader == "" {
+		return "X-Api-Key"
+	}
+	return s.ClientHeader
+}
+
+// quotaJSON is a Quota in the format of LoadQuotaConfig.
+type quotaJSON struct {
+	Name     string `json:"name"`
+	Requests int    `json:"requests"`
+	Window   string `json:"window"`
+	MaxBytes int64  `json:"maxBytes"`
+}
+
+func (q *quotaJSON) quota() (*Quota, error) {
+	if q == nil {
+		return nil, errors.New("missing quota")
+	}
+	out := &Quota{Name: q.Name, Requests: q.Requests, MaxBytes: q.MaxBytes}
+	if q.Window != "" {
+		w, err := time.ParseDuration(q.Window)
+		if err != nil {
+			return nil, err
+		}
+		out.Window = w
+	}
+	return out, nil
+}
+
+func quotaName(q *Quota) string {
+	if q.Name == "" {
+		return "default"
+	}
+	return q.Name
+}
+
+func tooLarge(q *Quota) *Error {
+	return &Error{
+		Code:    "RESOURCE_EXHAUSTED",
+		Reason:  ReasonTooLarge,
+		Message: fmt.Sprintf("request larger than %d bytes", q.MaxBytes),
+		Client:  quotaName(q),
+	}
+}
+
+// reject writes the error of a rejected request.
+func reject(w http.ResponseWriter, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	code := http.StatusRequestEntityTooLarge
+	if e.Reason == ReasonRateLimited {
+		code = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfterSeconds))
+	}
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// limitReader fails the reads past n bytes, remembering that the limit was
+// exceeded.
+type limitReader struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Check if there's more data.
+		var b [1]byte
+		n, err := l.ReadCloser.Read(b[:])
+		if n != 0 {
+			l.exceeded = true
+			return 0, errTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+var errTooLarge = errors.New("request too large")
@@ -0,0 +1,535 @@
+// Package server is the reference implementation of the Parse service
+// defined in panicparse.proto, so programs not written in Go can use the
+// parser over the network.
+//
+// RegisterGRPC serves the methods over gRPC, with the bindings generated in
+// package pb.
+//
+// The methods are also served over HTTP using the protobuf JSON mapping, at
+// "/panicparse.v1.Parse/<Method>". The streaming methods read the raw dump
+// from the request body as it is uploaded; ParseDumpStream writes one JSON
+// Goroutine per line as soon as it is parsed and ParseAndAggregateStream
+// reads the similarity from the "similarity" query parameter.
+//
+// The server also answers GET "/healthz" for liveness checks and GET
+// "/metrics" with the request counts, error counts, audit log failures and
+// parse latency of each method in the Prometheus text format.
+//
+// The requests can be limited per client with Quota. A rejected request gets
+// a 429 or 413 status with an Error message as the body, or a
+// ResourceExhausted status over gRPC. The quotas can be reloaded from a file
+// without a restart, see Server.QuotaConfig.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/Tchinmai7/panicparse/stack/analysis"
+)
+
+// Similarity is the Similarity enum.
+type Similarity int32
+
+// Similarity values.
+const (
+	ExactFlags Similarity = iota
+	ExactLines
+	AnyPointer
+	AnyValue
+)
+
+var similarityNames = []string{"EXACT_FLAGS", "EXACT_LINES", "ANY_POINTER", "ANY_VALUE"}
+
+// MarshalJSON implements json.Marshaler.
+func (s Similarity) MarshalJSON() ([]byte, error) {
+	if s < 0 || int(s) >= len(similarityNames) {
+		return []byte(strconv.Itoa(int(s))), nil
+	}
+	return json.Marshal(similarityNames[s])
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts both the name and the
+// number.
+func (s *Similarity) UnmarshalJSON(b []byte) error {
+	var name string
+	if json.Unmarshal(b, &name) == nil {
+		return s.parse(name)
+	}
+	var i int32
+	if err := json.Unmarshal(b, &i); err != nil {
+		return err
+	}
+	v := Similarity(i)
+	if err := v.validate(); err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// Call is the Call message.
+type Call struct {
+	Func    string `json:"func,omitempty"`
+	SrcPath string `json:"srcPath,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Args    string `json:"args,omitempty"`
+}
+
+// Goroutine is the Goroutine message.
+type Goroutine struct {
+	ID        int    `json:"id,omitempty"`
+	State     string `json:"state,omitempty"`
+	First     bool   `json:"first,omitempty"`
+	Calls     []Call `json:"calls,omitempty"`
+	Elided    bool   `json:"elided,omitempty"`
+	CreatedBy *Call  `json:"createdBy,omitempty"`
+	SleepMin  int    `json:"sleepMin,omitempty"`
+	SleepMax  int    `json:"sleepMax,omitempty"`
+	Locked    bool   `json:"locked,omitempty"`
+}
+
+// Bucket is the Bucket message.
+type Bucket struct {
+	IDs         []int  `json:"ids,omitempty"`
+	State       string `json:"state,omitempty"`
+	First       bool   `json:"first,omitempty"`
+	Calls       []Call `json:"calls,omitempty"`
+	Elided      bool   `json:"elided,omitempty"`
+	CreatedBy   *Call  `json:"createdBy,omitempty"`
+	SleepMin    int    `json:"sleepMin,omitempty"`
+	SleepMax    int    `json:"sleepMax,omitempty"`
+	Locked      bool   `json:"locked,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// ParseDumpRequest is the ParseDumpRequest message.
+type ParseDumpRequest struct {
+	Dump string `json:"dump"`
+}
+
+// ParseDumpResponse is the ParseDumpResponse message.
+type ParseDumpResponse struct {
+	Crash      string       `json:"crash,omitempty"`
+	Goroutines []*Goroutine `json:"goroutines,omitempty"`
+	Segments   []string     `json:"segments,omitempty"`
+}
+
+// ParseAndAggregateRequest is the ParseAndAggregateRequest message.
+type ParseAndAggregateRequest struct {
+	Dump       string     `json:"dump"`
+	Similarity Similarity `json:"similarity"`
+}
+
+// ParseAndAggregateResponse is the ParseAndAggregateResponse message.
+type ParseAndAggregateResponse struct {
+	Crash      string    `json:"crash,omitempty"`
+	Goroutines int       `json:"goroutines,omitempty"`
+	Buckets    []*Bucket `json:"buckets,omitempty"`
+}
+
+// ExplainRequest is the ExplainRequest message.
+type ExplainRequest struct {
+	Dump string `json:"dump"`
+}
+
+// Frame is the Frame message.
+type Frame struct {
+	Func     string `json:"func,omitempty"`
+	Location string `json:"location,omitempty"`
+	Args     string `json:"args,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ExplainResponse is the ExplainResponse message.
+type ExplainResponse struct {
+	Crash    string    `json:"crash,omitempty"`
+	Deadlock string    `json:"deadlock,omitempty"`
+	Frames   []Frame   `json:"frames,omitempty"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Finding is the Finding message.
+type Finding struct {
+	Analyzer   string `json:"analyzer,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+	Confidence string `json:"confidence,omitempty"`
+	IDs        []int  `json:"ids,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Server implements the Parse service.
+//
+// The zero value is ready to use.
+type Server struct {
+	// MaxBytes is the maximum size of a unary request. Defaults to 64MiB. The
+	// streaming methods are not limited.
+	MaxBytes int64
+	// CorpusVersion is the version of the corpus of known signatures used by
+	// the deployment, exported in /metrics as panicparse_corpus_info.
+	CorpusVersion string
+	// ClientHeader is the request header identifying the client, e.g. its API
+	// key. Defaults to "X-Api-Key".
+	ClientHeader string
+	// Quotas is the quota of each client, by the value of ClientHeader.
+	Quotas map[string]Quota
+	// DefaultQuota is the quota of the clients not in Quotas. They share it.
+	// The requests are not limited if nil.
+	DefaultQuota *Quota
+	// QuotaConfig replaces Quotas and DefaultQuota when set, so the quotas
+	// can be changed without a restart. It must load a *QuotaConfig, e.g.
+	// with LoadQuotaConfig.
+	QuotaConfig *lib.Reloader
+	// Clock is used to count the requests per quota window and to measure
+	// the parse latency. Defaults to lib.SystemClock.
+	Clock lib.Clock
+	// Audit records the successful requests with the client and the
+	// fingerprints of the buckets returned, when set. A request whose entry
+	// can't be recorded fails.
+	Audit *lib.AuditLog
+
+	m metrics
+	q quotaState
+}
+
+// ParseDump implements Parse.ParseDump.
+func (s *Server) ParseDump(ctx context.Context, req *ParseDumpRequest) (*ParseDumpResponse, error) {
+	c, err := parse(strings.NewReader(req.Dump), nil)
+	if c == nil || err != nil {
+		return &ParseDumpResponse{}, err
+	}
+	out := &ParseDumpResponse{Crash: c.Crash.String()}
+	for _, g := range c.Goroutines {
+		out.Goroutines = append(out.Goroutines, toGoroutine(g))
+	}
+	for _, seg := range c.Segments {
+		out.Segments = append(out.Segments, seg.Text)
+	}
+	return out, nil
+}
+
+// ParseAndAggregate implements Parse.ParseAndAggregate.
+func (s *Server) ParseAndAggregate(ctx context.Context, req *ParseAndAggregateRequest) (*ParseAndAggregateResponse, error) {
+	if err := req.Similarity.validate(); err != nil {
+		return &ParseAndAggregateResponse{}, err
+	}
+	c, err := parse(strings.NewReader(req.Dump), nil)
+	if c == nil || err != nil {
+		return &ParseAndAggregateResponse{}, err
+	}
+	return toAggregateResponse(c.Crash, len(c.Goroutines), stack.Aggregate(c.Goroutines, stack.Similarity(req.Similarity))), nil
+}
+
+// Explain implements Parse.Explain.
+func (s *Server) Explain(ctx context.Context, req *ExplainRequest) (*ExplainResponse, error) {
+	c, err := parse(strings.NewReader(req.Dump), nil)
+	if c == nil || err != nil {
+		return &ExplainResponse{}, err
+	}
+	out := &ExplainResponse{Crash: c.Crash.String()}
+	if d := analysis.FindDeadlock(c); d != nil {
+		out.Deadlock = d.Explanation
+	}
+	g := c.Culprit()
+	for i := range g.Stack.Calls {
+		e := lib.ExplainFrame(&g.Stack.Calls[i])
+		out.Frames = append(out.Frames, Frame{Func: e.Func, Location: e.Location, Args: e.Args, Kind: string(e.Kind), URL: e.URL})
+	}
+	for _, f := range analysis.Run(c, analysis.Analyzers()) {
+		out.Findings = append(out.Findings, Finding{Analyzer: f.Analyzer, Severity: f.Severity.String(), Confidence: f.Confidence.String(), IDs: f.IDs, Message: f.Message})
+	}
+	return out, nil
+}
+
+// ParseDumpStream implements Parse.ParseDumpStream.
+//
+// send is called with each goroutine as soon as it is parsed. It stops at the
+// first error returned by send.
+func (s *Server) ParseDumpStream(ctx context.Context, r io.Reader, send func(*Goroutine) error) error {
+	var serr error
+	_, err := parse(r, func(g *stack.Goroutine) {
+		if serr == nil {
+			serr = send(toGoroutine(g))
+		}
+	})
+	if serr != nil {
+		return serr
+	}
+	return err
+}
+
+// ParseAndAggregateStream implements Parse.ParseAndAggregateStream.
+func (s *Server) ParseAndAggregateStream(ctx context.Context, r io.Reader, similar Similarity) (*ParseAndAggregateResponse, error) {
+	if err := similar.validate(); err != nil {
+		return &ParseAndAggregateResponse{}, err
+	}
+	a := stack.NewAggregator(stack.Similarity(similar))
+	c, err := parse(r, a.Add)
+	if c == nil || err != nil {
+		return &ParseAndAggregateResponse{}, err
+	}
+	n := 0
+	buckets := a.Buckets()
+	for _, b := range buckets {
+		n += b.Size()
+	}
+	return toAggregateResponse(c.Crash, n, buckets), nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz", "/metrics":
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/healthz" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(w, "ok\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = s.m.write(w, s.CorpusVersion)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	var resp interface{}
+	var err error
+	method := strings.TrimPrefix(r.URL.Path, "/panicparse.v1.Parse/")
+	var lr *limitReader
+	q := s.quota(r)
+	if q != nil {
+		e := s.q.allow(q, s.clock().Now())
+		if e == nil && q.MaxBytes > 0 {
+			if r.ContentLength > q.MaxBytes {
+				e = tooLarge(q)
+			} else {
+				lr = &limitReader{ReadCloser: r.Body, n: q.MaxBytes}
+				r.Body = lr
+			}
+		}
+		if e != nil {
+			s.m.reject(e)
+			reject(w, e)
+			return
+		}
+	}
+	start := s.clock().Now()
+	switch method {
+	case "ParseDump":
+		req := &ParseDumpRequest{}
+		if err = s.decode(w, r, req); err == nil {
+			resp, err = s.ParseDump(ctx, req)
+		}
+	case "ParseAndAggregate":
+		req := &ParseAndAggregateRequest{}
+		if err = s.decode(w, r, req); err == nil {
+			resp, err = s.ParseAndAggregate(ctx, req)
+		}
+	case "Explain":
+		req := &ExplainRequest{}
+		if err = s.decode(w, r, req); err == nil {
+			resp, err = s.Explain(ctx, req)
+		}
+	case "ParseDumpStream":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		f, _ := w.(http.Flusher)
+		e := json.NewEncoder(w)
+		// Errors after the first goroutine was sent can't be reported in the
+		// status code anymore.
+		err = s.ParseDumpStream(ctx, r.Body, func(g *Goroutine) error {
+			if err := e.Encode(g); err != nil {
+				return err
+			}
+			if f != nil {
+				f.Flush()
+			}
+			return ctx.Err()
+		})
+		s.m.observe(method, s.since(start), err)
+		if lr != nil && lr.exceeded {
+			s.m.reject(tooLarge(q))
+		} else if err == nil {
+			// The response was sent already, so a failure can't be reported in
+			// the status code; audit counts it in the metrics.
+			_ = s.audit(q, method, nil)
+		}
+		return
+	case "ParseAndAggregateStream":
+		var sim Similarity
+		if v := r.URL.Query().Get("similarity"); v != "" {
+			if err = sim.parse(v); err != nil {
+				break
+			}
+		}
+		resp, err = s.ParseAndAggregateStream(ctx, r.Body, sim)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	s.m.observe(method, s.since(start), err)
+	if lr != nil && lr.exceeded {
+		e := tooLarge(q)
+		s.m.reject(e)
+		reject(w, e)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.audit(q, method, resp); err != nil {
+		http.Error(w, "audit log failure", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Private stuff.
+
+func (s *Similarity) parse(v string) error {
+	for i, n := range similarityNames {
+		if v == n {
+			*s = Similarity(i)
+			return nil
+		}
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid similarity %q", v)
+	}
+	n := Similarity(i)
+	if err := n.validate(); err != nil {
+		return err
+	}
+	*s = n
+	return nil
+}
+
+// validate returns an error if s is not one of the Similarity values. An
+// unknown value would silently put each goroutine in its own bucket.
+func (s Similarity) validate() error {
+	if s < ExactFlags || s > AnyValue {
+		return fmt.Errorf("invalid similarity %d", int(s))
+	}
+	return nil
+}
+
+func (s *Server) clock() lib.Clock {
+	if s.Clock == nil {
+		return lib.SystemClock
+	}
+	return s.Clock
+}
+
+// since returns the time elapsed since start, according to s.Clock.
+func (s *Server) since(start time.Time) time.Duration {
+	return s.clock().Now().Sub(start)
+}
+
+// audit records a successful request in s.Audit. A failure is counted in
+// the metrics.
+func (s *Server) audit(q *Quota, method string, resp interface{}) error {
+	if s.Audit == nil {
+		return nil
+	}
+	e := lib.AuditEntry{Action: method}
+	if q != nil {
+		e.Actor = quotaName(q)
+	}
+	if r, ok := resp.(*ParseAndAggregateResponse); ok {
+		for _, b := range r.Buckets {
+			e.Fingerprints = append(e.Fingerprints, b.Fingerprint)
+		}
+	}
+	if err := s.Audit.Record(e); err != nil {
+		s.m.auditFailure(method)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	max := s.MaxBytes
+	if max <= 0 {
+		max = 64 << 20
+	}
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(http.MaxBytesReader(w, r.Body, max)); err != nil {
+		return err
+	}
+	return json.Unmarshal(b.Bytes(), v)
+}
+
+// parse parses a dump, streaming the goroutines to onGoroutine when set.
+func parse(r io.Reader, onGoroutine func(g *stack.Goroutine)) (*stack.Context, error) {
+	return stack.ParseDumpOpts(r, nil, &stack.Opts{OnGoroutine: onGoroutine})
+}
+
+func toCall(c *stack.Call) Call {
+	return Call{Func: c.Func.Raw, SrcPath: c.SrcPath, Line: c.Line, Args: c.Args.String()}
+}
+
+func toCalls(s *stack.Signature) ([]Call, *Call) {
+	calls := make([]Call, len(s.Stack.Calls))
+	for i := range s.Stack.Calls {
+		calls[i] = toCall(&s.Stack.Calls[i])
+	}
+	var created *Call
+	if s.CreatedBy.Func.Raw != "" {
+		c := toCall(&s.CreatedBy)
+		created = &c
+	}
+	return calls, created
+}
+
+func toGoroutine(g *stack.Goroutine) *Goroutine {
+	calls, created := toCalls(&g.Signature)
+	return &Goroutine{
+		ID:        g.ID,
+		State:     g.State,
+		First:     g.First,
+		Calls:     calls,
+		Elided:    g.Stack.Elided,
+		CreatedBy: created,
+		SleepMin:  g.SleepMin,
+		SleepMax:  g.SleepMax,
+		Locked:    g.Locked,
+	}
+}
+
+func toAggregateResponse(crash stack.CrashKind, n int, buckets []*stack.Bucket) *ParseAndAggregateResponse {
+	out := &ParseAndAggregateResponse{Crash: crash.String(), Goroutines: n}
+	for _, b := range buckets {
+		calls, created := toCalls(&b.Signature)
+		out.Buckets = append(out.Buckets, &Bucket{
+			IDs:         b.IDs,
+			State:       b.State,
+			First:       b.First,
+			Calls:       calls,
+			Elided:      b.Stack.Elided,
+			CreatedBy:   created,
+			SleepMin:    b.SleepMin,
+			SleepMax:    b.SleepMax,
+			Locked:      b.Locked,
+			Fingerprint: b.Fingerprint(),
+		})
+	}
+	return out
+}
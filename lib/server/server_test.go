@@ -0,0 +1,387 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+)
+
+const dump = "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n\ngoroutine 6 [chan receive]:\nmain.worker(0xc000010000)\n\t/home/user/src/foo/main.go:20 +0x12\ncreated by main.main\n\t/home/user/src/foo/main.go:8 +0x30\n\ngoroutine 7 [chan receive]:\nmain.worker(0xc000010008)\n\t/home/user/src/foo/main.go:20 +0x12\ncreated by main.main\n\t/home/user/src/foo/main.go:8 +0x30\n"
+
+func TestServeHTTP(t *testing.T) {
+	s := httptest.NewServer(&Server{})
+	defer s.Close()
+	post := func(method, body string, out interface{}) {
+		t.Helper()
+		resp, err := http.Post(s.URL+"/panicparse.v1.Parse/"+method, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %s", method, resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, _ := json.Marshal(map[string]interface{}{"dump": dump, "similarity": "ANY_POINTER"})
+
+	var pd ParseDumpResponse
+	post("ParseDump", string(req), &pd)
+	if pd.Crash != "panic" || len(pd.Goroutines) != 3 || pd.Goroutines[1].CreatedBy == nil || pd.Goroutines[1].Calls[0].Args != "#1" {
+		t.Fatalf("unexpected response: %#v", pd)
+	}
+
+	var pa ParseAndAggregateResponse
+	post("ParseAndAggregate", string(req), &pa)
+	if pa.Goroutines != 3 || len(pa.Buckets) != 2 || len(pa.Buckets[1].IDs) != 2 || pa.Buckets[1].Fingerprint == "" {
+		t.Fatalf("unexpected response: %#v", pa)
+	}
+
+	var ex ExplainResponse
+	post("Explain", string(req), &ex)
+	if ex.Crash != "panic" || len(ex.Frames) != 1 || ex.Frames[0].Kind != "main" {
+		t.Fatalf("unexpected response: %#v", ex)
+	}
+
+	var ps ParseAndAggregateResponse
+	post("ParseAndAggregateStream?similarity=ANY_POINTER", dump, &ps)
+	if ps.Goroutines != 3 || len(ps.Buckets) != 2 {
+		t.Fatalf("unexpected response: %#v", ps)
+	}
+}
+
+func TestServeHTTPParseDumpStream(t *testing.T) {
+	s := httptest.NewServer(&Server{})
+	defer s.Close()
+	resp, err := http.Post(s.URL+"/panicparse.v1.Parse/ParseDumpStream", "text/plain", strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var ids []int
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var g Goroutine
+		if err := json.Unmarshal(sc.Bytes(), &g); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, g.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 7 {
+		t.Fatalf("unexpected goroutines: %v", ids)
+	}
+}
+
+func TestSimilarityJSON(t *testing.T) {
+	for _, in := range []string{`"ANY_VALUE"`, `3`} {
+		var s Similarity
+		if err := json.Unmarshal([]byte(in), &s); err != nil || s != AnyValue {
+			t.Fatalf("%s: %v %v", in, s, err)
+		}
+	}
+	if b, _ := json.Marshal(AnyPointer); string(b) != `"ANY_POINTER"` {
+		t.Fatalf("unexpected %s", b)
+	}
+	for _, in := range []string{`4`, `-1`, `"FOO"`} {
+		var s Similarity
+		if err := json.Unmarshal([]byte(in), &s); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func TestServeHTTPInvalidSimilarity(t *testing.T) {
+	s := httptest.NewServer(&Server{})
+	defer s.Close()
+	for _, line := range []struct {
+		method string
+		body   string
+	}{
+		{"ParseAndAggregate", `{"dump":"x","similarity":42}`},
+		{"ParseAndAggregate", `{"dump":"x","similarity":"42"}`},
+		{"ParseAndAggregateStream?similarity=42", dump},
+		{"ParseAndAggregateStream?similarity=-1", dump},
+	} {
+		resp, err := http.Post(s.URL+"/panicparse.v1.Parse/"+line.method, "application/json", strings.NewReader(line.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s %s: want 400, got %s", line.method, line.body, resp.Status)
+		}
+	}
+}
+
+func TestServeHTTPHealthzMetrics(t *testing.T) {
+	s := httptest.NewServer(&Server{CorpusVersion: "2024.1"})
+	defer s.Close()
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %s", path, resp.Status)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if got := get("/healthz"); got != "ok\n" {
+		t.Fatalf("unexpected /healthz: %q", got)
+	}
+	for _, body := range []string{`{"dump":"panic: boom\n"}`, `{`} {
+		resp, err := http.Post(s.URL+"/panicparse.v1.Parse/ParseDump", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	got := get("/metrics")
+	for _, want := range []string{
+		"panicparse_requests_total{method=\"ParseDump\"} 2\n",
+		"panicparse_errors_total{method=\"ParseDump\"} 1\n",
+		"panicparse_parse_duration_seconds_bucket{method=\"ParseDump\",le=\"+Inf\"} 2\n",
+		"panicparse_parse_duration_seconds_count{method=\"ParseDump\"} 2\n",
+		"panicparse_corpus_info{version=\"2024.1\"} 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestServeHTTPQuota(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := &Server{
+		Quotas: map[string]Quota{
+			"secret": {Name: "team-a", Requests: 2, Window: time.Minute},
+		},
+		DefaultQuota: &Quota{MaxBytes: 64},
+		Clock:        lib.NewFakeClock(start),
+	}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+	post := func(key, body string, chunked bool) (int, *Error) {
+		t.Helper()
+		var r io.Reader = strings.NewReader(body)
+		if chunked {
+			// Hide the length so the limit is enforced while reading.
+			r = struct{ io.Reader }{r}
+		}
+		req, err := http.NewRequest("POST", s.URL+"/panicparse.v1.Parse/ParseAndAggregateStream", r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		e := &Error{}
+		if err := json.NewDecoder(resp.Body).Decode(e); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, e
+	}
+
+	for i := 0; i < 2; i++ {
+		if code, e := post("secret", dump, false); code != http.StatusOK {
+			t.Fatalf("#%d: unexpected %d %v", i, code, e)
+		}
+	}
+	code, e := post("secret", dump, false)
+	if code != http.StatusTooManyRequests || e.Reason != ReasonRateLimited || e.Client != "team-a" || e.RetryAfterSeconds != 60 {
+		t.Fatalf("unexpected %d %#v", code, e)
+	}
+	srv.Clock.(*lib.FakeClock).Advance(time.Minute)
+	if code, e := post("secret", dump, false); code != http.StatusOK {
+		t.Fatalf("unexpected %d %v", code, e)
+	}
+
+	for _, chunked := range []bool{false, true} {
+		code, e := post("", dump, chunked)
+		if code != http.StatusRequestEntityTooLarge || e.Reason != ReasonTooLarge || e.Client != "default" {
+			t.Fatalf("chunked=%t: unexpected %d %#v", chunked, code, e)
+		}
+	}
+	if code, e := post("unknown", "panic: boom\n", false); code != http.StatusOK {
+		t.Fatalf("unexpected %d %v", code, e)
+	}
+
+	var b strings.Builder
+	if err := srv.m.write(&b, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"panicparse_rejections_total{client=\"default\",reason=\"TOO_LARGE\"} 2\n",
+		"panicparse_rejections_total{client=\"team-a\",reason=\"RATE_LIMITED\"} 1\n",
+		// The latency is measured with the fake clock.
+		"panicparse_parse_duration_seconds_sum{method=\"ParseAndAggregateStream\"} 0\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestServeHTTPAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "audit.log")
+	a, err := lib.OpenAuditLog(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	s := httptest.NewServer(&Server{Quotas: map[string]Quota{"secret": {Name: "team-a"}}, Audit: a})
+	defer s.Close()
+	req, err := http.NewRequest("POST", s.URL+"/panicparse.v1.Parse/ParseAndAggregateStream?similarity=ANY_POINTER", strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Api-Key", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(resp.Status)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := lib.VerifyAuditLog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "team-a" || entries[0].Action != "ParseAndAggregateStream" || len(entries[0].Fingerprints) != 2 {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
+
+func TestServeHTTPAuditFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, err := lib.OpenAuditLog(filepath.Join(dir, "audit.log"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recording fails once closed.
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Audit: a}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+	for _, method := range []string{"ParseDumpStream", "ParseAndAggregateStream"} {
+		resp, err := http.Post(s.URL+"/panicparse.v1.Parse/"+method, "text/plain", strings.NewReader(dump))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	var b strings.Builder
+	if err := srv.m.write(&b, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"panicparse_audit_failures_total{method=\"ParseAndAggregateStream\"} 1\n",
+		"panicparse_audit_failures_total{method=\"ParseDumpStream\"} 1\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestServeHTTPQuotaConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "quotas.json")
+	if err := ioutil.WriteFile(p, []byte(`{"quotas":{"secret":{"name":"team-a","requests":1,"window":"1h"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := lib.NewReloader(p, LoadQuotaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{QuotaConfig: r, Clock: lib.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+	post := func(key string) int {
+		t.Helper()
+		req, err := http.NewRequest("POST", s.URL+"/panicparse.v1.Parse/ParseAndAggregateStream", strings.NewReader(dump))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Api-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("secret"); code != http.StatusOK {
+		t.Fatalf("unexpected %d", code)
+	}
+	if code := post("secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected %d", code)
+	}
+
+	// The new quota applies without restarting the server.
+	if err := ioutil.WriteFile(p, []byte(`{"quotas":{"secret":{"name":"team-a","requests":3,"window":"1h"}},"defaultQuota":{"maxBytes":1}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if code := post("secret"); code != http.StatusOK {
+		t.Fatalf("unexpected %d", code)
+	}
+	if code := post("other"); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected %d", code)
+	}
+
+	for _, bad := range []string{`{`, `{"quotas":{"secret":null}}`, `{"defaultQuota":{"window":"soon"}}`} {
+		if _, err := LoadQuotaConfig([]byte(bad)); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
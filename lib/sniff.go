@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"regexp"
+	"sort"
+)
+
+// ParseAny parses a stack trace that may have been mangled in transit and
+// renders all the buckets.
+//
+// See Normalize for the supported encodings.
+func ParseAny(input []byte) ([]string, error) {
+	b, err := Normalize(input)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePanicStringOpts(string(b), nil)
+}
+
+// Normalize detects how a stack trace was encoded and decodes it.
+//
+// It supports, possibly nested:
+//   - gzip compression, up to 64MiB decompressed.
+//   - A JSON string, e.g. a value copied from a structured log, or a JSON
+//     object with a field containing the stack trace.
+//   - Quoted-printable encoding, e.g. pasted from an email.
+//   - HTML escaping, e.g. pasted from a web page or a chat.
+//   - Backslash escapes on a single line, see Unescape.
+//
+// Input not matching any of these is returned as-is.
+func Normalize(input []byte) ([]byte, error) {
+	for i := 0; i < maxNormalizePasses; i++ {
+		out, err := normalizeOnce(input)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(out, input) {
+			break
+		}
+		input = out
+	}
+	return input, nil
+}
+
+// Private stuff.
+
+// maxNormalizePasses bounds the number of nested encodings.
+const maxNormalizePasses = 4
+
+// maxGzipSize is the maximum decompressed size of gzip input, to bound the
+// memory used by a decompression bomb. It is a variable so the tests can lower
+// it.
+var maxGzipSize int64 = 64 << 20
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	// reQuotedPrintable matches a soft line break or an escaped "=", tab or
+	// space.
+	reQuotedPrintable = regexp.MustCompile(`=\r?\n|=3D|=09|=20`)
+	// reHTML matches HTML entities and line breaks.
+	reHTML = regexp.MustCompile(`&(?:lt|gt|amp|quot|#\d+|#x[0-9a-fA-F]+);|<br\s*/?>`)
+	reBR   = regexp.MustCompile(`(?i)<br\s*/?>\r?\n?`)
+	// reRoutineLine matches a goroutine header anywhere.
+	reRoutineLine = regexp.MustCompile(`goroutine \d+ .*\[`)
+)
+
+func normalizeOnce(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		out, err := ioutil.ReadAll(io.LimitReader(r, maxGzipSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(out)) > maxGzipSize {
+			return nil, fmt.Errorf("gzip: larger than %d bytes", maxGzipSize)
+		}
+		return out, nil
+	}
+	t := bytes.TrimSpace(b)
+	if len(t) != 0 && t[0] == '"' {
+		var s string
+		if json.Unmarshal(t, &s) == nil {
+			return []byte(s), nil
+		}
+	}
+	if len(t) != 0 && t[0] == '{' {
+		var v interface{}
+		if json.Unmarshal(t, &v) == nil {
+			if s := findTrace(v); s != "" {
+				return []byte(s), nil
+			}
+		}
+	}
+	if reQuotedPrintable.Match(b) {
+		if out, err := ioutil.ReadAll(quotedprintable.NewReader(bytes.NewReader(b))); err == nil {
+			return out, nil
+		}
+	}
+	if bytes.IndexByte(t, '\n') == -1 && bytes.Contains(t, []byte(`\n`)) {
+		// An escaped stack trace copied without its surrounding quotes.
+		return []byte(Unescape(string(b))), nil
+	}
+	if reHTML.Match(b) {
+		return []byte(html.UnescapeString(reBR.ReplaceAllString(string(b), "\n"))), nil
+	}
+	return b, nil
+}
+
+// findTrace returns the first string in a decoded JSON value that looks like
+// a stack trace.
+//
+// The fields of an object are searched in the order of their names, so the
+// result is deterministic.
+func findTrace(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		if reRoutineLine.MatchString(t) {
+			return t
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s := findTrace(t[k]); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if s := findTrace(e); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const sniffPanic = "panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:4 +0x25\n"
+
+func TestNormalize(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(sniffPanic))
+	_ = w.Close()
+	jsonStr, _ := json.Marshal(sniffPanic)
+	jsonObj, _ := json.Marshal(map[string]interface{}{"level": "error", "fields": map[string]string{"stack": sniffPanic}})
+	data := []struct {
+		name string
+		in   []byte
+	}{
+		{"plain", []byte(sniffPanic)},
+		{"gzip", gz.Bytes()},
+		{"json string", jsonStr},
+		{"json object", jsonObj},
+		{"quoted-printable", []byte("panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n=09/tmp/main.go:4 +0x=\n25\n")},
+		{"html", []byte("panic: oh no<br><br>goroutine 1 [running]:<br>main.main()<br>&#9;/tmp/main.go:4 +0x25<br>")},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			got, err := Normalize(line.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != sniffPanic {
+				t.Fatalf("want %q, got %q", sniffPanic, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeGzipTooLarge(t *testing.T) {
+	defer func(m int64) {
+		maxGzipSize = m
+	}(maxGzipSize)
+	maxGzipSize = int64(len(sniffPanic) - 1)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(sniffPanic))
+	_ = w.Close()
+	want := fmt.Sprintf("gzip: larger than %d bytes", maxGzipSize)
+	if _, err := Normalize(gz.Bytes()); err == nil || err.Error() != want {
+		t.Fatalf("want %q, got %v", want, err)
+	}
+}
+
+func TestNormalizeJSONFieldOrder(t *testing.T) {
+	other := strings.Replace(sniffPanic, "oh no", "other", 1)
+	in, _ := json.Marshal(map[string]string{"b": other, "a": sniffPanic, "c": other})
+	for i := 0; i < 20; i++ {
+		got, err := Normalize(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != sniffPanic {
+			t.Fatalf("want %q, got %q", sniffPanic, got)
+		}
+	}
+}
+
+func TestParseAny(t *testing.T) {
+	jsonStr, _ := json.Marshal(sniffPanic)
+	out, err := ParseAny(jsonStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] == "" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
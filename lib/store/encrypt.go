@@ -0,0 +1,172 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeyProvider provides the AES keys used to encrypt the objects.
+//
+// Each encrypted object records the ID of its key, so the keys can be rotated
+// while the old objects stay readable.
+type KeyProvider interface {
+	// DataKey returns the key to encrypt a new object and its ID. The key must
+	// be 16, 24 or 32 bytes long.
+	DataKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with the ID returned by DataKey.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// EnvKeyProvider reads a base64 encoded key from an environment variable.
+//
+// The ID of the key is the name of the variable, so the key can be rotated by
+// switching to a new variable while the old one is kept set.
+type EnvKeyProvider struct {
+	// Var is the environment variable, e.g. "PANICPARSE_STORE_KEY".
+	Var string
+}
+
+// DataKey implements KeyProvider.
+func (e *EnvKeyProvider) DataKey(ctx context.Context) (string, []byte, error) {
+	k, err := e.Key(ctx, e.Var)
+	return e.Var, k, err
+}
+
+// Key implements KeyProvider.
+func (e *EnvKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	v := os.Getenv(id)
+	if v == "" {
+		return nil, fmt.Errorf("environment variable %q is not set", id)
+	}
+	k, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %q: %v", id, err)
+	}
+	return k, nil
+}
+
+// KMS is a key management service holding a master key, e.g. a cloud
+// provider KMS. The master key never leaves the service.
+type KMS interface {
+	// Encrypt encrypts a data key with the master key.
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	// Decrypt decrypts a data key encrypted by Encrypt.
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// KMSKeyProvider implements envelope encryption: each object is encrypted
+// with a new random data key, which is stored encrypted by the KMS as the
+// key ID.
+//
+// Reading an object costs a KMS call.
+type KMSKeyProvider struct {
+	KMS KMS
+}
+
+// DataKey implements KeyProvider.
+func (k *KMSKeyProvider) DataKey(ctx context.Context) (string, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	wrapped, err := k.KMS.Encrypt(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return base64.RawStdEncoding.EncodeToString(wrapped), key, nil
+}
+
+// Key implements KeyProvider.
+func (k *KMSKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	wrapped, err := base64.RawStdEncoding.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+	return k.KMS.Decrypt(ctx, wrapped)
+}
+
+// Encrypt encrypts data with AES-GCM with a key from keys.
+//
+// The result starts with a header containing the key ID, which is
+// authenticated but not encrypted.
+func Encrypt(ctx context.Context, keys KeyProvider, data []byte) ([]byte, error) {
+	id, key, err := keys.DataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 0xFFFF {
+		return nil, errors.New("key ID too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encMagic)+2+len(id)+aead.NonceSize()+len(data)+aead.Overhead())
+	out = append(out, encMagic...)
+	out = append(out, byte(len(id)>>8), byte(len(id)))
+	out = append(out, id...)
+	header := len(out)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, out[:header]), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt.
+func Decrypt(ctx context.Context, keys KeyProvider, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("not encrypted")
+	}
+	rest := data[len(encMagic):]
+	if len(rest) < 2 {
+		return nil, errors.New("truncated header")
+	}
+	n := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < n {
+		return nil, errors.New("truncated header")
+	}
+	key, err := keys.Key(ctx, string(rest[:n]))
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[n:]
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("truncated nonce")
+	}
+	header := data[:len(data)-len(rest)]
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+}
+
+// IsEncrypted returns true if data was returned by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encMagic)
+}
+
+// Private stuff.
+
+// encMagic starts the encrypted objects. It can't start a stack dump or a
+// JSON report.
+var encMagic = []byte("\x00ppenc1")
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Site is a source line on a crash path.
+type Site struct {
+	// File is the path relative to the module root when known, e.g.
+	// "pkg/order/order.go", otherwise the path as printed in the dump.
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Func is the fully qualified function name, e.g.
+	// "github.com/foo/bar/order.Process".
+	Func string `json:"func,omitempty"`
+}
+
+// HotSite is a source line weighted by the number of crashes going through
+// it.
+type HotSite struct {
+	Site
+	// Weight is the number of crashes whose path goes through the line.
+	Weight int `json:"weight"`
+}
+
+// HotPaths returns the statistics of the signatures that crashed in the
+// reports stored by PutReport, the most frequent first. See
+// FingerprintStats.Crashes and FingerprintStats.Path.
+//
+// max limits the number of paths returned; 0 means no limit.
+func (d *Dir) HotPaths(ctx context.Context, max int) ([]FingerprintStats, error) {
+	all, err := d.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []FingerprintStats
+	for _, s := range all {
+		if s.Crashes != 0 {
+			out = append(out, s)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Crashes > out[j].Crashes
+	})
+	if max > 0 && len(out) > max {
+		out = out[:max]
+	}
+	return out, nil
+}
+
+// HotSites returns the source lines on the crash paths, weighted by the
+// number of crashes going through them, the heaviest first, e.g. to
+// prioritize the tests and the reviews of the code that crashes the most.
+//
+// A line is counted once per path, even when the path goes through it more
+// than once, e.g. with recursion.
+func HotSites(paths []FingerprintStats) []HotSite {
+	index := map[Site]int{}
+	var out []HotSite
+	for _, p := range paths {
+		seen := map[Site]bool{}
+		for _, site := range p.Path {
+			if seen[site] {
+				continue
+			}
+			seen[site] = true
+			i, ok := index[site]
+			if !ok {
+				i = len(out)
+				index[site] = i
+				out = append(out, HotSite{Site: site})
+			}
+			out[i].Weight += p.Crashes
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Weight != out[j].Weight {
+			return out[i].Weight > out[j].Weight
+		}
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out
+}
+
+// WriteHotSites writes one site per line, as "file:line weight func", e.g.:
+//
+//	pkg/order/order.go:88 12 github.com/foo/bar/order.Process
+//
+// The fields are separated by a space; the function is last since it may be
+// empty, in which case it is omitted.
+func WriteHotSites(w io.Writer, sites []HotSite) error {
+	b := bufio.NewWriter(w)
+	for _, s := range sites {
+		fmt.Fprintf(b, "%s:%d %d", s.File, s.Line, s.Weight)
+		if s.Func != "" {
+			b.WriteString(" " + s.Func)
+		}
+		if err := b.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
+
+// Private stuff.
+
+// crashPath returns the sites of the call stack of s, skipping the standard
+// library.
+func crashPath(s *stack.Signature) []Site {
+	out := []Site{}
+	for i := range s.Stack.Calls {
+		c := &s.Stack.Calls[i]
+		if c.IsStdlib || c.Func.Raw == "" {
+			continue
+		}
+		f := c.RelSrcPath
+		if f == "" {
+			f = c.SrcPath
+		}
+		out = append(out, Site{File: f, Line: c.Line, Func: c.Func.String()})
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/lib"
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestDirHotPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &Dir{Path: dir}
+	ctx := context.Background()
+	inner := "panic: boom\n\ngoroutine 1 [running]:\nmain.inner()\n\t/home/user/src/foo/main.go:20 +0x45\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	other := "panic: other\n\ngoroutine 1 [running]:\nmain.other()\n\t/home/user/src/foo/other.go:5 +0x45\nmain.main()\n\t/home/user/src/foo/main.go:11 +0x45\n\n" +
+		"goroutine 2 [chan receive]:\nmain.worker()\n\t/home/user/src/foo/main.go:30 +0x1\n"
+	for _, s := range []string{inner, dump, inner, other} {
+		c, err := stack.ParseDump(bytes.NewReader([]byte(s)), nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &lib.Report{Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}
+		if err := d.PutReport(ctx, r, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := d.HotPaths(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The goroutine that didn't crash is not a crash path.
+	if len(paths) != 3 || paths[0].Crashes != 2 || len(paths[0].Path) != 2 || paths[1].Crashes != 1 || paths[2].Crashes != 1 {
+		t.Fatalf("unexpected paths: %#v", paths)
+	}
+	if top, err := d.HotPaths(ctx, 1); err != nil || len(top) != 1 || top[0].Fingerprint != paths[0].Fingerprint {
+		t.Fatalf("unexpected %#v, %v", top, err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteHotSites(&b, HotSites(paths)); err != nil {
+		t.Fatal(err)
+	}
+	want := "/home/user/src/foo/main.go:10 3 main.main\n" +
+		"/home/user/src/foo/main.go:20 2 main.inner\n" +
+		"/home/user/src/foo/main.go:11 1 main.main\n" +
+		"/home/user/src/foo/other.go:5 1 main.other\n"
+	if got := b.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
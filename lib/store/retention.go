@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+)
+
+// FingerprintStats is the statistics of a signature across the stored
+// reports. They are kept when the reports expire.
+type FingerprintStats struct {
+	Fingerprint string `json:"fingerprint"`
+	// Count is the number of reports containing the signature.
+	Count int `json:"count"`
+	// FirstSeen and LastSeen are the creation time of the oldest and newest
+	// reports containing the signature.
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// FirstBuild and LastBuild are lib.Report.Build of the oldest and newest
+	// reports containing the signature, when known.
+	FirstBuild string `json:"firstBuild,omitempty"`
+	LastBuild  string `json:"lastBuild,omitempty"`
+	// Crashes is the number of reports where the signature is the one of the
+	// goroutine that crashed, i.e. stack.Bucket.First.
+	Crashes int `json:"crashes,omitempty"`
+	// Path is the call stack of the signature, innermost first, without the
+	// standard library. It is only set when Crashes is not 0.
+	Path []Site `json:"path,omitempty"`
+}
+
+// Stats returns the statistics of each signature of the reports stored by
+// PutReport, the most frequent first.
+func (d *Dir) Stats(ctx context.Context) ([]FingerprintStats, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.loadStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FingerprintStats, 0, len(m))
+	for _, s := range m {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out, nil
+}
+
+// History returns the history of the signatures of r from the reports
+// already stored, by fingerprint, to render with lib.Options.History. Call it
+// before storing r with PutReport. The new signatures are not in the result.
+func (d *Dir) History(ctx context.Context, r *lib.Report) (map[string]lib.SignatureHistory, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.loadStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]lib.SignatureHistory{}
+	for _, b := range r.Buckets {
+		f := b.Fingerprint()
+		if s := m[f]; s != nil {
+			out[f] = lib.SignatureHistory{Fingerprint: f, Count: s.Count, FirstSeen: s.FirstSeen, LastSeen: s.LastSeen, FirstBuild: s.FirstBuild}
+		}
+	}
+	return out, nil
+}
+
+// Expire removes the raw dumps older than DumpRetention and the reports older
+// than ReportRetention, based on the modification time of the files. The
+// fingerprint statistics are kept. It also removes the temporary files older
+// than an hour left by an interrupted Put.
+//
+// It returns the names of the objects removed.
+func (d *Dir) Expire(ctx context.Context) ([]string, error) {
+	// Don't race with Put replacing an object that was just listed.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entries, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	now := d.clock().Now()
+	var out []string
+	for _, fi := range entries {
+		name := fi.Name()
+		var ttl time.Duration
+		switch {
+		case strings.HasPrefix(name, tmpPrefix):
+			if !fi.IsDir() && now.Sub(fi.ModTime()) >= tmpRetention {
+				if err := os.Remove(filepath.Join(d.Path, name)); err != nil && !os.IsNotExist(err) {
+					return out, err
+				}
+			}
+			continue
+		case strings.HasSuffix(name, ".dump"):
+			ttl = d.DumpRetention
+		case strings.HasSuffix(name, ".json") && name != statsName:
+			ttl = d.ReportRetention
+		}
+		if ttl <= 0 || fi.IsDir() || now.Sub(fi.ModTime()) < ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.Path, name)); err != nil && !os.IsNotExist(err) {
+			return out, err
+		}
+		out = append(out, name)
+		if d.Audit != nil {
+			id := name[:strings.LastIndexByte(name, '.')]
+			if err := d.Audit.Record(lib.AuditEntry{Action: "expire", ReportID: id, Destination: d.Path}); err != nil {
+				return out, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// Janitor calls Expire every interval until ctx is done. interval defaults
+// to 1 hour.
+//
+// The errors are passed to onError, which can be nil.
+func (d *Dir) Janitor(ctx context.Context, interval time.Duration, onError func(err error)) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := d.Expire(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Private stuff.
+
+// statsName is the object holding the fingerprint statistics.
+const statsName = "fingerprints.json"
+
+// tmpPrefix is the prefix of the temporary files written by Put.
+const tmpPrefix = ".tmp"
+
+// tmpRetention is how long a temporary file can be left by Put before Expire
+// removes it. Put normally removes it within milliseconds but not when the
+// process dies in the middle.
+const tmpRetention = time.Hour
+
+func (d *Dir) clock() lib.Clock {
+	if d.Clock == nil {
+		return lib.SystemClock
+	}
+	return d.Clock
+}
+
+// loadStats must be called with d.mu held.
+func (d *Dir) loadStats(ctx context.Context) (map[string]*FingerprintStats, error) {
+	m := map[string]*FingerprintStats{}
+	b, err := d.Get(ctx, statsName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// addStats counts the report in the fingerprint statistics. It must be
+// called with d.mu held.
+func (d *Dir) addStats(ctx context.Context, r *lib.Report) error {
+	m, err := d.loadStats(ctx)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, b := range r.Buckets {
+		f := b.Fingerprint()
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		s := m[f]
+		if s == nil {
+			s = &FingerprintStats{Fingerprint: f, FirstSeen: *r.Created, FirstBuild: r.Build}
+			m[f] = s
+		}
+		s.Count++
+		if r.Created.Before(s.FirstSeen) {
+			s.FirstSeen = *r.Created
+			s.FirstBuild = r.Build
+		}
+		if !r.Created.Before(s.LastSeen) {
+			s.LastSeen = *r.Created
+			s.LastBuild = r.Build
+		}
+		if b.First {
+			s.Crashes++
+			if s.Path == nil {
+				s.Path = crashPath(&b.Signature)
+			}
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return d.put(ctx, statsName, b)
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestDirExpire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := lib.NewFakeClock(time.Now())
+	d := &Dir{Path: dir, Clock: clock, DumpRetention: 24 * time.Hour, ReportRetention: 72 * time.Hour}
+	ctx := context.Background()
+	c, err := stack.ParseDump(bytes.NewReader([]byte(dump)), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		r := &lib.Report{Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}
+		if err := d.PutReport(ctx, r, []byte(dump)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed, err := d.Expire(ctx); err != nil || len(removed) != 0 {
+		t.Fatalf("unexpected %v, %v", removed, err)
+	}
+
+	clock.Advance(48 * time.Hour)
+	removed, err := d.Expire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"00000000000000000000000000000001.dump", "00000000000000000000000000000002.dump"}
+	if len(removed) != 2 || removed[0] != want[0] || removed[1] != want[1] {
+		t.Fatalf("unexpected removed: %v", removed)
+	}
+	if _, dump, err := d.GetReport(ctx, "00000000000000000000000000000001"); err != nil || dump != nil {
+		t.Fatalf("unexpected %q, %v", dump, err)
+	}
+
+	clock.Advance(48 * time.Hour)
+	if removed, err = d.Expire(ctx); err != nil || len(removed) != 2 {
+		t.Fatalf("unexpected %v, %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "00000000000000000000000000000001.json")); !os.IsNotExist(err) {
+		t.Fatalf("report not removed: %v", err)
+	}
+
+	// The statistics are kept.
+	stats, err := d.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Count != 2 || stats[0].Fingerprint != c.Goroutines[0].Fingerprint() {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestDirJanitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := lib.NewFakeClock(time.Now().Add(time.Hour))
+	d := &Dir{Path: dir, Clock: clock, DumpRetention: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.Put(ctx, "a.dump", []byte(dump)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Janitor(ctx, time.Millisecond, func(err error) { t.Error(err) })
+	}()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, err := os.Stat(filepath.Join(dir, "a.dump")); os.IsNotExist(err) {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("dump not expired")
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestDirExpireConcurrentPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &Dir{Path: dir, DumpRetention: time.Hour}
+	ctx := context.Background()
+	old := time.Now().Add(-2 * time.Hour)
+	names := make([]string, 200)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d.dump", i)
+		if err := d.Put(ctx, names[i], []byte(dump)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, names[i]), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A temporary file left by an interrupted Put, and one being written.
+	stale := filepath.Join(dir, tmpPrefix+"1")
+	fresh := filepath.Join(dir, tmpPrefix+"2")
+	for _, p := range []string{stale, fresh} {
+		if err := ioutil.WriteFile(p, []byte(dump), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the expired objects while they are being expired.
+	done := make(chan error)
+	go func() {
+		for i := len(names) - 1; i >= 0; i-- {
+			if err := d.Put(ctx, names[i], []byte(dump)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for running := true; running; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			running = false
+		default:
+		}
+		if _, err := d.Expire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The objects put last are fresh.
+	for _, name := range names {
+		if _, err := d.Get(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale temporary file not removed: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := lib.NewFakeClock(start)
+	d := &Dir{Path: dir, Clock: clock}
+	ctx := context.Background()
+	c, err := stack.ParseDump(bytes.NewReader([]byte(dump)), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newReport := func(build string) *lib.Report {
+		return &lib.Report{Build: build, Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}
+	}
+	r := newReport("1.4.2")
+	if h, err := d.History(ctx, r); err != nil || len(h) != 0 {
+		t.Fatalf("unexpected %v, %v", h, err)
+	}
+	for _, build := range []string{"1.4.2", "1.5.0"} {
+		if err := d.PutReport(ctx, newReport(build), nil); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Hour)
+	}
+	r = newReport("1.5.1")
+	h, err := d.History(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := r.Buckets[0].Fingerprint()
+	want := lib.SignatureHistory{Fingerprint: f, Count: 2, FirstSeen: start, LastSeen: start.Add(time.Hour), FirstBuild: "1.4.2"}
+	if len(h) != 1 || h[f] != want {
+		t.Fatalf("want %#v, got %#v", want, h)
+	}
+	stats, err := d.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].LastBuild != "1.5.0" {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
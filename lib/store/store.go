@@ -0,0 +1,183 @@
+// Package store archives raw stack dumps and their reports, optionally
+// encrypted at rest.
+//
+// Dumps can contain sensitive data, e.g. the arguments of the calls, and the
+// archive is often shared widely, so the objects can be encrypted with
+// AES-GCM using keys from a KeyProvider.
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+)
+
+// Dir is an archive stored in a local directory, e.g. a mounted object store
+// bucket.
+//
+// Each report is stored as "<id>.dump" for the raw dump and "<id>.json" for
+// the report. The statistics of the signatures are stored in
+// "fingerprints.json", so "fingerprints" is not a valid report ID.
+type Dir struct {
+	// Path is the directory. It is created as needed.
+	Path string
+	// Keys encrypts the objects when set. The objects stored unencrypted stay
+	// readable.
+	Keys KeyProvider
+	// Clock provides the ID and the creation time of the reports without
+	// one. Defaults to lib.SystemClock.
+	Clock lib.Clock
+	// Audit records the reports stored and expired, when set.
+	Audit *lib.AuditLog
+	// DumpRetention is how long the raw dumps are kept, see Expire. 0 means
+	// forever.
+	DumpRetention time.Duration
+	// ReportRetention is how long the reports are kept, see Expire. 0 means
+	// forever.
+	ReportRetention time.Duration
+
+	mu sync.Mutex
+}
+
+// PutReport stores the raw dump and the report. The report ID and creation
+// time are set if they were empty. dump can be nil.
+func (d *Dir) PutReport(ctx context.Context, r *lib.Report, dump []byte) error {
+	c := d.clock()
+	if r.ID == "" {
+		r.ID = c.NewID()
+	}
+	if r.Created == nil {
+		now := c.Now().UTC()
+		r.Created = &now
+	}
+	var b bytes.Buffer
+	if err := lib.WriteReport(&b, r); err != nil {
+		return err
+	}
+	if err := d.putReport(ctx, r, dump, b.Bytes()); err != nil {
+		return err
+	}
+	if d.Audit == nil {
+		return nil
+	}
+	e := lib.AuditEntry{Action: "store", ReportID: r.ID, Destination: d.Path}
+	for _, bucket := range r.Buckets {
+		e.Fingerprints = append(e.Fingerprints, bucket.Fingerprint())
+	}
+	return d.Audit.Record(e)
+}
+
+// GetReport returns the raw dump and the report stored by PutReport. The dump
+// is nil if it wasn't stored.
+func (d *Dir) GetReport(ctx context.Context, id string) (*lib.Report, []byte, error) {
+	b, err := d.Get(ctx, id+".json")
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := lib.ReadJSON(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	dump, err := d.Get(ctx, id+".dump")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	return r, dump, nil
+}
+
+// Put stores an object, encrypted when Keys is set. The objects managed by
+// Dir itself, like the statistics, can't be replaced.
+//
+// The object is written to a temporary file first so a partial object is
+// never visible.
+func (d *Dir) Put(ctx context.Context, name string, data []byte) error {
+	if name == statsName {
+		return errors.New("reserved object name " + name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.put(ctx, name, data)
+}
+
+// Get returns an object stored by Put, decrypted.
+func (d *Dir) Get(ctx context.Context, name string) ([]byte, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil || !IsEncrypted(b) {
+		return b, err
+	}
+	if d.Keys == nil {
+		return nil, errors.New("object " + name + " is encrypted and no key provider is set")
+	}
+	return Decrypt(ctx, d.Keys, b)
+}
+
+// Private stuff.
+
+// putReport stores the objects of a report and counts it in the statistics.
+func (d *Dir) putReport(ctx context.Context, r *lib.Report, dump, report []byte) error {
+	if r.ID+".json" == statsName {
+		return errors.New("reserved report ID " + r.ID)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dump != nil {
+		if err := d.put(ctx, r.ID+".dump", dump); err != nil {
+			return err
+		}
+	}
+	if err := d.put(ctx, r.ID+".json", report); err != nil {
+		return err
+	}
+	return d.addStats(ctx, r)
+}
+
+// put is Put. It must be called with d.mu held, so Expire doesn't remove an
+// object being replaced.
+func (d *Dir) put(ctx context.Context, name string, data []byte) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if d.Keys != nil {
+		if data, err = Encrypt(ctx, d.Keys, data); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(d.Path, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(d.Path, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (d *Dir) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", errors.New("invalid object name " + name)
+	}
+	return filepath.Join(d.Path, name), nil
+}
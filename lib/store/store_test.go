@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/lib"
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+const dump = "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+
+func TestDirEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("PANICPARSE_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	defer os.Unsetenv("PANICPARSE_TEST_KEY")
+	a, err := lib.OpenAuditLog(filepath.Join(dir, "audit.log"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	d := &Dir{
+		Path:  filepath.Join(dir, "archive"),
+		Keys:  &EnvKeyProvider{Var: "PANICPARSE_TEST_KEY"},
+		Clock: lib.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Audit: a,
+	}
+	ctx := context.Background()
+	c, err := stack.ParseDump(bytes.NewReader([]byte(dump)), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &lib.Report{Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}
+	if err := d.PutReport(ctx, r, []byte(dump)); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != "00000000000000000000000000000001" || r.Created == nil {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+
+	// The objects on disk are encrypted.
+	raw, err := ioutil.ReadFile(filepath.Join(d.Path, r.ID+".dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(raw) || bytes.Contains(raw, []byte("main.main")) {
+		t.Fatalf("not encrypted: %q", raw)
+	}
+
+	got, gotDump, err := d.GetReport(ctx, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != r.ID || len(got.Buckets) != 1 || string(gotDump) != dump {
+		t.Fatalf("unexpected report: %#v, %q", got, gotDump)
+	}
+
+	// Without the key, the objects can't be read.
+	if _, err := (&Dir{Path: d.Path}).Get(ctx, r.ID+".dump"); err == nil {
+		t.Fatal("expected error")
+	}
+	// A tampered object is rejected.
+	raw[len(raw)-1] ^= 1
+	if _, err := Decrypt(ctx, d.Keys, raw); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := d.Put(ctx, "../escape", nil); err == nil {
+		t.Fatal("expected error")
+	}
+
+	f, err := os.Open(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := lib.VerifyAuditLog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ReportID != r.ID || len(entries[0].Fingerprints) != 1 {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
+
+func TestDirPlaintext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &Dir{Path: dir}
+	ctx := context.Background()
+	if err := d.Put(ctx, "a.dump", []byte(dump)); err != nil {
+		t.Fatal(err)
+	}
+	// Objects stored unencrypted stay readable once a key is set.
+	d.Keys = &KMSKeyProvider{KMS: &fakeKMS{}}
+	if b, err := d.Get(ctx, "a.dump"); err != nil || string(b) != dump {
+		t.Fatalf("unexpected %q, %v", b, err)
+	}
+}
+
+func TestDirReservedNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &Dir{Path: dir, Clock: lib.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))}
+	ctx := context.Background()
+	c, err := stack.ParseDump(bytes.NewReader([]byte(dump)), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutReport(ctx, &lib.Report{Buckets: stack.Aggregate(c.Goroutines, stack.AnyPointer)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// A report can't replace the statistics.
+	if err := d.PutReport(ctx, &lib.Report{ID: "fingerprints"}, []byte(dump)); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := d.Put(ctx, statsName, []byte("{}")); err == nil {
+		t.Fatal("expected error")
+	}
+	if s, err := d.Stats(ctx); err != nil || len(s) != 1 || s[0].Count != 1 {
+		t.Fatalf("unexpected stats %#v, %v", s, err)
+	}
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	k := &KMSKeyProvider{KMS: &fakeKMS{}}
+	ctx := context.Background()
+	b, err := Encrypt(ctx, k, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Decrypt(ctx, k, b)
+	if err != nil || string(out) != "secret" {
+		t.Fatalf("unexpected %q, %v", out, err)
+	}
+	if _, err := Decrypt(ctx, &KMSKeyProvider{KMS: &fakeKMS{fail: true}}, b); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// fakeKMS wraps the data keys with a fixed master key.
+type fakeKMS struct {
+	fail bool
+}
+
+func (f *fakeKMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	aead := f.aead()
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if f.fail {
+		return nil, errors.New("access denied")
+	}
+	aead := f.aead()
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+func (f *fakeKMS) aead() cipher.AEAD {
+	b, _ := aes.NewCipher(bytes.Repeat([]byte{2}, 16))
+	aead, _ := cipher.NewGCM(b)
+	return aead
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Summarize returns a one line summary of the dump fitting in maxChars
+// characters, for chat bots and alert bodies where the full stacks don't
+// fit, e.g.:
+//
+//	panic: nil map in order.Process (order.go:88); 212 goroutines, 150 chan receive in pool.(*Pool).Get for 12m
+//
+// It starts with the crash message and the function that crashed, followed
+// by the number of goroutines and the largest buckets, as many as fit. The
+// crash message is truncated with "…" if it doesn't fit by itself. maxChars
+// 0 means no limit.
+func Summarize(c *stack.Context, maxChars int) string {
+	buckets := stack.Aggregate(c.Goroutines, stack.AnyPointer)
+	var head string
+	if h := headline(c.Segments); h != "" {
+		head = strings.SplitN(h, "\n", 2)[0]
+	} else if c.Crash != stack.CrashNone {
+		head = c.Crash.String()
+	}
+	var first *stack.Bucket
+	for _, b := range buckets {
+		if b.First {
+			first = b
+			break
+		}
+	}
+	if first != nil && c.Crash.IsCrash() {
+		if call := summaryCall(first); call != nil {
+			head += " in " + call.Func.PkgDotName() + " (" + formatCall(call) + ")"
+		}
+	}
+	n := fmt.Sprintf("%d goroutines", len(c.Goroutines))
+	if len(c.Goroutines) == 1 {
+		n = "1 goroutine"
+	}
+	out := n
+	if head != "" {
+		out = head + "; " + n
+	}
+	if maxChars > 0 && utf8.RuneCountInString(out) > maxChars {
+		return truncateRunes(out, maxChars)
+	}
+
+	// The largest buckets first.
+	sorted := make([]*stack.Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b != first || !c.Crash.IsCrash() {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Size() > sorted[j].Size()
+	})
+	for _, b := range sorted {
+		s := fmt.Sprintf("%d %s", b.Size(), b.State)
+		if call := summaryCall(b); call != nil {
+			s += " in " + call.Func.PkgDotName()
+		}
+		if b.SleepMax != 0 {
+			s += fmt.Sprintf(" for %dm", b.SleepMax)
+		}
+		next := out + ", " + s
+		if maxChars > 0 && utf8.RuneCountInString(next) > maxChars {
+			break
+		}
+		out = next
+	}
+	return out
+}
+
+// Private stuff.
+
+// summaryCall returns the innermost call outside of package runtime, or the
+// innermost call if all of them are.
+func summaryCall(b *stack.Bucket) *stack.Call {
+	calls := b.Stack.Calls
+	for i := range calls {
+		if calls[i].Func.PkgName() != "runtime" {
+			return &calls[i]
+		}
+	}
+	if len(calls) != 0 {
+		return &calls[0]
+	}
+	return nil
+}
+
+// truncateRunes truncates s to max runes, ending with "…" when truncated.
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max-1]) + "…"
+}
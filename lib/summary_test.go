@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestSummarize(t *testing.T) {
+	c, err := stack.ParseDump(strings.NewReader(goldenDump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		max  int
+		want string
+	}{
+		{0, "panic: oh no in main.crash (main.go:12); 3 goroutines, 2 chan receive in worker.(*Pool).run for 5m"},
+		{60, "panic: oh no in main.crash (main.go:12); 3 goroutines"},
+		{20, "panic: oh no in mai…"},
+	}
+	for i, line := range data {
+		if got := Summarize(c, line.max); got != line.want {
+			t.Errorf("#%d: want %q, got %q", i, line.want, got)
+		}
+	}
+}
+
+func TestSummarizeSnapshot(t *testing.T) {
+	c, err := stack.ParseDump(strings.NewReader("goroutine 1 [select, 12 minutes]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Summarize(c, 0), "1 goroutine, 1 select in main.main for 12m"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Unescape decodes the backslash escapes and HTML entities of a stack trace
+// that was embedded in a JSON log or a web page and copied without its
+// surroundings, e.g.:
+//
+//	panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:4 +0x25
+//
+// It decodes \n, \r, \t, \", \\, \/ and \uXXXX including surrogate pairs, then
+// HTML entities like &lt; or &#9;. Unknown escapes are kept as-is.
+//
+// Contrary to Normalize, the input doesn't have to be a valid JSON string.
+func Unescape(s string) string {
+	if strings.IndexByte(s, '\\') != -1 {
+		s = unescapeBackslash(s)
+	}
+	if strings.IndexByte(s, '&') != -1 {
+		s = html.UnescapeString(s)
+	}
+	return s
+}
+
+// Private stuff.
+
+func unescapeBackslash(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch c := s[i+1]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '/':
+			b.WriteByte(c)
+		case 'u':
+			r, n := decodeU(s[i:])
+			if n == 0 {
+				b.WriteByte('\\')
+				continue
+			}
+			b.WriteRune(r)
+			i += n - 2
+		default:
+			b.WriteByte('\\')
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
+
+// decodeU decodes "\uXXXX" at the start of s, including a following low
+// surrogate. Returns the number of bytes consumed, 0 on failure.
+func decodeU(s string) (rune, int) {
+	if len(s) < 6 {
+		return 0, 0
+	}
+	v, err := strconv.ParseUint(s[2:6], 16, 16)
+	if err != nil {
+		return 0, 0
+	}
+	r := rune(v)
+	if r >= 0xD800 && r < 0xDC00 && len(s) >= 12 && s[6] == '\\' && s[7] == 'u' {
+		if lo, err := strconv.ParseUint(s[8:12], 16, 16); err == nil && lo >= 0xDC00 && lo < 0xE000 {
+			return (r-0xD800)<<10 + (rune(lo) - 0xDC00) + 0x10000, 12
+		}
+	}
+	if r >= 0xD800 && r < 0xE000 {
+		r = utf8.RuneError
+	}
+	return r, 6
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestUnescape(t *testing.T) {
+	data := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"logfmt",
+			`panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:4 +0x25\n`,
+			sniffPanic,
+		},
+		{
+			"unicode",
+			`panic: oh no\u000a\u000agoroutine 1 [running]:\u000amain.main()\u000a\u0009/tmp/main.go:4 +0x25\u000a`,
+			sniffPanic,
+		},
+		{
+			"html",
+			"panic: &lt;nil&gt; &quot;x&quot;\n\tfoo&#9;bar",
+			"panic: <nil> \"x\"\n\tfoo\tbar",
+		},
+		{
+			"windows paths and unknown escapes",
+			`C:\\go\\src\\main.go:4 \x \ud83d\ude00 \ud83d`,
+			"C:\\go\\src\\main.go:4 \\x \U0001F600 \uFFFD",
+		},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			if got := Unescape(line.in); got != line.want {
+				t.Fatalf("want %q, got %q", line.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeEscaped(t *testing.T) {
+	got, err := Normalize([]byte(`panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:4 +0x25\n`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != sniffPanic {
+		t.Fatalf("want %q, got %q", sniffPanic, got)
+	}
+}
+
+func TestParsePanicStringOptsUnescape(t *testing.T) {
+	out, err := ParsePanicStringOpts(`panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/main.go:4 +0x25\n`, &Options{Unescape: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] == "" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+// NewBundleViewer returns a handler serving a crash bundle read with
+// ReadBundle.
+//
+// "/" is a self contained HTML page with the manifest, the findings, the text
+// report and the source snippets, so the incident can be explored without the
+// original sources. The files of the bundle are served as an API for other
+// front ends, e.g. a terminal UI:
+//
+//	/api/manifest  the BundleManifest as JSON
+//	/api/report    the Report as JSON
+//	/api/snippets  the []Snippet as JSON
+//	/api/text      the text report
+//	/api/dump      the raw dump
+func NewBundleViewer(b *Bundle) http.Handler {
+	return &bundleViewer{b: b}
+}
+
+// Private stuff.
+
+type bundleViewer struct {
+	b *Bundle
+}
+
+func (v *bundleViewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := bundleTmpl.Execute(w, &bundlePage{Bundle: v.b, DumpURL: "/api/dump"}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "/api/manifest":
+		serveJSON(w, &v.b.Manifest)
+	case "/api/report":
+		serveJSON(w, v.b.Report)
+	case "/api/snippets":
+		serveJSON(w, v.b.Snippets)
+	case "/api/text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(v.b.Text))
+	case "/api/dump":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(v.b.Dump)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// bundlePage is the data of bundleTmpl.
+type bundlePage struct {
+	*Bundle
+	// DumpURL is the link to the raw dump.
+	DumpURL string
+}
+
+func serveJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	_ = e.Encode(v)
+}
+
+var bundleTmpl = template.Must(template.New("bundle").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Crash bundle: {{.Manifest.Crash}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+th { text-align: left; padding-right: 1em; }
+.current { background: #fff3b0; }
+</style>
+</head>
+<body>
+<h1>Crash bundle: {{.Manifest.Crash}}</h1>
+<table>
+<tr><th>Created</th><td>{{.Manifest.Created}}</td></tr>
+<tr><th>Goroutines</th><td>{{.Manifest.Goroutines}}</td></tr>
+<tr><th>Producer</th><td>{{.Manifest.Producer}}</td></tr>
+{{- range $k, $v := .Manifest.Metadata}}
+<tr><th>{{$k}}</th><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{- if .Report}}{{if .Report.Findings}}
+<h2>Findings</h2>
+<ul>
+{{- range .Report.Findings}}
+<li>[{{.Severity}}] {{.Analyzer}} ({{.Confidence}} confidence): {{.Message}}</li>
+{{- end}}
+</ul>
+{{- end}}{{end}}
+<h2>Report</h2>
+<pre>{{.Text}}</pre>
+{{- if .Snippets}}
+<h2>Sources</h2>
+{{- range .Snippets}}
+<h3>{{.Func}} at {{.Location}}</h3>
+<pre>
+{{- range .Lines}}
+{{if .Current}}<span class="current">{{printf "%5d" .Number}} | {{.Text}}</span>{{else}}{{printf "%5d" .Number}} | {{.Text}}{{end}}
+{{- end}}
+</pre>
+{{- end}}
+{{- end}}
+<p><a href="{{.DumpURL}}">Raw dump</a></p>
+</body>
+</html>
+`))
@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundleViewer(t *testing.T) {
+	b := &Bundle{
+		Manifest: BundleManifest{Version: BundleVersion, Created: time.Unix(0, 0).UTC(), Crash: "panic", Goroutines: 1, Metadata: map[string]string{"host": "<foo>"}},
+		Dump:     []byte("panic: boom\n"),
+		Report:   &Report{SchemaVersion: SchemaVersion},
+		Text:     "1: running\nmain main.go:4 main()\n",
+		Snippets: []Snippet{{Location: "main.go:4", Func: "main.main", Lines: []SourceLine{{Number: 4, Text: "\tpanic(\"boom\")", Current: true}}}},
+	}
+	s := httptest.NewServer(NewBundleViewer(b))
+	defer s.Close()
+	get := func(p string) (int, string) {
+		resp, err := http.Get(s.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+	code, page := get("/")
+	if code != 200 || !strings.Contains(page, "main main.go:4 main()") || !strings.Contains(page, "&lt;foo&gt;") || !strings.Contains(page, `<span class="current">    4 | 	panic(&#34;boom&#34;)</span>`) {
+		t.Fatalf("unexpected page %d:\n%s", code, page)
+	}
+	code, body := get("/api/manifest")
+	var m BundleManifest
+	if err := json.Unmarshal([]byte(body), &m); err != nil || code != 200 || m.Crash != "panic" {
+		t.Fatalf("unexpected manifest %d %q: %v", code, body, err)
+	}
+	if code, body = get("/api/dump"); code != 200 || body != "panic: boom\n" {
+		t.Fatalf("unexpected dump %d %q", code, body)
+	}
+	if code, _ = get("/nope"); code != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", code)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// Activity is a coarse classification of what a goroutine was doing at the
+// time of the snapshot.
+type Activity int
+
+const (
+	// ActivityUnknown is used when the state is not recognized, e.g. a wait
+	// reason added in a Go version more recent than this package.
+	ActivityUnknown Activity = iota
+	// ActivityCPU is a goroutine running or runnable, that is using or waiting
+	// for a CPU. It includes the "runtime stack" of the thread that crashed.
+	ActivityCPU
+	// ActivitySyscall is a goroutine blocked in a system call.
+	ActivitySyscall
+	// ActivityParked is a goroutine parked by the scheduler, waiting on a
+	// channel, a lock, a timer, network I/O, etc.
+	ActivityParked
+)
+
+func (a Activity) String() string {
+	switch a {
+	case ActivityCPU:
+		return "cpu"
+	case ActivitySyscall:
+		return "syscall"
+	case ActivityParked:
+		return "parked"
+	default:
+		return "unknown"
+	}
+}
+
+// Activity returns the classification of the goroutine state.
+func (s *Signature) Activity() Activity {
+	switch s.WaitReason() {
+	case WaitRunning, WaitRunnable, WaitCopyStack, WaitPreempted:
+		return ActivityCPU
+	case WaitSyscall:
+		return ActivitySyscall
+	case WaitIdle, WaitDead:
+		return ActivityUnknown
+	case WaitUnknown:
+		if s.State == runtimeStack {
+			// The system stack of the thread that crashed; it was running.
+			return ActivityCPU
+		}
+		// Don't guess, it could be a new way to run or to block.
+		return ActivityUnknown
+	default:
+		// Everything else is a wait reason passed to gopark().
+		return ActivityParked
+	}
+}
+
+// ActivityStats is the number of goroutines per Activity.
+type ActivityStats struct {
+	CPU     int
+	Syscall int
+	Parked  int
+	Unknown int
+}
+
+// Total returns the total number of goroutines.
+func (a *ActivityStats) Total() int {
+	return a.CPU + a.Syscall + a.Parked + a.Unknown
+}
+
+// Percent returns the percentage of goroutines with this activity, between 0
+// and 100.
+func (a *ActivityStats) Percent(act Activity) float64 {
+	t := a.Total()
+	if t == 0 {
+		return 0
+	}
+	n := a.Unknown
+	switch act {
+	case ActivityCPU:
+		n = a.CPU
+	case ActivitySyscall:
+		n = a.Syscall
+	case ActivityParked:
+		n = a.Parked
+	}
+	return 100. * float64(n) / float64(t)
+}
+
+func (a *ActivityStats) add(act Activity, n int) {
+	switch act {
+	case ActivityCPU:
+		a.CPU += n
+	case ActivitySyscall:
+		a.Syscall += n
+	case ActivityParked:
+		a.Parked += n
+	default:
+		a.Unknown += n
+	}
+}
+
+// Activities returns the number of goroutines per Activity, to quickly tell if
+// the process is spinning or stuck.
+func (c *Context) Activities() ActivityStats {
+	var a ActivityStats
+	for _, g := range c.Goroutines {
+		a.add(g.Activity(), 1)
+	}
+	return a
+}
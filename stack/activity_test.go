@@ -0,0 +1,57 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+)
+
+func TestActivity(t *testing.T) {
+	t.Parallel()
+	data := map[string]Activity{
+		"running":        ActivityCPU,
+		"runnable":       ActivityCPU,
+		"scanrunnable":   ActivityCPU,
+		"syscall":        ActivitySyscall,
+		"chan receive":   ActivityParked,
+		"select":         ActivityParked,
+		"IO wait":        ActivityParked,
+		"sleep":          ActivityParked,
+		"GC assist wait": ActivityParked,
+		"runtime stack":  ActivityCPU,
+		"":               ActivityUnknown,
+		"idle":           ActivityUnknown,
+		"waiting":        ActivityUnknown,
+		"new wait":       ActivityUnknown,
+	}
+	for state, want := range data {
+		s := Signature{State: state}
+		if got := s.Activity(); got != want {
+			t.Errorf("%q: got %s, want %s", state, got, want)
+		}
+	}
+}
+
+func TestContextActivities(t *testing.T) {
+	t.Parallel()
+	c := &Context{
+		Goroutines: []*Goroutine{
+			{Signature: Signature{State: "running"}},
+			{Signature: Signature{State: "syscall"}},
+			{Signature: Signature{State: "chan receive"}},
+			{Signature: Signature{State: "select"}},
+		},
+	}
+	a := c.Activities()
+	if a != (ActivityStats{CPU: 1, Syscall: 1, Parked: 2}) {
+		t.Fatalf("unexpected %#v", a)
+	}
+	if p := a.Percent(ActivityParked); p != 50 {
+		t.Fatalf("want 50%%, got %g", p)
+	}
+	if p := (&ActivityStats{}).Percent(ActivityCPU); p != 0 {
+		t.Fatalf("want 0%%, got %g", p)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package analysis implements targeted analyses of parsed stack dumps, e.g.
+// to explain deadlocks.
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Private stuff.
+
+// isStdlibFunc returns true if the function is likely from the standard
+// library.
+//
+// Call.IsStdlib is only set when guesspaths was used so fallback on the
+// import path; the first element of a standard library import path doesn't
+// contain a dot.
+func isStdlibFunc(c *stack.Call) bool {
+	if c.IsStdlib {
+		return true
+	}
+	if c.IsPkgMain() {
+		return false
+	}
+	s := c.Func.String()
+	i := strings.IndexByte(s, '/')
+	if i == -1 {
+		// e.g. "runtime.gopark" or "sync.(*WaitGroup).Wait".
+		return true
+	}
+	return !strings.Contains(s[:i], ".")
+}
+
+// userCall returns the innermost call that is not in the standard library,
+// falling back to the innermost call outside of package runtime.
+func userCall(s *stack.Signature) *stack.Call {
+	for i := range s.Stack.Calls {
+		if !isStdlibFunc(&s.Stack.Calls[i]) {
+			return &s.Stack.Calls[i]
+		}
+	}
+	for i := range s.Stack.Calls {
+		if s.Stack.Calls[i].Func.PkgName() != "runtime" {
+			return &s.Stack.Calls[i]
+		}
+	}
+	if len(s.Stack.Calls) != 0 {
+		return &s.Stack.Calls[0]
+	}
+	return nil
+}
+
+// location returns "pkg.Func at file.go:123".
+func location(c *stack.Call) string {
+	if c == nil {
+		return "unknown location"
+	}
+	return fmt.Sprintf("%s at %s:%d", c.Func.PkgDotName(), c.SrcName(), c.Line)
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Baseline is the expected buckets of a healthy process, keyed by
+// stack.Signature.Fingerprint().
+//
+// It is meant to be serialized as JSON and stored, then used to only report
+// what changed in a new dump.
+type Baseline struct {
+	Buckets map[string]BaselineBucket `json:"buckets"`
+}
+
+// BaselineBucket is an expected bucket.
+type BaselineBucket struct {
+	// Count is the number of goroutines expected.
+	Count int `json:"count"`
+	// Location is where the goroutines are, e.g. "foo.Serve at foo.go:12".
+	Location string `json:"location"`
+}
+
+// NewBaseline records the buckets of a healthy process.
+//
+// Buckets with the same fingerprint, e.g. in different states, are combined.
+func NewBaseline(buckets []*stack.Bucket) *Baseline {
+	b := &Baseline{Buckets: map[string]BaselineBucket{}}
+	for _, bucket := range buckets {
+		f := bucket.Fingerprint()
+		e := b.Buckets[f]
+		e.Count += bucket.Size()
+		e.Location = location(userCall(&bucket.Signature))
+		b.Buckets[f] = e
+	}
+	return b
+}
+
+// DeviationKind is how a bucket differs from the baseline.
+type DeviationKind int
+
+const (
+	// DeviationNew is a bucket not in the baseline.
+	DeviationNew DeviationKind = iota
+	// DeviationMissing is a baseline bucket not in the dump.
+	DeviationMissing
+	// DeviationGrew is a bucket with more goroutines than expected.
+	DeviationGrew
+	// DeviationShrank is a bucket with fewer goroutines than expected.
+	DeviationShrank
+)
+
+func (d DeviationKind) String() string {
+	switch d {
+	case DeviationNew:
+		return "new"
+	case DeviationMissing:
+		return "missing"
+	case DeviationGrew:
+		return "grew"
+	case DeviationShrank:
+		return "shrank"
+	default:
+		return "unknown"
+	}
+}
+
+// Deviation is a difference between a dump and the baseline.
+type Deviation struct {
+	Kind        DeviationKind
+	Fingerprint string
+	// Buckets is the buckets in the dump with this fingerprint. Empty for
+	// DeviationMissing.
+	Buckets []*stack.Bucket
+	// Want is the number of goroutines in the baseline.
+	Want int
+	// Got is the number of goroutines in the dump.
+	Got int
+	// Location is where the goroutines are.
+	Location string
+}
+
+func (d *Deviation) String() string {
+	return fmt.Sprintf("%s: %s (%d -> %d)", d.Kind, d.Location, d.Want, d.Got)
+}
+
+// Compare returns how the buckets differ from the baseline.
+//
+// Count changes are only reported when the relative change is above
+// threshold, e.g. 0.5 to ignore changes within ±50%. The deviations are sorted
+// by kind, then by the size of the change, largest first.
+func (b *Baseline) Compare(buckets []*stack.Bucket, threshold float64) []Deviation {
+	got := map[string]*Deviation{}
+	for _, bucket := range buckets {
+		f := bucket.Fingerprint()
+		d := got[f]
+		if d == nil {
+			d = &Deviation{Fingerprint: f, Location: location(userCall(&bucket.Signature))}
+			got[f] = d
+		}
+		d.Buckets = append(d.Buckets, bucket)
+		d.Got += bucket.Size()
+	}
+	var out []Deviation
+	for f, d := range got {
+		e, ok := b.Buckets[f]
+		if !ok {
+			d.Kind = DeviationNew
+			out = append(out, *d)
+			continue
+		}
+		d.Want = e.Count
+		delta := d.Got - d.Want
+		if delta < 0 {
+			delta = -delta
+		}
+		if float64(delta) <= threshold*float64(d.Want) {
+			continue
+		}
+		d.Kind = DeviationGrew
+		if d.Got < d.Want {
+			d.Kind = DeviationShrank
+		}
+		out = append(out, *d)
+	}
+	for f, e := range b.Buckets {
+		if _, ok := got[f]; !ok {
+			out = append(out, Deviation{Kind: DeviationMissing, Fingerprint: f, Want: e.Count, Location: e.Location})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		if di, dj := abs(out[i].Got-out[i].Want), abs(out[j].Got-out[j].Want); di != dj {
+			return di > dj
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out
+}
+
+// Private stuff.
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
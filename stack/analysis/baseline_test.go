@@ -0,0 +1,53 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestBaselineCompare(t *testing.T) {
+	t.Parallel()
+	dump := func(counts map[string]int) []*stack.Bucket {
+		var data []string
+		id := 1
+		for _, f := range []string{"serve", "poll", "leak"} {
+			for i := 0; i < counts[f]; i++ {
+				data = append(data,
+					fmt.Sprintf("goroutine %d [chan receive]:", id),
+					"main."+f+"()",
+					"	/home/user/src/foo/main.go:10 +0x45",
+					"")
+				id++
+			}
+		}
+		return stack.Aggregate(parse(t, strings.Join(data, "\n")).Goroutines, stack.AnyPointer)
+	}
+	b := NewBaseline(dump(map[string]int{"serve": 10, "poll": 1}))
+	if len(b.Buckets) != 2 {
+		t.Fatalf("unexpected baseline: %v", b)
+	}
+	if d := b.Compare(dump(map[string]int{"serve": 12, "poll": 1}), 0.5); len(d) != 0 {
+		t.Fatalf("unexpected deviations: %v", d)
+	}
+	d := b.Compare(dump(map[string]int{"serve": 40, "leak": 3}), 0.5)
+	want := []string{
+		"new: main.leak at main.go:10 (0 -> 3)",
+		"missing: main.poll at main.go:10 (1 -> 0)",
+		"grew: main.serve at main.go:10 (10 -> 40)",
+	}
+	if len(d) != len(want) {
+		t.Fatalf("unexpected deviations: %v", d)
+	}
+	for i := range want {
+		if s := d[i].String(); s != want[i] {
+			t.Errorf("#%d: want %q, got %q", i, want[i], s)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// FrameContext is the declaration of the function of a frame, read from the
+// source, to give the context that the stack dump lacks.
+type FrameContext struct {
+	// Call is the frame.
+	Call *stack.Call
+	// Decl is the function declaration, e.g.
+	// "func (s *Server) handle(ctx context.Context, r *Request) error". For a
+	// closure, it is the function literal, e.g. "func(i int)".
+	Decl string
+	// Receiver is the receiver type of a method, e.g. "*Server".
+	Receiver string
+	// Params is the parameters, e.g. ["ctx context.Context", "r *Request"].
+	Params []string
+	// Results is the results, e.g. ["error"].
+	Results []string
+	// Enclosing is the declaration of the function containing the closure. It
+	// is empty if the frame is not a closure.
+	Enclosing string
+}
+
+// EnrichCulprit returns the declaration of the functions of the goroutine
+// that crashed, for the frames where the arguments were elided or not
+// printed.
+//
+// Since Go 1.17 the arguments are passed in registers and the dump often
+// elides them, so the signature and the receiver type are the only context
+// left. The sources must be available locally, i.e. the stack trace must be
+// parsed with guesspaths.
+//
+// Each file is parsed on its own with go/parser rather than loading the
+// packages with golang.org/x/tools/go/packages, which this module doesn't
+// depend on. So only the syntax is analyzed: the types are as written in
+// the source, not resolved, and no call graph is built.
+func EnrichCulprit(c *stack.Context) []FrameContext {
+	g := c.Culprit()
+	if g == nil {
+		return nil
+	}
+	files := map[string]*srcFile{}
+	var out []FrameContext
+	for i := range g.Stack.Calls {
+		call := &g.Stack.Calls[i]
+		if isStdlibFunc(call) || call.LocalSrcPath == "" {
+			continue
+		}
+		f, ok := files[call.LocalSrcPath]
+		if !ok {
+			f = &srcFile{fset: token.NewFileSet()}
+			// Errors are ignored, a partial AST is still useful.
+			f.f, _ = parser.ParseFile(f.fset, call.LocalSrcPath, nil, 0)
+			files[call.LocalSrcPath] = f
+		}
+		if f.f == nil {
+			continue
+		}
+		if fc := f.frameContext(call); fc != nil {
+			out = append(out, *fc)
+		}
+	}
+	return out
+}
+
+// Private stuff.
+
+// srcFile is a parsed source file.
+type srcFile struct {
+	fset *token.FileSet
+	f    *ast.File
+}
+
+// frameContext returns the context of call if its arguments are missing.
+func (f *srcFile) frameContext(call *stack.Call) *FrameContext {
+	var decl *ast.FuncDecl
+	var lit *ast.FuncLit
+	closure := strings.Contains(call.Func.Name(), ".func")
+	ast.Inspect(f.f, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.FuncDecl:
+			if t.Body != nil && f.containsLine(t, call.Line) {
+				decl = t
+				return closure
+			}
+			return false
+		case *ast.FuncLit:
+			if closure && f.containsLine(t, call.Line) {
+				// Keep the innermost one.
+				lit = t
+			}
+		}
+		return true
+	})
+	if decl == nil {
+		return nil
+	}
+	typ := decl.Type
+	if lit != nil {
+		typ = lit.Type
+	}
+	params := fieldList(typ.Params)
+	if !call.Args.Elided && (len(call.Args.Values) != 0 || len(params) == 0) {
+		return nil
+	}
+	out := &FrameContext{Call: call, Params: params, Results: fieldList(typ.Results)}
+	if decl.Recv != nil && len(decl.Recv.List) != 0 {
+		out.Receiver = types.ExprString(decl.Recv.List[0].Type)
+	}
+	d := funcDecl(decl)
+	if lit != nil {
+		out.Decl = "func(" + strings.Join(out.Params, ", ") + ")" + results(lit.Type.Results)
+		out.Enclosing = d
+		out.Receiver = ""
+	} else {
+		out.Decl = d
+	}
+	return out
+}
+
+// containsLine returns true if the node spans the line.
+func (f *srcFile) containsLine(n ast.Node, line int) bool {
+	return f.fset.Position(n.Pos()).Line <= line && line <= f.fset.Position(n.End()).Line
+}
+
+// funcDecl renders the declaration without the body.
+func funcDecl(d *ast.FuncDecl) string {
+	var b strings.Builder
+	b.WriteString("func ")
+	if d.Recv != nil && len(d.Recv.List) != 0 {
+		b.WriteString("(" + strings.Join(fieldList(d.Recv), ", ") + ") ")
+	}
+	b.WriteString(d.Name.Name)
+	b.WriteString("(" + strings.Join(fieldList(d.Type.Params), ", ") + ")")
+	b.WriteString(results(d.Type.Results))
+	return b.String()
+}
+
+// results renders the results of a signature.
+func results(l *ast.FieldList) string {
+	r := fieldList(l)
+	switch {
+	case len(r) == 0:
+		return ""
+	case len(l.List) == 1 && len(l.List[0].Names) == 0:
+		return " " + r[0]
+	default:
+		return " (" + strings.Join(r, ", ") + ")"
+	}
+}
+
+// fieldList renders each field, e.g. "a, b int".
+func fieldList(l *ast.FieldList) []string {
+	if l == nil {
+		return nil
+	}
+	var out []string
+	for _, f := range l.List {
+		t := types.ExprString(f.Type)
+		if len(f.Names) == 0 {
+			out = append(out, t)
+			continue
+		}
+		names := make([]string, len(f.Names))
+		for i, n := range f.Names {
+			names[i] = n.Name
+		}
+		out = append(out, strings.Join(names, ", ")+" "+t)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnrichCulprit(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := []string{
+		"package main",
+		"",
+		"func (s *Server) handle(ctx context.Context, a, b int) (n int, err error) {",
+		"	for i := range s.items {",
+		"		func(i int) {",
+		"			panic(i)",
+		"		}(i)",
+		"	}",
+		"	return 0, nil",
+		"}",
+		"",
+		"func main() {",
+		"	new(Server).handle(nil, 1, 2)",
+		"}",
+		"",
+		"func known(x int) error {",
+		"	return nil",
+		"}",
+	}
+	if err := ioutil.WriteFile(main, []byte(strings.Join(src, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := []string{
+		"panic: 1",
+		"",
+		"goroutine 1 [running]:",
+		"main.(*Server).handle.func1(...)",
+		"	" + main + ":6",
+		"main.(*Server).handle(0xc000010000, {0x0, 0x0}, ...)",
+		"	" + main + ":7 +0x25",
+		"main.known(0x1)",
+		"	" + main + ":17 +0x25",
+		"main.main()",
+		"	" + main + ":13 +0x25",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	for _, g := range c.Goroutines {
+		for i := range g.Stack.Calls {
+			g.Stack.Calls[i].LocalSrcPath = main
+		}
+	}
+	type frame struct {
+		Func      string
+		Decl      string
+		Receiver  string
+		Params    []string
+		Results   []string
+		Enclosing string
+	}
+	var got []frame
+	for _, f := range EnrichCulprit(c) {
+		got = append(got, frame{f.Call.Func.Name(), f.Decl, f.Receiver, f.Params, f.Results, f.Enclosing})
+	}
+	want := []frame{
+		{
+			Func:      "(*Server).handle.func1",
+			Decl:      "func(i int)",
+			Params:    []string{"i int"},
+			Enclosing: "func (s *Server) handle(ctx context.Context, a, b int) (n int, err error)",
+		},
+		{
+			Func:     "(*Server).handle",
+			Decl:     "func (s *Server) handle(ctx context.Context, a, b int) (n int, err error)",
+			Receiver: "*Server",
+			Params:   []string{"ctx context.Context", "a, b int"},
+			Results:  []string{"n int", "err error"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("EnrichCulprit mismatch (-want +got):\n%s", diff)
+	}
+}
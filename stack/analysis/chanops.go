@@ -0,0 +1,170 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// OrphanedOp is a channel operation that will never complete because no
+// other goroutine has a reference to the channel.
+type OrphanedOp struct {
+	// Op is "send" or "receive".
+	Op string
+	// Channel is the address of the channel, as passed to the runtime.
+	Channel uint64
+	// Expr is the channel expression in the source, e.g. "s.done". It is only
+	// set when the source file is available locally, i.e. the stack trace
+	// was parsed with guesspaths.
+	Expr string
+	// IDs is the goroutines blocked on the operation.
+	IDs []int
+	// Call is the innermost user call, the one blocked. It may be nil.
+	Call *stack.Call
+	// Explanation is a plain English description.
+	Explanation string
+}
+
+// FindOrphanedChanOps returns the channel sends and receives that are blocked
+// on a channel that no other goroutine references in its arguments. The
+// goroutines are grouped per channel and operation.
+//
+// It relies on the channel address passed to runtime.chansend1 and
+// runtime.chanrecv1, so operations inside select statements are not
+// analyzed. Since the arguments are not always printed, especially with
+// inlining, this can have false positives; it is a hint, not a proof.
+func FindOrphanedChanOps(c *stack.Context) []OrphanedOp {
+	byOp := map[chanOpKey]*OrphanedOp{}
+	var order []chanOpKey
+	referenced := map[uint64]bool{}
+	for _, g := range c.Goroutines {
+		op, ch := chanOp(&g.Signature)
+		// A goroutine blocked on a channel operation can still hold references
+		// to other channels, e.g. a worker blocked sending its result while
+		// owning the channel another goroutine waits on.
+		for i := range g.Stack.Calls {
+			if _, ok := chanFuncs[g.Stack.Calls[i].Func.Raw]; ok {
+				continue
+			}
+			for _, a := range g.Stack.Calls[i].Args.Values {
+				if a.Value != ch {
+					referenced[a.Value] = true
+				}
+			}
+		}
+		if ch == 0 {
+			continue
+		}
+		k := chanOpKey{ch, op}
+		o := byOp[k]
+		if o == nil {
+			o = &OrphanedOp{Op: op, Channel: ch, Call: userCall(&g.Signature)}
+			if o.Call != nil {
+				o.Expr = chanExpr(o.Call, op)
+			}
+			byOp[k] = o
+			order = append(order, k)
+		}
+		o.IDs = append(o.IDs, g.ID)
+	}
+	var out []OrphanedOp
+	for _, k := range order {
+		if referenced[k.ch] {
+			continue
+		}
+		o := byOp[k]
+		what := "a channel"
+		if o.Expr != "" {
+			what = o.Expr
+		}
+		who := fmt.Sprintf("%d goroutines block", len(o.IDs))
+		if len(o.IDs) == 1 {
+			who = fmt.Sprintf("goroutine %d blocks", o.IDs[0])
+		}
+		o.Explanation = fmt.Sprintf("%s to %s on %s (0x%x) at %s; no other goroutine references this channel", who, o.Op, what, o.Channel, location(o.Call))
+		out = append(out, *o)
+	}
+	return out
+}
+
+// Private stuff.
+
+// chanOpKey identifies the goroutines blocked on the same operation on a
+// channel.
+type chanOpKey struct {
+	ch uint64
+	op string
+}
+
+// chanFuncs are the runtime functions blocking on a channel operation, with
+// the channel as first argument.
+var chanFuncs = map[string]string{
+	"runtime.chansend":  "send",
+	"runtime.chansend1": "send",
+	"runtime.chanrecv":  "receive",
+	"runtime.chanrecv1": "receive",
+	"runtime.chanrecv2": "receive",
+}
+
+// chanOp returns the channel operation the goroutine is blocked on and the
+// channel address, if any.
+func chanOp(s *stack.Signature) (string, uint64) {
+	switch s.WaitReason() {
+	case stack.WaitChanSend, stack.WaitChanReceive:
+	default:
+		return "", 0
+	}
+	for i := range s.Stack.Calls {
+		c := &s.Stack.Calls[i]
+		if op, ok := chanFuncs[c.Func.Raw]; ok && len(c.Args.Values) != 0 {
+			return op, c.Args.Values[0].Value
+		}
+	}
+	return "", 0
+}
+
+var (
+	reChanSend = regexp.MustCompile(`^\s*(.+?)\s*<-`)
+	reChanRecv = regexp.MustCompile(`<-\s*([\w.]+(?:\[[^\]]*\])?(?:\(\))?)`)
+)
+
+// chanExpr returns the channel expression at the call site, read from the
+// local source file.
+func chanExpr(c *stack.Call, op string) string {
+	l := sourceLine(c.LocalSrcPath, c.Line)
+	re := reChanRecv
+	if op == "send" {
+		re = reChanSend
+	}
+	if m := re.FindStringSubmatch(l); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+// sourceLine returns the line in the file, or "" if it can't be read.
+func sourceLine(file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if n == line {
+			return s.Text()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindOrphanedChanOps(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [select]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:30 +0x45",
+		"",
+		"goroutine 6 [chan send]:",
+		"runtime.chansend1(0xc00001e0c0?, 0xc000012345?)",
+		"	/goroot/src/runtime/chan.go:145 +0x1d",
+		"main.produce()",
+		"	/home/user/src/foo/main.go:3 +0x45",
+		"",
+		"goroutine 7 [chan receive]:",
+		"runtime.chanrecv1(0xc00001e180, 0x0)",
+		"	/goroot/src/runtime/chan.go:442 +0x18",
+		"main.consume()",
+		"	/home/user/src/foo/main.go:4 +0x45",
+		"",
+		"goroutine 8 [running]:",
+		"main.feed(0xc00001e180)",
+		"	/home/user/src/foo/main.go:20 +0x45",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(main, []byte("package main\n\n\ts.results <- v\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c.Goroutines[1].Stack.Calls[1].LocalSrcPath = main
+	o := FindOrphanedChanOps(c)
+	if len(o) != 1 {
+		t.Fatalf("unexpected ops: %#v", o)
+	}
+	want := "goroutine 6 blocks to send on s.results (0xc00001e0c0) at main.produce at main.go:3; no other goroutine references this channel"
+	if o[0].Explanation != want {
+		t.Fatalf("want %q, got %q", want, o[0].Explanation)
+	}
+}
+
+func TestFindOrphanedChanOpsBlockedReference(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 6 [chan send]:",
+		"runtime.chansend1(0xc00001e0c0, 0xc000012345)",
+		"	/goroot/src/runtime/chan.go:145 +0x1d",
+		"main.produce(0xc00001e0c0)",
+		"	/home/user/src/foo/main.go:3 +0x45",
+		"",
+		"goroutine 7 [chan receive]:",
+		"runtime.chanrecv1(0xc00001e180, 0x0)",
+		"	/goroot/src/runtime/chan.go:442 +0x18",
+		"main.collect(0xc00001e0c0, 0xc00001e180)",
+		"	/home/user/src/foo/main.go:4 +0x45",
+		"",
+		"goroutine 8 [chan send]:",
+		"runtime.chansend1(0xc00001e240, 0xc000012345)",
+		"	/goroot/src/runtime/chan.go:145 +0x1d",
+		"main.produce(0xc00001e240)",
+		"	/home/user/src/foo/main.go:3 +0x45",
+		"",
+		"goroutine 9 [chan receive]:",
+		"runtime.chanrecv1(0xc00001e240, 0x0)",
+		"	/goroot/src/runtime/chan.go:442 +0x18",
+		"main.consume(0xc00001e240)",
+		"	/home/user/src/foo/main.go:5 +0x45",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	o := FindOrphanedChanOps(c)
+	// Goroutine 6 is not orphaned since the blocked goroutine 7 references its
+	// channel. The send and the receive on 0xc00001e240 are reported
+	// separately.
+	var got []string
+	for _, op := range o {
+		got = append(got, fmt.Sprintf("%s 0x%x %v", op.Op, op.Channel, op.IDs))
+	}
+	want := []string{"receive 0xc00001e180 [7]", "send 0xc00001e240 [8]", "receive 0xc00001e240 [9]"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
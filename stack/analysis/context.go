@@ -0,0 +1,238 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ContextWait is goroutines blocked on the same <-ctx.Done() and where the
+// context was created.
+type ContextWait struct {
+	// IDs is the goroutines, in the order they were printed.
+	IDs []int
+	// Call is the innermost user call, the one blocked on Done.
+	Call *stack.Call
+	// Expr is the context expression, e.g. "ctx" or "r.Context()". It is
+	// empty for goroutines in the context package itself.
+	Expr string
+	// Origin is where the context was created. It is nil when not
+	// determinable.
+	Origin *ContextOrigin
+	// Explanation is a plain English description.
+	Explanation string
+}
+
+// ContextOrigin is the call creating a context.
+type ContextOrigin struct {
+	// Func is the function creating the context, e.g. "context.WithTimeout".
+	Func string
+	// Call is the frame of the function calling Func.
+	Call *stack.Call
+	// Line is the line calling Func, in Call.SrcPath.
+	Line int
+}
+
+// Cancellable returns false if the context is never cancelled, e.g. created
+// by context.Background.
+func (o *ContextOrigin) Cancellable() bool {
+	switch o.Func {
+	case "context.Background", "context.TODO", "context.WithoutCancel":
+		return false
+	}
+	return true
+}
+
+// FindContextWaits returns the goroutines blocked on <-ctx.Done() or in the
+// context package internals, with the context creation site.
+//
+// The sources must be available locally, i.e. the stack trace must be parsed
+// with guesspaths. The creation site is the closest context.WithXXX call
+// preceding the call line in the blocked function or one of its callers,
+// then in the function that started the goroutine. It is a hint, not a
+// proof.
+func FindContextWaits(c *stack.Context) []ContextWait {
+	src := sources{}
+	var out []ContextWait
+	for _, g := range c.Goroutines {
+		w := contextWait(c, g, src)
+		if w == nil {
+			continue
+		}
+		merged := false
+		for i := range out {
+			o := &out[i]
+			if sameSite(o.Call, w.Call) && o.Expr == w.Expr && sameOrigin(o.Origin, w.Origin) {
+				o.IDs = append(o.IDs, g.ID)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, *w)
+		}
+	}
+	for i := range out {
+		out[i].Explanation = explainContextWait(&out[i])
+	}
+	return out
+}
+
+// Private stuff.
+
+var (
+	// reCtxDone matches the context expression waited on, e.g.
+	// "<-r.Context().Done()".
+	reCtxDone = regexp.MustCompile(`<-\s*([\w.]+(?:\(\))?(?:\.[\w]+(?:\(\))?)*)\.Done\(\)`)
+	// reCtxWith matches a call creating a context.
+	reCtxWith = regexp.MustCompile(`\bcontext\.(With(?:Cancel|Timeout|Deadline)(?:Cause)?|WithoutCancel|WithValue|Background|TODO)\(`)
+)
+
+// sources caches the lines of the local source files. A nil entry is a file
+// that couldn't be read.
+type sources map[string][]string
+
+// lines returns the lines of the file.
+func (s sources) lines(file string) []string {
+	if file == "" {
+		return nil
+	}
+	l, ok := s[file]
+	if !ok {
+		if f, err := os.Open(file); err == nil {
+			sc := bufio.NewScanner(f)
+			for sc.Scan() {
+				l = append(l, sc.Text())
+			}
+			f.Close()
+		}
+		s[file] = l
+	}
+	return l
+}
+
+// contextWait returns the wait if g is blocked on a context.
+func contextWait(c *stack.Context, g *stack.Goroutine, src sources) *ContextWait {
+	switch g.WaitReason() {
+	case stack.WaitSelect, stack.WaitChanReceive:
+	default:
+		return nil
+	}
+	calls := g.Stack.Calls
+	// Goroutines started by the context package, e.g. to propagate the
+	// cancellation of a custom parent context, block in the package itself.
+	if strings.HasPrefix(g.CreatedBy.Func.String(), "context.") {
+		w := &ContextWait{IDs: []int{g.ID}, Call: userCall(&g.Signature)}
+		if p := c.Creator(g); p != nil {
+			w.Origin = findOrigin(p.Stack.Calls, 0, nil, src)
+		}
+		return w
+	}
+	for i := range calls {
+		call := &calls[i]
+		if isStdlibFunc(call) {
+			continue
+		}
+		l := src.lines(call.LocalSrcPath)
+		expr := ""
+		for _, s := range call.SelectCases {
+			if m := reCtxDone.FindStringSubmatch(s); m != nil {
+				expr = m[1]
+				break
+			}
+		}
+		if expr == "" && call.Line > 0 && call.Line <= len(l) {
+			if m := reCtxDone.FindStringSubmatch(l[call.Line-1]); m != nil {
+				expr = m[1]
+			}
+		}
+		if expr == "" {
+			return nil
+		}
+		w := &ContextWait{IDs: []int{g.ID}, Call: call, Expr: expr}
+		w.Origin = findOrigin(calls, i, &g.CreatedBy, src)
+		return w
+	}
+	return nil
+}
+
+// findOrigin looks for the closest context creation before the call line of
+// each frame starting at calls[i], then before the "go" statement.
+func findOrigin(calls []stack.Call, i int, createdBy *stack.Call, src sources) *ContextOrigin {
+	for ; i < len(calls); i++ {
+		if o := originIn(&calls[i], src); o != nil {
+			return o
+		}
+	}
+	if createdBy != nil && createdBy.Func.Raw != "" {
+		return originIn(createdBy, src)
+	}
+	return nil
+}
+
+// originIn returns the context creation preceding the call line in the
+// function of call.
+func originIn(call *stack.Call, src sources) *ContextOrigin {
+	l := src.lines(call.LocalSrcPath)
+	if call.Line <= 0 || call.Line > len(l) {
+		return nil
+	}
+	for n := call.Line; n > 0; n-- {
+		line := l[n-1]
+		// context.WithValue doesn't change the cancellation; keep looking.
+		for _, m := range reCtxWith.FindAllStringSubmatch(line, -1) {
+			if m[1] != "WithValue" {
+				return &ContextOrigin{Func: "context." + m[1], Call: call, Line: n}
+			}
+		}
+		if strings.HasPrefix(line, "func ") {
+			// Reached the declaration of the function.
+			break
+		}
+	}
+	return nil
+}
+
+// sameOrigin returns true if both origins are the same call.
+func sameOrigin(a, b *ContextOrigin) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Func == b.Func && a.Line == b.Line && a.Call.SrcPath == b.Call.SrcPath
+}
+
+// explainContextWait describes the wait and the context origin.
+func explainContextWait(w *ContextWait) string {
+	who := fmt.Sprintf("%d goroutines wait", len(w.IDs))
+	if len(w.IDs) == 1 {
+		who = fmt.Sprintf("goroutine %d waits", w.IDs[0])
+	}
+	what := "a context"
+	if w.Expr != "" {
+		what = w.Expr
+	}
+	s := fmt.Sprintf("%s at %s for %s to be done", who, location(w.Call), what)
+	if w.Expr == "" {
+		s = fmt.Sprintf("%s in the context package (%s) to propagate a cancellation", who, location(w.Call))
+	}
+	o := w.Origin
+	if o == nil {
+		return s + "; the context creation site is unknown"
+	}
+	s += fmt.Sprintf("; created by %s in %s at %s:%d", o.Func, o.Call.Func.PkgDotName(), o.Call.SrcName(), o.Line)
+	switch {
+	case !o.Cancellable():
+		s += ", which is never cancelled"
+	case strings.HasPrefix(o.Func, "context.WithCancel"):
+		s += ", which is only cancelled explicitly or by its parent"
+	}
+	return s
+}
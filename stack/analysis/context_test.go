@@ -0,0 +1,98 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindContextWaits(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := []string{
+		"package main",
+		"",
+		"func serve(parent context.Context) {",
+		"	ctx, cancel := context.WithTimeout(parent, time.Minute)",
+		"	defer cancel()",
+		"	work(ctx)",
+		"}",
+		"",
+		"func work(ctx context.Context) {",
+		"	select {",
+		"	case <-ctx.Done():",
+		"	case <-time.After(time.Hour):",
+		"	}",
+		"}",
+		"",
+		"func main() {",
+		"	go watch(context.Background())",
+		"}",
+		"",
+		"func watch(ctx context.Context) {",
+		"	<-ctx.Done()",
+		"}",
+	}
+	if err := ioutil.WriteFile(main, []byte(strings.Join(src, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := []string{
+		"goroutine 6 [select]:",
+		"runtime.selectgo(0xc000057f28, 0xc000057f14, 0x0?, 0x0, 0x0?, 0x1)",
+		"	/goroot/src/runtime/select.go:327 +0x7be",
+		"main.work({0x5a1e40, 0xc00001e0c0})",
+		"	" + main + ":10 +0x8f",
+		"main.serve({0x5a1e08, 0xc000012345})",
+		"	" + main + ":6 +0x7c",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.watch({0x5a1e08, 0xc000012345})",
+		"	" + main + ":21 +0x2a",
+		"created by main.main in goroutine 1",
+		"	" + main + ":17 +0x3e",
+		"",
+		"goroutine 8 [select]:",
+		"context.(*cancelCtx).propagateCancel.func2()",
+		"	/goroot/src/context/context.go:510 +0xa5",
+		"created by context.(*cancelCtx).propagateCancel in goroutine 6",
+		"	/goroot/src/context/context.go:509 +0x3f3",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	for _, g := range c.Goroutines {
+		for i := range g.Stack.Calls {
+			if g.Stack.Calls[i].SrcPath == main {
+				g.Stack.Calls[i].LocalSrcPath = main
+			}
+		}
+		g.CreatedBy.LocalSrcPath = g.CreatedBy.SrcPath
+		stack.NewAugmenter(nil).Augment(&g.Signature)
+	}
+	w := FindContextWaits(c)
+	var got []string
+	for _, x := range w {
+		got = append(got, x.Explanation)
+	}
+	want := []string{
+		"goroutine 6 waits at main.work at main.go:10 for ctx to be done; created by context.WithTimeout in main.serve at main.go:4",
+		"goroutine 7 waits at main.watch at main.go:21 for ctx to be done; created by context.Background in main.main at main.go:17, which is never cancelled",
+		"goroutine 8 waits in the context package (context.(*cancelCtx).propagateCancel.func2 at context.go:510) to propagate a cancellation; created by context.WithTimeout in main.serve at main.go:4",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Explanation mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Deadlock describes a deadlock detected by the Go runtime, when it printed
+// "fatal error: all goroutines are asleep - deadlock!".
+type Deadlock struct {
+	// Waiters is each goroutine that was waiting, in the order they were
+	// printed.
+	Waiters []Waiter
+	// Explanation is a plain English description of the deadlock shape.
+	Explanation string
+}
+
+// Waiter is a goroutine waiting forever.
+type Waiter struct {
+	// ID is the goroutine ID.
+	ID int
+	// Op is what the goroutine waits on, e.g. "receive on a channel".
+	Op string
+	// Call is the innermost user call, the one that is blocked. It may be nil.
+	Call *stack.Call
+	// Explanation is a plain English sentence describing the wait.
+	Explanation string
+}
+
+// FindDeadlock returns the deadlock description if the runtime reported that
+// all goroutines are asleep.
+//
+// Returns nil if the runtime didn't detect a deadlock.
+func FindDeadlock(c *stack.Context) *Deadlock {
+	found := false
+	for _, s := range c.Segments {
+		if strings.Contains(s.Text, deadlockMsg) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	d := &Deadlock{}
+	ops := map[string]int{}
+	for _, g := range c.Goroutines {
+		w := Waiter{ID: g.ID, Op: waitOp(g.State), Call: userCall(&g.Signature)}
+		who := "goroutine " + fmt.Sprint(g.ID)
+		if g.ID == 1 {
+			who = "main goroutine"
+		}
+		w.Explanation = fmt.Sprintf("%s (%s) waits to %s", who, location(w.Call), w.Op)
+		ops[w.Op]++
+		d.Waiters = append(d.Waiters, w)
+	}
+	d.Explanation = explain(d.Waiters, ops)
+	return d
+}
+
+// Private stuff.
+
+const deadlockMsg = "all goroutines are asleep - deadlock!"
+
+// waitOp converts a goroutine state into a description of the operation.
+func waitOp(state string) string {
+	switch state {
+	case "chan receive":
+		return "receive on a channel"
+	case "chan send":
+		return "send on a channel"
+	case "chan receive (nil chan)":
+		return "receive on a nil channel"
+	case "chan send (nil chan)":
+		return "send on a nil channel"
+	case "select":
+		return "select on channels"
+	case "select (no cases)":
+		return "select with no cases"
+	case "semacquire", "sync.Mutex.Lock", "sync.RWMutex.Lock", "sync.RWMutex.RLock":
+		return "acquire a lock"
+	case "sync.WaitGroup.Wait":
+		return "wait on a sync.WaitGroup"
+	case "sync.Cond.Wait":
+		return "wait on a sync.Cond"
+	default:
+		return "complete \"" + state + "\""
+	}
+}
+
+func explain(waiters []Waiter, ops map[string]int) string {
+	var lines []string
+	for _, w := range waiters {
+		lines = append(lines, w.Explanation)
+	}
+	recv := ops["receive on a channel"]
+	send := ops["send on a channel"]
+	switch {
+	case len(waiters) == 0:
+		lines = append(lines, "no goroutine is left to run")
+	case recv == len(waiters):
+		lines = append(lines, "no goroutine exists that could send")
+	case send == len(waiters):
+		lines = append(lines, "no goroutine exists that could receive")
+	case ops["receive on a nil channel"]+ops["send on a nil channel"]+ops["select with no cases"] != 0:
+		lines = append(lines, "operations on a nil channel or an empty select block forever")
+	case len(waiters) == 1:
+		lines = append(lines, "no other goroutine exists to unblock it")
+	default:
+		lines = append(lines, "the goroutines are all waiting on each other")
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestFindDeadlock(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"fatal error: all goroutines are asleep - deadlock!",
+		"",
+		"goroutine 1 [chan receive]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"exit status 2",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	d := FindDeadlock(c)
+	if d == nil {
+		t.Fatal("expected a deadlock")
+	}
+	if len(d.Waiters) != 1 || d.Waiters[0].ID != 1 || d.Waiters[0].Op != "receive on a channel" {
+		t.Fatalf("unexpected waiters: %#v", d.Waiters)
+	}
+	want := "main goroutine (main.main at main.go:10) waits to receive on a channel; no goroutine exists that could send"
+	if d.Explanation != want {
+		t.Fatalf("want %q, got %q", want, d.Explanation)
+	}
+}
+
+func TestFindDeadlockNone(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"",
+	}
+	if d := FindDeadlock(parse(t, strings.Join(data, "\n"))); d != nil {
+		t.Fatalf("unexpected deadlock: %#v", d)
+	}
+}
+
+func parse(t *testing.T, s string) *stack.Context {
+	t.Helper()
+	c, err := stack.ParseDump(strings.NewReader(s), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil {
+		t.Fatal("no goroutine found")
+	}
+	return c
+}
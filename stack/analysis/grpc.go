@@ -0,0 +1,238 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// GRPCRole is what a goroutine does for google.golang.org/grpc.
+type GRPCRole int
+
+const (
+	// GRPCServerHandler is a goroutine serving an RPC.
+	GRPCServerHandler GRPCRole = iota
+	// GRPCClientCall is a goroutine waiting on an outgoing RPC.
+	GRPCClientCall
+	// GRPCTransportReader is a goroutine reading HTTP/2 frames from a
+	// connection.
+	GRPCTransportReader
+	// GRPCTransportWriter is a goroutine writing HTTP/2 frames to a
+	// connection.
+	GRPCTransportWriter
+	// GRPCInternal is any other goroutine running in grpc, e.g. keepalive,
+	// balancer or resolver.
+	GRPCInternal
+)
+
+func (g GRPCRole) String() string {
+	switch g {
+	case GRPCServerHandler:
+		return "server-handler"
+	case GRPCClientCall:
+		return "client-call"
+	case GRPCTransportReader:
+		return "transport-reader"
+	case GRPCTransportWriter:
+		return "transport-writer"
+	case GRPCInternal:
+		return "internal"
+	default:
+		return fmt.Sprintf("GRPCRole(%d)", int(g))
+	}
+}
+
+// GRPCGroup is the goroutines with the same role for the same RPC method.
+type GRPCGroup struct {
+	// Role is what the goroutines do.
+	Role GRPCRole
+	// Method is the RPC method, e.g. "Greeter/SayHello". It is empty for the
+	// transport and internal goroutines and when it can't be determined.
+	Method string
+	// Call is the innermost user call of the first goroutine, or the
+	// innermost grpc call if there's none. It may be nil.
+	Call *stack.Call
+	// IDs is the goroutines, in the order they were printed.
+	IDs []int
+	// Blocked is the number of goroutines parked or in a system call.
+	Blocked int
+	// LongestMinutes is the longest wait of the goroutines, as printed by the
+	// runtime.
+	LongestMinutes int
+}
+
+// GRPCReport is the goroutines running google.golang.org/grpc code, grouped
+// by role and method.
+type GRPCReport struct {
+	// Groups is sorted by role, then by decreasing number of blocked
+	// goroutines.
+	Groups []GRPCGroup
+}
+
+// FindGRPC classifies the goroutines running google.golang.org/grpc code.
+//
+// The method of a server handler is found from the generated
+// _<Service>_<Method>_Handler function and the method of a client call from
+// the generated client stub, e.g. (*greeterClient).SayHello. The method of a
+// client stream being read or written is unknown since the stub already
+// returned. Returns nil if no goroutine runs grpc code.
+func FindGRPC(c *stack.Context) *GRPCReport {
+	type key struct {
+		role   GRPCRole
+		method string
+	}
+	groups := map[key]*GRPCGroup{}
+	var order []key
+	for _, g := range c.Goroutines {
+		role, method, ok := grpcRole(&g.Signature)
+		if !ok {
+			continue
+		}
+		k := key{role, method}
+		e := groups[k]
+		if e == nil {
+			e = &GRPCGroup{Role: role, Method: method, Call: grpcUserCall(&g.Signature)}
+			groups[k] = e
+			order = append(order, k)
+		}
+		e.IDs = append(e.IDs, g.ID)
+		if a := g.Activity(); a == stack.ActivityParked || a == stack.ActivitySyscall {
+			e.Blocked++
+			if g.SleepMax > e.LongestMinutes {
+				e.LongestMinutes = g.SleepMax
+			}
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	out := &GRPCReport{}
+	for _, k := range order {
+		out.Groups = append(out.Groups, *groups[k])
+	}
+	sort.SliceStable(out.Groups, func(i, j int) bool {
+		a, b := &out.Groups[i], &out.Groups[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Blocked > b.Blocked
+	})
+	return out
+}
+
+// String renders the report as a table, one group per line.
+func (g *GRPCReport) String() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tMETHOD\tLOCATION\tGOROUTINES\tBLOCKED\tLONGEST")
+	for _, e := range g.Groups {
+		method := e.Method
+		if method == "" {
+			method = "-"
+		}
+		longest := "-"
+		if e.LongestMinutes != 0 {
+			longest = fmt.Sprintf("%d minutes", e.LongestMinutes)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", e.Role, method, location(e.Call), len(e.IDs), e.Blocked, longest)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Private stuff.
+
+const grpcPkg = "google.golang.org/grpc"
+
+var (
+	// reGRPCHandler matches the generated server handler, e.g.
+	// "_Greeter_SayHello_Handler".
+	reGRPCHandler = regexp.MustCompile(`^_([A-Za-z0-9]+)_([A-Za-z0-9]+)_Handler$`)
+	// reGRPCClient matches the generated client stub, e.g.
+	// "(*greeterClient).SayHello".
+	reGRPCClient = regexp.MustCompile(`^\(\*([a-z][A-Za-z0-9]*)Client\)\.([A-Za-z0-9]+)$`)
+)
+
+// isGRPC returns true if the function is in grpc or one of its
+// subpackages.
+func isGRPC(c *stack.Call) bool {
+	s := c.Func.String()
+	return strings.HasPrefix(s, grpcPkg+".") || strings.HasPrefix(s, grpcPkg+"/")
+}
+
+// grpcUserCall returns the innermost call outside of grpc and the standard
+// library, falling back to userCall.
+func grpcUserCall(s *stack.Signature) *stack.Call {
+	for i := range s.Stack.Calls {
+		if c := &s.Stack.Calls[i]; !isGRPC(c) && !isStdlibFunc(c) {
+			return c
+		}
+	}
+	return userCall(s)
+}
+
+// grpcRole classifies the goroutine. ok is false if it doesn't run grpc code.
+func grpcRole(s *stack.Signature) (role GRPCRole, method string, ok bool) {
+	calls := s.Stack.Calls
+	inGRPC := false
+	for i := range calls {
+		if isGRPC(&calls[i]) {
+			inGRPC = true
+			break
+		}
+	}
+	if !inGRPC && !isGRPC(&s.CreatedBy) {
+		return 0, "", false
+	}
+	// A server handler is the most specific, it can also make client calls.
+	for i := range calls {
+		if m := reGRPCHandler.FindStringSubmatch(calls[i].Func.Name()); m != nil {
+			return GRPCServerHandler, m[1] + "/" + m[2], true
+		}
+	}
+	for i := range calls {
+		switch strings.TrimPrefix(calls[i].Func.String(), grpcPkg+".") {
+		case "(*Server).processUnaryRPC", "(*Server).processStreamingRPC", "(*Server).handleStream":
+			return GRPCServerHandler, "", true
+		}
+	}
+	// A client call is a grpc frame called by user code.
+	for i := 0; i < len(calls)-1; i++ {
+		if isGRPC(&calls[i]) && !isGRPC(&calls[i+1]) && !isStdlibFunc(&calls[i+1]) {
+			switch strings.TrimPrefix(calls[i].Func.String(), grpcPkg+".") {
+			case "(*ClientConn).Invoke", "Invoke", "(*ClientConn).NewStream", "NewClientStream":
+				// The stub is only in the stack when starting the RPC, not when
+				// using the stream afterward.
+				if m := reGRPCClient.FindStringSubmatch(calls[i+1].Func.Name()); m != nil {
+					method = strings.ToUpper(m[1][:1]) + m[1][1:] + "/" + m[2]
+				}
+				return GRPCClientCall, method, true
+			case "(*clientStream).RecvMsg", "(*clientStream).SendMsg", "(*clientStream).CloseSend", "(*clientStream).Header":
+				return GRPCClientCall, "", true
+			}
+		}
+	}
+	for i := range calls {
+		f := calls[i].Func.String()
+		if !strings.HasPrefix(f, grpcPkg+"/internal/transport.") {
+			continue
+		}
+		f = f[len(grpcPkg+"/internal/transport."):]
+		switch {
+		case strings.HasPrefix(f, "(*loopyWriter)."), strings.HasPrefix(f, "(*bufWriter)."):
+			return GRPCTransportWriter, "", true
+		case f == "(*http2Client).reader", f == "(*http2Server).HandleStreams", strings.HasPrefix(f, "(*recvBufferReader)."), strings.HasPrefix(f, "(*framer)."):
+			return GRPCTransportReader, "", true
+		}
+	}
+	return GRPCInternal, "", true
+}
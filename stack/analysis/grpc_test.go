@@ -0,0 +1,80 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindGRPC(t *testing.T) {
+	t.Parallel()
+	var data []string
+	add := func(id int, state string, frames ...string) {
+		data = append(data, fmt.Sprintf("goroutine %d [%s]:", id, state))
+		for _, f := range frames {
+			data = append(data, f+"(...)", "	/home/user/src/foo/x.go:10 +0x45")
+		}
+		data = append(data, "")
+	}
+	add(1, "IO wait", "main.main")
+	add(5, "select", "google.golang.org/grpc/internal/transport.(*recvBufferReader).read", "google.golang.org/grpc/internal/transport.(*http2Client).reader")
+	add(6, "select", "google.golang.org/grpc/internal/transport.(*controlBuffer).get", "google.golang.org/grpc/internal/transport.(*loopyWriter).run")
+	for i := 0; i < 2; i++ {
+		add(10+i, "select, 4 minutes",
+			"runtime.selectgo",
+			"google.golang.org/grpc.(*ClientConn).Invoke",
+			"example.com/pb.(*inventoryClient).Reserve",
+			"example.com/svc.(*Server).PlaceOrder",
+			"example.com/pb._Orders_PlaceOrder_Handler",
+			"google.golang.org/grpc.(*Server).processUnaryRPC",
+			"google.golang.org/grpc.(*Server).handleStream")
+	}
+	add(20, "select",
+		"runtime.selectgo",
+		"google.golang.org/grpc.(*ClientConn).Invoke",
+		"example.com/pb.(*inventoryClient).Reserve",
+		"main.worker")
+	add(21, "select",
+		"google.golang.org/grpc.(*clientStream).RecvMsg",
+		"example.com/pb.(*inventoryWatchClient).Recv",
+		"main.watch")
+	add(30, "select", "google.golang.org/grpc.(*ccBalancerWrapper).watcher")
+	c := parse(t, strings.Join(data, "\n"))
+	h := FindGRPC(c)
+	if h == nil {
+		t.Fatal("expected report")
+	}
+	type group struct {
+		Role    GRPCRole
+		Method  string
+		IDs     []int
+		Blocked int
+	}
+	var got []group
+	for _, g := range h.Groups {
+		got = append(got, group{g.Role, g.Method, g.IDs, g.Blocked})
+	}
+	want := []group{
+		{GRPCServerHandler, "Orders/PlaceOrder", []int{10, 11}, 2},
+		{GRPCClientCall, "Inventory/Reserve", []int{20}, 1},
+		{GRPCClientCall, "", []int{21}, 1},
+		{GRPCTransportReader, "", []int{5}, 1},
+		{GRPCTransportWriter, "", []int{6}, 1},
+		{GRPCInternal, "", []int{30}, 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Groups mismatch (-want +got):\n%s", diff)
+	}
+	if s := h.String(); !strings.Contains(s, "server-handler    Orders/PlaceOrder  pb.(*inventoryClient).Reserve at x.go:10") {
+		t.Fatalf("unexpected table:\n%s", s)
+	}
+	if FindGRPC(parse(t, strings.Join(data[:4], "\n"))) != nil {
+		t.Fatal("expected no report")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// HTTPRoute is the net/http server goroutines serving requests with the same
+// handler.
+type HTTPRoute struct {
+	// Handler is the handler function, e.g. "main.(*API).GetUser".
+	Handler string
+	// Call is the handler frame of the first goroutine.
+	Call *stack.Call
+	// IDs is the goroutines running the handler, in the order they were
+	// printed.
+	IDs []int
+	// Blocked is the number of goroutines parked or in a system call, as
+	// opposed to running or runnable.
+	Blocked int
+	// Longest is the goroutine blocked the longest.
+	Longest int
+	// LongestMinutes is how long Longest has been blocked, as printed by the
+	// runtime. It is 0 for less than a minute.
+	LongestMinutes int
+}
+
+// HTTPReport is the requests being served by net/http servers, grouped by
+// handler.
+type HTTPReport struct {
+	// Routes is sorted by decreasing number of blocked goroutines.
+	Routes []HTTPRoute
+}
+
+// FindHTTPHandlers groups the goroutines serving an HTTP request by handler.
+//
+// The handler is the innermost user function called by a net/http ServeHTTP
+// method or a known router (gorilla/mux, chi, gin, echo, httprouter) on a
+// goroutine started by net/http.(*Server).Serve, so handlers wrapped by
+// middlewares are attributed to the innermost handler. Returns nil if there is
+// no such goroutine.
+func FindHTTPHandlers(c *stack.Context) *HTTPReport {
+	byHandler := map[string]*HTTPRoute{}
+	var order []string
+	for _, g := range c.Goroutines {
+		call := httpHandler(&g.Signature)
+		if call == nil {
+			continue
+		}
+		h := call.Func.String()
+		r := byHandler[h]
+		if r == nil {
+			r = &HTTPRoute{Handler: h, Call: call, Longest: g.ID}
+			byHandler[h] = r
+			order = append(order, h)
+		}
+		r.IDs = append(r.IDs, g.ID)
+		if a := g.Activity(); a == stack.ActivityParked || a == stack.ActivitySyscall {
+			if r.Blocked == 0 || g.SleepMax > r.LongestMinutes {
+				r.Longest = g.ID
+				r.LongestMinutes = g.SleepMax
+			}
+			r.Blocked++
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	out := &HTTPReport{}
+	for _, h := range order {
+		out.Routes = append(out.Routes, *byHandler[h])
+	}
+	sort.SliceStable(out.Routes, func(i, j int) bool {
+		a, b := &out.Routes[i], &out.Routes[j]
+		if a.Blocked != b.Blocked {
+			return a.Blocked > b.Blocked
+		}
+		return a.LongestMinutes > b.LongestMinutes
+	})
+	return out
+}
+
+// String renders the report as a table, one route per line.
+func (h *HTTPReport) String() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HANDLER\tLOCATION\tGOROUTINES\tBLOCKED\tLONGEST")
+	for _, r := range h.Routes {
+		longest := "-"
+		if r.Blocked != 0 {
+			longest = fmt.Sprintf("goroutine %d", r.Longest)
+			if r.LongestMinutes != 0 {
+				longest += fmt.Sprintf(" (%d minutes)", r.LongestMinutes)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s:%d\t%d\t%d\t%s\n", r.Handler, r.Call.SrcName(), r.Call.Line, len(r.IDs), r.Blocked, longest)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Private stuff.
+
+// httpRouters are the prefixes of the routers' functions calling handlers.
+var httpRouters = []string{
+	"github.com/gorilla/mux.",
+	"github.com/go-chi/chi.",
+	"github.com/go-chi/chi/v5.",
+	"github.com/gin-gonic/gin.",
+	"github.com/labstack/echo.",
+	"github.com/labstack/echo/v4.",
+	"github.com/julienschmidt/httprouter.",
+}
+
+// isHTTPDispatcher returns true if the function calls HTTP handlers, i.e. a
+// net/http ServeHTTP method or a known router.
+func isHTTPDispatcher(c *stack.Call) bool {
+	s := c.Func.String()
+	if strings.HasPrefix(s, "net/http.") {
+		return strings.HasSuffix(s, ".ServeHTTP")
+	}
+	for _, p := range httpRouters {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpHandler returns the innermost user call made by a dispatcher if the
+// goroutine is serving an HTTP request.
+func httpHandler(s *stack.Signature) *stack.Call {
+	calls := s.Stack.Calls
+	serving := false
+	for i := range calls {
+		if calls[i].Func.Raw == "net/http.(*conn).serve" {
+			serving = true
+			break
+		}
+	}
+	if !serving && s.CreatedBy.Func.Raw != "net/http.(*Server).Serve" {
+		return nil
+	}
+	for i := 0; i < len(calls)-1; i++ {
+		if !isHTTPDispatcher(&calls[i]) && !isStdlibFunc(&calls[i]) && isHTTPDispatcher(&calls[i+1]) {
+			return &calls[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindHTTPHandlers(t *testing.T) {
+	t.Parallel()
+	var data []string
+	add := func(id int, state string, frames ...string) {
+		data = append(data, fmt.Sprintf("goroutine %d [%s]:", id, state))
+		for _, f := range frames {
+			data = append(data, f+"()", "	/home/user/src/foo/"+f[strings.LastIndexAny(f, ".)")+1:]+".go:10 +0x45")
+		}
+		data = append(data,
+			"net/http.(*conn).serve(0xc000120000)",
+			"	/goroot/src/net/http/server.go:2009 +0x645",
+			"created by net/http.(*Server).Serve in goroutine 1",
+			"	/goroot/src/net/http/server.go:3086 +0x4cc",
+			"")
+	}
+	data = append(data,
+		"goroutine 1 [IO wait]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"")
+	// Blocked in a downstream call through the logging middleware.
+	for i, s := range []string{"select, 3 minutes", "select, 7 minutes", "select"} {
+		add(10+i, s,
+			"runtime.selectgo",
+			"net/http.(*persistConn).roundTrip",
+			"net/http.(*Client).Do",
+			"main.(*API).GetUser",
+			"net/http.HandlerFunc.ServeHTTP",
+			"github.com/gorilla/mux.(*Router).ServeHTTP",
+			"main.logging.func1",
+			"net/http.HandlerFunc.ServeHTTP",
+			"net/http.serverHandler.ServeHTTP")
+	}
+	add(20, "running",
+		"main.health",
+		"github.com/gin-gonic/gin.(*Context).Next",
+		"github.com/gin-gonic/gin.(*Engine).ServeHTTP",
+		"net/http.serverHandler.ServeHTTP")
+	add(21, "IO wait",
+		"net/http.(*connReader).backgroundRead")
+	c := parse(t, strings.Join(data, "\n"))
+	h := FindHTTPHandlers(c)
+	if h == nil || len(h.Routes) != 2 {
+		t.Fatalf("unexpected report: %#v", h)
+	}
+	r := h.Routes[0]
+	if r.Handler != "main.(*API).GetUser" || len(r.IDs) != 3 || r.Blocked != 3 || r.Longest != 11 || r.LongestMinutes != 7 {
+		t.Fatalf("unexpected route: %#v", r)
+	}
+	want := "" +
+		"HANDLER              LOCATION       GOROUTINES  BLOCKED  LONGEST\n" +
+		"main.(*API).GetUser  GetUser.go:10  3           3        goroutine 11 (7 minutes)\n" +
+		"main.health          health.go:10   1           0        -\n"
+	if got := h.String(); got != want {
+		t.Fatalf("want:\n%s\ngot:\n%s", want, got)
+	}
+	if FindHTTPHandlers(parse(t, strings.Join(data[:4], "\n"))) != nil {
+		t.Fatal("expected no report")
+	}
+}
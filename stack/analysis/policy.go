@@ -0,0 +1,125 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Limit is a budget that a snapshot must respect, e.g. "no more than 50
+// goroutines in package foo" or "no goroutine blocked for more than 10
+// minutes".
+//
+// The zero values of the Max fields mean no limit.
+type Limit struct {
+	// Name identifies the limit in violations.
+	Name string
+	// Match selects the goroutines with any call where the fully qualified
+	// function name matches, e.g. "^github.com/foo/bar\.". Nil selects all the
+	// goroutines.
+	Match *regexp.Regexp
+	// MaxGoroutines is the maximum number of selected goroutines.
+	MaxGoroutines int
+	// MaxPerBucket is the maximum number of selected goroutines with a similar
+	// stack, as aggregated with stack.AnyPointer.
+	MaxPerBucket int
+	// MaxMinutes is the maximum duration in minutes a selected goroutine can
+	// be blocked.
+	MaxMinutes int
+}
+
+// Policy is a list of limits.
+type Policy []Limit
+
+// Violation is a limit that was exceeded.
+type Violation struct {
+	// Limit is the limit exceeded.
+	Limit *Limit
+	// Goroutines is the goroutines in excess.
+	Goroutines []*stack.Goroutine
+	// Message is a plain English description of the violation.
+	Message string
+}
+
+func (v *Violation) String() string {
+	return v.Limit.Name + ": " + v.Message
+}
+
+// Check returns the violations of the policy in the snapshot, in the order
+// of the limits.
+//
+// Returns nil if the snapshot respects the policy.
+func (p Policy) Check(c *stack.Context) []Violation {
+	var out []Violation
+	for i := range p {
+		out = append(out, p[i].check(c)...)
+	}
+	return out
+}
+
+// Private stuff.
+
+func (l *Limit) check(c *stack.Context) []Violation {
+	var matched []*stack.Goroutine
+	for _, g := range c.Goroutines {
+		if l.matches(&g.Signature) {
+			matched = append(matched, g)
+		}
+	}
+	var out []Violation
+	if l.MaxGoroutines > 0 && len(matched) > l.MaxGoroutines {
+		out = append(out, Violation{
+			Limit:      l,
+			Goroutines: matched,
+			Message:    fmt.Sprintf("%d goroutines, limit is %d", len(matched), l.MaxGoroutines),
+		})
+	}
+	if l.MaxPerBucket > 0 {
+		byID := make(map[int]*stack.Goroutine, len(matched))
+		for _, g := range matched {
+			byID[g.ID] = g
+		}
+		for _, b := range stack.Aggregate(matched, stack.AnyPointer) {
+			if b.Size() <= l.MaxPerBucket {
+				continue
+			}
+			v := Violation{
+				Limit:   l,
+				Message: fmt.Sprintf("%d goroutines in %s, limit is %d", b.Size(), location(userCall(&b.Signature)), l.MaxPerBucket),
+			}
+			for _, id := range b.IDs {
+				v.Goroutines = append(v.Goroutines, byID[id])
+			}
+			out = append(out, v)
+		}
+	}
+	if l.MaxMinutes > 0 {
+		for _, g := range matched {
+			if g.SleepMax > l.MaxMinutes {
+				out = append(out, Violation{
+					Limit:      l,
+					Goroutines: []*stack.Goroutine{g},
+					Message:    fmt.Sprintf("goroutine %d blocked for %d minutes in %s, limit is %d", g.ID, g.SleepMax, location(userCall(&g.Signature)), l.MaxMinutes),
+				})
+			}
+		}
+	}
+	return out
+}
+
+func (l *Limit) matches(s *stack.Signature) bool {
+	if l.Match == nil {
+		return true
+	}
+	for i := range s.Stack.Calls {
+		if l.Match.MatchString(s.Stack.Calls[i].Func.Raw) {
+			return true
+		}
+	}
+	return false
+}
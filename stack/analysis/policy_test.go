@@ -0,0 +1,52 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"",
+		"goroutine 6 [chan receive, 12 minutes]:",
+		"github.com/foo/bar.worker()",
+		"	/home/user/go/src/github.com/foo/bar/bar.go:20 +0x45",
+		"",
+		"goroutine 7 [chan receive, 2 minutes]:",
+		"github.com/foo/bar.worker()",
+		"	/home/user/go/src/github.com/foo/bar/bar.go:20 +0x45",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	p := Policy{
+		{Name: "bar", Match: regexp.MustCompile(`^github\.com/foo/bar\.`), MaxGoroutines: 1, MaxPerBucket: 1},
+		{Name: "blocked", MaxMinutes: 10},
+		{Name: "ok", MaxGoroutines: 3},
+	}
+	v := p.Check(c)
+	want := []string{
+		"bar: 2 goroutines, limit is 1",
+		"bar: 2 goroutines in bar.worker at bar.go:20, limit is 1",
+		"blocked: goroutine 6 blocked for 12 minutes in bar.worker at bar.go:20, limit is 10",
+	}
+	if len(v) != len(want) {
+		t.Fatalf("unexpected violations: %v", v)
+	}
+	for i := range want {
+		if s := v[i].String(); s != want[i] {
+			t.Errorf("#%d: want %q, got %q", i, want[i], s)
+		}
+	}
+	if len(v[1].Goroutines) != 2 || v[2].Goroutines[0].ID != 6 {
+		t.Fatal("unexpected goroutines")
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Severity is how bad a Finding is.
+type Severity int
+
+const (
+	// SeverityInfo is a finding worth knowing about while investigating.
+	SeverityInfo Severity = iota
+	// SeverityWarning is a likely problem, e.g. a leak.
+	SeverityWarning
+	// SeverityCritical is a problem that stops the process from making
+	// progress, e.g. a deadlock.
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(b []byte) error {
+	for v := SeverityInfo; v <= SeverityCritical; v++ {
+		if v.String() == string(b) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", b)
+}
+
+// Confidence is how likely a Finding is to be right.
+type Confidence int
+
+const (
+	// ConfidenceLow is a heuristic with frequent false positives.
+	ConfidenceLow Confidence = iota
+	// ConfidenceMedium is a heuristic that is usually right.
+	ConfidenceMedium
+	// ConfidenceHigh is reported by the runtime itself or is certain.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Confidence(%d)", int(c))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Confidence) UnmarshalText(b []byte) error {
+	for v := ConfidenceLow; v <= ConfidenceHigh; v++ {
+		if v.String() == string(b) {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown confidence %q", b)
+}
+
+// Finding is a problem found by an Analyzer.
+type Finding struct {
+	// Analyzer is the name of the Analyzer that reported the finding.
+	Analyzer string `json:"analyzer"`
+	// Severity is how bad the problem is.
+	Severity Severity `json:"severity"`
+	// Confidence is how likely the finding is to be right.
+	Confidence Confidence `json:"confidence"`
+	// IDs is the goroutines involved.
+	IDs []int `json:"ids,omitempty"`
+	// Call is the most relevant call, e.g. the one blocked. It may be nil.
+	Call *stack.Call `json:"call,omitempty"`
+	// Message is a plain English description.
+	Message string `json:"message"`
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Analyzer, f.Message)
+}
+
+// Analyzer is a pass over a parsed stack dump.
+type Analyzer interface {
+	// Name identifies the analyzer, e.g. "deadlock". It must be unique.
+	Name() string
+	// Analyze returns the findings. It must not modify c.
+	Analyze(c *stack.Context) []Finding
+}
+
+// NewAnalyzer returns an Analyzer calling f.
+func NewAnalyzer(name string, f func(c *stack.Context) []Finding) Analyzer {
+	return &funcAnalyzer{name: name, f: f}
+}
+
+// RegisterAnalyzer adds an analyzer to the registry.
+//
+// Analyzers are run in the order they were registered. The analyzers of this
+// package are registered first. It panics if the name is already registered.
+func RegisterAnalyzer(a Analyzer) {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	for _, r := range analyzers {
+		if r.Name() == a.Name() {
+			panic(fmt.Sprintf("analyzer %q is already registered", a.Name()))
+		}
+	}
+	analyzers = append(analyzers, a)
+}
+
+// Analyzers returns a copy of the registered analyzers.
+func Analyzers() []Analyzer {
+	analyzersMu.Lock()
+	defer analyzersMu.Unlock()
+	return append([]Analyzer(nil), analyzers...)
+}
+
+// SelectAnalyzers returns the registered analyzers named in enable, or all of
+// them if enable is empty, minus the ones named in disable.
+//
+// It returns an error if a name is not registered.
+func SelectAnalyzers(enable, disable []string) ([]Analyzer, error) {
+	all := Analyzers()
+	known := make(map[string]bool, len(all))
+	for _, a := range all {
+		known[a.Name()] = true
+	}
+	want := map[string]bool{}
+	for _, n := range enable {
+		if !known[n] {
+			return nil, fmt.Errorf("unknown analyzer %q", n)
+		}
+		want[n] = true
+	}
+	skip := map[string]bool{}
+	for _, n := range disable {
+		if !known[n] {
+			return nil, fmt.Errorf("unknown analyzer %q", n)
+		}
+		skip[n] = true
+	}
+	var out []Analyzer
+	for _, a := range all {
+		if (len(want) == 0 || want[a.Name()]) && !skip[a.Name()] {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// Run runs the analyzers in order and returns their findings, the most
+// severe first.
+//
+// Finding.Analyzer is set to the name of the analyzer.
+func Run(c *stack.Context, analyzers []Analyzer) []Finding {
+	var out []Finding
+	for _, a := range analyzers {
+		for _, f := range a.Analyze(c) {
+			f.Analyzer = a.Name()
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Severity > out[j].Severity
+	})
+	return out
+}
+
+// Private stuff.
+
+type funcAnalyzer struct {
+	name string
+	f    func(c *stack.Context) []Finding
+}
+
+func (f *funcAnalyzer) Name() string {
+	return f.name
+}
+
+func (f *funcAnalyzer) Analyze(c *stack.Context) []Finding {
+	return f.f(c)
+}
+
+var (
+	analyzersMu sync.Mutex
+	analyzers   = []Analyzer{
+		NewAnalyzer("deadlock", func(c *stack.Context) []Finding {
+			d := FindDeadlock(c)
+			if d == nil {
+				return nil
+			}
+			f := Finding{Severity: SeverityCritical, Confidence: ConfidenceHigh, Message: d.Explanation}
+			for _, w := range d.Waiters {
+				f.IDs = append(f.IDs, w.ID)
+			}
+			if len(d.Waiters) != 0 {
+				f.Call = d.Waiters[0].Call
+			}
+			return []Finding{f}
+		}),
+		NewAnalyzer("orphaned-chan", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, o := range FindOrphanedChanOps(c) {
+				out = append(out, Finding{Severity: SeverityWarning, Confidence: ConfidenceMedium, IDs: o.IDs, Call: o.Call, Message: o.Explanation})
+			}
+			return out
+		}),
+		NewAnalyzer("sleep-loop", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, l := range FindSleepLoops(c) {
+				out = append(out, Finding{Severity: SeverityWarning, Confidence: ConfidenceLow, IDs: l.IDs, Call: l.Sleeper, Message: l.Explanation})
+			}
+			return out
+		}),
+		NewAnalyzer("waitgroup", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, w := range FindWaitGroupWaits(c) {
+				out = append(out, Finding{Severity: SeverityInfo, Confidence: ConfidenceLow, IDs: []int{w.ID}, Call: w.Call, Message: w.Explanation})
+			}
+			return out
+		}),
+		NewAnalyzer("sql-pool", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, p := range FindSQLPoolExhaustion(c) {
+				f := Finding{Severity: SeverityCritical, Confidence: ConfidenceMedium, IDs: p.Waiting, Message: p.Explanation}
+				if len(p.Sites) != 0 {
+					f.Call = p.Sites[0].Call
+				}
+				out = append(out, f)
+			}
+			return out
+		}),
+		NewAnalyzer("context", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, w := range FindContextWaits(c) {
+				out = append(out, Finding{Severity: SeverityInfo, Confidence: ConfidenceMedium, IDs: w.IDs, Call: w.Call, Message: w.Explanation})
+			}
+			return out
+		}),
+		NewAnalyzer("timer-overdue", func(c *stack.Context) []Finding {
+			var out []Finding
+			for _, w := range FindTimerWaits(c) {
+				if w.Overdue {
+					out = append(out, Finding{Severity: SeverityWarning, Confidence: ConfidenceMedium, IDs: w.IDs, Call: w.Call, Message: w.Explanation})
+				}
+			}
+			return out
+		}),
+	}
+)
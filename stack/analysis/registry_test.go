@@ -0,0 +1,107 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	// Not parallel since it modifies the registry.
+	RegisterAnalyzer(NewAnalyzer("test-count", func(c *stack.Context) []Finding {
+		return []Finding{{Severity: SeverityInfo, Message: "goroutines: " + strings.Repeat("x", len(c.Goroutines))}}
+	}))
+	defer func() {
+		analyzersMu.Lock()
+		analyzers = analyzers[:len(analyzers)-1]
+		analyzersMu.Unlock()
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic on duplicate name")
+			}
+		}()
+		RegisterAnalyzer(NewAnalyzer("test-count", nil))
+	}()
+
+	data := []string{
+		"fatal error: all goroutines are asleep - deadlock!",
+		"",
+		"goroutine 1 [chan receive]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	a, err := SelectAnalyzers(nil, []string{"context", "timer-overdue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, x := range a {
+		names = append(names, x.Name())
+	}
+	want := []string{"deadlock", "orphaned-chan", "sleep-loop", "waitgroup", "sql-pool", "test-count"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("Analyzers mismatch (-want +got):\n%s", diff)
+	}
+	var got []string
+	for _, f := range Run(c, a) {
+		got = append(got, f.String())
+	}
+	want = []string{
+		"[critical] deadlock: " + FindDeadlock(c).Explanation,
+		"[info] test-count: goroutines: x",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Findings mismatch (-want +got):\n%s", diff)
+	}
+	if a, err := SelectAnalyzers([]string{"test-count"}, nil); err != nil || len(a) != 1 {
+		t.Fatalf("unexpected: %v, %v", a, err)
+	}
+	if _, err := SelectAnalyzers([]string{"nope"}, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFindingJSON(t *testing.T) {
+	t.Parallel()
+	f := Finding{Analyzer: "x", Severity: SeverityCritical, Confidence: ConfidenceMedium, IDs: []int{1}, Message: "m"}
+	b, err := json.Marshal(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"analyzer":"x","severity":"critical","confidence":"medium","ids":[1],"message":"m"}`
+	if string(b) != want {
+		t.Fatalf("want %s, got %s", want, b)
+	}
+	var got Finding
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(f, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunSeverityOrder(t *testing.T) {
+	t.Parallel()
+	a := NewAnalyzer("a", func(c *stack.Context) []Finding {
+		return []Finding{{Severity: SeverityInfo, Message: "1"}, {Severity: SeverityCritical, Message: "2"}, {Severity: SeverityWarning, Message: "3"}, {Severity: SeverityCritical, Message: "4"}}
+	})
+	var got []string
+	for _, f := range Run(&stack.Context{}, []Analyzer{a}) {
+		got = append(got, f.Message)
+	}
+	if diff := cmp.Diff([]string{"2", "4", "3", "1"}, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+}
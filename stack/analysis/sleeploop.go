@@ -0,0 +1,106 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// SleepLoop is a group of goroutines created at the same place and sleeping
+// in time.Sleep, which is typical of retry loops that never succeed.
+//
+// The goroutines without a creator, e.g. the main goroutine, are grouped by
+// the call to time.Sleep instead.
+type SleepLoop struct {
+	// CreatedBy is the call that created the goroutines. It is nil for the
+	// goroutines without a creator.
+	CreatedBy *stack.Call
+	// Sleeper is the innermost user call, the one calling time.Sleep. It may
+	// be nil.
+	Sleeper *stack.Call
+	// IDs is the goroutines sleeping.
+	IDs []int
+	// Minutes is the cumulative time the goroutines have been sleeping, as
+	// reported by the runtime.
+	Minutes int
+	// Explanation is a plain English description of the loop.
+	Explanation string
+}
+
+// FindSleepLoops returns the groups of goroutines likely stuck in sleep and
+// retry loops, largest first.
+//
+// A lone goroutine sleeping for a short time is considered a legitimate
+// scheduled task, e.g. a poller, and is not reported.
+func FindSleepLoops(c *stack.Context) []SleepLoop {
+	groups := map[string]*SleepLoop{}
+	var keys []string
+	for _, g := range c.Goroutines {
+		if g.WaitReason() != stack.WaitSleep || !callsSleep(&g.Signature) {
+			continue
+		}
+		sleeper := userCall(&g.Signature)
+		var createdBy *stack.Call
+		var k string
+		if g.CreatedBy.Func.Raw != "" {
+			createdBy = &g.CreatedBy
+			k = fmt.Sprintf("%s:%s:%d", g.CreatedBy.Func.Raw, g.CreatedBy.SrcPath, g.CreatedBy.Line)
+		} else if sleeper != nil {
+			k = fmt.Sprintf("sleeper %s:%s:%d", sleeper.Func.Raw, sleeper.SrcPath, sleeper.Line)
+		} else {
+			// Nothing to group by.
+			k = fmt.Sprintf("goroutine %d", g.ID)
+		}
+		l := groups[k]
+		if l == nil {
+			l = &SleepLoop{CreatedBy: createdBy, Sleeper: sleeper}
+			groups[k] = l
+			keys = append(keys, k)
+		}
+		l.IDs = append(l.IDs, g.ID)
+		l.Minutes += g.SleepMax
+	}
+	var out []SleepLoop
+	for _, k := range keys {
+		l := groups[k]
+		if len(l.IDs) < minSleepers && l.Minutes < minSleepMinutes {
+			continue
+		}
+		if l.CreatedBy != nil {
+			l.Explanation = fmt.Sprintf("%d goroutines created by %s sleep in %s, for %d minutes in total; likely a retry loop", len(l.IDs), location(l.CreatedBy), location(l.Sleeper), l.Minutes)
+		} else {
+			l.Explanation = fmt.Sprintf("%d goroutines sleep in %s, for %d minutes in total; likely a retry loop", len(l.IDs), location(l.Sleeper), l.Minutes)
+		}
+		out = append(out, *l)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return len(out[i].IDs) > len(out[j].IDs)
+	})
+	return out
+}
+
+// Private stuff.
+
+const (
+	// minSleepers is the number of goroutines sleeping from the same creator
+	// to be reported.
+	minSleepers = 2
+	// minSleepMinutes is the cumulative sleep duration to report a lone
+	// goroutine.
+	minSleepMinutes = 10
+)
+
+// callsSleep returns true if time.Sleep is in the stack.
+func callsSleep(s *stack.Signature) bool {
+	for i := range s.Stack.Calls {
+		if s.Stack.Calls[i].Func.Raw == "time.Sleep" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindSleepLoops(t *testing.T) {
+	t.Parallel()
+	var data []string
+	sleeper := func(id, minutes int, f string, line int) {
+		data = append(data,
+			fmt.Sprintf("goroutine %d [sleep, %d minutes]:", id, minutes),
+			"time.Sleep(0x3b9aca00)",
+			"	/goroot/src/runtime/time.go:195 +0x135",
+			"github.com/foo/bar."+f+"()",
+			"	/home/user/go/src/github.com/foo/bar/bar.go:20 +0x45",
+			"created by github.com/foo/bar.Start",
+			fmt.Sprintf("	/home/user/go/src/github.com/foo/bar/bar.go:%d +0x45", line),
+			"")
+	}
+	sleeper(6, 3, "retry", 10)
+	sleeper(7, 4, "retry", 10)
+	// A lone poller is legitimate.
+	sleeper(8, 1, "poll", 11)
+	c := parse(t, strings.Join(data, "\n"))
+	l := FindSleepLoops(c)
+	if len(l) != 1 {
+		t.Fatalf("unexpected loops: %#v", l)
+	}
+	want := "2 goroutines created by bar.Start at bar.go:10 sleep in bar.retry at bar.go:20, for 7 minutes in total; likely a retry loop"
+	if l[0].Explanation != want {
+		t.Fatalf("want %q, got %q", want, l[0].Explanation)
+	}
+}
+
+func TestFindSleepLoopsNoCreator(t *testing.T) {
+	t.Parallel()
+	var data []string
+	sleeper := func(id, minutes int, f string) {
+		data = append(data,
+			fmt.Sprintf("goroutine %d [sleep, %d minutes]:", id, minutes),
+			"time.Sleep(0x3b9aca00)",
+			"	/goroot/src/runtime/time.go:195 +0x135",
+			"github.com/foo/bar."+f+"()",
+			"	/home/user/go/src/github.com/foo/bar/bar.go:20 +0x45",
+			"")
+	}
+	// Goroutines without a creator sleeping at different places are not
+	// grouped together.
+	sleeper(1, 30, "retry")
+	sleeper(2, 3, "poll")
+	sleeper(3, 4, "wait")
+	c := parse(t, strings.Join(data, "\n"))
+	l := FindSleepLoops(c)
+	if len(l) != 1 || l[0].CreatedBy != nil || len(l[0].IDs) != 1 {
+		t.Fatalf("unexpected loops: %#v", l)
+	}
+	want := "1 goroutines sleep in bar.retry at bar.go:20, for 30 minutes in total; likely a retry loop"
+	if l[0].Explanation != want {
+		t.Fatalf("want %q, got %q", want, l[0].Explanation)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// SQLPoolExhaustion is goroutines waiting for a connection from a
+// database/sql connection pool, which only happens when the pool reached
+// DB.SetMaxOpenConns.
+type SQLPoolExhaustion struct {
+	// DB is the address of the sql.DB, if printed.
+	DB uint64
+	// Waiting is the goroutines waiting for a connection, in the order they
+	// were printed.
+	Waiting []int
+	// Transactions is the number of goroutines watching the context of an
+	// open transaction. Each holds a connection until committed or rolled
+	// back. It includes the transactions of all the pools.
+	Transactions int
+	// Sites is the call sites waiting for a connection, the most frequent
+	// first.
+	Sites []SQLCallSite
+	// Explanation is a plain English description.
+	Explanation string
+}
+
+// SQLCallSite is the innermost user call waiting for a connection.
+type SQLCallSite struct {
+	// Call is the user call, e.g. the one calling DB.QueryContext. It may be
+	// nil.
+	Call *stack.Call
+	// IDs is the goroutines waiting at this call site.
+	IDs []int
+}
+
+// FindSQLPoolExhaustion returns the database/sql pools with goroutines
+// waiting for a connection, one per sql.DB when the addresses are printed.
+//
+// Returns nil if no goroutine waits for a connection.
+func FindSQLPoolExhaustion(c *stack.Context) []SQLPoolExhaustion {
+	byDB := map[uint64]*SQLPoolExhaustion{}
+	var order []uint64
+	tx := 0
+	for _, g := range c.Goroutines {
+		if hasFunc(&g.Signature, "database/sql.(*Tx).awaitDone") {
+			tx++
+			continue
+		}
+		db, ok := sqlConnWait(&g.Signature)
+		if !ok {
+			continue
+		}
+		p := byDB[db]
+		if p == nil {
+			p = &SQLPoolExhaustion{DB: db}
+			byDB[db] = p
+			order = append(order, db)
+		}
+		p.Waiting = append(p.Waiting, g.ID)
+		call := userCall(&g.Signature)
+		found := false
+		for i := range p.Sites {
+			if s := &p.Sites[i]; sameSite(s.Call, call) {
+				s.IDs = append(s.IDs, g.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			p.Sites = append(p.Sites, SQLCallSite{Call: call, IDs: []int{g.ID}})
+		}
+	}
+	var out []SQLPoolExhaustion
+	for _, db := range order {
+		p := byDB[db]
+		p.Transactions = tx
+		sort.SliceStable(p.Sites, func(i, j int) bool {
+			return len(p.Sites[i].IDs) > len(p.Sites[j].IDs)
+		})
+		p.Explanation = explainSQLPool(p)
+		out = append(out, *p)
+	}
+	return out
+}
+
+// Private stuff.
+
+// hasFunc returns true if the function is in the stack.
+func hasFunc(s *stack.Signature, raw string) bool {
+	for i := range s.Stack.Calls {
+		if s.Stack.Calls[i].Func.Raw == raw {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlConnWait returns the sql.DB address if the goroutine is blocked
+// waiting for a connection. The address is 0 if not printed.
+func sqlConnWait(s *stack.Signature) (uint64, bool) {
+	switch s.WaitReason() {
+	case stack.WaitSelect, stack.WaitChanReceive:
+	default:
+		return 0, false
+	}
+	// The innermost database/sql frame must be the one waiting on the
+	// connection request, not e.g. a query waiting on the network.
+	for i := range s.Stack.Calls {
+		c := &s.Stack.Calls[i]
+		f := c.Func.String()
+		if !strings.HasPrefix(f, "database/sql.") {
+			continue
+		}
+		if f != "database/sql.(*DB).conn" {
+			return 0, false
+		}
+		if len(c.Args.Values) != 0 {
+			return c.Args.Values[0].Value, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sameSite returns true if both calls are at the same location.
+func sameSite(a, b *stack.Call) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Func.Raw == b.Func.Raw && a.SrcPath == b.SrcPath && a.Line == b.Line
+}
+
+// explainSQLPool describes the pool exhaustion and the top call sites.
+func explainSQLPool(p *SQLPoolExhaustion) string {
+	who := fmt.Sprintf("%d goroutines wait", len(p.Waiting))
+	if len(p.Waiting) == 1 {
+		who = fmt.Sprintf("goroutine %d waits", p.Waiting[0])
+	}
+	s := who + " for a database/sql connection; the pool likely reached its maximum number of open connections"
+	switch p.Transactions {
+	case 0:
+	case 1:
+		s += ", 1 transaction is open"
+	default:
+		s += fmt.Sprintf(", %d transactions are open", p.Transactions)
+	}
+	var sites []string
+	for i, c := range p.Sites {
+		if i == 3 {
+			sites = append(sites, fmt.Sprintf("%d more", len(p.Sites)-i))
+			break
+		}
+		sites = append(sites, fmt.Sprintf("%s (%d)", location(c.Call), len(c.IDs)))
+	}
+	return s + "; waiting at " + strings.Join(sites, ", ")
+}
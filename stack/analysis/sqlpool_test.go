@@ -0,0 +1,67 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindSQLPoolExhaustion(t *testing.T) {
+	t.Parallel()
+	var data []string
+	wait := func(id int, site string, line int) {
+		data = append(data,
+			fmt.Sprintf("goroutine %d [select, 2 minutes]:", id),
+			"database/sql.(*DB).conn(0xc0000b4000, {0x7f1a20, 0xc000016060}, 0x1)",
+			"	/goroot/src/database/sql/sql.go:1300 +0x49c",
+			"database/sql.(*DB).query(0xc0000b4000, {0x7f1a20, 0xc000016060}, {0x6f0e8c, 0x10}, {0x0, 0x0, 0x0}, 0x0?)",
+			"	/goroot/src/database/sql/sql.go:1721 +0x57",
+			"database/sql.(*DB).QueryContext(0xc0000b4000, {0x7f1a20, 0xc000016060}, {0x6f0e8c, 0x10}, {0x0, 0x0, 0x0})",
+			"	/goroot/src/database/sql/sql.go:1702 +0xc5",
+			"main.(*Store)."+site+"(0xc00000e028)",
+			fmt.Sprintf("	/home/user/src/foo/store.go:%d +0x45", line),
+			"")
+	}
+	wait(10, "Get", 30)
+	wait(11, "List", 50)
+	wait(12, "Get", 30)
+	data = append(data,
+		"goroutine 20 [select]:",
+		"database/sql.(*Tx).awaitDone(0xc000200000)",
+		"	/goroot/src/database/sql/sql.go:2202 +0x2b",
+		"created by database/sql.(*DB).beginDC in goroutine 5",
+		"	/goroot/src/database/sql/sql.go:1901 +0x20f",
+		"",
+		"goroutine 21 [IO wait]:",
+		"internal/poll.runtime_pollWait(0x7f0e1c3e6e48, 0x72)",
+		"	/goroot/src/runtime/netpoll.go:343 +0x85",
+		"database/sql.(*Rows).Next(0xc000300000)",
+		"	/goroot/src/database/sql/sql.go:2990 +0x85",
+		"main.(*Store).Get(0xc00000e028)",
+		"	/home/user/src/foo/store.go:32 +0x45",
+		"")
+	c := parse(t, strings.Join(data, "\n"))
+	p := FindSQLPoolExhaustion(c)
+	if len(p) != 1 {
+		t.Fatalf("unexpected result: %#v", p)
+	}
+	if p[0].DB != 0xc0000b4000 || p[0].Transactions != 1 {
+		t.Fatalf("unexpected pool: %#v", p[0])
+	}
+	if diff := cmp.Diff([]int{10, 11, 12}, p[0].Waiting); diff != "" {
+		t.Fatalf("Waiting mismatch (-want +got):\n%s", diff)
+	}
+	want := "3 goroutines wait for a database/sql connection; the pool likely reached its maximum number of open connections, 1 transaction is open; waiting at main.(*Store).Get at store.go:30 (2), main.(*Store).List at store.go:50 (1)"
+	if p[0].Explanation != want {
+		t.Fatalf("want %q, got %q", want, p[0].Explanation)
+	}
+	if p := FindSQLPoolExhaustion(parse(t, strings.Join(data[30:], "\n"))); p != nil {
+		t.Fatalf("unexpected result: %#v", p)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// TimerWait is goroutines waiting on a timer at the same call site, with the
+// intended duration when it can be decoded.
+type TimerWait struct {
+	// IDs is the goroutines, in the order they were printed.
+	IDs []int
+	// Call is the innermost user call, the one waiting.
+	Call *stack.Call
+	// Func is the timer function, e.g. "time.Sleep" or "time.After".
+	Func string
+	// Duration is the intended duration. It is 0 when unknown.
+	Duration time.Duration
+	// Minutes is the longest wait of the goroutines, as printed by the
+	// runtime.
+	Minutes int
+	// Overdue is set when the goroutines have been waiting longer than
+	// Duration, i.e. the timer path is not what is blocking them.
+	Overdue bool
+	// Explanation is a plain English description.
+	Explanation string
+}
+
+// FindTimerWaits returns the goroutines sleeping in time.Sleep or waiting on
+// time.After or time.Tick.
+//
+// The duration of time.Sleep is decoded from its argument when printed, i.e.
+// before the register based calling convention of Go 1.17, otherwise from the
+// source. The duration of time.After and time.Tick is always decoded from the
+// source, which must be available locally, i.e. the stack trace must be
+// parsed with guesspaths. Only constant expressions like "5*time.Second" are
+// decoded.
+func FindTimerWaits(c *stack.Context) []TimerWait {
+	src := sources{}
+	var out []TimerWait
+	for _, g := range c.Goroutines {
+		w := timerWait(g, src)
+		if w == nil {
+			continue
+		}
+		merged := false
+		for i := range out {
+			o := &out[i]
+			if sameSite(o.Call, w.Call) && o.Func == w.Func && o.Duration == w.Duration {
+				o.IDs = append(o.IDs, g.ID)
+				if w.Minutes > o.Minutes {
+					o.Minutes = w.Minutes
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, *w)
+		}
+	}
+	for i := range out {
+		w := &out[i]
+		w.Overdue = w.Duration != 0 && time.Duration(w.Minutes)*time.Minute > w.Duration
+		w.Explanation = explainTimerWait(w)
+	}
+	return out
+}
+
+// Private stuff.
+
+// reTimerCall matches the timer calls in a source line and their argument,
+// allowing one level of parenthesis, e.g. "time.Duration(n)".
+var reTimerCall = regexp.MustCompile(`\b(time\.(?:Sleep|After|Tick))\(((?:[^()]|\([^()]*\))*)\)`)
+
+// timerWait returns the wait if g is blocked on a timer.
+func timerWait(g *stack.Goroutine, src sources) *TimerWait {
+	call := userCall(&g.Signature)
+	if call == nil {
+		return nil
+	}
+	w := &TimerWait{IDs: []int{g.ID}, Call: call, Minutes: g.SleepMax}
+	switch g.WaitReason() {
+	case stack.WaitSleep:
+		calls := g.Stack.Calls
+		for i := range calls {
+			if calls[i].Func.Raw == "time.Sleep" {
+				w.Func = "time.Sleep"
+				if len(calls[i].Args.Values) != 0 {
+					w.Duration = time.Duration(calls[i].Args.Values[0].Value)
+				}
+				break
+			}
+		}
+		if w.Func == "" {
+			return nil
+		}
+		if w.Duration == 0 {
+			w.Duration = parseTimerCall(sourceLineOf(call, src), w.Func)
+		}
+		return w
+	case stack.WaitSelect, stack.WaitChanReceive:
+		lines := append([]string{sourceLineOf(call, src)}, call.SelectCases...)
+		for _, fn := range []string{"time.After", "time.Tick"} {
+			for _, s := range lines {
+				if strings.Contains(s, fn+"(") {
+					w.Func = fn
+					w.Duration = parseTimerCall(s, fn)
+					return w
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sourceLineOf returns the source line of the call, or "".
+func sourceLineOf(call *stack.Call, src sources) string {
+	if l := src.lines(call.LocalSrcPath); call.Line > 0 && call.Line <= len(l) {
+		return l[call.Line-1]
+	}
+	return ""
+}
+
+// parseTimerCall returns the duration passed to fn in s.
+func parseTimerCall(s, fn string) time.Duration {
+	for _, m := range reTimerCall.FindAllStringSubmatch(s, -1) {
+		if m[1] == fn {
+			d, _ := parseDurationExpr(m[2])
+			return d
+		}
+	}
+	return 0
+}
+
+// timeUnits are the time package constants.
+var timeUnits = map[string]time.Duration{
+	"time.Nanosecond":  time.Nanosecond,
+	"time.Microsecond": time.Microsecond,
+	"time.Millisecond": time.Millisecond,
+	"time.Second":      time.Second,
+	"time.Minute":      time.Minute,
+	"time.Hour":        time.Hour,
+}
+
+// parseDurationExpr decodes a constant duration expression, a product of
+// numbers and time units, e.g. "5 * time.Second" or "time.Duration(3) *
+// time.Minute".
+func parseDurationExpr(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	out := float64(1)
+	for _, f := range strings.Split(s, "*") {
+		f = strings.TrimSpace(f)
+		if strings.HasPrefix(f, "time.Duration(") && strings.HasSuffix(f, ")") {
+			f = f[len("time.Duration(") : len(f)-1]
+		}
+		if u, ok := timeUnits[f]; ok {
+			out *= float64(u)
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.Replace(f, "_", "", -1), 64)
+		if err != nil {
+			return 0, false
+		}
+		out *= v
+	}
+	return time.Duration(out), true
+}
+
+// explainTimerWait describes the wait.
+func explainTimerWait(w *TimerWait) string {
+	who := fmt.Sprintf("%d goroutines wait", len(w.IDs))
+	if len(w.IDs) == 1 {
+		who = fmt.Sprintf("goroutine %d waits", w.IDs[0])
+	}
+	d := "an unknown duration"
+	if w.Duration != 0 {
+		d = w.Duration.String()
+	}
+	s := fmt.Sprintf("%s in %s(%s) at %s", who, w.Func, d, location(w.Call))
+	if w.Minutes != 0 {
+		s += fmt.Sprintf(" for %d minutes", w.Minutes)
+	}
+	if w.Overdue {
+		s += "; longer than the timer, something else is blocking"
+	}
+	return s
+}
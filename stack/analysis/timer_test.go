@@ -0,0 +1,119 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindTimerWaits(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := []string{
+		"package main",
+		"",
+		"func poll() {",
+		"	time.Sleep(50 * time.Millisecond)",
+		"}",
+		"",
+		"func call(c chan int) {",
+		"	select { case <-c: case <-time.After(time.Duration(2)*time.Second): }",
+		"}",
+	}
+	if err := ioutil.WriteFile(main, []byte(strings.Join(src, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := []string{
+		"goroutine 5 [sleep]:",
+		"time.Sleep(0x2faf080)",
+		"	/goroot/src/runtime/time.go:195 +0x135",
+		"main.retry()",
+		"	/home/user/src/foo/retry.go:12 +0x25",
+		"",
+		"goroutine 6 [sleep]:",
+		"time.Sleep(...)",
+		"	/goroot/src/runtime/time.go:195 +0x135",
+		"main.poll()",
+		"	" + main + ":4 +0x25",
+		"",
+		"goroutine 7 [select, 20 minutes]:",
+		"runtime.selectgo(0xc000057f28, 0xc000057f14, 0x0?, 0x0, 0x0?, 0x1)",
+		"	/goroot/src/runtime/select.go:327 +0x7be",
+		"main.call(0xc00001e0c0)",
+		"	" + main + ":8 +0x8f",
+		"",
+		"goroutine 8 [select, 20 minutes]:",
+		"runtime.selectgo(0xc000057f28, 0xc000057f14, 0x0?, 0x0, 0x0?, 0x1)",
+		"	/goroot/src/runtime/select.go:327 +0x7be",
+		"main.call(0xc00001e180)",
+		"	" + main + ":8 +0x8f",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	for _, g := range c.Goroutines {
+		for i := range g.Stack.Calls {
+			if g.Stack.Calls[i].SrcPath == main {
+				g.Stack.Calls[i].LocalSrcPath = main
+			}
+		}
+	}
+	w := FindTimerWaits(c)
+	type wait struct {
+		IDs      []int
+		Func     string
+		Duration time.Duration
+		Overdue  bool
+	}
+	var got []wait
+	for _, x := range w {
+		got = append(got, wait{x.IDs, x.Func, x.Duration, x.Overdue})
+	}
+	want := []wait{
+		{[]int{5}, "time.Sleep", 50 * time.Millisecond, false},
+		{[]int{6}, "time.Sleep", 50 * time.Millisecond, false},
+		{[]int{7, 8}, "time.After", 2 * time.Second, true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	e := "2 goroutines wait in time.After(2s) at main.call at main.go:8 for 20 minutes; longer than the timer, something else is blocking"
+	if w[2].Explanation != e {
+		t.Fatalf("want %q, got %q", e, w[2].Explanation)
+	}
+}
+
+func TestParseDurationExpr(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"time.Second", time.Second, true},
+		{"5 * time.Second", 5 * time.Second, true},
+		{"1_000*time.Microsecond", time.Millisecond, true},
+		{"time.Minute * 1.5", 90 * time.Second, true},
+		{"100", 100, true},
+		{"d", 0, false},
+		{"", 0, false},
+	}
+	for i, l := range data {
+		if got, ok := parseDurationExpr(l.in); got != l.want || ok != l.ok {
+			t.Errorf("#%d: parseDurationExpr(%q) = %s, %t; want %s, %t", i, l.in, got, ok, l.want, l.ok)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// WaitGroupWait is a goroutine blocked in sync.(*WaitGroup).Wait and the
+// goroutines it is likely waiting on.
+type WaitGroupWait struct {
+	// ID is the goroutine blocked in Wait.
+	ID int
+	// WaitGroup is the address of the sync.WaitGroup, if printed.
+	WaitGroup uint64
+	// Call is the innermost user call, the one calling Wait. It may be nil.
+	Call *stack.Call
+	// Candidates is the goroutines likely to call Done, the most likely
+	// first.
+	Candidates []WaitGroupCandidate
+	// Explanation is a plain English description.
+	Explanation string
+}
+
+// WaitGroupCandidate is a goroutine that may be the one a WaitGroup is
+// waiting on.
+type WaitGroupCandidate struct {
+	// ID is the goroutine ID.
+	ID int
+	// Link is why the goroutine is a candidate.
+	Link WaitGroupLink
+	// Call is the innermost user call of the candidate. It may be nil.
+	Call *stack.Call
+}
+
+// WaitGroupLink is the relation between a goroutine waiting on a WaitGroup
+// and a candidate, from the most to the least reliable.
+type WaitGroupLink int
+
+const (
+	// LinkChild is a goroutine created by the waiting goroutine.
+	LinkChild WaitGroupLink = iota
+	// LinkSharedWaitGroup is a goroutine with the WaitGroup address in its
+	// arguments.
+	LinkSharedWaitGroup
+	// LinkSameFunction is a goroutine created by the function calling Wait.
+	LinkSameFunction
+	// LinkSameFile is a goroutine created close to the Wait call in the same
+	// source file.
+	LinkSameFile
+	// LinkSamePackage is a goroutine created in the package calling Wait.
+	LinkSamePackage
+)
+
+func (l WaitGroupLink) String() string {
+	switch l {
+	case LinkChild:
+		return "created by the waiting goroutine"
+	case LinkSharedWaitGroup:
+		return "references the WaitGroup"
+	case LinkSameFunction:
+		return "created by the same function"
+	case LinkSameFile:
+		return "created nearby in the same file"
+	case LinkSamePackage:
+		return "created in the same package"
+	default:
+		return fmt.Sprintf("WaitGroupLink(%d)", int(l))
+	}
+}
+
+// FindWaitGroupWaits returns the goroutines blocked in
+// sync.(*WaitGroup).Wait with the goroutines they are likely waiting on.
+//
+// The runtime doesn't record which goroutines will call Done, so this is
+// guessed from the creator goroutine ID (Go 1.21 and later), the WaitGroup
+// address in the arguments and the location of the "go" statements relative
+// to the Wait call. It is a hint, not a proof. Goroutines themselves blocked
+// in Wait are never candidates of the same WaitGroup.
+func FindWaitGroupWaits(c *stack.Context) []WaitGroupWait {
+	var out []WaitGroupWait
+	for _, g := range c.Goroutines {
+		wg, ok := waitGroupWait(&g.Signature)
+		if !ok {
+			continue
+		}
+		w := WaitGroupWait{ID: g.ID, WaitGroup: wg, Call: userCall(&g.Signature)}
+		for _, r := range c.Goroutines {
+			if r == g || r.Source != g.Source {
+				continue
+			}
+			if o, ok := waitGroupWait(&r.Signature); ok && (o == wg || wg == 0) {
+				continue
+			}
+			if l, ok := waitGroupLink(g, &w, r); ok {
+				w.Candidates = append(w.Candidates, WaitGroupCandidate{ID: r.ID, Link: l, Call: userCall(&r.Signature)})
+			}
+		}
+		sort.SliceStable(w.Candidates, func(i, j int) bool {
+			return w.Candidates[i].Link < w.Candidates[j].Link
+		})
+		w.Explanation = explainWaitGroup(&w)
+		out = append(out, w)
+	}
+	return out
+}
+
+// Private stuff.
+
+// waitGroupRegion is the maximum distance in lines between a "go" statement
+// and the Wait call to consider them related.
+const waitGroupRegion = 50
+
+// waitGroupWait returns the WaitGroup address if the goroutine is blocked in
+// sync.(*WaitGroup).Wait. The address is 0 if not printed.
+func waitGroupWait(s *stack.Signature) (uint64, bool) {
+	for i := range s.Stack.Calls {
+		c := &s.Stack.Calls[i]
+		if c.Func.Raw == "sync.(*WaitGroup).Wait" {
+			if len(c.Args.Values) != 0 {
+				return c.Args.Values[0].Value, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// waitGroupLink returns the most reliable link between the waiting goroutine
+// g and r, if any.
+func waitGroupLink(g *stack.Goroutine, w *WaitGroupWait, r *stack.Goroutine) (WaitGroupLink, bool) {
+	if r.CreatedByID != 0 && r.CreatedByID == g.ID {
+		return LinkChild, true
+	}
+	if w.WaitGroup != 0 {
+		for i := range r.Stack.Calls {
+			for _, a := range r.Stack.Calls[i].Args.Values {
+				if a.Value == w.WaitGroup {
+					return LinkSharedWaitGroup, true
+				}
+			}
+		}
+	}
+	cb := &r.CreatedBy
+	if w.Call == nil || cb.Func.Raw == "" {
+		return 0, false
+	}
+	// Closures started with "go func() {...}()" are reported as created by
+	// the enclosing function.
+	if cb.Func.Raw == w.Call.Func.Raw || strings.HasPrefix(w.Call.Func.Raw, cb.Func.Raw+".func") {
+		return LinkSameFunction, true
+	}
+	if cb.SrcPath == w.Call.SrcPath {
+		d := cb.Line - w.Call.Line
+		if d >= -waitGroupRegion && d <= waitGroupRegion {
+			return LinkSameFile, true
+		}
+	}
+	if path.Dir(cb.SrcPath) == path.Dir(w.Call.SrcPath) && cb.Func.PkgName() == w.Call.Func.PkgName() {
+		return LinkSamePackage, true
+	}
+	return 0, false
+}
+
+// explainWaitGroup describes the wait and its best candidates.
+func explainWaitGroup(w *WaitGroupWait) string {
+	s := fmt.Sprintf("goroutine %d waits on a sync.WaitGroup at %s", w.ID, location(w.Call))
+	if len(w.Candidates) == 0 {
+		return s + "; no candidate goroutine found, Done may have been missed"
+	}
+	best := w.Candidates[0].Link
+	var ids []string
+	for _, c := range w.Candidates {
+		if c.Link != best {
+			break
+		}
+		ids = append(ids, fmt.Sprint(c.ID))
+	}
+	who := "goroutines"
+	if len(ids) == 1 {
+		who = "goroutine"
+	}
+	return fmt.Sprintf("%s; likely waiting on %s %s (%s)", s, who, strings.Join(ids, ", "), best)
+}
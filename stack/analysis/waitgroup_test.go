@@ -0,0 +1,82 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindWaitGroupWaits(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [semacquire]:",
+		"sync.runtime_Semacquire(0xc000012348?)",
+		"	/goroot/src/runtime/sema.go:62 +0x25",
+		"sync.(*WaitGroup).Wait(0xc000012340?)",
+		"	/goroot/src/sync/waitgroup.go:116 +0x48",
+		"main.run()",
+		"	/home/user/src/foo/main.go:30 +0x45",
+		"main.main()",
+		"	/home/user/src/foo/main.go:50 +0x17",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.worker(0xc000012340)",
+		"	/home/user/src/foo/worker.go:10 +0x45",
+		"created by main.start in goroutine 5",
+		"	/home/user/src/foo/worker.go:3 +0x45",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.run.func1()",
+		"	/home/user/src/foo/main.go:25 +0x45",
+		"created by main.run in goroutine 1",
+		"	/home/user/src/foo/main.go:24 +0x45",
+		"",
+		"goroutine 8 [IO wait]:",
+		"main.poll()",
+		"	/home/user/src/foo/poll.go:10 +0x45",
+		"created by main.init.0 in goroutine 3",
+		"	/home/user/src/foo/main.go:60 +0x45",
+		"",
+		"goroutine 9 [IO wait]:",
+		"main.serve()",
+		"	/home/user/src/foo/serve.go:10 +0x45",
+		"created by main.listen in goroutine 3",
+		"	/home/user/src/foo/serve.go:3 +0x45",
+		"",
+		"goroutine 10 [IO wait]:",
+		"net.(*conn).Read()",
+		"	/goroot/src/net/net.go:10 +0x45",
+		"created by net/http.(*Server).Serve in goroutine 3",
+		"	/goroot/src/net/http/server.go:3 +0x45",
+		"",
+	}
+	c := parse(t, strings.Join(data, "\n"))
+	w := FindWaitGroupWaits(c)
+	if len(w) != 1 {
+		t.Fatalf("unexpected waits: %#v", w)
+	}
+	if w[0].ID != 1 || w[0].WaitGroup != 0xc000012340 {
+		t.Fatalf("unexpected wait: %#v", w[0])
+	}
+	type cand struct {
+		ID   int
+		Link WaitGroupLink
+	}
+	var got []cand
+	for _, c := range w[0].Candidates {
+		got = append(got, cand{c.ID, c.Link})
+	}
+	want := []cand{{7, LinkChild}, {6, LinkSharedWaitGroup}, {8, LinkSameFile}, {9, LinkSamePackage}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Candidates mismatch (-want +got):\n%s", diff)
+	}
+	e := "goroutine 1 waits on a sync.WaitGroup at main.run at main.go:30; likely waiting on goroutine 7 (created by the waiting goroutine)"
+	if w[0].Explanation != e {
+		t.Fatalf("want %q, got %q", e, w[0].Explanation)
+	}
+}
@@ -27,44 +27,104 @@ const (
 //
 // The buckets are ordered in library provided order of relevancy. You can
 // reorder at your chosing.
+//
+// The goroutines are not modified and the buckets do not share memory with
+// them.
 func Aggregate(goroutines []*Goroutine, similar Similarity) []*Bucket {
-	type count struct {
-		ids   []int
-		first bool
+	a := NewAggregator(similar)
+	for _, routine := range goroutines {
+		a.Add(routine)
 	}
-	b := map[*Signature]*count{}
-	// O(n²). Fix eventually.
+	return a.Buckets()
+}
+
+// AggregateFunc merges the goroutines into buckets with a custom policy:
+// a goroutine is added to the first bucket whose signature matches, e.g. to
+// ignore the innermost frames or to compare only the packages.
+//
+// The signature of a bucket is the one of its first goroutine; unlike with
+// Aggregate, the signatures are not merged.
+func AggregateFunc(goroutines []*Goroutine, match func(a, b *Signature) bool) []*Bucket {
+	a := NewAggregatorFunc(match)
 	for _, routine := range goroutines {
-		found := false
-		for key, c := range b {
-			// When a match is found, this effectively drops the other goroutine ID.
-			if key.similar(&routine.Signature, similar) {
-				found = true
-				c.ids = append(c.ids, routine.ID)
-				c.first = c.first || routine.First
-				if !key.equal(&routine.Signature) {
-					// Almost but not quite equal. There's different pointers passed
-					// around but the same values. Zap out the different values.
-					newKey := key.merge(&routine.Signature)
-					b[newKey] = c
-					delete(b, key)
-				}
-				break
+		a.Add(routine)
+	}
+	return a.Buckets()
+}
+
+// Aggregator merges similar goroutines into buckets incrementally.
+//
+// The goroutines are not retained: only the bucket signatures, and the ID
+// and the index of every goroutine are kept. Used with Opts.OnGoroutine, the
+// memory still grows with the number of goroutines, by two ints each. Use
+// TopK to bound it.
+type Aggregator struct {
+	similar Similarity
+	match   func(a, b *Signature) bool
+	entries []*aggregated
+}
+
+// NewAggregator returns an Aggregator that merges goroutines at the
+// specified similarity level.
+func NewAggregator(similar Similarity) *Aggregator {
+	return &Aggregator{similar: similar}
+}
+
+// NewAggregatorFunc returns an Aggregator that merges goroutines with a
+// custom policy, see AggregateFunc.
+func NewAggregatorFunc(match func(a, b *Signature) bool) *Aggregator {
+	return &Aggregator{match: match}
+}
+
+// Add adds a goroutine to the matching bucket, creating one if needed.
+//
+// The goroutine is not retained.
+func (a *Aggregator) Add(routine *Goroutine) {
+	// O(n²). Fix eventually.
+	for _, e := range a.entries {
+		// When a match is found, this effectively drops the other goroutine ID.
+		if a.match != nil {
+			if !a.match(e.key, &routine.Signature) {
+				continue
 			}
+			e.ids = append(e.ids, routine.ID)
+			e.indices = append(e.indices, routine.Index)
+			e.first = e.first || routine.First
+			return
 		}
-		if !found {
-			// Create a copy of the Signature, since it will be mutated.
-			key := &Signature{}
-			*key = routine.Signature
-			b[key] = &count{ids: []int{routine.ID}, first: routine.First}
+		if e.key.similar(&routine.Signature, a.similar) {
+			e.ids = append(e.ids, routine.ID)
+			e.indices = append(e.indices, routine.Index)
+			e.first = e.first || routine.First
+			if !e.key.equal(&routine.Signature) {
+				// Almost but not quite equal. There's different pointers passed
+				// around but the same values. Zap out the different values.
+				e.key = e.key.merge(&routine.Signature)
+			}
+			return
 		}
 	}
-	out := make(buckets, 0, len(b))
-	for signature, c := range b {
-		sort.Ints(c.ids)
-		out = append(out, &Bucket{Signature: *signature, IDs: c.ids, First: c.first})
+	// Create a deep copy of the Signature, so the goroutine is never mutated
+	// via the buckets.
+	a.entries = append(a.entries, &aggregated{key: routine.Signature.Clone(), ids: []int{routine.ID}, indices: []int{routine.Index}, first: routine.First, raw: routine.Raw})
+}
+
+// Buckets returns the buckets so far.
+//
+// The buckets are ordered like with Aggregate. It is safe to continue calling
+// Add afterward.
+func (a *Aggregator) Buckets() []*Bucket {
+	out := make(buckets, 0, len(a.entries))
+	for _, e := range a.entries {
+		ids := make([]int, len(e.ids))
+		copy(ids, e.ids)
+		sort.Ints(ids)
+		indices := make([]int, len(e.indices))
+		copy(indices, e.indices)
+		sort.Ints(indices)
+		out = append(out, &Bucket{Signature: *e.key.Clone(), IDs: ids, Indices: indices, First: e.first, Raw: e.raw})
 	}
-	sort.Sort(out)
+	sort.Stable(out)
 	return out
 }
 
@@ -74,22 +134,82 @@ type Bucket struct {
 	// Signature is the generalized signature for this bucket.
 	Signature
 	// IDs is the ID of each Goroutine with this Signature.
+	//
+	// When built by TopK, it is only a sample of the IDs.
 	IDs []int
+	// Indices is the Goroutine.Index of each goroutine in this bucket, in
+	// increasing order. It is not in the same order as IDs.
+	//
+	// Not set by TopK.
+	Indices []int
+	// Count is the number of goroutines with this Signature. It is only set by
+	// TopK, use Size().
+	Count int
+	// Overcount is the maximum overestimation of Count when built by TopK.
+	Overcount int
 	// First is true if this Bucket contains the first goroutine, e.g. the one
 	// Signature that likely generated the panic() call, if any.
 	First bool
+	// Raw is the verbatim text of the representative goroutine of this bucket,
+	// the first one to be printed.
+	//
+	// Only set when Opts.KeepRaw was used with ParseDumpOpts.
+	Raw string
+}
+
+// Size returns the number of goroutines in this bucket.
+func (b *Bucket) Size() int {
+	if b.Count != 0 {
+		return b.Count
+	}
+	return len(b.IDs)
+}
+
+// SortDumpOrder sorts the buckets in the order their first goroutine was
+// printed in the dump, instead of by relevancy as returned by Aggregate.
+//
+// Buckets without Indices are last.
+func SortDumpOrder(buckets []*Bucket) {
+	sort.SliceStable(buckets, func(i, j int) bool {
+		a, b := buckets[i].Indices, buckets[j].Indices
+		if len(a) == 0 || len(b) == 0 {
+			return len(b) == 0 && len(a) != 0
+		}
+		return a[0] < b[0]
+	})
 }
 
 // less does reverse sort.
+//
+// It is a total order for buckets with distinct goroutine IDs, so the output
+// is the same on every run for the same input.
 func (b *Bucket) less(r *Bucket) bool {
-	if b.First || r.First {
+	if b.First != r.First {
 		return b.First
 	}
-	return b.Signature.less(&r.Signature)
+	if b.Signature.less(&r.Signature) {
+		return true
+	}
+	if r.Signature.less(&b.Signature) {
+		return false
+	}
+	if len(b.IDs) != 0 && len(r.IDs) != 0 {
+		return b.IDs[0] < r.IDs[0]
+	}
+	return false
 }
 
 //
 
+// aggregated is a bucket being built by Aggregator.
+type aggregated struct {
+	key     *Signature
+	ids     []int
+	indices []int
+	first   bool
+	raw     string
+}
+
 // buckets is a list of Bucket sorted by repeation count.
 type buckets []*Bucket
 
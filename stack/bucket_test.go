@@ -6,6 +6,7 @@ package stack
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -47,8 +48,9 @@ func TestAggregateNotAggressive(t *testing.T) {
 					},
 				},
 			},
-			IDs:   []int{6},
-			First: true,
+			IDs:     []int{6},
+			Indices: []int{0},
+			First:   true,
 		},
 		{
 			Signature: Signature{
@@ -63,7 +65,8 @@ func TestAggregateNotAggressive(t *testing.T) {
 					},
 				},
 			},
-			IDs: []int{7},
+			IDs:     []int{7},
+			Indices: []int{1},
 		},
 	}
 	compareBuckets(t, want, Aggregate(c.Goroutines, ExactLines))
@@ -111,8 +114,9 @@ func TestAggregateExactMatching(t *testing.T) {
 					},
 				},
 			},
-			IDs:   []int{6, 7},
-			First: true,
+			IDs:     []int{6, 7},
+			Indices: []int{0, 1},
+			First:   true,
 		},
 	}
 	compareBuckets(t, want, Aggregate(c.Goroutines, ExactLines))
@@ -157,8 +161,9 @@ func TestAggregateAggressive(t *testing.T) {
 					},
 				},
 			},
-			IDs:   []int{6, 7, 8},
-			First: true,
+			IDs:     []int{6, 7, 8},
+			Indices: []int{0, 1, 2},
+			First:   true,
 		},
 	}
 	compareBuckets(t, want, Aggregate(c.Goroutines, AnyPointer))
@@ -188,3 +193,183 @@ func compareBuckets(t *testing.T, want, got []*Bucket) {
 		t.Fatalf("Bucket mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestAggregateKeepRaw(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: runtime error: index out of range",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.func·001()",
+		"	/gopath/src/github.com/Tchinmai7/panicparse/stack/stack.go:72 +0x49",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.func·001()",
+		"	/gopath/src/github.com/Tchinmai7/panicparse/stack/stack.go:72 +0x49",
+		"",
+	}
+	c, err := ParseDumpOpts(bytes.NewBufferString(strings.Join(data, "\n")), nil, &Opts{KeepRaw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, strings.Join(data[6:9], "\n")+"\n", c.Goroutines[1].Raw)
+	b := Aggregate(c.Goroutines, AnyPointer)
+	if len(b) != 1 {
+		t.Fatalf("expected 1 bucket, got %d", len(b))
+	}
+	compareString(t, strings.Join(data[2:5], "\n")+"\n", b[0].Raw)
+}
+
+func TestAggregatorStreaming(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: oh no",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/gopath/src/foo/main.go:10 +0x1",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.worker(0xc000010000)",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.worker(0xc000020000)",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"exit status 2",
+		"",
+	}
+	in := strings.Join(data, "\n")
+	c, err := ParseDump(strings.NewReader(in), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Aggregate(c.Goroutines, AnyPointer)
+
+	a := NewAggregator(AnyPointer)
+	var ids []int
+	opts := &Opts{OnGoroutine: func(g *Goroutine) {
+		ids = append(ids, g.ID)
+		a.Add(g)
+	}}
+	s, err := ParseDumpOpts(strings.NewReader(in), nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Goroutines) != 0 || s.Crash != CrashPanic {
+		t.Fatalf("unexpected Context: %#v", s)
+	}
+	if diff := cmp.Diff(c.Segments, s.Segments); diff != "" {
+		t.Fatalf("Segments mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 6, 7}, ids); diff != "" {
+		t.Fatalf("IDs mismatch (-want +got):\n%s", diff)
+	}
+	got := a.Buckets()
+	if len(got) != len(want) {
+		t.Fatalf("want %d buckets, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if diff := cmp.Diff(want[i].IDs, got[i].IDs); diff != "" || want[i].First != got[i].First {
+			t.Fatalf("bucket %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestAggregateDeterministic(t *testing.T) {
+	t.Parallel()
+	// Goroutines with signatures that compare equal but are not similar must
+	// be ordered by goroutine ID, independently of the input order.
+	var data []string
+	for _, id := range []int{9, 3, 7, 5} {
+		data = append(data,
+			fmt.Sprintf("goroutine %d [chan receive]:", id),
+			fmt.Sprintf("main.func·001(0x%d, 2)", id),
+			"	/gopath/src/github.com/Tchinmai7/panicparse/stack/stack.go:72 +0x49",
+			"")
+	}
+	var prev []int
+	for i := 0; i < 20; i++ {
+		c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), ioutil.Discard, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, b := range Aggregate(c.Goroutines, ExactLines) {
+			ids = append(ids, b.IDs...)
+		}
+		// Goroutine 9 is printed first.
+		if diff := cmp.Diff([]int{9, 3, 5, 7}, ids); diff != "" {
+			t.Fatalf("IDs mismatch (-want +got):\n%s", diff)
+		}
+		if prev != nil {
+			if diff := cmp.Diff(prev, ids); diff != "" {
+				t.Fatalf("run %d mismatch (-want +got):\n%s", i, diff)
+			}
+		}
+		prev = ids
+	}
+}
+
+func TestSortedPrefixes(t *testing.T) {
+	t.Parallel()
+	got := sortedPrefixes(map[string]string{"/a": "", "/b/c": "", "/b": "", "/a/b/c": ""})
+	if diff := cmp.Diff([]string{"/a/b/c", "/b/c", "/a", "/b"}, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSortDumpOrder(t *testing.T) {
+	t.Parallel()
+	a := &Bucket{IDs: []int{9}, Indices: []int{2}}
+	b := &Bucket{IDs: []int{3, 1}, Indices: []int{0, 3}}
+	c := &Bucket{IDs: []int{5}}
+	d := &Bucket{IDs: []int{4}, Indices: []int{1}}
+	got := []*Bucket{c, a, b, d}
+	SortDumpOrder(got)
+	if diff := cmp.Diff([]*Bucket{b, d, a, c}, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAggregateFunc(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 6 [chan receive]:",
+		"main.a()",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"main.worker()",
+		"	/gopath/src/foo/main.go:30 +0x1",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.b()",
+		"	/gopath/src/foo/main.go:25 +0x1",
+		"main.worker()",
+		"	/gopath/src/foo/main.go:30 +0x1",
+		"",
+		"goroutine 8 [chan receive]:",
+		"main.b()",
+		"	/gopath/src/foo/main.go:25 +0x1",
+		"main.main()",
+		"	/gopath/src/foo/main.go:40 +0x1",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ignore the innermost frame.
+	b := AggregateFunc(c.Goroutines, func(x, y *Signature) bool {
+		return x.Stack.Calls[1].Func.Raw == y.Stack.Calls[1].Func.Raw
+	})
+	var got [][]int
+	for _, bucket := range b {
+		got = append(got, bucket.IDs)
+	}
+	if diff := cmp.Diff([][]int{{6, 7}, {8}}, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if b[0].Stack.Calls[0].Func.Raw != "main.a" {
+		t.Fatalf("unexpected signature: %#v", b[0].Signature)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// StackFromPCs returns the stack of the program counters returned by
+// runtime.Callers, resolved with runtime.CallersFrames.
+//
+// It is meant for the call stacks captured in process, e.g. by an error
+// annotation library, to be formatted and fingerprinted like a parsed stack
+// trace without printing and parsing it. The inlined calls are expanded. The
+// source paths are local, so LocalSrcPath and IsStdlib are set. The argument
+// values are not available.
+func StackFromPCs(pcs []uintptr) *Stack {
+	if len(pcs) == 0 {
+		return &Stack{}
+	}
+	return StackFromFrames(runtime.CallersFrames(pcs))
+}
+
+// StackFromFrames returns the stack of the frames, e.g. as returned by
+// runtime.CallersFrames.
+func StackFromFrames(frames *runtime.Frames) *Stack {
+	goroot, gopaths := localRoots()
+	out := &Stack{}
+	for {
+		f, more := frames.Next()
+		if f.Function != "" || f.File != "" {
+			c := Call{
+				Func:    NewFunc(f.Function),
+				SrcPath: strings.Replace(f.File, "\\", "/", -1),
+				Line:    f.Line,
+			}
+			c.updateLocations(goroot, goroot, gopaths)
+			if c.LocalSrcPath == "" && !c.IsTrimmed() {
+				// The sources are on this host, e.g. a module outside GOPATH.
+				c.LocalSrcPath = c.SrcPath
+			}
+			out.Calls = append(out.Calls, c)
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+// SignatureFromPCs returns a Signature with the stack of the program counters
+// returned by runtime.Callers, see StackFromPCs.
+//
+// Only Stack is set, so it can be passed to Fingerprint or compared with the
+// buckets of a dump.
+func SignatureFromPCs(pcs []uintptr) *Signature {
+	return &Signature{Stack: *StackFromPCs(pcs)}
+}
+
+// SkipOpts selects the innermost frames to remove from a stack captured in
+// process, so it starts at the code of interest instead of the capture
+// plumbing.
+//
+// Frames are counted after the inlined calls were expanded, so the result
+// doesn't depend on the inlining decisions of the compiler. Prefer Packages
+// over Frames when the plumbing can change.
+type SkipOpts struct {
+	// Frames is the number of innermost frames to remove.
+	Frames int
+	// Packages removes the innermost frames, after Frames, as long as they are
+	// in one of these packages. Each item is an import path, e.g.
+	// "github.com/pkg/errors", or an import path followed by "/..." to include
+	// its subpackages.
+	Packages []string
+}
+
+// Capture returns the stack of the calling goroutine, starting at the caller
+// of Capture, with the frames selected by opts removed.
+func Capture(opts SkipOpts) *Stack {
+	pcs := make([]uintptr, 64)
+	for {
+		// Skip runtime.Callers and Capture.
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	s := StackFromPCs(pcs)
+	s.Skip(opts)
+	return s
+}
+
+// Skip removes the innermost frames selected by opts.
+//
+// The frames are removed even when it leaves the stack empty.
+func (s *Stack) Skip(opts SkipOpts) {
+	i := opts.Frames
+	if i > len(s.Calls) {
+		i = len(s.Calls)
+	}
+	if i < 0 {
+		i = 0
+	}
+	for i < len(s.Calls) && inPackages(&s.Calls[i].Func, opts.Packages) {
+		i++
+	}
+	s.Calls = s.Calls[i:]
+}
+
+// Private stuff.
+
+// inPackages returns true if the function is in one of the packages, as
+// specified by SkipOpts.Packages.
+func inPackages(f *Func, pkgs []string) bool {
+	if len(pkgs) == 0 {
+		return false
+	}
+	p := funcPkgPath(f.Raw)
+	for _, pkg := range pkgs {
+		if p == pkg {
+			return true
+		}
+		if prefix := strings.TrimSuffix(pkg, "/..."); prefix != pkg && (p == prefix || strings.HasPrefix(p, prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// funcPkgPath returns the import path of the package of the symbol s, e.g.
+// "sync" for "sync.(*Mutex).Lock".
+func funcPkgPath(s string) string {
+	i := strings.LastIndexByte(s, '/') + 1
+	j := strings.IndexByte(s[i:], '.')
+	if j == -1 {
+		return ""
+	}
+	p, err := url.QueryUnescape(s[:i+j])
+	if err != nil {
+		return s[:i+j]
+	}
+	return p
+}
+
+var (
+	localRootsOnce    sync.Once
+	localRootsGOROOT  string
+	localRootsGOPATHs map[string]string
+)
+
+// localRoots returns the GOROOT and GOPATHs of the host, in the form expected
+// by Call.updateLocations.
+func localRoots() (string, map[string]string) {
+	localRootsOnce.Do(func() {
+		localRootsGOROOT = strings.Replace(runtime.GOROOT(), "\\", "/", -1)
+		localRootsGOPATHs = map[string]string{}
+		for _, p := range getGOPATHs() {
+			localRootsGOPATHs[p] = p
+		}
+	})
+	return localRootsGOROOT, localRootsGOPATHs
+}
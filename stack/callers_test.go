@@ -0,0 +1,119 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStackFromPCs(t *testing.T) {
+	t.Parallel()
+	capture := func() []uintptr {
+		pcs := make([]uintptr, 32)
+		return pcs[:runtime.Callers(1, pcs)]
+	}
+	pcs := capture()
+	_, _, line, _ := runtime.Caller(0)
+	s := StackFromPCs(pcs)
+	if len(s.Calls) < 3 {
+		t.Fatalf("expected at least 3 calls, got %d", len(s.Calls))
+	}
+	c := &s.Calls[0]
+	if got := c.Func.Name(); got != "TestStackFromPCs.func1" {
+		t.Fatalf("unexpected func %q", got)
+	}
+	if !strings.HasSuffix(c.SrcPath, "/callers_test.go") || c.LocalSrcPath == "" || c.IsStdlib {
+		t.Fatalf("unexpected call %#v", c)
+	}
+	if c = &s.Calls[1]; c.Func.Name() != "TestStackFromPCs" || c.Line != line-1 {
+		t.Fatalf("unexpected call %#v", c)
+	}
+	if c = &s.Calls[2]; c.Func.PkgDotName() != "testing.tRunner" || !c.IsStdlib {
+		t.Fatalf("unexpected call %#v", c)
+	}
+	if a, b := SignatureFromPCs(pcs).Fingerprint(), SignatureFromPCs(capture()).Fingerprint(); a == b {
+		t.Fatal("expected different fingerprints for different call sites")
+	}
+	if got := StackFromPCs(nil); len(got.Calls) != 0 {
+		t.Fatalf("unexpected %#v", got)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		f    func(opts SkipOpts) *Stack
+	}{
+		{"direct", Capture},
+		{"inlined", captureInline},
+		{"not inlined", captureNoInline},
+		{"nested", captureNested},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			// Skip all the plumbing of this file, whatever was inlined.
+			s := line.f(SkipOpts{Packages: []string{"github.com/Tchinmai7/panicparse/stack"}})
+			if len(s.Calls) == 0 || s.Calls[0].Func.PkgDotName() != "testing.tRunner" {
+				t.Fatalf("unexpected stack %#v", s.Calls)
+			}
+		})
+	}
+	if s := captureNested(SkipOpts{Frames: 2}); s.Calls[0].Func.Name() != "TestCapture" {
+		t.Fatalf("unexpected stack %#v", s.Calls)
+	}
+	if s := captureNoInline(SkipOpts{Frames: 1}); s.Calls[0].Func.Name() != "TestCapture" {
+		t.Fatalf("unexpected stack %#v", s.Calls)
+	}
+}
+
+func TestStackSkip(t *testing.T) {
+	t.Parallel()
+	calls := []Call{
+		{Func: newFunc("github.com/pkg/errors.Wrap")},
+		{Func: newFunc("github.com/pkg/errors/internal.callers")},
+		{Func: newFunc("gopkg.in/yaml%2ev2.(*decoder).unmarshal")},
+		{Func: newFunc("main.main")},
+	}
+	data := []struct {
+		opts SkipOpts
+		want string
+	}{
+		{SkipOpts{}, "github.com/pkg/errors.Wrap"},
+		{SkipOpts{Frames: 1}, "github.com/pkg/errors/internal.callers"},
+		{SkipOpts{Packages: []string{"github.com/pkg/errors"}}, "github.com/pkg/errors/internal.callers"},
+		{SkipOpts{Packages: []string{"github.com/pkg/errors/..."}}, "gopkg.in/yaml%2ev2.(*decoder).unmarshal"},
+		{SkipOpts{Packages: []string{"github.com/pkg/errors/...", "gopkg.in/yaml.v2"}}, "main.main"},
+		{SkipOpts{Frames: 3, Packages: []string{"github.com/pkg/errors/..."}}, "main.main"},
+		{SkipOpts{Frames: 10}, ""},
+	}
+	for i, line := range data {
+		s := Stack{Calls: append([]Call(nil), calls...)}
+		s.Skip(line.opts)
+		got := ""
+		if len(s.Calls) != 0 {
+			got = s.Calls[0].Func.Raw
+		}
+		if got != line.want {
+			t.Errorf("#%d: want %q, got %q", i, line.want, got)
+		}
+	}
+}
+
+func captureInline(opts SkipOpts) *Stack {
+	return Capture(opts)
+}
+
+//go:noinline
+func captureNoInline(opts SkipOpts) *Stack {
+	return Capture(opts)
+}
+
+func captureNested(opts SkipOpts) *Stack {
+	return captureNoInline(opts)
+}
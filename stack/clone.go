@@ -0,0 +1,112 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// Clone returns a deep copy of the Context.
+//
+// Use it before calling a function documented as modifying its input, like
+// Augment, when the same Context is shared with other consumers.
+func (c *Context) Clone() *Context {
+	out := *c
+	if c.Goroutines != nil {
+		out.Goroutines = make([]*Goroutine, len(c.Goroutines))
+		for i, g := range c.Goroutines {
+			out.Goroutines[i] = g.Clone()
+		}
+	}
+	if c.GOPATHs != nil {
+		out.GOPATHs = make(map[string]string, len(c.GOPATHs))
+		for k, v := range c.GOPATHs {
+			out.GOPATHs[k] = v
+		}
+	}
+	out.Segments = append([]Segment(nil), c.Segments...)
+	if c.Exit != nil {
+		e := *c.Exit
+		out.Exit = &e
+	}
+	if c.CorrelationIDs != nil {
+		out.CorrelationIDs = make(map[string]string, len(c.CorrelationIDs))
+		for k, v := range c.CorrelationIDs {
+			out.CorrelationIDs[k] = v
+		}
+	}
+	if c.Process != nil {
+		p := *c.Process
+		out.Process = &p
+	}
+	if c.Message != nil {
+		out.Message = c.Message.clone()
+	}
+	if c.Panic != nil {
+		p := *c.Panic
+		p.Fields = append([]PanicField(nil), c.Panic.Fields...)
+		out.Panic = &p
+		// Keep Panic pointing into Message.Chain, as set by ParseDump.
+		if c.Message != nil {
+			for i, v := range c.Message.Chain {
+				if v == c.Panic {
+					out.Panic = out.Message.Chain[i]
+				}
+			}
+		}
+	}
+	if c.FormatErrors != nil {
+		out.FormatErrors = make([]*FormatError, len(c.FormatErrors))
+		for i, f := range c.FormatErrors {
+			e := *f
+			out.FormatErrors[i] = &e
+		}
+	}
+	out.localgopaths = append([]string(nil), c.localgopaths...)
+	return &out
+}
+
+// Clone returns a deep copy of the Goroutine.
+func (g *Goroutine) Clone() *Goroutine {
+	out := *g
+	out.Signature = *g.Signature.Clone()
+	out.CallSpans = append([]Span(nil), g.CallSpans...)
+	if g.Thread != nil {
+		t := *g.Thread
+		out.Thread = &t
+	}
+	return &out
+}
+
+// Clone returns a deep copy of the Signature.
+func (s *Signature) Clone() *Signature {
+	out := *s
+	out.CreatedBy = s.CreatedBy.clone()
+	out.Stack = *s.Stack.Clone()
+	return &out
+}
+
+// Clone returns a deep copy of the Stack.
+func (s *Stack) Clone() *Stack {
+	out := &Stack{Elided: s.Elided}
+	if s.Calls != nil {
+		out.Calls = make([]Call, len(s.Calls))
+		for i := range s.Calls {
+			out.Calls[i] = s.Calls[i].clone()
+		}
+	}
+	return out
+}
+
+// Private stuff.
+
+func (c *Call) clone() Call {
+	out := *c
+	out.Args.Values = append([]Arg(nil), c.Args.Values...)
+	out.Args.Processed = append([]string(nil), c.Args.Processed...)
+	out.Args.Labeled = append([]LabeledArg(nil), c.Args.Labeled...)
+	if c.Args.Receiver != nil {
+		r := *c.Args.Receiver
+		out.Args.Receiver = &r
+	}
+	out.SelectCases = append([]string(nil), c.SelectCases...)
+	return out
+}
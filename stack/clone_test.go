@@ -0,0 +1,78 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestContextClone(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main(0x1, 0x2)",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"created by main.init",
+		"	/home/user/src/foo/main.go:3 +0x10",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.GOPATHs = map[string]string{"/home/user": "/local"}
+	c.Exit = &Exit{Code: 2, Line: "exit status 2"}
+	c.Process = &Process{Binary: "foo", PID: 1}
+	c.FormatErrors = []*FormatError{{Line: 1, Text: "bad"}}
+	n := c.Clone()
+	if diff := cmp.Diff(c.Goroutines, n.Goroutines); diff != "" {
+		t.Fatalf("Clone mismatch (-want +got):\n%s", diff)
+	}
+	g := n.Goroutines[0]
+	g.Stack.Calls[0].Args.Values[0].Value = 42
+	g.Stack.Calls[0].Line = 42
+	g.CreatedBy.Func.Raw = "changed"
+	n.GOPATHs["/home/user"] = "changed"
+	n.Segments[0].Text = "changed"
+	n.Exit.Code = 3
+	n.Process.PID = 2
+	n.FormatErrors[0].Line = 2
+	if n.Panic != n.Message.Chain[len(n.Message.Chain)-1] {
+		t.Fatal("Panic is not in Message.Chain")
+	}
+	n.Panic.Message = "changed"
+	o := c.Goroutines[0]
+	if o.Stack.Calls[0].Args.Values[0].Value != 1 || o.Stack.Calls[0].Line != 10 || o.CreatedBy.Func.Raw != "main.init" {
+		t.Fatalf("original modified: %#v", o)
+	}
+	if c.GOPATHs["/home/user"] != "/local" || c.Segments[0].Text != "panic: boom\n\n" {
+		t.Fatal("original modified")
+	}
+	if c.Exit.Code != 2 || c.Process.PID != 1 || c.FormatErrors[0].Line != 1 || c.Panic.Message != "boom" || c.Message.Chain[0].Message != "boom" {
+		t.Fatal("original modified")
+	}
+}
+
+func TestAggregateDoesNotAlias(t *testing.T) {
+	t.Parallel()
+	g := &Goroutine{
+		Signature: Signature{
+			State: "running",
+			Stack: Stack{Calls: []Call{newCall("main.main", Args{Values: []Arg{{Value: 1}}}, "/src/main.go", 10)}},
+		},
+		ID: 1,
+	}
+	b := Aggregate([]*Goroutine{g}, AnyPointer)
+	b[0].Stack.Calls[0].Args.Values[0].Name = "changed"
+	b[0].Stack.Calls[0].Args.Processed = []string{"changed"}
+	if c := g.Stack.Calls[0]; c.Args.Values[0].Name != "" || c.Args.Processed != nil {
+		t.Fatalf("goroutine modified via its bucket: %#v", c)
+	}
+}
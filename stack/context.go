@@ -25,6 +25,10 @@ import (
 // Context is a parsing context.
 //
 // It contains the deduced GOROOT and GOPATH, if guesspaths is true.
+//
+// The formatters and analyses of this module never modify a Context, so one
+// Context can be read by multiple consumers concurrently. Augment is the
+// exception; call Clone first when the Context is shared.
 type Context struct {
 	// Goroutines is the Goroutines found.
 	//
@@ -36,6 +40,9 @@ type Context struct {
 	// It can be empty if no root was determined, for example the traceback
 	// contains only non-stdlib source references.
 	//
+	// When the input contains several dumps, it is the GOROOT of the first
+	// one; each dump is analyzed separately, see Goroutine.Dump.
+	//
 	// Empty is guesspaths was false.
 	GOROOT string
 	// GOPATHs is the GOPATH as detected in the traceback, with the value being
@@ -43,46 +50,231 @@ type Context struct {
 	//
 	// It can be empty if only stdlib code is in the traceback or if no local
 	// sources were matched up. In the general case there is only one entry in
-	// the map.
+	// the map. When the input contains several dumps, it is the union of their
+	// GOPATHs.
 	//
 	// Nil is guesspaths was false.
 	GOPATHs map[string]string
 
+	// Segments is the text found in the input that was not part of a goroutine
+	// stack trace, e.g. the panic message or log lines printed before the
+	// crash.
+	//
+	// They are in the order that they were printed. Use Segment.Before to
+	// interleave them with Goroutines to reconstruct the original output order.
+	Segments []Segment
+
+	// Crash is why the dump was printed, as determined from the text
+	// preceding the goroutines.
+	//
+	// When the input contains several dumps, Crash, Panic, Message and
+	// Process describe the first one and Exit the last one; use
+	// Goroutine.Dump and Goroutine.First to tell the dumps apart, or parse
+	// each dump separately for its own values.
+	Crash CrashKind
+	// Exit is how the process terminated, as determined from the text
+	// following the goroutines. It is nil when not printed.
+	Exit *Exit
+	// Panic is the value passed to the panic that crashed the process, i.e.
+	// the last value of Message.Chain. It is nil when no panic message was
+	// printed.
+	Panic *PanicValue
+	// Message is the message telling why the process died, e.g. the panic
+	// with the panics it recovered from, a fatal error, a signal or a race
+	// report. It is nil when none was printed.
+	Message *PanicMessage
+	// Process is the identity of the process that printed the dump, guessed
+	// from the lines printed before and after the goroutines. It is nil when
+	// nothing was found. With several dumps, it is the one of the first dump.
+	Process *Process
+	// CorrelationIDs is the IDs found with Opts.IDPatterns in the lines printed
+	// before the goroutines, keyed by IDPattern.Name, e.g. the trace ID of the
+	// failing request. It is nil when none was found.
+	CorrelationIDs map[string]string
+	// FormatErrors is the lines inside goroutines that were not in a
+	// supported format, when Opts.Lenient is set.
+	//
+	// A non-empty value likely means the dump comes from a Go version whose
+	// format is not supported yet.
+	FormatErrors []*FormatError
+
+	// Source is a free form label identifying where the dump comes from, e.g.
+	// a host name. It is never set by ParseDump; Merge copies it into
+	// Goroutine.Source.
+	Source string
+
 	// localgoroot is GOROOT with "/" as path separator. No trailing "/".
 	localgoroot string
 	// localgopaths is GOPATH with "/" as path separator. No trailing "/".
 	localgopaths []string
 }
 
+// Segment is a run of consecutive lines of the input that were not detected
+// as part of a goroutine stack trace.
+type Segment struct {
+	// Text is the verbatim text, including the line terminators.
+	Text string
+	// Before is the index in Context.Goroutines of the goroutine that was
+	// printed right after this segment.
+	//
+	// It is len(Context.Goroutines) when the segment is trailing all the
+	// goroutines.
+	Before int
+	// Span is the lines of the input covered by this segment.
+	Span Span
+}
+
+// Span is a range of lines in the original input.
+//
+// Line numbers are 1-based and both bounds are inclusive. The zero value means
+// unknown.
+type Span struct {
+	First int
+	Last  int
+}
+
+// Extract returns the lines covered by the span in input, including the line
+// terminators.
+//
+// input must be the same data that was passed to ParseDump.
+func (s Span) Extract(input []byte) []byte {
+	if s.First <= 0 || s.Last < s.First {
+		return nil
+	}
+	start := 0
+	for l := 1; l < s.First; l++ {
+		i := bytes.IndexByte(input[start:], '\n')
+		if i == -1 {
+			return nil
+		}
+		start += i + 1
+	}
+	end := start
+	for l := s.First; l <= s.Last && end < len(input); l++ {
+		i := bytes.IndexByte(input[end:], '\n')
+		if i == -1 {
+			end = len(input)
+			break
+		}
+		end += i + 1
+	}
+	return input[start:end]
+}
+
+// Opts are the options to use with ParseDumpOpts.
+type Opts struct {
+	// GuessPaths enables guessing of GOROOT and GOPATH. See ParseDump for
+	// details.
+	GuessPaths bool
+	// KeepRaw retains the verbatim text of each goroutine in Goroutine.Raw.
+	//
+	// It increases the memory usage, since the whole input stack trace is
+	// kept.
+	KeepRaw bool
+	// WorkspaceRoot is the local directory the source paths rewritten by the
+	// registered PathRule are relative to, e.g. the root of a Bazel workspace.
+	//
+	// Only used when GuessPaths is true.
+	WorkspaceRoot string
+	// Logger receives debug information about the parsing, e.g. the lines
+	// that were not recognized and the GOROOT and GOPATH that were guessed.
+	//
+	// Nil discards it.
+	Logger Logger
+	// OnGoroutine, when set, is called with each goroutine as soon as it is
+	// fully parsed, instead of accumulating them in Context.Goroutines. Use it
+	// with a TopK to summarize dumps too large to be held in memory.
+	//
+	// The post-processing steps that need all the goroutines are skipped:
+	// GuessPaths is ignored, Call.Args are not named and Goroutine.Thread is
+	// not updated from the scheduler details.
+	OnGoroutine func(g *Goroutine)
+	// Lenient proceeds best-effort past the lines inside a goroutine that are
+	// not in a supported format: the goroutine is marked as Incomplete and
+	// the error is recorded in Context.FormatErrors.
+	//
+	// By default, the parsing stops at the first such line and returns a
+	// *FormatError.
+	Lenient bool
+	// IDPatterns extracts IDs, e.g. the trace ID of the failing request, from
+	// the lines printed before the goroutines into Context.CorrelationIDs.
+	// DefaultIDPatterns covers the common formats. Nil disables it.
+	IDPatterns []IDPattern
+	// IDLines is the number of lines before the goroutines searched with
+	// IDPatterns. Defaults to 20.
+	IDLines int
+}
+
 // ParseDump processes the output from runtime.Stack().
 //
 // Returns nil *Context if no stack trace was detected.
 //
 // It pipes anything not detected as a panic stack trace from r into out. It
 // assumes there is junk before the actual stack trace. The junk is streamed to
-// out.
+// out. out can be nil, the junk is always available in Context.Segments
+// anyway.
 //
 // If guesspaths is false, no guessing of GOROOT and GOPATH is done, and Call
 // entites do not have LocalSrcPath and IsStdlib filled in. If true, be warned
 // that file presence is done, which means some level of disk I/O.
 func ParseDump(r io.Reader, out io.Writer, guesspaths bool) (*Context, error) {
-	goroutines, err := parseDump(r, out)
-	if len(goroutines) == 0 {
+	return ParseDumpOpts(r, out, &Opts{GuessPaths: guesspaths})
+}
+
+// ParseDumpOpts is the same as ParseDump but with options.
+//
+// opts can be nil.
+func ParseDumpOpts(r io.Reader, out io.Writer, opts *Opts) (*Context, error) {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	goroutines, segments, ferrs, n, err := parseDump(r, out, opts)
+	if n == 0 {
 		return nil, err
 	}
+	msg := findPanicMessage(segments)
 	c := &Context{
-		Goroutines:   goroutines,
-		localgoroot:  strings.Replace(runtime.GOROOT(), "\\", "/", -1),
-		localgopaths: getGOPATHs(),
+		Goroutines:     goroutines,
+		Segments:       segments,
+		FormatErrors:   ferrs,
+		Crash:          findCrash(goroutines, segments),
+		Exit:           findExit(segments, n),
+		Panic:          msg.crashValue(),
+		Message:        msg,
+		Process:        findProcess(segments, n),
+		CorrelationIDs: findCorrelationIDs(segments, opts.IDPatterns, opts.IDLines),
+		localgoroot:    strings.Replace(runtime.GOROOT(), "\\", "/", -1),
+		localgopaths:   getGOPATHs(),
+	}
+	if opts.OnGoroutine != nil {
+		return c, err
 	}
-	nameArguments(goroutines)
+	// Each dump comes from a different process, possibly built with a
+	// different Go version and GOROOT, so they are processed separately.
+	dumps := splitDumps(goroutines)
+	for _, d := range dumps {
+		nameArguments(d)
+	}
+	parseSchedDetail(goroutines, segments)
 	// Corresponding local values on the host for Context.
-	if guesspaths {
-		c.findRoots()
-		for _, r := range c.Goroutines {
-			// Note that this is important to call it even if
-			// c.GOROOT == c.localgoroot.
-			r.updateLocations(c.GOROOT, c.localgoroot, c.GOPATHs)
+	if opts.GuessPaths {
+		workspace := strings.TrimSuffix(strings.Replace(opts.WorkspaceRoot, "\\", "/", -1), "/")
+		c.GOPATHs = map[string]string{}
+		for i, d := range dumps {
+			dc := &Context{Goroutines: d, localgoroot: c.localgoroot, localgopaths: c.localgopaths}
+			dc.findRoots(getLogger(opts.Logger))
+			for _, r := range d {
+				// Note that this is important to call it even if
+				// dc.GOROOT == c.localgoroot.
+				r.updateLocations(dc.GOROOT, c.localgoroot, dc.GOPATHs)
+				r.applyPathRules(workspace)
+			}
+			if i == 0 {
+				c.GOROOT = dc.GOROOT
+			}
+			for k, v := range dc.GOPATHs {
+				c.GOPATHs[k] = v
+			}
 		}
 	}
 	return c, err
@@ -91,10 +283,15 @@ func ParseDump(r io.Reader, out io.Writer, guesspaths bool) (*Context, error) {
 // Private stuff.
 
 const (
+	// runtimeStack is the state of the pseudo goroutine for the system stack
+	// printed by runtime.throw, e.g. on "fatal error: unexpected signal".
+	runtimeStack     = "runtime stack"
 	lockedToThread   = "locked to thread"
 	elided           = "...additional frames elided..."
 	raceHeaderFooter = "=================="
 	raceHeader       = "WARNING: DATA RACE"
+	// nonGoFunction is printed for a C frame without a cgo symbolizer.
+	nonGoFunction = "non-Go function"
 )
 
 // These are effectively constants.
@@ -104,8 +301,11 @@ var (
 	// - found next stack barrier at 0x123; expected
 	// - runtime: unexpected return pc for FUNC_NAME called from 0x123
 
-	reRoutineHeader = regexp.MustCompile("^([ \t]*)goroutine (\\d+) \\[([^\\]]+)\\]\\:$")
+	// With GOTRACEBACK=system, Go 1.23+ prints scheduler details in the header,
+	// e.g. "goroutine 1 gp=0xc000002380 m=0 mp=0x5a3f40 [running]:".
+	reRoutineHeader = regexp.MustCompile("^([ \t]*)goroutine (\\d+)((?: [a-z]+=(?:0x[0-9a-f]+|-?\\d+|nil))*) \\[([^\\]]+)\\]\\:$")
 	reMinutes       = regexp.MustCompile("^(\\d+) minutes$")
+	reRuntimeStack  = regexp.MustCompile("^([ \t]*)" + runtimeStack + ":$")
 	reUnavail       = regexp.MustCompile("^(?:\t| +)goroutine running on other thread; stack unavailable")
 	// See gentraceback() in src/runtime/traceback.go for more information.
 	// - Sometimes the source file comes up as "<autogenerated>". It is the
@@ -124,9 +324,13 @@ var (
 	//   These are discarded.
 	// - For cgo, the source file may be "??".
 	reFile = regexp.MustCompile("^(?:\t| +)(\\?\\?|\\<autogenerated\\>|.+\\.(?:c|go|s))\\:(\\d+)(?:| \\+0x[0-9a-f]+)(?:| fp=0x[0-9a-f]+ sp=0x[0-9a-f]+(?:| pc=0x[0-9a-f]+))$")
-	// Sadly, it doesn't note the goroutine number so we could cascade them per
-	// parenthood.
-	reCreated = regexp.MustCompile("^created by (.+)$")
+	// rePC matches the program counter printed under an unsymbolized C frame,
+	// e.g. "\tpc=0x7f3b2c1d4e5f". See printOneCgoTraceback() in package
+	// runtime.
+	rePC = regexp.MustCompile("^(?:\t| +)pc=(0x[0-9a-f]+)$")
+	// Go 1.21+ notes the creator goroutine ID, e.g.
+	// "created by main.main in goroutine 1".
+	reCreated = regexp.MustCompile("^created by (.+?)(?: in goroutine (\\d+))?$")
 	reFunc    = regexp.MustCompile("^(.+)\\((.*)\\)$")
 
 	// See https://github.com/llvm/llvm-project/blob/master/compiler-rt/lib/tsan/rtl/tsan_report.cc
@@ -141,22 +345,108 @@ var (
 	reRaceGoroutine                   = regexp.MustCompile("^Goroutine (\\d+) \\((running|finished)\\) created at:$")
 )
 
-func parseDump(r io.Reader, out io.Writer) ([]*Goroutine, error) {
+// splitDumps returns the goroutines of each dump, see Goroutine.Dump.
+func splitDumps(goroutines []*Goroutine) [][]*Goroutine {
+	var out [][]*Goroutine
+	start := 0
+	for i := 1; i <= len(goroutines); i++ {
+		if i == len(goroutines) || goroutines[i].Dump != goroutines[start].Dump {
+			out = append(out, goroutines[start:i])
+			start = i
+		}
+	}
+	return out
+}
+
+// parseDump returns the goroutines, segments and format errors found, plus
+// the total number of goroutines including the ones sent to
+// opts.OnGoroutine.
+func parseDump(r io.Reader, out io.Writer, opts *Opts) ([]*Goroutine, []Segment, []*FormatError, int, error) {
+	l := getLogger(opts.Logger)
 	scanner := bufio.NewScanner(r)
 	scanner.Split(scanLines)
 	// Do not enable race detection parsing yet, since it cannot be returned in
 	// Context at the moment.
 	s := scanningState{}
+	var segments []Segment
+	// cur accumulates the current segment, to not reallocate the string on each
+	// line.
+	var cur strings.Builder
+	before := -1
+	var span Span
+	flush := func() {
+		if cur.Len() != 0 {
+			segments = append(segments, Segment{Text: cur.String(), Before: before, Span: span})
+			cur.Reset()
+		}
+	}
+	// raw accumulates the text of the last goroutine when opts.KeepRaw is set.
+	var raw strings.Builder
+	var last *Goroutine
+	flushRaw := func() {
+		if last != nil {
+			last.Raw = raw.String()
+			raw.Reset()
+		}
+	}
+	var ferrs []*FormatError
 	for scanner.Scan() {
-		line, err := s.scan(scanner.Text())
+		text := scanner.Text()
+		line, err := s.scan(text)
+		if err != nil && opts.Lenient {
+			// Proceed best-effort: close the current goroutine and rescan the
+			// line outside of a goroutine. In the normal state, scan only
+			// returns errors for the race detector output, which is disabled.
+			ferr := &FormatError{Line: s.lineno, Text: strings.TrimRight(text, "\r\n"), Err: err, lenient: true}
+			l.Printf("%v", ferr)
+			ferrs = append(ferrs, ferr)
+			s.abort()
+			s.lineno--
+			line, err = s.scan(text)
+		}
+		if opts.KeepRaw && len(s.goroutines) != 0 {
+			if g := s.goroutines[len(s.goroutines)-1]; g.Span.Last == s.lineno {
+				if g != last {
+					flushRaw()
+					last = g
+				}
+				_, _ = raw.WriteString(text)
+			}
+		}
 		if line != "" {
-			_, _ = io.WriteString(out, line)
+			l.Printf("line %d: not part of a stack trace: %q", s.lineno, strings.TrimRight(line, "\r\n"))
+			if out != nil {
+				_, _ = io.WriteString(out, line)
+			}
+			// A crash header after goroutines starts the dump of another
+			// process, e.g. in aggregated logs.
+			if s.count() != 0 && reCrash.MatchString(line) {
+				s.newDump = true
+			}
+			// Start a new segment if a goroutine was found since the last line.
+			if s.count() != before {
+				flush()
+				before = s.count()
+				span.First = s.lineno
+			}
+			span.Last = s.lineno
+			_, _ = cur.WriteString(line)
 		}
 		if err != nil {
-			return s.goroutines, err
+			err = &FormatError{Line: s.lineno, Text: strings.TrimRight(text, "\r\n"), Err: err}
+			l.Printf("%v", err)
+			flush()
+			flushRaw()
+			s.emit(opts.OnGoroutine, 0)
+			return s.goroutines, segments, ferrs, s.count(), err
 		}
+		// All but the last goroutine are complete.
+		s.emit(opts.OnGoroutine, 1)
 	}
-	return s.goroutines, scanner.Err()
+	flush()
+	flushRaw()
+	s.emit(opts.OnGoroutine, 0)
+	return s.goroutines, segments, ferrs, s.count(), scanner.Err()
 }
 
 // scanLines is similar to bufio.ScanLines except that it:
@@ -282,6 +572,105 @@ type scanningState struct {
 	state  state
 	prefix string
 	races  []raceOp
+	// lineno is the 1-based line number of the line being scanned.
+	lineno int
+	// emitted is the number of goroutines sent to Opts.OnGoroutine and
+	// removed from goroutines.
+	emitted int
+	// strs is the interned function names and source paths.
+	strs map[string]string
+	// funcs is the interned functions, so the names derived from the same
+	// function are computed once and shared.
+	funcs map[string]Func
+	// dump is the index of the current dump and newDump is set when the next
+	// goroutine starts a new one.
+	dump    int
+	newDump bool
+	// hasFirst is set once a goroutine of the current dump was marked as
+	// Goroutine.First.
+	hasFirst bool
+}
+
+// abort marks the current goroutine as incomplete and goes back to the
+// normal state, after a line failed to be parsed.
+func (s *scanningState) abort() {
+	if len(s.goroutines) != 0 && s.state != normal && s.state != betweenRoutine {
+		s.goroutines[len(s.goroutines)-1].Incomplete = true
+	}
+	s.state = normal
+	s.prefix = ""
+}
+
+// intern returns a copy of v shared with all the previous equal values.
+//
+// This saves a lot of memory since stack dumps are highly repetitive, and
+// releases the line v was sliced from.
+func (s *scanningState) intern(v string) string {
+	if i, ok := s.strs[v]; ok {
+		return i
+	}
+	if s.strs == nil {
+		s.strs = map[string]string{}
+	}
+	i := string([]byte(v))
+	s.strs[i] = i
+	return i
+}
+
+// newFunc returns the Func for raw, shared with all the previous equal
+// values.
+func (s *scanningState) newFunc(raw string) Func {
+	if f, ok := s.funcs[raw]; ok {
+		return f
+	}
+	if s.funcs == nil {
+		s.funcs = map[string]Func{}
+	}
+	f := NewFunc(s.intern(raw))
+	s.funcs[f.Raw] = f
+	return f
+}
+
+// dumpIndex returns the index of the dump of a goroutine starting on the
+// current line.
+func (s *scanningState) dumpIndex() int {
+	if s.newDump {
+		s.dump++
+		s.newDump = false
+		s.hasFirst = false
+	}
+	return s.dump
+}
+
+// first returns true for the first goroutine printed in the current dump,
+// which is normally the one that crashed.
+//
+// It must be called after dumpIndex. It must not be called for the "runtime
+// stack" pseudo goroutine, which precedes the goroutine that threw.
+func (s *scanningState) first() bool {
+	if s.hasFirst {
+		return false
+	}
+	s.hasFirst = true
+	return true
+}
+
+// count returns the number of goroutines found so far.
+func (s *scanningState) count() int {
+	return s.emitted + len(s.goroutines)
+}
+
+// emit sends all the goroutines but the last keep ones to f, if set.
+func (s *scanningState) emit(f func(g *Goroutine), keep int) {
+	if f == nil || len(s.goroutines) <= keep {
+		return
+	}
+	n := len(s.goroutines) - keep
+	for _, g := range s.goroutines[:n] {
+		f(g)
+	}
+	s.emitted += n
+	s.goroutines = append(s.goroutines[:0], s.goroutines[n:]...)
 }
 
 // scan scans one line, updates goroutines and move to the next state.
@@ -291,6 +680,7 @@ func (s *scanningState) scan(line string) (string, error) {
 		log.Printf("scan(%q) -> %s", line, s.state)
 	}()
 	//*/
+	s.lineno++
 	var cur *Goroutine
 	if len(s.goroutines) != 0 {
 		cur = s.goroutines[len(s.goroutines)-1]
@@ -332,7 +722,7 @@ func (s *scanningState) scan(line string) (string, error) {
 			if id, err := strconv.Atoi(match[2]); err == nil {
 				// See runtime/traceback.go.
 				// "<state>, \d+ minutes, locked to thread"
-				items := strings.Split(match[3], ", ")
+				items := strings.Split(match[4], ", ")
 				sleep := 0
 				locked := false
 				for i := 1; i < len(items); i++ {
@@ -345,6 +735,7 @@ func (s *scanningState) scan(line string) (string, error) {
 						sleep, _ = strconv.Atoi(match2[1])
 					}
 				}
+				dump := s.dumpIndex()
 				g := &Goroutine{
 					Signature: Signature{
 						State:    items[0],
@@ -353,7 +744,13 @@ func (s *scanningState) scan(line string) (string, error) {
 						Locked:   locked,
 					},
 					ID:    id,
-					First: len(s.goroutines) == 0,
+					First: s.first(),
+					Index: s.count(),
+					Dump:  dump,
+					Span:  Span{First: s.lineno, Last: s.lineno},
+				}
+				if match[3] != "" {
+					g.Thread = parseThreadHeader(match[3])
 				}
 				// Increase performance by always allocating 4 goroutines minimally.
 				if s.goroutines == nil {
@@ -365,6 +762,23 @@ func (s *scanningState) scan(line string) (string, error) {
 				return "", nil
 			}
 		}
+		// runtime.throw prints the stack of the thread that threw without a
+		// goroutine header, e.g. "runtime: unexpected return pc" or when the
+		// crash happened on the system stack.
+		if match := reRuntimeStack.FindStringSubmatch(trimmed); match != nil {
+			if s.goroutines == nil {
+				s.goroutines = make([]*Goroutine, 0, 4)
+			}
+			s.goroutines = append(s.goroutines, &Goroutine{
+				Signature: Signature{State: runtimeStack},
+				Index:     s.count(),
+				Dump:      s.dumpIndex(),
+				Span:      Span{First: s.lineno, Last: s.lineno},
+			})
+			s.state = gotRoutineHeader
+			s.prefix = match[1]
+			return "", nil
+		}
 		// Switch to race detection mode.
 		if s.raceDetectionEnabled && trimmed == raceHeaderFooter {
 			s.state = gotRaceHeader1
@@ -380,13 +794,17 @@ func (s *scanningState) scan(line string) (string, error) {
 		if reUnavail.MatchString(trimmed) {
 			// Generate a fake stack entry.
 			cur.Stack.Calls = []Call{{SrcPath: "<unavailable>"}}
+			cur.CallSpans = []Span{{First: s.lineno, Last: s.lineno}}
+			cur.Span.Last = s.lineno
 			// Next line is expected to be an empty line.
 			s.state = gotUnavail
 			return "", nil
 		}
 		c := Call{}
-		if found, err := parseFunc(&c, trimmed); found {
+		if found, err := s.parseFunc(&c, trimmed); found {
 			cur.Stack.Calls = append(cur.Stack.Calls, c)
+			cur.CallSpans = append(cur.CallSpans, Span{First: s.lineno, Last: s.lineno})
+			cur.Span.Last = s.lineno
 			s.state = gotFunc
 			return "", err
 		}
@@ -394,41 +812,58 @@ func (s *scanningState) scan(line string) (string, error) {
 
 	case gotFunc:
 		// cur.Stack.Calls is guaranteed to have at least one item.
-		if found, err := parseFile(&cur.Stack.Calls[len(cur.Stack.Calls)-1], trimmed); err != nil {
+		if found, err := s.parseFile(&cur.Stack.Calls[len(cur.Stack.Calls)-1], trimmed); err != nil {
 			return "", err
 		} else if !found {
 			return "", fmt.Errorf("expected a file after a function, got: %q", strings.TrimSpace(trimmed))
 		}
+		cur.CallSpans[len(cur.CallSpans)-1].Last = s.lineno
+		cur.Span.Last = s.lineno
 		s.state = gotFileFunc
 		return "", nil
 
 	case gotCreated:
-		if found, err := parseFile(&cur.CreatedBy, trimmed); err != nil {
+		if found, err := s.parseFile(&cur.CreatedBy, trimmed); err != nil {
 			return "", err
 		} else if !found {
 			return "", fmt.Errorf("expected a file after a created line, got: %q", trimmed)
 		}
+		cur.CreatedBySpan.Last = s.lineno
+		cur.Span.Last = s.lineno
 		s.state = gotFileCreated
 		return "", nil
 
 	case gotFileFunc:
 		if match := reCreated.FindStringSubmatch(trimmed); match != nil {
-			cur.CreatedBy.Func.Raw = match[1]
+			cur.CreatedBy.Func = s.newFunc(match[1])
+			cur.CreatedByID, _ = strconv.Atoi(match[2])
+			cur.CreatedBySpan = Span{First: s.lineno, Last: s.lineno}
+			cur.Span.Last = s.lineno
 			s.state = gotCreated
 			return "", nil
 		}
 		if elided == trimmed {
 			cur.Stack.Elided = true
+			cur.Span.Last = s.lineno
 			// TODO(Tchinmai7): New state.
 			return "", nil
 		}
+		if reExitStatus.MatchString(trimmed) || reExitSignal.MatchString(trimmed) {
+			// The process exit printed right after the last goroutine, e.g.
+			// "signal: segmentation fault (core dumped)" looks like a call.
+			s.state = normal
+			s.prefix = ""
+			return line, nil
+		}
 		c := Call{}
-		if found, err := parseFunc(&c, trimmed); found {
+		if found, err := s.parseFunc(&c, trimmed); found {
 			// Increase performance by always allocating 4 calls minimally.
 			if cur.Stack.Calls == nil {
 				cur.Stack.Calls = make([]Call, 0, 4)
 			}
 			cur.Stack.Calls = append(cur.Stack.Calls, c)
+			cur.CallSpans = append(cur.CallSpans, Span{First: s.lineno, Last: s.lineno})
+			cur.Span.Last = s.lineno
 			s.state = gotFunc
 			return "", err
 		}
@@ -456,7 +891,10 @@ func (s *scanningState) scan(line string) (string, error) {
 			return "", nil
 		}
 		if match := reCreated.FindStringSubmatch(trimmed); match != nil {
-			cur.CreatedBy.Func.Raw = match[1]
+			cur.CreatedBy.Func = s.newFunc(match[1])
+			cur.CreatedByID, _ = strconv.Atoi(match[2])
+			cur.CreatedBySpan = Span{First: s.lineno, Last: s.lineno}
+			cur.Span.Last = s.lineno
 			s.state = gotCreated
 			return "", nil
 		}
@@ -495,7 +933,7 @@ func (s *scanningState) scan(line string) (string, error) {
 
 	case gotRaceOperationHeader:
 		c := Call{}
-		if found, err := parseFunc(&c, trimmed); found {
+		if found, err := s.parseFunc(&c, trimmed); found {
 			// TODO(Tchinmai7): Figure out.
 			//cur.Stack.Calls = append(cur.Stack.Calls, *call)
 			s.state = gotRaceOperationFunc
@@ -505,7 +943,7 @@ func (s *scanningState) scan(line string) (string, error) {
 
 	case gotRaceGoroutineHeader:
 		c := Call{}
-		if found, err := parseFunc(&c, strings.TrimLeft(trimmed, "\t ")); found {
+		if found, err := s.parseFunc(&c, strings.TrimLeft(trimmed, "\t ")); found {
 			// Increase performance by always allocating 4 calls minimally.
 			if cur.Stack.Calls == nil {
 				cur.Stack.Calls = make([]Call, 0, 4)
@@ -521,7 +959,7 @@ func (s *scanningState) scan(line string) (string, error) {
 		// TODO(Tchinmai7): Bug, should be cur.Stack.Calls[len(cur.Stack.Calls)-1] but
 		// s.goroutine isn't initialized properly.
 		c := Call{}
-		if found, err := parseFile(&c, trimmed); err != nil {
+		if found, err := s.parseFile(&c, trimmed); err != nil {
 			return "", err
 		} else if !found {
 			return "", fmt.Errorf("expected a file after a race function, got: %q", trimmed)
@@ -531,7 +969,7 @@ func (s *scanningState) scan(line string) (string, error) {
 
 	case gotRaceGoroutineFunc:
 		// cur.Stack.Calls is guaranteed to have at least one item.
-		if found, err := parseFile(&cur.Stack.Calls[len(cur.Stack.Calls)-1], trimmed); err != nil {
+		if found, err := s.parseFile(&cur.Stack.Calls[len(cur.Stack.Calls)-1], trimmed); err != nil {
 			return "", err
 		} else if !found {
 			return "", fmt.Errorf("expected a file after a race function, got: %q", trimmed)
@@ -557,7 +995,7 @@ func (s *scanningState) scan(line string) (string, error) {
 			return "", nil
 		}
 		c := Call{}
-		if found, err := parseFunc(&c, strings.TrimLeft(trimmed, "\t ")); found {
+		if found, err := s.parseFunc(&c, strings.TrimLeft(trimmed, "\t ")); found {
 			// TODO(Tchinmai7): Process match.
 			s.state = gotRaceGoroutineFunc
 			return "", err
@@ -592,7 +1030,8 @@ func (s *scanningState) scan(line string) (string, error) {
 			g := &Goroutine{
 				Signature: Signature{State: match[2]},
 				ID:        id,
-				First:     len(s.goroutines) == 0,
+				First:     s.first(),
+				Index:     s.count(),
 			}
 			// Increase performance by always allocating 4 goroutines minimally.
 			if s.goroutines == nil {
@@ -610,10 +1049,18 @@ func (s *scanningState) scan(line string) (string, error) {
 }
 
 // parseFunc only return an error if also returning a Call.
-func parseFunc(c *Call, line string) (bool, error) {
+func (s *scanningState) parseFunc(c *Call, line string) (bool, error) {
+	if line == nonGoFunction {
+		c.Func = s.newFunc(nonGoFunction)
+		c.IsCgo = true
+		return true, nil
+	}
 	if match := reFunc.FindStringSubmatch(line); match != nil {
-		c.Func.Raw = match[1]
+		c.Func = s.newFunc(match[1])
 		for _, a := range strings.Split(match[2], ", ") {
+			// Go 1.17+ prints the words of aggregates in braces, e.g.
+			// "{0xc000012345, 0x3}". They are flattened.
+			a = strings.TrimRight(strings.TrimLeft(a, "{"), "}")
 			if a == "..." {
 				c.Args.Elided = true
 				continue
@@ -622,7 +1069,12 @@ func parseFunc(c *Call, line string) (bool, error) {
 				// Remaining values were dropped.
 				break
 			}
-			v, err := strconv.ParseUint(a, 0, 64)
+			if a == "_" {
+				// Go 1.17+ prints "_" for dead values.
+				a = "0"
+			}
+			// Go 1.18+ suffixes "?" to values that may be inaccurate.
+			v, err := strconv.ParseUint(strings.TrimSuffix(a, "?"), 0, 64)
 			if err != nil {
 				return true, fmt.Errorf("failed to parse int on line: %q", strings.TrimSpace(line))
 			}
@@ -638,13 +1090,24 @@ func parseFunc(c *Call, line string) (bool, error) {
 }
 
 // parseFile only return an error if also processing a Call.
-func parseFile(c *Call, line string) (bool, error) {
+func (s *scanningState) parseFile(c *Call, line string) (bool, error) {
+	if c.IsCgo {
+		if match := rePC.FindStringSubmatch(line); match != nil {
+			pc, err := strconv.ParseUint(match[1], 0, 64)
+			if err != nil {
+				return true, fmt.Errorf("failed to parse pc on line: %q", strings.TrimSpace(line))
+			}
+			c.SrcPath = "??"
+			c.PC = pc
+			return true, nil
+		}
+	}
 	if match := reFile.FindStringSubmatch(line); match != nil {
 		num, err := strconv.Atoi(match[2])
 		if err != nil {
 			return true, fmt.Errorf("failed to parse int on line: %q", strings.TrimSpace(line))
 		}
-		c.SrcPath = match[1]
+		c.SrcPath = s.intern(match[1])
 		c.Line = num
 		return true, nil
 	}
@@ -708,6 +1171,12 @@ func isFile(p string) bool {
 	return err == nil && !i.IsDir()
 }
 
+// isDir returns true if the path is a valid directory.
+func isDir(p string) bool {
+	i, err := os.Stat(p)
+	return err == nil && i.IsDir()
+}
+
 // rootedIn returns a root if the file split in parts is rooted in root.
 //
 // Uses "/" as path separator.
@@ -725,13 +1194,13 @@ func rootedIn(root string, parts []string) string {
 // findRoots sets member GOROOT and GOPATHs.
 //
 // This causes disk I/O as it checks for file presence.
-func (c *Context) findRoots() {
+func (c *Context) findRoots(logger Logger) {
 	c.GOPATHs = map[string]string{}
-	//log.Printf("localgopaths: %v", c.localgopaths)
+	logger.Printf("localgopaths: %v", c.localgopaths)
 	for _, f := range getFiles(c.Goroutines) {
 		// TODO(Tchinmai7): Could a stack dump have mixed cases? I think it's
 		// possible, need to confirm and handle.
-		//log.Printf("  Analyzing %s", f)
+		logger.Printf("  Analyzing %s", f)
 		if c.GOROOT != "" && strings.HasPrefix(f, c.GOROOT+"/src/") {
 			continue
 		}
@@ -742,20 +1211,20 @@ func (c *Context) findRoots() {
 		if c.GOROOT == "" {
 			if r := rootedIn(c.localgoroot+"/src", parts); r != "" {
 				c.GOROOT = r[:len(r)-4]
-				//log.Printf("Found GOROOT=%s", c.GOROOT)
+				logger.Printf("Found GOROOT=%s", c.GOROOT)
 				continue
 			}
 		}
 		found := false
 		for _, l := range c.localgopaths {
 			if r := rootedIn(l+"/src", parts); r != "" {
-				//log.Printf("Found GOPATH=%s", r[:len(r)-4])
+				logger.Printf("Found GOPATH=%s", r[:len(r)-4])
 				c.GOPATHs[r[:len(r)-4]] = l
 				found = true
 				break
 			}
 			if r := rootedIn(l+"/pkg/mod", parts); r != "" {
-				//log.Printf("Found GOPATH=%s", r[:len(r)-8])
+				logger.Printf("Found GOPATH=%s", r[:len(r)-8])
 				c.GOPATHs[r[:len(r)-8]] = l
 				found = true
 				break
@@ -763,7 +1232,7 @@ func (c *Context) findRoots() {
 		}
 		if !found {
 			// If the source is not found, just too bad.
-			//log.Printf("Failed to find locally: %s", f)
+			logger.Printf("Failed to find locally: %s", f)
 		}
 	}
 }
@@ -0,0 +1,300 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDumpSegments(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"junk",
+		"panic: oh no",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/gopath/src/foo/main.go:10 +0x1",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.f()",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"exit status 2",
+		"",
+	}
+	// out is optional.
+	c, err := ParseDump(bytes.NewBufferString(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Segment{
+		{Text: "junk\npanic: oh no\n\n", Before: 0, Span: Span{First: 1, Last: 3}},
+		{Text: "exit status 2\n", Before: 2, Span: Span{First: 11, Last: 11}},
+	}
+	if diff := cmp.Diff(want, c.Segments); diff != "" {
+		t.Fatalf("Segment mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDumpSpans(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: oh no",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/gopath/src/foo/main.go:10 +0x1",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.f()",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"main.g()",
+		"	/gopath/src/foo/main.go:30 +0x1",
+		"created by main.main",
+		"	/gopath/src/foo/main.go:11 +0x1",
+		"",
+	}
+	in := []byte(strings.Join(data, "\n"))
+	c, err := ParseDump(bytes.NewReader(in), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 2 {
+		t.Fatalf("expected 2 goroutines, got %d", len(c.Goroutines))
+	}
+	g := c.Goroutines[1]
+	if diff := cmp.Diff(Span{First: 7, Last: 13}, g.Span); diff != "" {
+		t.Fatalf("Span mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Span{{First: 8, Last: 9}, {First: 10, Last: 11}}, g.CallSpans); diff != "" {
+		t.Fatalf("CallSpans mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(Span{First: 12, Last: 13}, g.CreatedBySpan); diff != "" {
+		t.Fatalf("CreatedBySpan mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(Span{First: 1, Last: 2}, c.Segments[0].Span); diff != "" {
+		t.Fatalf("Segment Span mismatch (-want +got):\n%s", diff)
+	}
+	compareString(t, strings.Join(data[2:5], "\n")+"\n", string(c.Goroutines[0].Span.Extract(in)))
+	compareString(t, "main.g()\n\t/gopath/src/foo/main.go:30 +0x1\n", string(g.CallSpans[1].Extract(in)))
+	compareString(t, "", string(Span{}.Extract(in)))
+}
+
+func TestParseDumpIndex(t *testing.T) {
+	t.Parallel()
+	data := "goroutine 9 [running]:\nmain.main()\n\t/gopath/src/foo/main.go:10 +0x1\n\n" +
+		"goroutine 3 [chan receive]:\nmain.f()\n\t/gopath/src/foo/main.go:20 +0x1\n\n" +
+		"goroutine 5 [chan receive]:\nmain.f()\n\t/gopath/src/foo/main.go:20 +0x1\n"
+	var got []int
+	opts := &Opts{OnGoroutine: func(g *Goroutine) { got = append(got, g.Index) }}
+	if _, err := ParseDumpOpts(strings.NewReader(data), nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int{0, 1, 2}, got); diff != "" {
+		t.Fatalf("Index mismatch (-want +got):\n%s", diff)
+	}
+	c, err := ParseDump(strings.NewReader(data), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Aggregate(c.Goroutines, AnyPointer)
+	if diff := cmp.Diff([]int{1, 2}, b[1].Indices); diff != "" {
+		t.Fatalf("Indices mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDumpMixedDumps(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: first",
+		"",
+		"goroutine 1 [running]:",
+		"fmt.Sprintf(0xc000010000)",
+		"	/remote1/go/src/fmt/print.go:219 +0x1",
+		"main.main()",
+		"	/home/a/src/foo/main.go:10 +0x1",
+		"exit status 2",
+		"fatal error: second",
+		"",
+		"goroutine 1 [running]:",
+		"fmt.Sprintf(0xc000010000)",
+		"	/remote2/go/src/fmt/print.go:219 +0x1",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.f(0xc000010000)",
+		"	/home/b/src/foo/main.go:20 +0x1",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 3 {
+		t.Fatalf("expected 3 goroutines, got %d", len(c.Goroutines))
+	}
+	var dumps []int
+	for _, g := range c.Goroutines {
+		dumps = append(dumps, g.Dump)
+	}
+	if diff := cmp.Diff([]int{0, 1, 1}, dumps); diff != "" {
+		t.Fatalf("Dump mismatch (-want +got):\n%s", diff)
+	}
+	// Each dump has its own crashing goroutine.
+	var first []bool
+	for _, g := range c.Goroutines {
+		first = append(first, g.First)
+	}
+	if diff := cmp.Diff([]bool{true, true, false}, first); diff != "" {
+		t.Fatalf("First mismatch (-want +got):\n%s", diff)
+	}
+	if c.Culprit() != c.Goroutines[0] || c.Crash != CrashPanic {
+		t.Fatalf("unexpected culprit %#v, crash %s", c.Culprit(), c.Crash)
+	}
+	if c.GOROOT != "/remote1/go" {
+		t.Fatalf("unexpected GOROOT %q", c.GOROOT)
+	}
+	// Both dumps are resolved with their own GOROOT.
+	for i := 0; i < 2; i++ {
+		if call := &c.Goroutines[i].Stack.Calls[0]; !call.IsStdlib || call.RelSrcPath != "fmt/print.go" {
+			t.Fatalf("goroutine %d: unexpected call %#v", i, call)
+		}
+	}
+	// The pointers are only named within a dump.
+	if diff := cmp.Diff("", c.Goroutines[0].Stack.Calls[0].Args.Values[0].Name); diff != "" {
+		t.Fatalf("Name mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("#1", c.Goroutines[2].Stack.Calls[0].Args.Values[0].Name); diff != "" {
+		t.Fatalf("Name mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDumpRuntimeThrow(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"runtime: unexpected return pc for main.f called from 0x0",
+		"stack: frame={sp:0xc000059f58, fp:0xc000059f78} stack=[0xc000058000,0xc00005a000)",
+		"fatal error: unknown caller pc",
+		"",
+		"runtime stack:",
+		"runtime.throw({0x4b9b4e?, 0x0?})",
+		"	/usr/local/go/src/runtime/panic.go:1047 +0x5d fp=0xc000059e58 sp=0xc000059e28 pc=0x432a9d",
+		"runtime.gentraceback(0x0?, _, {...}, 0x0)",
+		"	/usr/local/go/src/runtime/traceback.go:258 +0x1cf7",
+		"",
+		"goroutine 1 [running]:",
+		"main.init.0()",
+		"	/gopath/src/foo/main.go:10 +0x1",
+		"runtime.doInit1(0x5a3f40)",
+		"	/usr/local/go/src/runtime/proc.go:7176 +0xea",
+		"runtime.doInit(...)",
+		"	/usr/local/go/src/runtime/proc.go:7143",
+		"",
+	}
+	c, err := ParseDump(bytes.NewBufferString(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 2 {
+		t.Fatalf("expected 2 goroutines, got %d", len(c.Goroutines))
+	}
+	g := c.Goroutines[0]
+	if g.ID != 0 || g.First || g.State != "runtime stack" || g.WaitReason() != WaitRunning {
+		t.Fatalf("unexpected runtime stack: %#v", g)
+	}
+	want := Args{Values: []Arg{{Value: 0x4b9b4e}, {}}}
+	if diff := cmp.Diff(want, g.Stack.Calls[0].Args); diff != "" {
+		t.Fatalf("Args mismatch (-want +got):\n%s", diff)
+	}
+	want = Args{Values: []Arg{{}, {}, {}}, Elided: true}
+	if diff := cmp.Diff(want, g.Stack.Calls[1].Args); diff != "" {
+		t.Fatalf("Args mismatch (-want +got):\n%s", diff)
+	}
+	if g = c.Goroutines[1]; g.ID != 1 || !g.First || len(g.Stack.Calls) != 3 {
+		t.Fatalf("unexpected goroutine: %#v", g)
+	}
+	if c.Culprit() != g {
+		t.Fatalf("unexpected culprit: %#v", c.Culprit())
+	}
+}
+
+func BenchmarkParseDumpLarge(b *testing.B) {
+	b.ReportAllocs()
+	var buf bytes.Buffer
+	for i := 1; i <= 10000; i++ {
+		fmt.Fprintf(&buf, "goroutine %d [chan receive]:\n", i)
+		fmt.Fprintf(&buf, "github.com/foo/bar/internal/server.(*Server).handle(0xc000010000, 0x%x)\n", i)
+		buf.WriteString("\t/home/user/go/src/github.com/foo/bar/internal/server/server.go:123 +0x45\n")
+		buf.WriteString("github.com/foo/bar/internal/server.(*Server).serve(0xc000010000)\n")
+		buf.WriteString("\t/home/user/go/src/github.com/foo/bar/internal/server/server.go:100 +0x45\n")
+		buf.WriteString("created by github.com/foo/bar/internal/server.(*Server).Start\n")
+		buf.WriteString("\t/home/user/go/src/github.com/foo/bar/internal/server/server.go:50 +0x45\n\n")
+	}
+	data := buf.Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c, err := ParseDump(bytes.NewReader(data), nil, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(c.Goroutines) != 10000 {
+			b.Fatal("unexpected goroutines")
+		}
+	}
+}
+
+func TestParseDumpFormatError(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"main.next()",
+		"	{unknown frame syntax}",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.worker()",
+		"	/home/user/src/foo/main.go:20 +0x12",
+		"",
+	}
+	input := strings.Join(data, "\n")
+	c, err := ParseDump(strings.NewReader(input), nil, false)
+	var ferr *FormatError
+	if !errors.As(err, &ferr) || ferr.Line != 7 {
+		t.Fatalf("want a *FormatError, got %v", err)
+	}
+	want := "line 7: dump appears to be from a Go version whose format is unsupported: expected a file after a function, got: \"{unknown frame syntax}\""
+	if err.Error() != want {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Goroutines) != 1 {
+		t.Fatalf("unexpected goroutines: %#v", c.Goroutines)
+	}
+
+	c, err = ParseDumpOpts(strings.NewReader(input), nil, &Opts{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 2 || !c.Goroutines[0].Incomplete || c.Goroutines[1].Incomplete {
+		t.Fatalf("unexpected goroutines: %#v", c.Goroutines)
+	}
+	if len(c.FormatErrors) != 1 || c.FormatErrors[0].Line != 7 || c.FormatErrors[0].Text != "	{unknown frame syntax}" {
+		t.Fatalf("unexpected format errors: %#v", c.FormatErrors)
+	}
+	want = "line 7: dump appears to be from a Go version whose format is unsupported; frames parsed best-effort: expected a file after a function, got: \"{unknown frame syntax}\""
+	if got := c.FormatErrors[0].Error(); got != want {
+		t.Fatalf("unexpected error: %v", got)
+	}
+	if c.Segments[1].Text != "	{unknown frame syntax}\n\n" {
+		t.Fatalf("unexpected segment: %q", c.Segments[1].Text)
+	}
+}
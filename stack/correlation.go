@@ -0,0 +1,66 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+)
+
+// IDPattern extracts an ID, e.g. a trace ID, from a log line.
+type IDPattern struct {
+	// Name is the kind of ID, used as key in Context.CorrelationIDs, e.g.
+	// "trace_id".
+	Name string
+	// Re matches the ID. Its first submatch is the ID.
+	Re *regexp.Regexp
+}
+
+// DefaultIDPatterns matches the common formats of trace, span and request
+// IDs: W3C traceparent, AWS X-Ray, B3 and OpenTelemetry style "trace_id=",
+// and "X-Request-Id: " style request IDs.
+var DefaultIDPatterns = []IDPattern{
+	{"trace_id", regexp.MustCompile(`(?i)\btraceparent["']?\s*[:=]\s*["']?[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}\b`)},
+	{"trace_id", regexp.MustCompile(`(?i)\bx-amzn-trace-id["']?\s*[:=]\s*["']?Root=([0-9a-zA-Z-]+)`)},
+	{"trace_id", regexp.MustCompile(`(?i)\btrace[_-]?id["']?\s*[:=]\s*["']?([0-9a-zA-Z-]{8,})`)},
+	{"span_id", regexp.MustCompile(`(?i)\bspan[_-]?id["']?\s*[:=]\s*["']?([0-9a-zA-Z-]{8,})`)},
+	{"request_id", regexp.MustCompile(`(?i)\b(?:x-)?request[_-]?id["']?\s*[:=]\s*["']?([0-9a-zA-Z._-]{4,})`)},
+}
+
+// Private stuff.
+
+// defaultIDLines is the default value of Opts.IDLines.
+const defaultIDLines = 20
+
+// findCorrelationIDs returns the IDs found in the last lines lines printed
+// before the first goroutine. The line closest to the goroutines wins.
+func findCorrelationIDs(segments []Segment, patterns []IDPattern, lines int) map[string]string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	if lines <= 0 {
+		lines = defaultIDLines
+	}
+	var out map[string]string
+	for _, s := range segments {
+		if s.Before != 0 {
+			continue
+		}
+		l := splitSegment(s.Text)
+		for i := len(l) - 1; i >= 0 && len(l)-i <= lines; i-- {
+			for _, p := range patterns {
+				if _, ok := out[p.Name]; ok {
+					continue
+				}
+				if m := p.Re.FindStringSubmatch(l[i]); len(m) > 1 && m[1] != "" {
+					if out == nil {
+						out = map[string]string{}
+					}
+					out[p.Name] = m[1]
+				}
+			}
+		}
+	}
+	return out
+}
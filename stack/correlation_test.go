@@ -0,0 +1,79 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDumpCorrelationIDs(t *testing.T) {
+	t.Parallel()
+	goroutine := "\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	custom := []IDPattern{{"job", regexp.MustCompile(`job #(\d+)`)}}
+	data := []struct {
+		name     string
+		before   string
+		patterns []IDPattern
+		lines    int
+		want     map[string]string
+	}{
+		{"disabled", "trace_id=4bf92f3577b34da6a3ce929d0e0e4736\npanic: boom\n", nil, 0, nil},
+		{"none", "panic: boom\n", DefaultIDPatterns, 0, nil},
+		{
+			"traceparent",
+			"GET /api traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\npanic: boom\n",
+			DefaultIDPatterns,
+			0,
+			map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+		{
+			"json",
+			`{"msg":"handling","trace_id":"abcdef0123456789","span_id":"0123456789abcdef","request_id":"req-42"}` + "\npanic: boom\n",
+			DefaultIDPatterns,
+			0,
+			map[string]string{"trace_id": "abcdef0123456789", "span_id": "0123456789abcdef", "request_id": "req-42"},
+		},
+		{
+			"x-ray and header",
+			"X-Amzn-Trace-Id: Root=1-5759e988-bd862e3fe1be46a994272793\nX-Request-Id: f9d1c2\npanic: boom\n",
+			DefaultIDPatterns,
+			0,
+			map[string]string{"trace_id": "1-5759e988-bd862e3fe1be46a994272793", "request_id": "f9d1c2"},
+		},
+		{
+			"closest wins",
+			"request_id=older1\nrequest_id=newer2\npanic: boom\n",
+			DefaultIDPatterns,
+			0,
+			map[string]string{"request_id": "newer2"},
+		},
+		{
+			"too far",
+			"request_id=abcd\nfoo\npanic: boom\n",
+			DefaultIDPatterns,
+			2,
+			nil,
+		},
+		{"custom", "running job #1234\npanic: boom\n", custom, 0, map[string]string{"job": "1234"}},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			opts := &Opts{IDPatterns: line.patterns, IDLines: line.lines}
+			c, err := ParseDumpOpts(strings.NewReader(line.before+goroutine), nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(line.want, c.CorrelationIDs); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
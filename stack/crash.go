@@ -0,0 +1,148 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CrashKind is why the stack dump was printed.
+type CrashKind int
+
+const (
+	// CrashNone is a dump without a crash preamble, e.g. the output of
+	// runtime.Stack() or of the goroutine pprof profile with debug=2.
+	CrashNone CrashKind = iota
+	// CrashPanic is an unrecovered panic, e.g. "panic: oh no".
+	CrashPanic
+	// CrashFatal is a fatal error thrown by the runtime, e.g. "fatal error:
+	// all goroutines are asleep - deadlock!".
+	CrashFatal
+	// CrashSignal is a fatal signal received while not running Go code, e.g.
+	// "SIGSEGV: segmentation violation".
+	CrashSignal
+	// CrashSnapshot is a dump requested on a healthy process with SIGQUIT,
+	// e.g. with Ctrl-\. It is not a bug in itself.
+	CrashSnapshot
+	// CrashPanicNil is an unrecovered panic(nil). Since Go 1.21 the value is
+	// converted to a *runtime.PanicNilError, printed as "panic: panic called
+	// with nil argument"; before, or with GODEBUG=panicnil=1, it is printed as
+	// "panic: nil".
+	CrashPanicNil
+	// CrashGoexit is a crash caused by runtime.Goexit: either the main
+	// goroutine called it and all the other goroutines exited, or a deferred
+	// function panicked while runtime.Goexit was unwinding the goroutine, e.g.
+	// after t.FailNow() in a test.
+	CrashGoexit
+)
+
+func (c CrashKind) String() string {
+	switch c {
+	case CrashPanic:
+		return "panic"
+	case CrashFatal:
+		return "fatal"
+	case CrashSignal:
+		return "signal"
+	case CrashSnapshot:
+		return "snapshot"
+	case CrashPanicNil:
+		return "panic-nil"
+	case CrashGoexit:
+		return "goexit"
+	default:
+		return "none"
+	}
+}
+
+// IsCrash returns true if the process crashed, as opposed to an intentional
+// dump.
+func (c CrashKind) IsCrash() bool {
+	return c == CrashPanic || c == CrashFatal || c == CrashSignal || c == CrashPanicNil || c == CrashGoexit
+}
+
+// Culprit returns the goroutine that crashed, i.e. the first one with First
+// set, falling back on the first goroutine printed. With several dumps, it is
+// the one of the first dump, like Crash.
+//
+// Returns nil if there is no goroutine.
+func (c *Context) Culprit() *Goroutine {
+	for _, g := range c.Goroutines {
+		if g.First {
+			return g
+		}
+	}
+	if len(c.Goroutines) == 0 {
+		return nil
+	}
+	return c.Goroutines[0]
+}
+
+// Private stuff.
+
+// reCrash matches the first line printed by the runtime when it dies. See
+// printpanics(), fatalthrow() and sighandler() in package runtime.
+var reCrash = regexp.MustCompile("^(?:(panic): |(fatal error): |(SIGQUIT): quit|(SIG[A-Z0-9]+): )")
+
+// rePanicNil matches the value printed for panic(nil), with and without
+// GODEBUG=panicnil=1.
+var rePanicNil = regexp.MustCompile(`^panic: (?:nil|panic called with nil argument)(?: \[recovered\])?$`)
+
+// goexitDeadlock is printed when the main goroutine called runtime.Goexit and
+// no other goroutine is left.
+const goexitDeadlock = "fatal error: no goroutines (main called runtime.Goexit) - deadlock!"
+
+// findCrash returns the kind of the first crash preamble found in segments.
+//
+// The runtime stack printed by runtime.throw is used as a fallback, in case
+// the preamble was lost.
+func findCrash(goroutines []*Goroutine, segments []Segment) CrashKind {
+	for _, s := range segments {
+		for _, l := range strings.Split(s.Text, "\n") {
+			match := reCrash.FindStringSubmatch(l)
+			switch {
+			case match == nil:
+			case match[1] != "":
+				return refinePanic(goroutines, rePanicNil.MatchString(l))
+			case match[2] != "":
+				if l == goexitDeadlock {
+					return CrashGoexit
+				}
+				return CrashFatal
+			case match[3] != "":
+				return CrashSnapshot
+			default:
+				return CrashSignal
+			}
+		}
+	}
+	for _, g := range goroutines {
+		if g.State == runtimeStack {
+			return CrashFatal
+		}
+	}
+	return CrashNone
+}
+
+// refinePanic distinguishes panics happening during runtime.Goexit and
+// panic(nil) from other panics.
+func refinePanic(goroutines []*Goroutine, isNil bool) CrashKind {
+	for _, g := range goroutines {
+		if !g.First {
+			continue
+		}
+		for i := range g.Stack.Calls {
+			if g.Stack.Calls[i].Func.Raw == "runtime.Goexit" {
+				return CrashGoexit
+			}
+		}
+		break
+	}
+	if isNil {
+		return CrashPanicNil
+	}
+	return CrashPanic
+}
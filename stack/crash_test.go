@@ -0,0 +1,76 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCrashKind(t *testing.T) {
+	t.Parallel()
+	stack := "\ngoroutine 1 [running]:\nmain.main()\n\t/gopath/src/foo/main.go:10 +0x1\n"
+	data := []struct {
+		name     string
+		preamble string
+		want     CrashKind
+	}{
+		{"none", "", CrashNone},
+		{"panic", "log line\npanic: oh no\n", CrashPanic},
+		{"nil", "panic: runtime error: invalid memory address or nil pointer dereference\n[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x1]\n", CrashPanic},
+		{"fatal", "fatal error: all goroutines are asleep - deadlock!\n", CrashFatal},
+		{"signal", "SIGSEGV: segmentation violation\nPC=0x7f0 m=0 sigcode=1\n", CrashSignal},
+		{"sigquit", "SIGQUIT: quit\nPC=0x45e7a1 m=0 sigcode=0\n", CrashSnapshot},
+		{"panicnil", "panic: panic called with nil argument [recovered]\n", CrashPanicNil},
+		{"panicnil_legacy", "panic: nil\n", CrashPanicNil},
+		{"goexit_main", "fatal error: no goroutines (main called runtime.Goexit) - deadlock!\n", CrashGoexit},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseDump(strings.NewReader(line.preamble+stack), nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Crash != line.want {
+				t.Fatalf("want %s, got %s", line.want, c.Crash)
+			}
+			if c.Crash.IsCrash() != (line.want != CrashNone && line.want != CrashSnapshot) {
+				t.Fatal("unexpected IsCrash()")
+			}
+		})
+	}
+}
+
+func TestCrashKindGoexitPanic(t *testing.T) {
+	t.Parallel()
+	// A deferred function panicked while runtime.Goexit was unwinding, e.g.
+	// after t.FailNow().
+	data := []string{
+		"panic: boom",
+		"",
+		"goroutine 6 [running]:",
+		"main.cleanup()",
+		"\t/gopath/src/foo/main.go:20 +0x1",
+		"runtime.Goexit()",
+		"\t/goroot/src/runtime/panic.go:626 +0x5e",
+		"testing.(*common).FailNow(0xc000003380)",
+		"\t/goroot/src/testing/testing.go:1005 +0x4a",
+		"created by testing.(*T).Run in goroutine 1",
+		"\t/goroot/src/testing/testing.go:1742 +0x390",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Crash != CrashGoexit || !c.Crash.IsCrash() || c.Crash.String() != "goexit" {
+		t.Fatalf("want goexit, got %s", c.Crash)
+	}
+	if len(c.Goroutines[0].Stack.Calls) != 3 || c.Goroutines[0].CreatedByID != 1 {
+		t.Fatalf("unexpected goroutine: %#v", c.Goroutines[0])
+	}
+}
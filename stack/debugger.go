@@ -0,0 +1,73 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseBacktrace parses the output of the "bt" command of gdb or lldb
+// attached to a Go binary.
+//
+// This is best effort; the debugger only shows the current thread so a
+// single goroutine with ID 0 and State "running" is returned. The arguments
+// printed by the debugger are not decoded. lldb may only print the base name
+// of the source file.
+//
+// Lines that are not recognized are returned in Context.Segments. Returns nil
+// *Context if no frame was found.
+func ParseBacktrace(r io.Reader) (*Context, error) {
+	g := &Goroutine{Signature: Signature{State: "running"}, First: true}
+	var segments []Segment
+	lineno := 0
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		lineno++
+		l := strings.TrimPrefix(strings.TrimPrefix(s.Text(), "(gdb) "), "(lldb) ")
+		m := reGDBFrame.FindStringSubmatch(l)
+		if m == nil {
+			m = reLLDBFrame.FindStringSubmatch(l)
+		}
+		if m == nil {
+			n := 0
+			if len(g.Stack.Calls) != 0 {
+				n = 1
+			}
+			segments = appendSegment(segments, s.Text(), n, lineno)
+			continue
+		}
+		c := Call{Func: NewFunc(m[1])}
+		if m[2] != "" {
+			c.SrcPath = m[2]
+			c.Line, _ = strconv.Atoi(m[3])
+		}
+		if len(g.Stack.Calls) == 0 {
+			g.Span.First = lineno
+		}
+		g.Span.Last = lineno
+		g.Stack.Calls = append(g.Stack.Calls, c)
+		g.CallSpans = append(g.CallSpans, Span{First: lineno, Last: lineno})
+	}
+	if len(g.Stack.Calls) == 0 {
+		return nil, s.Err()
+	}
+	return &Context{Goroutines: []*Goroutine{g}, Segments: segments}, s.Err()
+}
+
+// Private stuff.
+
+var (
+	// e.g. "#1  0x000000000042f6e6 in runtime.futexsleep (addr=0xc0000a4148, val=0, ns=-1) at /usr/local/go/src/runtime/os_linux.go:69"
+	// or "#0  runtime.futex () at /usr/local/go/src/runtime/sys_linux_amd64.s:557"
+	reGDBFrame = regexp.MustCompile(`^#\d+\s+(?:0x[0-9a-f]+ in )?(\S+?)(?: \(.*\))?(?: at (.+):(\d+))?$`)
+	// e.g. "  * frame #0: 0x000000000046c103 foo`runtime.futex at sys_linux_amd64.s:557"
+	// or "    frame #1: 0x0000000000434d36 foo`runtime.futexsleep(addr=0xc0000a4148, val=0, ns=-1) at os_linux.go:69:3"
+	reLLDBFrame = regexp.MustCompile("^[ *]*frame #\\d+: 0x[0-9a-f]+ (?:[^`\\s]+`)?([^(\\s]+)(?:\\(.*\\))?(?: \\+ \\d+)?(?: at (.+?):(\\d+)(?::\\d+)?)?$")
+)
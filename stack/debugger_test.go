@@ -0,0 +1,56 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBacktrace(t *testing.T) {
+	t.Parallel()
+	data := map[string][]string{
+		"gdb": {
+			"(gdb) bt",
+			"#0  runtime.futex () at /usr/local/go/src/runtime/sys_linux_amd64.s:557",
+			"#1  0x000000000042f6e6 in runtime.futexsleep (addr=0xc0000a4148, val=0, ns=-1) at /usr/local/go/src/runtime/os_linux.go:69",
+			"#2  0x00000000004a1b2c in main.main () at /home/user/main.go:10",
+		},
+		"lldb": {
+			"(lldb) bt",
+			"* thread #1, name = 'foo', stop reason = signal SIGSTOP",
+			"  * frame #0: 0x000000000046c103 foo`runtime.futex at sys_linux_amd64.s:557",
+			"    frame #1: 0x000000000042f6e6 foo`runtime.futexsleep(addr=0xc0000a4148, val=0, ns=-1) at os_linux.go:69:3",
+			"    frame #2: 0x00000000004a1b2c foo`main.main at main.go:10",
+		},
+	}
+	for name, lines := range data {
+		lines := lines
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseBacktrace(strings.NewReader(strings.Join(lines, "\n")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Goroutines) != 1 {
+				t.Fatalf("want 1 goroutine, got %d", len(c.Goroutines))
+			}
+			calls := c.Goroutines[0].Stack.Calls
+			if len(calls) != 3 {
+				t.Fatalf("want 3 calls, got %#v", calls)
+			}
+			want := []string{"runtime.futex", "runtime.futexsleep", "main.main"}
+			lineNos := []int{557, 69, 10}
+			for i := range calls {
+				if calls[i].Func.Raw != want[i] || calls[i].Line != lineNos[i] {
+					t.Fatalf("#%d: unexpected call %#v", i, calls[i])
+				}
+			}
+			if len(c.Segments) != 1 || c.Segments[0].Before != 0 {
+				t.Fatalf("unexpected segments: %#v", c.Segments)
+			}
+		})
+	}
+}
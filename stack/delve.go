@@ -0,0 +1,106 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseDelve parses the output of the delve debugger commands "goroutines
+// -t" and "stack".
+//
+// The output of "stack" has no goroutine header; it is returned as a single
+// goroutine with ID 0 and State "running". Delve doesn't print the call
+// arguments nor the creator, so Call.Args and Signature.CreatedBy are not set.
+//
+// Lines that are not recognized are returned in Context.Segments. Returns nil
+// *Context if no goroutine was found.
+func ParseDelve(r io.Reader) (*Context, error) {
+	var goroutines []*Goroutine
+	var segments []Segment
+	var cur *Goroutine
+	var call *Call
+	lineno := 0
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		lineno++
+		l := strings.TrimPrefix(s.Text(), "(dlv) ")
+		if m := reDelveGoroutine.FindStringSubmatch(l); m != nil {
+			id, _ := strconv.Atoi(m[2])
+			state := "running"
+			if m[3] != "" {
+				state = m[3]
+			}
+			cur = &Goroutine{
+				Signature: Signature{State: state},
+				ID:        id,
+				// Delve marks the current goroutine with "*".
+				First: m[1] == "*",
+				Span:  Span{First: lineno, Last: lineno},
+			}
+			goroutines = append(goroutines, cur)
+			call = nil
+			continue
+		}
+		if m := reDelveFrame.FindStringSubmatch(l); m != nil {
+			if cur == nil {
+				cur = &Goroutine{Signature: Signature{State: "running"}, First: true, Span: Span{First: lineno}}
+				goroutines = append(goroutines, cur)
+			}
+			cur.Stack.Calls = append(cur.Stack.Calls, Call{Func: NewFunc(m[1])})
+			cur.CallSpans = append(cur.CallSpans, Span{First: lineno, Last: lineno})
+			cur.Span.Last = lineno
+			call = &cur.Stack.Calls[len(cur.Stack.Calls)-1]
+			continue
+		}
+		if m := reDelveAt.FindStringSubmatch(l); m != nil && call != nil {
+			call.SrcPath = m[1]
+			call.Line, _ = strconv.Atoi(m[2])
+			cur.CallSpans[len(cur.CallSpans)-1].Last = lineno
+			cur.Span.Last = lineno
+			call = nil
+			continue
+		}
+		if cur != nil && reDelveTruncated.MatchString(l) {
+			cur.Stack.Elided = true
+			continue
+		}
+		call = nil
+		segments = appendSegment(segments, s.Text(), len(goroutines), lineno)
+	}
+	if len(goroutines) == 0 {
+		return nil, s.Err()
+	}
+	return &Context{Goroutines: goroutines, Segments: segments}, s.Err()
+}
+
+// Private stuff.
+
+// appendSegment appends an unrecognized line to segments, coalescing it with
+// the previous segment when no goroutine was found in between.
+func appendSegment(segments []Segment, line string, before, lineno int) []Segment {
+	if n := len(segments); n != 0 && segments[n-1].Before == before {
+		segments[n-1].Text += line + "\n"
+		segments[n-1].Span.Last = lineno
+		return segments
+	}
+	return append(segments, Segment{Text: line + "\n", Before: before, Span: Span{First: lineno, Last: lineno}})
+}
+
+var (
+	// e.g. "* Goroutine 1 - User: ./main.go:10 main.main (0x4a1b2c) [chan receive]"
+	reDelveGoroutine = regexp.MustCompile(`^([ *]) Goroutine (\d+) - .*?(?: \[([^\]]+?)(?: \d[\dhms.]*)?\])?(?: \(thread \d+\))?$`)
+	// e.g. "	0  0x000000000043a3c5 in runtime.gopark"
+	reDelveFrame = regexp.MustCompile(`^\s*\d+\s+0x[0-9a-f]+ in (.+)$`)
+	// e.g. "	    at /usr/local/go/src/runtime/proc.go:381"
+	reDelveAt = regexp.MustCompile(`^\s+at (.+):(\d+)$`)
+	// e.g. "(truncated)" or "...+12 more"
+	reDelveTruncated = regexp.MustCompile(`^\s*(?:\(truncated\)|\.\.\.\+?\d* more)\s*$`)
+)
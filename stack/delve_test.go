@@ -0,0 +1,62 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDelve(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"(dlv) goroutines -t",
+		"  Goroutine 1 - User: ./main.go:10 main.main (0x4a1b2c) [chan receive]",
+		"	0  0x000000000043a3c5 in runtime.gopark",
+		"	    at /usr/local/go/src/runtime/proc.go:381",
+		"	1  0x00000000004a1b2c in main.main",
+		"	    at ./main.go:10",
+		"* Goroutine 6 - User: ./main.go:20 main.worker (0x4a1c00) (thread 1234)",
+		"	0  0x00000000004a1c00 in main.worker",
+		"	    at ./main.go:20",
+		"	(truncated)",
+		"[2 goroutines]",
+	}
+	c, err := ParseDelve(strings.NewReader(strings.Join(data, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 2 {
+		t.Fatalf("want 2 goroutines, got %d", len(c.Goroutines))
+	}
+	g := c.Goroutines[0]
+	if g.ID != 1 || g.State != "chan receive" || g.First || len(g.Stack.Calls) != 2 || g.Stack.Calls[1].Func.Raw != "main.main" || g.Stack.Calls[1].SrcPath != "./main.go" || g.Stack.Calls[1].Line != 10 {
+		t.Fatalf("unexpected goroutine: %#v", g)
+	}
+	g = c.Goroutines[1]
+	if g.ID != 6 || g.State != "running" || !g.First || !g.Stack.Elided || g.Span != (Span{First: 7, Last: 9}) {
+		t.Fatalf("unexpected goroutine: %#v", g)
+	}
+	if len(c.Segments) != 2 || c.Segments[1].Text != "[2 goroutines]\n" {
+		t.Fatalf("unexpected segments: %#v", c.Segments)
+	}
+}
+
+func TestParseDelveStack(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"0  0x00000000004a1b2c in main.main",
+		"   at ./main.go:10",
+		"1  0x0000000000437e73 in runtime.main",
+		"   at /usr/local/go/src/runtime/proc.go:250",
+	}
+	c, err := ParseDelve(strings.NewReader(strings.Join(data, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 1 || len(c.Goroutines[0].Stack.Calls) != 2 || c.Goroutines[0].Stack.Calls[1].Line != 250 {
+		t.Fatalf("unexpected context: %#v", c)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Exit is how the process terminated, as printed after the dump by the
+// program that ran it, e.g. "go run", "go test" or os/exec.
+type Exit struct {
+	// Code is the exit code, e.g. 2 for an unrecovered panic. It is -1 when
+	// the process was killed by a signal.
+	Code int
+	// Signal is the signal that killed the process, e.g. "killed" or
+	// "segmentation fault".
+	Signal string
+	// CoreDumped is set when a core dump was written.
+	CoreDumped bool
+	// Line is the verbatim line, without the line terminator.
+	Line string
+}
+
+func (e *Exit) String() string {
+	return e.Line
+}
+
+// Private stuff.
+
+var (
+	// reExitStatus matches os/exec.ExitError printed by "go run" and "go
+	// test", e.g. "exit status 2".
+	reExitStatus = regexp.MustCompile(`^exit status (\d+)$`)
+	// reExitSignal matches os/exec.ExitError for a process killed by a signal,
+	// e.g. "signal: segmentation fault (core dumped)".
+	reExitSignal = regexp.MustCompile(`^signal: (.+?)( \(core dumped\))?$`)
+)
+
+// findExit returns the last exit line in the segments printed after the n
+// goroutines.
+func findExit(segments []Segment, n int) *Exit {
+	var out *Exit
+	for _, s := range segments {
+		if s.Before != n {
+			continue
+		}
+		for _, l := range strings.Split(s.Text, "\n") {
+			l = strings.TrimRight(l, "\r")
+			if m := reExitStatus.FindStringSubmatch(l); m != nil {
+				if c, err := strconv.Atoi(m[1]); err == nil {
+					out = &Exit{Code: c, Line: l}
+				}
+			} else if m := reExitSignal.FindStringSubmatch(l); m != nil {
+				out = &Exit{Code: -1, Signal: m[1], CoreDumped: m[2] != "", Line: l}
+			}
+		}
+	}
+	return out
+}
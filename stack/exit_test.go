@@ -0,0 +1,51 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDumpExit(t *testing.T) {
+	t.Parallel()
+	const dump = "panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	data := []struct {
+		name  string
+		input string
+		want  *Exit
+	}{
+		{"none", dump, nil},
+		{"status", dump + "exit status 2\n", &Exit{Code: 2, Line: "exit status 2"}},
+		{"go test", dump + "exit status 2\nFAIL\tfoo\t0.011s\n", &Exit{Code: 2, Line: "exit status 2"}},
+		{"crlf", strings.Replace(dump+"exit status 1\n", "\n", "\r\n", -1), &Exit{Code: 1, Line: "exit status 1"}},
+		{"killed", dump + "signal: killed\n", &Exit{Code: -1, Signal: "killed", Line: "signal: killed"}},
+		{"core", dump + "signal: segmentation fault (core dumped)\n", &Exit{Code: -1, Signal: "segmentation fault", CoreDumped: true, Line: "signal: segmentation fault (core dumped)"}},
+		// Only the text after the goroutines is considered.
+		{"before", "exit status 3\n" + dump, nil},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseDump(strings.NewReader(line.input), nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(line.want, c.Exit); diff != "" {
+				t.Fatalf("Exit mismatch (-want +got):\n%s", diff)
+			}
+			s, err := ParseDumpOpts(strings.NewReader(line.input), nil, &Opts{OnGoroutine: func(*Goroutine) {}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(line.want, s.Exit); diff != "" {
+				t.Fatalf("streaming Exit mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
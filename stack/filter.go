@@ -0,0 +1,92 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GCMode controls how the goroutines of the garbage collector and other
+// runtime background workers are reported.
+type GCMode int
+
+const (
+	// GCShow shows them like any other goroutine.
+	GCShow GCMode = iota
+	// GCCollapse collapses them into a single summary line.
+	GCCollapse
+	// GCHide excludes them.
+	GCHide
+)
+
+// IsGC returns true if the goroutine is a background worker of the garbage
+// collector or of the runtime, like the finalizer goroutine or the scavenger.
+func (s *Signature) IsGC() bool {
+	for i := range s.Stack.Calls {
+		if gcFuncs[s.Stack.Calls[i].Func.Raw] {
+			return true
+		}
+	}
+	return gcCreators[s.CreatedBy.Func.Raw]
+}
+
+// SplitGC splits the buckets between the ones that are garbage collector
+// workers as determined by IsGC() and the others.
+//
+// The order is preserved. The input slice is not modified.
+func SplitGC(buckets []*Bucket) (other, gc []*Bucket) {
+	for _, b := range buckets {
+		if b.IsGC() {
+			gc = append(gc, b)
+		} else {
+			other = append(other, b)
+		}
+	}
+	return other, gc
+}
+
+// IsWrapper returns true if the call is a wrapper generated by the compiler,
+// e.g. an ABI wrapper, a deferred call wrapper or an interface method thunk.
+// They add no information to the stack.
+func (c *Call) IsWrapper() bool {
+	if c.SrcPath == "<autogenerated>" {
+		return true
+	}
+	r := c.Func.Raw
+	if strings.HasSuffix(r, ".abi0") || strings.HasSuffix(r, ".abiinternal") {
+		return true
+	}
+	if strings.HasPrefix(r, "go:itab.") || strings.HasPrefix(r, "go.itab.") {
+		return true
+	}
+	// Go 1.22+ wraps the deferred calls, e.g. "main.main.deferwrap1".
+	return reDeferWrap.MatchString(r)
+}
+
+// Private stuff.
+
+// reDeferWrap matches the name of a deferred call wrapper.
+var reDeferWrap = regexp.MustCompile(`\.deferwrap\d+$`)
+
+// gcFuncs are the entry points of the runtime background goroutines.
+var gcFuncs = map[string]bool{
+	"runtime.bgscavenge":     true,
+	"runtime.bgsweep":        true,
+	"runtime.forcegchelper":  true,
+	"runtime.gcBgMarkWorker": true,
+	"runtime.runfinq":        true,
+	"runtime.runCleanups":    true,
+	"runtime.timerproc":      true,
+}
+
+// gcCreators are the functions creating the runtime background goroutines.
+var gcCreators = map[string]bool{
+	"runtime.createfing":                              true,
+	"runtime.gcBgMarkStartWorkers":                    true,
+	"runtime.gcenable":                                true,
+	"runtime.newAddCleanupStatus":                     true,
+	"runtime.unique_runtime_registerUniqueMapCleanup": true,
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+)
+
+func TestSplitGC(t *testing.T) {
+	t.Parallel()
+	user := &Bucket{Signature: Signature{Stack: Stack{Calls: []Call{newCall("main.main", Args{}, "main.go", 1)}}}}
+	worker := &Bucket{Signature: Signature{
+		State: "GC worker (idle)",
+		Stack: Stack{Calls: []Call{
+			newCall("runtime.gopark", Args{}, "/goroot/src/runtime/proc.go", 1),
+			newCall("runtime.gcBgMarkWorker", Args{}, "/goroot/src/runtime/mgc.go", 1),
+		}},
+	}}
+	sweep := &Bucket{Signature: Signature{
+		CreatedBy: newCall("runtime.gcenable", Args{}, "/goroot/src/runtime/mgc.go", 1),
+		Stack:     Stack{Calls: []Call{newCall("runtime.gopark", Args{}, "/goroot/src/runtime/proc.go", 1)}},
+	}}
+	other, gc := SplitGC([]*Bucket{worker, user, sweep})
+	if len(other) != 1 || other[0] != user {
+		t.Fatalf("unexpected other: %v", other)
+	}
+	if len(gc) != 2 || gc[0] != worker || gc[1] != sweep {
+		t.Fatalf("unexpected gc: %v", gc)
+	}
+}
+
+func TestCallIsWrapper(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		c    Call
+		want bool
+	}{
+		{Call{Func: newFunc("main.(*T).M"), SrcPath: "<autogenerated>", Line: 1}, true},
+		{Call{Func: newFunc("runtime.morestack_noctxt.abi0"), SrcPath: "/goroot/src/runtime/asm_amd64.s", Line: 593}, true},
+		{Call{Func: newFunc("main.main.deferwrap1"), SrcPath: "/src/main.go", Line: 5}, true},
+		{Call{Func: newFunc("go:itab.*main.T,main.I.M"), SrcPath: "/src/main.go", Line: 1}, true},
+		{Call{Func: newFunc("main.main"), SrcPath: "/src/main.go", Line: 5}, false},
+		{Call{Func: newFunc("main.deferwrapper"), SrcPath: "/src/main.go", Line: 5}, false},
+	}
+	for i, line := range data {
+		if got := line.c.IsWrapper(); got != line.want {
+			t.Errorf("#%d: IsWrapper(%s) = %t", i, line.c.Func.Raw, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+)
+
+// Fingerprint returns a stable identifier for the signature, suitable to
+// deduplicate the same crash across processes, hosts and builds.
+//
+// It only depends on the function names, the source file names and the line
+// numbers of the call stack and of the creator. In particular it ignores the
+// argument values, the goroutine state and the directories of the source
+// files, so that binaries built with or without -trimpath, or on builders
+// with different GOPATH, produce the same fingerprint.
+func (s *Signature) Fingerprint() string {
+	return s.FingerprintTags(nil)
+}
+
+// FingerprintTags is the same as Fingerprint, with tags also participating
+// in the identifier, e.g. a tenant shard derived from an argument, so crashes
+// are deduplicated per tag value.
+//
+// The tags are hashed in key order. With no tags, the result is the same as
+// Fingerprint.
+func (s *Signature) FingerprintTags(tags map[string]string) string {
+	h := sha256.New()
+	buf := make([]byte, 0, 128)
+	add := func(c *Call) {
+		buf = append(buf[:0], c.Func.Raw...)
+		buf = append(buf, 0)
+		buf = append(buf, c.SrcName()...)
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, int64(c.Line), 10)
+		buf = append(buf, '\n')
+		_, _ = h.Write(buf)
+	}
+	for i := range s.Stack.Calls {
+		add(&s.Stack.Calls[i])
+	}
+	if s.Stack.Elided {
+		_, _ = h.Write([]byte("...\n"))
+	}
+	if s.CreatedBy.Func.Raw != "" {
+		_, _ = h.Write([]byte("created by\n"))
+		add(&s.CreatedBy)
+	}
+	if len(tags) != 0 {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		_, _ = h.Write([]byte("tags\n"))
+		for _, k := range keys {
+			buf = append(append(append(append(buf[:0], k...), 0), tags[k]...), '\n')
+			_, _ = h.Write(buf)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintTrimpath(t *testing.T) {
+	t.Parallel()
+	abs := Signature{
+		State: "running",
+		Stack: Stack{Calls: []Call{
+			newCall("github.com/foo/bar.Baz", Args{Values: []Arg{{Value: 0xc000010000}}}, "/home/builder/go/pkg/mod/github.com/foo/bar@v1.2.3/baz.go", 10),
+			newCall("runtime.main", Args{}, "/usr/local/go/src/runtime/proc.go", 250),
+		}},
+	}
+	trimmed := Signature{
+		State: "chan receive",
+		Stack: Stack{Calls: []Call{
+			newCall("github.com/foo/bar.Baz", Args{Values: []Arg{{Value: 0xc000020000}}}, "github.com/foo/bar@v1.2.3/baz.go", 10),
+			newCall("runtime.main", Args{}, "runtime/proc.go", 250),
+		}},
+	}
+	compareString(t, abs.Fingerprint(), trimmed.Fingerprint())
+	trimmed.Stack.Calls[0].Line = 11
+	if abs.Fingerprint() == trimmed.Fingerprint() {
+		t.Fatal("expected different fingerprints")
+	}
+}
+
+func TestFingerprintTags(t *testing.T) {
+	t.Parallel()
+	s := Signature{
+		State: "running",
+		Stack: Stack{Calls: []Call{newCall("main.main", Args{}, "/home/user/proj/main.go", 10)}},
+	}
+	compareString(t, s.Fingerprint(), s.FingerprintTags(nil))
+	a := s.FingerprintTags(map[string]string{"shard": "1", "tenant": "foo"})
+	if a == s.Fingerprint() {
+		t.Fatal("expected tags to change the fingerprint")
+	}
+	compareString(t, a, s.FingerprintTags(map[string]string{"tenant": "foo", "shard": "1"}))
+	if a == s.FingerprintTags(map[string]string{"shard": "2", "tenant": "foo"}) {
+		t.Fatal("expected different fingerprints")
+	}
+}
+
+func TestCallModuleSrcPath(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		f       string
+		src     string
+		trimmed bool
+		want    string
+	}{
+		{"github.com/foo/bar.Baz", "/home/builder/go/pkg/mod/github.com/foo/bar@v1.2.3/baz.go", false, "github.com/foo/bar/baz.go"},
+		{"github.com/foo/bar.Baz", "github.com/foo/bar@v1.2.3/baz.go", true, "github.com/foo/bar/baz.go"},
+		{"github.com/foo/bar.Baz", "/home/builder/src/github.com/foo/bar/baz.go", false, "github.com/foo/bar/baz.go"},
+		{"github.com/foo/bar.Baz", "C:\\src\\bar\\baz.go", false, "github.com/foo/bar/baz.go"},
+		{"runtime.main", "/usr/local/go/src/runtime/proc.go", false, "runtime/proc.go"},
+		{"runtime.main", "runtime/proc.go", true, "runtime/proc.go"},
+		{"net/http.(*conn).serve", "/goroot/src/net/http/server.go", false, "net/http/server.go"},
+		{"main.main", "/home/user/proj/main.go", false, "main.go"},
+		{"main.main", "example.com/proj/main.go", true, "example.com/proj/main.go"},
+		{"foo", "??", false, "??"},
+	}
+	for i, line := range data {
+		c := newCall(line.f, Args{}, line.src, 1)
+		if got := c.IsTrimmed(); got != line.trimmed {
+			t.Errorf("#%d: IsTrimmed() = %t", i, got)
+		}
+		if got := c.ModuleSrcPath(); got != line.want {
+			t.Errorf("#%d: ModuleSrcPath() = %q, want %q", i, got, line.want)
+		}
+	}
+}
+
+func TestCallTrimmedStdlib(t *testing.T) {
+	t.Parallel()
+	goroot, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(goroot)
+	if err := os.MkdirAll(filepath.Join(goroot, "src", "runtime"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	goroot = strings.Replace(goroot, "\\", "/", -1)
+	c := newCall("runtime.main", Args{}, "runtime/proc.go", 250)
+	c.updateLocations("", goroot, map[string]string{})
+	compareBool(t, true, c.IsStdlib)
+	compareString(t, "runtime/proc.go", c.RelSrcPath)
+	compareString(t, goroot+"/src/runtime/proc.go", c.LocalSrcPath)
+	// Module paths without a dot in their first element are not in GOROOT.
+	for _, src := range []string{"example/foo/foo.go", "myapp/internal/x/x.go", "github.com/foo/bar@v1.2.3/baz.go"} {
+		c = newCall("example/foo.Bar", Args{}, src, 1)
+		c.updateLocations("", goroot, map[string]string{})
+		compareBool(t, false, c.IsStdlib)
+		compareString(t, "", c.LocalSrcPath)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "fmt"
+
+// FormatError is a line inside a goroutine that is not in any supported
+// format.
+//
+// It likely means the dump comes from a Go version newer than the ones
+// supported by this package, or that the dump was mangled.
+type FormatError struct {
+	// Line is the 1-based line number in the input.
+	Line int
+	// Text is the line, without the line terminator.
+	Text string
+	// Err is the parser error.
+	Err error
+
+	// lenient is true when the parsing proceeded past the line.
+	lenient bool
+}
+
+func (f *FormatError) Error() string {
+	if f.lenient {
+		return fmt.Sprintf("line %d: dump appears to be from a Go version whose format is unsupported; frames parsed best-effort: %v", f.Line, f.Err)
+	}
+	return fmt.Sprintf("line %d: dump appears to be from a Go version whose format is unsupported: %v", f.Line, f.Err)
+}
+
+// Unwrap returns the parser error.
+func (f *FormatError) Unwrap() error {
+	return f.Err
+}
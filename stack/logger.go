@@ -0,0 +1,40 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"log"
+)
+
+// Logger receives debug information from the parser and the augmenter, e.g.
+// the lines that were not recognized or the source files that could not be
+// loaded.
+//
+// *log.Logger implements this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Private stuff.
+
+// nopLogger discards everything.
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// stdLogger logs to the standard logger of package log.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// getLogger returns l or a logger discarding everything if l is nil.
+func getLogger(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ParseCloudLogging parses Google Cloud Logging entries and returns the stack
+// dumps found, one per log stream containing one.
+//
+// The input is either a JSON array of entries, as printed by
+// "gcloud logging read --format=json", or one JSON entry per line, as
+// exported to Cloud Storage. The text is read from textPayload, or from
+// jsonPayload.message for structured entries.
+//
+// Entries are grouped by logName and resource labels, then ordered by
+// timestamp and insertId. opts can be nil.
+func ParseCloudLogging(r io.Reader, opts *stack.Opts) ([]Dump, error) {
+	s := streams{}
+	err := decodeJSONEntries(r, func(raw json.RawMessage) error {
+		var e cloudLoggingEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		var text string
+		switch {
+		case e.TextPayload != nil:
+			text = *e.TextPayload
+		case e.JSONPayload.Message != nil:
+			text = *e.JSONPayload.Message
+		default:
+			return nil
+		}
+		labels := map[string]string{}
+		for k, v := range e.Resource.Labels {
+			labels[k] = v
+		}
+		if e.LogName != "" {
+			labels["logName"] = e.LogName
+		}
+		t, _ := time.Parse(time.RFC3339Nano, e.Timestamp)
+		s.add(labels, entry{time: t, order: e.InsertID, text: text})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.parse(opts)
+}
+
+// Private stuff.
+
+// cloudLoggingEntry is the subset of a LogEntry that is used.
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry.
+type cloudLoggingEntry struct {
+	InsertID    string  `json:"insertId"`
+	Timestamp   string  `json:"timestamp"`
+	LogName     string  `json:"logName"`
+	TextPayload *string `json:"textPayload"`
+	JSONPayload struct {
+		Message *string `json:"message"`
+	} `json:"jsonPayload"`
+	Resource struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+}
+
+// decodeJSONEntries calls f with each element of a JSON array, or with each
+// value of a stream of JSON values.
+func decodeJSONEntries(r io.Reader, f func(raw json.RawMessage) error) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		if _, err := br.ReadByte(); err != nil {
+			return err
+		}
+	}
+	d := json.NewDecoder(br)
+	if b, _ := br.Peek(1); b[0] == '[' {
+		var all []json.RawMessage
+		if err := d.Decode(&all); err != nil {
+			return err
+		}
+		for _, raw := range all {
+			if err := f(raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := f(raw); err != nil {
+			return err
+		}
+	}
+}
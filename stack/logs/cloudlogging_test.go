@@ -0,0 +1,84 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseCloudLogging(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/home/user/src/foo/main.go:10 +0x45",
+		"exit status 2",
+	}
+	// Shuffle the entries and interleave another pod to make sure they are
+	// reassembled.
+	type res struct {
+		Labels map[string]string `json:"labels"`
+	}
+	type ent struct {
+		InsertID    string `json:"insertId"`
+		Timestamp   string `json:"timestamp"`
+		LogName     string `json:"logName"`
+		TextPayload string `json:"textPayload"`
+		Resource    res    `json:"resource"`
+	}
+	var entries []ent
+	for _, i := range []int{3, 0, 5, 1, 4, 2} {
+		entries = append(entries, ent{
+			InsertID:    string(rune('a' + i)),
+			Timestamp:   "2020-01-02T03:04:05.000000001Z",
+			LogName:     "projects/p/logs/stderr",
+			TextPayload: lines[i],
+			Resource:    res{Labels: map[string]string{"pod_name": "foo-1"}},
+		})
+		entries = append(entries, ent{
+			InsertID:    string(rune('a' + i)),
+			Timestamp:   "2020-01-02T03:04:05Z",
+			LogName:     "projects/p/logs/stderr",
+			TextPayload: "unrelated",
+			Resource:    res{Labels: map[string]string{"pod_name": "bar-1"}},
+		})
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ndjson []string
+	for _, e := range entries {
+		l, _ := json.Marshal(e)
+		ndjson = append(ndjson, string(l))
+	}
+	for name, input := range map[string]string{"array": string(b), "lines": strings.Join(ndjson, "\n")} {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			dumps, err := ParseCloudLogging(strings.NewReader(input), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dumps) != 1 {
+				t.Fatalf("want 1 dump, got %d", len(dumps))
+			}
+			d := dumps[0]
+			if d.Labels["pod_name"] != "foo-1" || d.Source != "logName=projects/p/logs/stderr,pod_name=foo-1" {
+				t.Fatalf("unexpected labels: %v %q", d.Labels, d.Source)
+			}
+			if len(d.Goroutines) != 1 || d.Goroutines[0].Stack.Calls[0].Line != 10 {
+				t.Fatalf("unexpected goroutines: %#v", d.Goroutines)
+			}
+			if d.Segments[0].Text != "panic: boom\n\n" {
+				t.Fatalf("unexpected segment: %q", d.Segments[0].Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ParseCloudWatch parses AWS CloudWatch Logs events and returns the stack
+// dumps found, one per log stream containing one.
+//
+// The input is either:
+//   - The JSON response of GetLogEvents or FilterLogEvents, e.g. as printed
+//     by "aws logs get-log-events".
+//   - A file exported to S3, where each line is a timestamp followed by the
+//     message. It may be gzip compressed.
+//
+// group and stream are the log group and stream names; they are attached to
+// each Dump as the "logGroupName" and "logStreamName" labels. The
+// logStreamName of FilterLogEvents events takes precedence over stream.
+//
+// opts can be nil.
+func ParseCloudWatch(r io.Reader, group, stream string, opts *stack.Opts) ([]Dump, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		if b, err = ioutil.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+	s := streams{}
+	labels := func(name string) map[string]string {
+		l := map[string]string{}
+		if group != "" {
+			l["logGroupName"] = group
+		}
+		if name == "" {
+			name = stream
+		}
+		if name != "" {
+			l["logStreamName"] = name
+		}
+		return l
+	}
+	if t := bytes.TrimSpace(b); len(t) != 0 && t[0] == '{' {
+		var resp cloudWatchEvents
+		if err := json.Unmarshal(t, &resp); err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Events {
+			t := time.Unix(0, e.Timestamp*int64(time.Millisecond))
+			s.add(labels(e.LogStreamName), entry{time: t, order: e.EventID, text: e.Message})
+		}
+		return s.parse(opts)
+	}
+	// Export format. A message containing new lines continues on the
+	// following lines, which do not start with a timestamp.
+	l := labels("")
+	var cur *entry
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				if cur != nil {
+					s.add(l, *cur)
+				}
+				cur = &entry{time: t, text: line[i+1:]}
+				continue
+			}
+		}
+		if cur != nil {
+			cur.text += "\n" + line
+		}
+	}
+	if cur != nil {
+		s.add(l, *cur)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s.parse(opts)
+}
+
+// Private stuff.
+
+// cloudWatchEvents is the subset of a GetLogEvents or FilterLogEvents
+// response that is used.
+type cloudWatchEvents struct {
+	Events []struct {
+		EventID       string `json:"eventId"`
+		LogStreamName string `json:"logStreamName"`
+		// Timestamp is in milliseconds since the epoch.
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	} `json:"events"`
+}
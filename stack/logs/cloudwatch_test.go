@@ -0,0 +1,78 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestParseCloudWatchEvents(t *testing.T) {
+	t.Parallel()
+	data := `{
+  "events": [
+    {"logStreamName": "b", "timestamp": 1000, "message": "hello", "eventId": "1"},
+    {"logStreamName": "a", "timestamp": 1002, "message": "goroutine 1 [running]:\n", "eventId": "3"},
+    {"logStreamName": "a", "timestamp": 1001, "message": "panic: boom\n", "eventId": "2"},
+    {"logStreamName": "a", "timestamp": 1003, "message": "main.main()", "eventId": "4"},
+    {"logStreamName": "a", "timestamp": 1003, "message": "\t/home/user/src/foo/main.go:10 +0x45", "eventId": "5"}
+  ],
+  "searchedLogStreams": []
+}`
+	dumps, err := ParseCloudWatch(strings.NewReader(data), "/ecs/foo", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 1 {
+		t.Fatalf("want 1 dump, got %d", len(dumps))
+	}
+	d := dumps[0]
+	if d.Labels["logGroupName"] != "/ecs/foo" || d.Labels["logStreamName"] != "a" {
+		t.Fatalf("unexpected labels: %v", d.Labels)
+	}
+	if len(d.Goroutines) != 1 || d.Goroutines[0].Stack.Calls[0].Func.Raw != "main.main" {
+		t.Fatalf("unexpected goroutines: %#v", d.Goroutines)
+	}
+}
+
+func TestParseCloudWatchExport(t *testing.T) {
+	t.Parallel()
+	data := strings.Join([]string{
+		"2020-01-02T03:04:05.000Z starting",
+		"2020-01-02T03:04:06.000Z panic: boom",
+		"2020-01-02T03:04:06.001Z ",
+		"2020-01-02T03:04:06.002Z goroutine 1 [running]:",
+		"2020-01-02T03:04:06.003Z main.main()",
+		"\t/home/user/src/foo/main.go:10 +0x45",
+		"",
+	}, "\n")
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dumps, err := ParseCloudWatch(&buf, "g", "s", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 1 {
+		t.Fatalf("want 1 dump, got %d", len(dumps))
+	}
+	d := dumps[0]
+	if d.Source != "logGroupName=g,logStreamName=s" {
+		t.Fatalf("unexpected source: %q", d.Source)
+	}
+	if len(d.Goroutines) != 1 || d.Goroutines[0].Stack.Calls[0].Line != 10 {
+		t.Fatalf("unexpected goroutines: %#v", d.Goroutines)
+	}
+	if d.Segments[0].Text != "starting\npanic: boom\n\n" {
+		t.Fatalf("unexpected segment: %q", d.Segments[0].Text)
+	}
+}
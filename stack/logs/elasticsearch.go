@@ -0,0 +1,297 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Crash is a stack dump found by a search, along with the fingerprint of the
+// goroutine that crashed.
+//
+// There is one Crash per dump found; crashes are not merged. Group them by
+// Fingerprint to find the same crash across processes.
+type Crash struct {
+	Dump
+	// Fingerprint is the Signature.Fingerprint of the goroutine that crashed.
+	Fingerprint string
+	// Truncated is true when a search returned MaxDocs documents, so the dump
+	// may be incomplete and other dumps may be missing.
+	Truncated bool
+}
+
+// Elasticsearch searches for stack dumps in an Elasticsearch or OpenSearch
+// cluster.
+type Elasticsearch struct {
+	// BaseURL is the cluster, e.g. "http://localhost:9200". It is required.
+	BaseURL string
+	// Index is the index or index pattern to search, e.g. "logs-*". It is
+	// required.
+	Index string
+	// Client is the HTTP client to use. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is added to each request, e.g. "Authorization".
+	Header http.Header
+	// MessageField is the field holding the log line. Defaults to "message".
+	MessageField string
+	// TimestampField is the field holding the time of the log line. Defaults
+	// to "@timestamp".
+	TimestampField string
+	// StreamFields are the fields identifying the output of one process, e.g.
+	// "host.name" and "container.id". When set, each document is a single log
+	// line and the documents surrounding the panic markers are fetched to
+	// reconstruct the dumps. When empty, each document is expected to hold a
+	// whole dump, e.g. as joined by a multiline shipper configuration.
+	StreamFields []string
+	// Window is how far before the first and after the last panic marker of a
+	// stream lines are fetched. Defaults to one minute.
+	Window time.Duration
+	// MaxDocs is the maximum number of documents fetched per search. Defaults
+	// to 10000, the default index.max_result_window. Crash.Truncated is set
+	// when it is reached.
+	MaxDocs int
+}
+
+// Search returns the stack dumps logged over [start, end).
+//
+// query is an optional query_string restricting the search, e.g.
+// `service.name:foo`. The documents are first searched for panic markers like
+// "goroutine" or "panic:"; see StreamFields for how the dumps are then
+// reconstructed.
+//
+// opts can be nil.
+func (e *Elasticsearch) Search(ctx context.Context, query string, start, end time.Time, opts *stack.Opts) ([]Crash, error) {
+	msgField := e.MessageField
+	if msgField == "" {
+		msgField = "message"
+	}
+	markers := make([]interface{}, 0, len(esMarkers))
+	for _, m := range esMarkers {
+		markers = append(markers, obj{"match_phrase": obj{msgField: m}})
+	}
+	filters := []interface{}{
+		e.timeRange(start, end),
+		obj{"bool": obj{"should": markers, "minimum_should_match": 1}},
+	}
+	if query != "" {
+		filters = append(filters, obj{"query_string": obj{"query": query}})
+	}
+	hits, err := e.search(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	// When the markers search is truncated, whole dumps may be missing.
+	missing := len(hits) >= e.maxDocs()
+	s := streams{}
+	if len(e.StreamFields) == 0 {
+		for _, h := range hits {
+			s.add(map[string]string{"_index": h.Index, "_id": h.ID}, e.entry(h))
+		}
+		dumps, err := s.parse(opts)
+		return fingerprint(dumps, missing, nil), err
+	}
+	// Find the time span to fetch for each stream.
+	type span struct {
+		labels      map[string]string
+		first, last time.Time
+	}
+	spans := map[string]*span{}
+	var keys []string
+	for _, h := range hits {
+		labels := e.labels(h)
+		k := labelsKey(labels)
+		t := e.entry(h).time
+		if sp := spans[k]; sp != nil {
+			if t.Before(sp.first) {
+				sp.first = t
+			}
+			if t.After(sp.last) {
+				sp.last = t
+			}
+			continue
+		}
+		spans[k] = &span{labels: labels, first: t, last: t}
+		keys = append(keys, k)
+	}
+	truncated := map[string]bool{}
+	window := e.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	for _, k := range keys {
+		sp := spans[k]
+		f := []interface{}{e.timeRange(sp.first.Add(-window), sp.last.Add(window))}
+		for _, name := range e.StreamFields {
+			f = append(f, obj{"term": obj{name: sp.labels[name]}})
+		}
+		docs, err := e.search(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		truncated[k] = len(docs) >= e.maxDocs()
+		for _, h := range docs {
+			s.add(sp.labels, e.entry(h))
+		}
+	}
+	dumps, err := s.parse(opts)
+	return fingerprint(dumps, missing, truncated), err
+}
+
+// Private stuff.
+
+// esMarkers are the phrases searched for to find the documents of a dump.
+var esMarkers = []string{"goroutine", "panic:", "fatal error:"}
+
+type obj map[string]interface{}
+
+type esHit struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+func (e *Elasticsearch) timeField() string {
+	if e.TimestampField != "" {
+		return e.TimestampField
+	}
+	return "@timestamp"
+}
+
+func (e *Elasticsearch) timeRange(start, end time.Time) obj {
+	return obj{"range": obj{e.timeField(): obj{
+		"gte":    start.UTC().Format(time.RFC3339Nano),
+		"lt":     end.UTC().Format(time.RFC3339Nano),
+		"format": "strict_date_optional_time_nanos",
+	}}}
+}
+
+func (e *Elasticsearch) labels(h *esHit) map[string]string {
+	labels := map[string]string{}
+	for _, name := range e.StreamFields {
+		if v := sourceField(h.Source, name); v != nil {
+			labels[name] = fmt.Sprint(v)
+		}
+	}
+	return labels
+}
+
+func (e *Elasticsearch) entry(h *esHit) entry {
+	msgField := e.MessageField
+	if msgField == "" {
+		msgField = "message"
+	}
+	// The documents are returned sorted by time; ties are kept in the order
+	// returned by the cluster.
+	out := entry{}
+	if v, ok := sourceField(h.Source, msgField).(string); ok {
+		out.text = v
+	}
+	switch v := sourceField(h.Source, e.timeField()).(type) {
+	case string:
+		out.time, _ = time.Parse(time.RFC3339Nano, v)
+	case float64:
+		// epoch_millis.
+		out.time = time.Unix(0, int64(v)*int64(time.Millisecond))
+	}
+	return out
+}
+
+func (e *Elasticsearch) maxDocs() int {
+	if e.MaxDocs > 0 {
+		return e.MaxDocs
+	}
+	return 10000
+}
+
+// search returns the documents matching all the filters, sorted by time, up
+// to MaxDocs.
+func (e *Elasticsearch) search(ctx context.Context, filters []interface{}) ([]*esHit, error) {
+	body, err := json.Marshal(obj{
+		"query": obj{"bool": obj{"filter": filters}},
+		"sort":  []interface{}{obj{e.timeField(): obj{"order": "asc"}}},
+		"size":  e.maxDocs(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimSuffix(e.BaseURL, "/") + "/" + url.PathEscape(e.Index) + "/_search"
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range e.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c := e.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search failed: %s: %s", resp.Status, b)
+	}
+	var out struct {
+		Hits struct {
+			Hits []*esHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %v", err)
+	}
+	return out.Hits.Hits, nil
+}
+
+// sourceField returns the value of a field in a document, either as a
+// dotted key or as nested objects.
+func sourceField(src map[string]interface{}, name string) interface{} {
+	if v, ok := src[name]; ok {
+		return v
+	}
+	i := strings.IndexByte(name, '.')
+	if i == -1 {
+		return nil
+	}
+	if sub, ok := src[name[:i]].(map[string]interface{}); ok {
+		return sourceField(sub, name[i+1:])
+	}
+	return nil
+}
+
+// fingerprint converts each dump into a crash.
+//
+// missing is true when the markers search was truncated, which affects all
+// the dumps. truncated is keyed by labelsKey of the streams whose search was
+// truncated.
+func fingerprint(dumps []Dump, missing bool, truncated map[string]bool) []Crash {
+	if dumps == nil {
+		return nil
+	}
+	out := make([]Crash, 0, len(dumps))
+	for _, d := range dumps {
+		t := missing || truncated[labelsKey(d.Labels)]
+		out = append(out, Crash{Dump: d, Fingerprint: d.Culprit().Fingerprint(), Truncated: t})
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchSearch(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"starting",
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/home/user/src/foo/main.go:10 +0x45",
+	}
+	doc := func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"_index": "logs",
+			"_id":    string(rune('a' + i)),
+			"_source": map[string]interface{}{
+				"@timestamp": time.Unix(100+int64(i), 0).UTC().Format(time.RFC3339Nano),
+				"message":    lines[i],
+				"host":       map[string]interface{}{"name": "h1"},
+			},
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logs-%2A/_search" && r.URL.Path != "/logs-*/_search" {
+			http.NotFound(w, r)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var hits []interface{}
+		if strings.Contains(string(b), `"term"`) {
+			if !strings.Contains(string(b), `{"term":{"host.name":"h1"}}`) {
+				t.Errorf("unexpected query %s", b)
+			}
+			for i := range lines {
+				hits = append(hits, doc(i))
+			}
+		} else {
+			if !strings.Contains(string(b), `"query":"service:foo"`) {
+				t.Errorf("unexpected query %s", b)
+			}
+			hits = append(hits, doc(1), doc(3))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+	}))
+	defer s.Close()
+	e := &Elasticsearch{BaseURL: s.URL, Index: "logs-*", StreamFields: []string{"host.name"}}
+	crashes, err := e.Search(context.Background(), "service:foo", time.Unix(0, 0), time.Unix(1000, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 1 {
+		t.Fatalf("want 1 crash, got %d", len(crashes))
+	}
+	c := crashes[0]
+	if c.Labels["host.name"] != "h1" {
+		t.Fatalf("unexpected labels: %v", c.Labels)
+	}
+	if c.Fingerprint == "" || c.Fingerprint != c.Goroutines[0].Fingerprint() {
+		t.Fatalf("unexpected fingerprint %q", c.Fingerprint)
+	}
+	if c.Segments[0].Text != "starting\npanic: boom\n\n" {
+		t.Fatalf("unexpected segment: %q", c.Segments[0].Text)
+	}
+}
+
+func TestElasticsearchSearchMultiline(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"hits":{"hits":[{"_index":"logs","_id":"x","_source":{"@timestamp":1000,"log":{"msg":"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"}}}]}}`))
+	}))
+	defer s.Close()
+	e := &Elasticsearch{BaseURL: s.URL, Index: "logs", MessageField: "log.msg"}
+	crashes, err := e.Search(context.Background(), "", time.Unix(0, 0), time.Unix(1000, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 1 || crashes[0].Source != "_id=x,_index=logs" || len(crashes[0].Goroutines) != 1 {
+		t.Fatalf("unexpected crashes: %#v", crashes)
+	}
+}
+
+func TestElasticsearchSearchTruncated(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"hits":{"hits":[{"_index":"logs","_id":"x","_source":{"@timestamp":1000,"message":"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"}}]}}`))
+	}))
+	defer s.Close()
+	e := &Elasticsearch{BaseURL: s.URL, Index: "logs", MaxDocs: 1}
+	crashes, err := e.Search(context.Background(), "", time.Unix(0, 0), time.Unix(1000, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 1 || !crashes[0].Truncated {
+		t.Fatalf("unexpected crashes: %#v", crashes)
+	}
+	e.MaxDocs = 2
+	if crashes, err = e.Search(context.Background(), "", time.Unix(0, 0), time.Unix(1000, 0), nil); err != nil || len(crashes) != 1 || crashes[0].Truncated {
+		t.Fatalf("unexpected crashes: %#v, %v", crashes, err)
+	}
+}
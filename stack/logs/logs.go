@@ -0,0 +1,115 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package logs reassembles stack dumps from the entries of hosted logging
+// services.
+//
+// Log collectors usually split the output of a process line by line, so a
+// single panic ends up spread over dozens of entries, possibly interleaved
+// with the output of other processes. The entries are grouped per stream,
+// ordered, concatenated back and then parsed with stack.ParseDumpOpts.
+package logs
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Dump is a stack dump reassembled from the entries of one log stream.
+type Dump struct {
+	// Labels identifies the log stream the dump was found in, e.g. the log
+	// name and the resource labels.
+	Labels map[string]string
+	// Context is the parsed dump. Context.Source is set to a string form of
+	// Labels so dumps of multiple streams can be combined with stack.Merge.
+	*stack.Context
+}
+
+// Private stuff.
+
+// entry is one log line.
+type entry struct {
+	time time.Time
+	// order breaks ties when the timestamps are equal, e.g. the insertId.
+	order string
+	// seq is the position in the input, the last resort tie breaker.
+	seq  int
+	text string
+}
+
+// stream is the entries sharing the same labels.
+type stream struct {
+	labels  map[string]string
+	entries []entry
+}
+
+// streams groups entries by labels.
+type streams map[string]*stream
+
+func (s streams) add(labels map[string]string, e entry) {
+	k := labelsKey(labels)
+	st := s[k]
+	if st == nil {
+		st = &stream{labels: labels}
+		s[k] = st
+	}
+	e.seq = len(st.entries)
+	st.entries = append(st.entries, e)
+}
+
+// parse returns the dumps found in each stream, ordered by stream.
+func (s streams) parse(opts *stack.Opts) ([]Dump, error) {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []Dump
+	for _, k := range keys {
+		st := s[k]
+		sort.SliceStable(st.entries, func(i, j int) bool {
+			a, b := &st.entries[i], &st.entries[j]
+			if !a.time.Equal(b.time) {
+				return a.time.Before(b.time)
+			}
+			if a.order != b.order {
+				return a.order < b.order
+			}
+			return a.seq < b.seq
+		})
+		var b strings.Builder
+		for _, e := range st.entries {
+			b.WriteString(e.text)
+			if !strings.HasSuffix(e.text, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+		c, err := stack.ParseDumpOpts(strings.NewReader(b.String()), nil, opts)
+		if err != nil {
+			return out, err
+		}
+		if c == nil {
+			continue
+		}
+		c.Source = k
+		out = append(out, Dump{Labels: st.labels, Context: c})
+	}
+	return out, nil
+}
+
+// labelsKey returns a stable string form of labels, e.g. "a=1,b=2".
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + labels[k]
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Loki queries a Grafana Loki server.
+type Loki struct {
+	// BaseURL is the Loki server, e.g. "http://loki:3100". It is required.
+	BaseURL string
+	// Client is the HTTP client to use. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Header is added to each request, e.g. "Authorization" or
+	// "X-Scope-OrgID" for a multi-tenant server.
+	Header http.Header
+	// Limit is the number of lines to fetch per request. Defaults to 5000.
+	Limit int
+}
+
+// Query runs a LogQL query over [start, end) and returns the stack dumps
+// found, one per label set containing one. The labels of the stream are
+// returned as Dump.Labels.
+//
+// The query must return the whole output of the processes, e.g.
+// `{app="foo"}`; a line filter like `|= "panic"` would only return the first
+// line of each dump. Results are fetched in pages of Limit lines, which must be
+// larger than the number of lines logged with the same timestamp.
+//
+// opts can be nil.
+func (l *Loki) Query(ctx context.Context, query string, start, end time.Time, opts *stack.Opts) ([]Dump, error) {
+	limit := l.Limit
+	if limit <= 0 {
+		limit = 5000
+	}
+	s := streams{}
+	// seen is the lines at the last timestamp of the previous page, since the
+	// next page starts at this timestamp.
+	var seen map[string]bool
+	for {
+		resp, err := l.get(ctx, query, start, end, limit)
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		var last time.Time
+		for _, r := range resp.Data.Result {
+			k := labelsKey(r.Stream)
+			for _, v := range r.Values {
+				n++
+				ns, err := strconv.ParseInt(v[0], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid loki timestamp %q: %v", v[0], err)
+				}
+				t := time.Unix(0, ns)
+				if t.After(last) {
+					last = t
+				}
+				if t.Equal(start) && seen[k+"\x00"+v[1]] {
+					continue
+				}
+				s.add(r.Stream, entry{time: t, text: v[1]})
+			}
+		}
+		if n < limit {
+			break
+		}
+		if !last.After(start) {
+			// The whole page has the same timestamp; skip ahead to not loop
+			// forever. Lines beyond Limit at this timestamp are lost.
+			start = start.Add(time.Nanosecond)
+			seen = nil
+			continue
+		}
+		seen = map[string]bool{}
+		for _, r := range resp.Data.Result {
+			k := labelsKey(r.Stream)
+			for _, v := range r.Values {
+				if ns, _ := strconv.ParseInt(v[0], 10, 64); ns == last.UnixNano() {
+					seen[k+"\x00"+v[1]] = true
+				}
+			}
+		}
+		start = last
+	}
+	return s.parse(opts)
+}
+
+// Private stuff.
+
+// lokiResponse is the subset of a query_range response that is used.
+type lokiResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			// Values is pairs of nanoseconds timestamp and line.
+			Values [][2]string `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func (l *Loki) get(ctx context.Context, query string, start, end time.Time, limit int) (*lokiResponse, error) {
+	v := url.Values{
+		"query":     {query},
+		"start":     {strconv.FormatInt(start.UnixNano(), 10)},
+		"end":       {strconv.FormatInt(end.UnixNano(), 10)},
+		"limit":     {strconv.Itoa(limit)},
+		"direction": {"forward"},
+	}
+	req, err := http.NewRequest("GET", l.BaseURL+"/loki/api/v1/query_range?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, vals := range l.Header {
+		req.Header[k] = vals
+	}
+	c := l.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("loki query failed: %s: %s", resp.Status, b)
+	}
+	out := &lokiResponse{}
+	if err := json.Unmarshal(b, out); err != nil {
+		return nil, fmt.Errorf("failed to decode loki response: %v", err)
+	}
+	if out.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("loki query must return streams, got %q", out.Data.ResultType)
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLokiQuery(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/home/user/src/foo/main.go:10 +0x45",
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/query_range" || r.Header.Get("X-Scope-OrgID") != "tenant" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("query") != `{app="foo"}` || q.Get("direction") != "forward" {
+			t.Errorf("unexpected query %v", q)
+		}
+		start, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		// Lines 1 and 2 share the same timestamp to exercise the page
+		// boundary.
+		var values [][2]string
+		for i, l := range lines {
+			ts := int64(1000 + i)
+			if i >= 2 {
+				ts--
+			}
+			if ts >= start && len(values) < limit {
+				values = append(values, [2]string{strconv.FormatInt(ts, 10), l})
+			}
+		}
+		resp := map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "streams",
+				"result": []interface{}{
+					map[string]interface{}{"stream": map[string]string{"app": "foo", "pod": "foo-1"}, "values": values},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer s.Close()
+	l := &Loki{BaseURL: s.URL, Header: http.Header{"X-Scope-Orgid": {"tenant"}}, Limit: 2}
+	dumps, err := l.Query(context.Background(), `{app="foo"}`, time.Unix(0, 0), time.Unix(0, 2000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 1 {
+		t.Fatalf("want 1 dump, got %d", len(dumps))
+	}
+	d := dumps[0]
+	if d.Labels["pod"] != "foo-1" || d.Source != "app=foo,pod=foo-1" {
+		t.Fatalf("unexpected labels: %v", d.Labels)
+	}
+	if len(d.Goroutines) != 1 || d.Goroutines[0].Stack.Calls[0].Line != 10 {
+		t.Fatalf("unexpected goroutines: %#v", d.Goroutines)
+	}
+	if d.Segments[0].Text != "panic: boom\n\n" {
+		t.Fatalf("unexpected segment: %q", d.Segments[0].Text)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// Merge combines the goroutines of multiple dumps, for example from multiple
+// processes or hosts, into a single Context.
+//
+// Each goroutine is copied and its Source is set to the Source of the Context
+// it comes from, unless it was already set by a previous Merge. Goroutine IDs
+// are kept as-is, so the same ID may appear multiple times; the pair (Source,
+// ID) identifies a goroutine. Give each Context a distinct Source to keep
+// them apart.
+//
+// Segments are concatenated, with Segment.Before adjusted to the merged
+// goroutines. GOROOT is kept only if it is the same for all the dumps, GOPATHs
+// are combined. Crash is the first crash found, if any. Message is the one of
+// the dump that crashed, otherwise the first one found.
+//
+// Nil contexts are ignored. Returns nil if there is no goroutine.
+func Merge(ctxs ...*Context) *Context {
+	out := &Context{}
+	goroot := ""
+	first := true
+	for _, c := range ctxs {
+		if c == nil {
+			continue
+		}
+		offset := len(out.Goroutines)
+		for _, g := range c.Goroutines {
+			n := *g
+			if n.Source == "" {
+				n.Source = c.Source
+			}
+			out.Goroutines = append(out.Goroutines, &n)
+		}
+		for _, s := range c.Segments {
+			s.Before += offset
+			out.Segments = append(out.Segments, s)
+		}
+		if first {
+			out.localgoroot = c.localgoroot
+			out.localgopaths = c.localgopaths
+			goroot = c.GOROOT
+			first = false
+		} else if goroot != c.GOROOT {
+			goroot = ""
+		}
+		for k, v := range c.GOPATHs {
+			if out.GOPATHs == nil {
+				out.GOPATHs = map[string]string{}
+			}
+			out.GOPATHs[k] = v
+		}
+		if !out.Crash.IsCrash() && c.Crash != CrashNone {
+			out.Crash = c.Crash
+			if c.Message != nil {
+				out.Message = c.Message
+			}
+		}
+		if out.Message == nil {
+			out.Message = c.Message
+		}
+	}
+	if len(out.Goroutines) == 0 {
+		return nil
+	}
+	out.GOROOT = goroot
+	return out
+}
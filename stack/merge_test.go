@@ -0,0 +1,61 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"panic: oh no",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/gopath/src/foo/main.go:10 +0x1",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.worker()",
+		"	/gopath/src/foo/main.go:20 +0x1",
+		"created by main.main in goroutine 1",
+		"	/gopath/src/foo/main.go:9 +0x1",
+		"",
+	}
+	a, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseDump(strings.NewReader(strings.Join(data[2:], "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Source = "host1"
+	b.Source = "host2"
+	if Merge() != nil || Merge(nil) != nil {
+		t.Fatal("expected nil")
+	}
+	c := Merge(a, nil, b)
+	if len(c.Goroutines) != 4 {
+		t.Fatalf("expected 4 goroutines, got %d", len(c.Goroutines))
+	}
+	if c.Goroutines[1].Source != "host1" || c.Goroutines[3].Source != "host2" || a.Goroutines[0].Source != "" {
+		t.Fatal("unexpected Source")
+	}
+	if c.Crash != CrashPanic || len(c.Segments) != 1 || c.Segments[0].Before != 0 {
+		t.Fatalf("unexpected Crash %s or Segments %v", c.Crash, c.Segments)
+	}
+	// Duplicate IDs are resolved with Source.
+	if c.Creator(c.Goroutines[3]) != c.Goroutines[2] {
+		t.Fatal("unexpected Creator")
+	}
+	if len(c.Children(c.Goroutines[0])) != 1 || len(c.Roots()) != 2 {
+		t.Fatal("unexpected creation tree")
+	}
+	if b := Aggregate(c.Goroutines, AnyPointer); len(b) != 2 || len(b[0].IDs) != 2 {
+		t.Fatalf("unexpected buckets: %v", b)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MessageKind is the class of the message printed before the goroutines.
+type MessageKind int
+
+const (
+	// MessagePanic is an unrecovered panic, e.g. "panic: oh no".
+	MessagePanic MessageKind = iota
+	// MessageFatal is a fatal error thrown by the runtime, e.g. "fatal error:
+	// concurrent map writes".
+	MessageFatal
+	// MessageSignal is a fatal signal received while not running Go code, e.g.
+	// "SIGSEGV: segmentation violation", or SIGQUIT.
+	MessageSignal
+	// MessageRace is a report of the race detector, "WARNING: DATA RACE".
+	MessageRace
+)
+
+func (m MessageKind) String() string {
+	switch m {
+	case MessageFatal:
+		return "fatal"
+	case MessageSignal:
+		return "signal"
+	case MessageRace:
+		return "race"
+	default:
+		return "panic"
+	}
+}
+
+// PanicMessage is the message printed before the goroutines, telling why the
+// process died, decoded from Context.Segments.
+type PanicMessage struct {
+	// Kind is the class of the message.
+	Kind MessageKind
+	// Value is the message without its prefix, e.g. "concurrent map writes"
+	// or "segmentation violation". For a panic, it is Chain[len(Chain)-1].Raw,
+	// the value that crashed the process. For a race, it is the first access,
+	// e.g. "Write at 0x00c0000a0018 by goroutine 7".
+	Value string
+	// Chain is the panics in the order printed, for MessagePanic: the ones
+	// that were recovered then panicked again first, the value that crashed
+	// the process last.
+	Chain []*PanicValue
+	// Signal is the signal that caused the crash. It is set for MessageSignal
+	// and when a panic or a fatal error was caused by a signal, e.g. a nil
+	// pointer dereference. It is nil otherwise.
+	Signal *SignalInfo
+	// Lines is the lines of the message, verbatim.
+	Lines []string
+}
+
+// Reason returns the human readable reason of the crash: the message of the
+// panic that crashed the process, otherwise Value.
+func (p *PanicMessage) Reason() string {
+	if len(p.Chain) != 0 {
+		return p.Chain[len(p.Chain)-1].Message
+	}
+	return p.Value
+}
+
+// SignalInfo is a signal as printed by the runtime, e.g. "[signal SIGSEGV:
+// segmentation violation code=0x1 addr=0x0 pc=0x48f0b7]".
+type SignalInfo struct {
+	// Name is the name of the signal, e.g. "SIGSEGV".
+	Name string
+	// Description is the description of the signal, e.g. "segmentation
+	// violation".
+	Description string
+	// Code is the signal code, i.e. si_code on unix.
+	Code uint64
+	// Addr is the faulting address. It is 0 when not printed.
+	Addr uint64
+	// PC is the program counter at the time of the signal. It is 0 when not
+	// printed.
+	PC uint64
+}
+
+// ParsePanicMessage decodes the first message telling why the process died
+// found in the text, e.g. the text preceding the goroutines.
+//
+// A panic, a fatal error or a signal takes precedence over a race report,
+// since the race detector doesn't stop the process by default. Returns nil if
+// no message was found.
+func ParsePanicMessage(text string) *PanicMessage {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	var race *PanicMessage
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.HasPrefix(l, "panic: "):
+			return parsePanicChain(lines[i:])
+		case strings.HasPrefix(l, "fatal error: "):
+			out := &PanicMessage{Kind: MessageFatal, Value: l[len("fatal error: "):], Lines: []string{l}}
+			out.parseSignalLine(lines[i+1:])
+			return out
+		case reSignalHeader.MatchString(l):
+			return parseSignalHeader(lines[i:])
+		case race == nil && l == raceHeader:
+			race = &PanicMessage{Kind: MessageRace, Value: "DATA RACE", Lines: []string{l}}
+			if i+1 < len(lines) {
+				if m := reRaceAccess.FindStringSubmatch(lines[i+1]); m != nil {
+					race.Value = m[1]
+					race.Lines = append(race.Lines, lines[i+1])
+				}
+			}
+		}
+	}
+	return race
+}
+
+// Private stuff.
+
+var (
+	// reSignalHeader matches the first line printed by sighandler() in package
+	// runtime, e.g. "SIGSEGV: segmentation violation".
+	reSignalHeader = regexp.MustCompile(`^(SIG[A-Z0-9]+): (.+)$`)
+	// reSignalRegs matches the line following reSignalHeader, e.g.
+	// "PC=0x7f0 m=0 sigcode=1 addr=0x0".
+	reSignalRegs = regexp.MustCompile(`^PC=(0x[0-9a-f]+) m=\d+ sigcode=(\d+)(?: addr=(0x[0-9a-f]+))?`)
+	// reSignalLine matches the line printed after a panic or a fatal error
+	// caused by a signal. See sigpanic() and printSignalInfo() in package
+	// runtime.
+	reSignalLine = regexp.MustCompile(`^\[signal (SIG[A-Z0-9]+): ([^\]]+?) code=(0x[0-9a-f]+|\d+) addr=(0x[0-9a-f]+) pc=(0x[0-9a-f]+)\]$`)
+	// reRaceAccess matches the first access of a race report.
+	reRaceAccess = regexp.MustCompile(`^((?:Read|Write) at 0x[0-9a-f]+ by (?:goroutine \d+|main goroutine)):$`)
+)
+
+// parsePanicChain decodes the panics printed by printpanics() in package
+// runtime, starting with lines[0]. The nested panics are indented with a tab.
+//
+// Since Go 1.18 a multiline panic value has its following lines indented
+// with a tab too, so they are appended to the current value.
+func parsePanicChain(lines []string) *PanicMessage {
+	out := &PanicMessage{Kind: MessagePanic}
+	var cur []string
+	flush := func() {
+		if cur != nil {
+			out.Chain = append(out.Chain, ParsePanicValue(strings.Join(cur, "\n")))
+		}
+	}
+	i := 0
+	for ; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case i == 0:
+			cur = []string{l[len("panic: "):]}
+		case strings.HasPrefix(l, "\tpanic: "):
+			flush()
+			cur = []string{l[len("\tpanic: "):]}
+		case strings.HasPrefix(l, "\t"):
+			cur = append(cur, l[1:])
+		default:
+			flush()
+			out.Lines = lines[:i]
+			out.Value = out.Chain[len(out.Chain)-1].Raw
+			out.parseSignalLine(lines[i:])
+			return out
+		}
+	}
+	flush()
+	out.Lines = lines[:i]
+	out.Value = out.Chain[len(out.Chain)-1].Raw
+	return out
+}
+
+// parseSignalHeader decodes the message printed by sighandler() in package
+// runtime, starting with lines[0].
+func parseSignalHeader(lines []string) *PanicMessage {
+	m := reSignalHeader.FindStringSubmatch(lines[0])
+	out := &PanicMessage{
+		Kind:   MessageSignal,
+		Value:  m[2],
+		Signal: &SignalInfo{Name: m[1], Description: m[2]},
+		Lines:  lines[:1],
+	}
+	if len(lines) > 1 {
+		if r := reSignalRegs.FindStringSubmatch(lines[1]); r != nil {
+			out.Signal.PC, _ = strconv.ParseUint(r[1], 0, 64)
+			out.Signal.Code, _ = strconv.ParseUint(r[2], 10, 64)
+			if r[3] != "" {
+				out.Signal.Addr, _ = strconv.ParseUint(r[3], 0, 64)
+			}
+			out.Lines = lines[:2]
+		}
+	}
+	return out
+}
+
+// parseSignalLine decodes the signal line following a panic or a fatal
+// error, if lines starts with one.
+func (p *PanicMessage) parseSignalLine(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	m := reSignalLine.FindStringSubmatch(lines[0])
+	if m == nil {
+		return
+	}
+	p.Signal = &SignalInfo{Name: m[1], Description: m[2]}
+	p.Signal.Code, _ = strconv.ParseUint(m[3], 0, 64)
+	p.Signal.Addr, _ = strconv.ParseUint(m[4], 0, 64)
+	p.Signal.PC, _ = strconv.ParseUint(m[5], 0, 64)
+	p.Lines = append(p.Lines[:len(p.Lines):len(p.Lines)], lines[0])
+}
+
+// findPanicMessage decodes the first message found in segments, see
+// ParsePanicMessage.
+func findPanicMessage(segments []Segment) *PanicMessage {
+	if len(segments) == 0 {
+		return nil
+	}
+	texts := make([]string, len(segments))
+	for i := range segments {
+		texts[i] = segments[i].Text
+	}
+	return ParsePanicMessage(strings.Join(texts, ""))
+}
+
+// clone returns a deep copy.
+func (p *PanicMessage) clone() *PanicMessage {
+	out := *p
+	if p.Chain != nil {
+		out.Chain = make([]*PanicValue, len(p.Chain))
+		for i, v := range p.Chain {
+			c := *v
+			c.Fields = append([]PanicField(nil), v.Fields...)
+			out.Chain[i] = &c
+		}
+	}
+	if p.Signal != nil {
+		s := *p.Signal
+		out.Signal = &s
+	}
+	out.Lines = append([]string(nil), p.Lines...)
+	return &out
+}
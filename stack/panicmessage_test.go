@@ -0,0 +1,120 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePanicMessage(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		in   string
+		want *PanicMessage
+	}{
+		{"none", "log line\n", nil},
+		{
+			"panic",
+			"log line\npanic: oh no\n\n",
+			&PanicMessage{
+				Kind:  MessagePanic,
+				Value: "oh no",
+				Chain: []*PanicValue{{Raw: "oh no", Message: "oh no"}},
+				Lines: []string{"panic: oh no"},
+			},
+		},
+		{
+			"recovered",
+			"panic: first [recovered]\n\tpanic: runtime error: invalid memory address or nil pointer dereference\n[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x48f0b7]\n\n",
+			&PanicMessage{
+				Kind:  MessagePanic,
+				Value: "runtime error: invalid memory address or nil pointer dereference",
+				Chain: []*PanicValue{
+					{Raw: "first", Message: "first", Recovered: true},
+					{Raw: "runtime error: invalid memory address or nil pointer dereference", Kind: PanicError, Type: "runtime.Error", Message: "runtime error: invalid memory address or nil pointer dereference"},
+				},
+				Signal: &SignalInfo{Name: "SIGSEGV", Description: "segmentation violation", Code: 1, PC: 0x48f0b7},
+				Lines: []string{
+					"panic: first [recovered]",
+					"\tpanic: runtime error: invalid memory address or nil pointer dereference",
+					"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x48f0b7]",
+				},
+			},
+		},
+		{
+			"multiline",
+			"panic: line 1\n\tline 2\n\n",
+			&PanicMessage{
+				Kind:  MessagePanic,
+				Value: "line 1\nline 2",
+				Chain: []*PanicValue{{Raw: "line 1\nline 2", Message: "line 1\nline 2"}},
+				Lines: []string{"panic: line 1", "\tline 2"},
+			},
+		},
+		{
+			"fatal",
+			"fatal error: concurrent map writes\n\n",
+			&PanicMessage{Kind: MessageFatal, Value: "concurrent map writes", Lines: []string{"fatal error: concurrent map writes"}},
+		},
+		{
+			"signal",
+			"SIGSEGV: segmentation violation\r\nPC=0x7f0 m=0 sigcode=1 addr=0x10\r\n",
+			&PanicMessage{
+				Kind:   MessageSignal,
+				Value:  "segmentation violation",
+				Signal: &SignalInfo{Name: "SIGSEGV", Description: "segmentation violation", Code: 1, Addr: 0x10, PC: 0x7f0},
+				Lines:  []string{"SIGSEGV: segmentation violation", "PC=0x7f0 m=0 sigcode=1 addr=0x10"},
+			},
+		},
+		{
+			"race",
+			"==================\nWARNING: DATA RACE\nWrite at 0x00c0000a0018 by goroutine 7:\n  main.main.func1()\n",
+			&PanicMessage{
+				Kind:  MessageRace,
+				Value: "Write at 0x00c0000a0018 by goroutine 7",
+				Lines: []string{"WARNING: DATA RACE", "Write at 0x00c0000a0018 by goroutine 7:"},
+			},
+		},
+		{
+			"race_then_fatal",
+			"WARNING: DATA RACE\nRead at 0x00c0000a0018 by main goroutine:\nfatal error: concurrent map writes\n",
+			&PanicMessage{Kind: MessageFatal, Value: "concurrent map writes", Lines: []string{"fatal error: concurrent map writes"}},
+		},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(line.want, ParsePanicMessage(line.in)); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextMessage(t *testing.T) {
+	t.Parallel()
+	in := "panic: oh no [recovered]\n\tpanic: again\n\ngoroutine 1 [running]:\nmain.main()\n\t/gopath/src/foo/main.go:10 +0x1\n"
+	c, err := ParseDump(strings.NewReader(in), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Message == nil || c.Message.Kind != MessagePanic || c.Message.Reason() != "again" || len(c.Message.Chain) != 2 {
+		t.Fatalf("unexpected message: %#v", c.Message)
+	}
+	// Panic is the value that crashed the process, like Reason.
+	if c.Panic == nil || c.Panic.Message != c.Message.Reason() {
+		t.Fatalf("unexpected panic: %#v", c.Panic)
+	}
+	clone := c.Clone()
+	clone.Message.Chain[0].Raw = "changed"
+	if c.Message.Chain[0].Raw != "oh no" {
+		t.Fatal("Clone aliased Message")
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PanicValueKind is the class of the value passed to panic().
+type PanicValueKind int
+
+const (
+	// PanicText is a value printed as plain text: either a string or the
+	// result of Error() or String(), which can't be told apart.
+	PanicText PanicValueKind = iota
+	// PanicError is an error: a runtime.Error, e.g. "runtime error: index out
+	// of range", or a value printed in Go syntax whose type is an error, e.g.
+	// &errors.errorString{s:"boom"}.
+	PanicError
+	// PanicCustom is a value of a custom type, e.g. main.MyErr{Code:5} or
+	// main.S("boom").
+	PanicCustom
+)
+
+func (p PanicValueKind) String() string {
+	switch p {
+	case PanicError:
+		return "error"
+	case PanicCustom:
+		return "custom"
+	default:
+		return "text"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p PanicValueKind) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *PanicValueKind) UnmarshalText(b []byte) error {
+	for v := PanicText; v <= PanicCustom; v++ {
+		if v.String() == string(b) {
+			*p = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown panic value kind %q", b)
+}
+
+// PanicValue is the value passed to panic(), decoded from the "panic: "
+// line.
+type PanicValue struct {
+	// Raw is the value as printed, without " [recovered]".
+	Raw string `json:"raw"`
+	// Kind is the class of the value.
+	Kind PanicValueKind `json:"kind"`
+	// Type is the type of the value, e.g. "*errors.errorString" or
+	// "main.MyErr". It is "runtime.Error" for runtime errors and usually
+	// empty for PanicText, as the runtime doesn't print it.
+	Type string `json:"type,omitempty"`
+	// Fields is the fields of a value printed as a composite literal, e.g.
+	// main.MyErr{Code:5}. Name is empty for unkeyed fields.
+	Fields []PanicField `json:"fields,omitempty"`
+	// Value is the underlying value of a basic custom type, e.g. `"boom"` for
+	// main.S("boom"), or the address for a type printed as
+	// "(main.T) 0xc000010000". It is verbatim, so strings are quoted.
+	Value string `json:"value,omitempty"`
+	// Message is the human readable message: the unquoted string when the
+	// value wraps a single string, otherwise Raw.
+	Message string `json:"message"`
+	// Recovered is set when the panic was recovered and panicked again.
+	Recovered bool `json:"recovered,omitempty"`
+}
+
+// PanicField is a field of a value printed in Go syntax.
+type PanicField struct {
+	// Name is the field name. It is empty for an unkeyed field.
+	Name string `json:"name,omitempty"`
+	// Value is the value verbatim, e.g. `"boom"` or `5`.
+	Value string `json:"value"`
+}
+
+// ParsePanicValue decodes the text following "panic: ".
+//
+// Values printed in Go syntax, e.g. with fmt's %#v, or by the runtime for
+// custom types are decoded into Type, Fields and Value. Anything else is
+// PanicText, unless it is a runtime error.
+func ParsePanicValue(s string) *PanicValue {
+	out := &PanicValue{Raw: s}
+	if m := rePanicRecovered.FindStringIndex(out.Raw); m != nil {
+		out.Raw = out.Raw[:m[0]]
+		out.Recovered = true
+	}
+	out.Message = out.Raw
+	switch {
+	case strings.HasPrefix(out.Raw, "runtime error: "):
+		out.Kind = PanicError
+		out.Type = "runtime.Error"
+	case parseOpaqueValue(out):
+	case parseGoValue(out):
+	}
+	return out
+}
+
+// Private stuff.
+
+var (
+	// rePanicRecovered matches the suffix printed for a panic that was
+	// recovered then panicked again.
+	rePanicRecovered = regexp.MustCompile(` \[recovered(?:, repanicked)?\]$`)
+	// rePanicOpaque matches a value of a type the runtime can't print, e.g.
+	// "(main.T) 0xc000010000" or "(*main.T) 0xc000010000". See
+	// printpanicval() in package runtime.
+	rePanicOpaque = regexp.MustCompile(`^\((\*?[\w/.\[\]]+\.[\w\[\],.]+)\) (0x[0-9a-f]+)$`)
+	// rePanicGoValue is a quick check for a value starting with a qualified
+	// type name, e.g. "&errors.errorString{" or "main.S(".
+	rePanicGoValue = regexp.MustCompile(`^&?\w+\.\w+[{(]`)
+)
+
+// parseOpaqueValue decodes a value printed as its type and address.
+func parseOpaqueValue(p *PanicValue) bool {
+	m := rePanicOpaque.FindStringSubmatch(p.Raw)
+	if m == nil {
+		return false
+	}
+	p.Type = m[1]
+	p.Value = m[2]
+	p.Kind = panicKindOf(p.Type)
+	return true
+}
+
+// parseGoValue decodes a value printed in Go syntax.
+func parseGoValue(p *PanicValue) bool {
+	if !rePanicGoValue.MatchString(p.Raw) {
+		return false
+	}
+	e, err := parser.ParseExpr(p.Raw)
+	if err != nil {
+		return false
+	}
+	ptr := false
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		ptr = true
+		e = u.X
+	}
+	var fields []PanicField
+	value := ""
+	var typ ast.Expr
+	switch v := e.(type) {
+	case *ast.CompositeLit:
+		typ = v.Type
+		for _, el := range v.Elts {
+			if kv, ok := el.(*ast.KeyValueExpr); ok {
+				fields = append(fields, PanicField{Name: p.Raw[kv.Key.Pos()-1 : kv.Key.End()-1], Value: p.Raw[kv.Value.Pos()-1 : kv.Value.End()-1]})
+			} else {
+				fields = append(fields, PanicField{Value: p.Raw[el.Pos()-1 : el.End()-1]})
+			}
+		}
+	case *ast.CallExpr:
+		if ptr || len(v.Args) != 1 {
+			return false
+		}
+		typ = v.Fun
+		value = p.Raw[v.Args[0].Pos()-1 : v.Args[0].End()-1]
+	default:
+		return false
+	}
+	sel, ok := typ.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if _, ok := sel.X.(*ast.Ident); !ok {
+		return false
+	}
+	p.Type = p.Raw[typ.Pos()-1 : typ.End()-1]
+	if ptr {
+		p.Type = "*" + p.Type
+	}
+	p.Fields = fields
+	p.Value = value
+	p.Kind = panicKindOf(p.Type)
+	// Use the string as the message when it is the only data, e.g.
+	// errors.errorString.
+	if len(fields) == 1 {
+		value = fields[0].Value
+	}
+	if s, err := strconv.Unquote(value); err == nil {
+		p.Message = s
+	}
+	return true
+}
+
+// knownErrorTypes is the unexported error types of the standard library.
+var knownErrorTypes = map[string]bool{
+	"errors.errorString": true,
+	"fmt.wrapError":      true,
+	"fmt.wrapErrors":     true,
+	"errors.joinError":   true,
+}
+
+// panicKindOf classifies a custom type by its name, as the method set is not
+// printed.
+func panicKindOf(t string) PanicValueKind {
+	t = strings.TrimPrefix(t, "*")
+	if knownErrorTypes[t] {
+		return PanicError
+	}
+	name := t[strings.LastIndexByte(t, '.')+1:]
+	if strings.HasSuffix(name, "Error") || strings.HasSuffix(name, "Err") {
+		return PanicError
+	}
+	return PanicCustom
+}
+
+// crashValue returns the value of the panic that crashed the process, the
+// last of the chain. Returns nil if m is nil or not a panic.
+func (m *PanicMessage) crashValue() *PanicValue {
+	if m == nil || len(m.Chain) == 0 {
+		return nil
+	}
+	return m.Chain[len(m.Chain)-1]
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePanicValue(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want PanicValue
+	}{
+		{
+			"oh no",
+			PanicValue{Raw: "oh no", Message: "oh no"},
+		},
+		{
+			"oh no [recovered]",
+			PanicValue{Raw: "oh no", Message: "oh no", Recovered: true},
+		},
+		{
+			"runtime error: index out of range [3] with length 1",
+			PanicValue{Raw: "runtime error: index out of range [3] with length 1", Kind: PanicError, Type: "runtime.Error", Message: "runtime error: index out of range [3] with length 1"},
+		},
+		{
+			`&errors.errorString{s:"boom"}`,
+			PanicValue{
+				Raw:     `&errors.errorString{s:"boom"}`,
+				Kind:    PanicError,
+				Type:    "*errors.errorString",
+				Fields:  []PanicField{{Name: "s", Value: `"boom"`}},
+				Message: "boom",
+			},
+		},
+		{
+			`main.MyErr{Code:5, Msg:"a, b", Inner:main.T{X:1}}`,
+			PanicValue{
+				Raw:     `main.MyErr{Code:5, Msg:"a, b", Inner:main.T{X:1}}`,
+				Kind:    PanicError,
+				Type:    "main.MyErr",
+				Fields:  []PanicField{{Name: "Code", Value: "5"}, {Name: "Msg", Value: `"a, b"`}, {Name: "Inner", Value: "main.T{X:1}"}},
+				Message: `main.MyErr{Code:5, Msg:"a, b", Inner:main.T{X:1}}`,
+			},
+		},
+		{
+			"main.Pair{1, 2}",
+			PanicValue{Raw: "main.Pair{1, 2}", Kind: PanicCustom, Type: "main.Pair", Fields: []PanicField{{Value: "1"}, {Value: "2"}}, Message: "main.Pair{1, 2}"},
+		},
+		{
+			`main.S("boom")`,
+			PanicValue{Raw: `main.S("boom")`, Kind: PanicCustom, Type: "main.S", Value: `"boom"`, Message: "boom"},
+		},
+		{
+			"main.Code(5)",
+			PanicValue{Raw: "main.Code(5)", Kind: PanicCustom, Type: "main.Code", Value: "5", Message: "main.Code(5)"},
+		},
+		{
+			"(*main.T) 0xc000010000",
+			PanicValue{Raw: "(*main.T) 0xc000010000", Kind: PanicCustom, Type: "*main.T", Value: "0xc000010000", Message: "(*main.T) 0xc000010000"},
+		},
+		{
+			// Looks like Go syntax but isn't.
+			"main.Foo{ is broken",
+			PanicValue{Raw: "main.Foo{ is broken", Message: "main.Foo{ is broken"},
+		},
+		{
+			"os.Exit(1) was called",
+			PanicValue{Raw: "os.Exit(1) was called", Message: "os.Exit(1) was called"},
+		},
+	}
+	for i, line := range data {
+		if diff := cmp.Diff(&line.want, ParsePanicValue(line.in)); diff != "" {
+			t.Errorf("#%d: ParsePanicValue(%q) mismatch (-want +got):\n%s", i, line.in, diff)
+		}
+	}
+}
+
+func TestParseDumpPanicValue(t *testing.T) {
+	t.Parallel()
+	const dump = "panic: other [recovered]\n\tpanic: main.S(\"boom\")\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	c, err := ParseDump(strings.NewReader(dump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PanicValue{Raw: `main.S("boom")`, Kind: PanicCustom, Type: "main.S", Value: `"boom"`, Message: "boom"}
+	if diff := cmp.Diff(want, c.Panic); diff != "" {
+		t.Fatalf("Panic mismatch (-want +got):\n%s", diff)
+	}
+	c, err = ParseDump(strings.NewReader("goroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Panic != nil {
+		t.Fatalf("unexpected Panic %#v", c.Panic)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// PathRule rewrites a synthetic source path generated by a build system into a
+// path relative to the workspace root.
+//
+// For example Bazel builds in a sandbox so the source paths embedded in the
+// binary look like
+// "/home/user/.cache/bazel/_bazel_user/<hash>/sandbox/linux-sandbox/1/execroot/<workspace>/foo/bar.go"
+// while the file is "foo/bar.go" in the workspace.
+type PathRule struct {
+	// Name identifies the rule.
+	Name string
+	// Match is matched against the source path, using "/" as path separator.
+	Match *regexp.Regexp
+	// Replace is the replacement template, as used by
+	// regexp.Regexp.ReplaceAllString. The result must be a relative path.
+	Replace string
+}
+
+// RegisterPathRule adds a rule to the registry of source path rewriting
+// rules.
+//
+// Rules are tried in the order they were registered; the first one matching
+// wins. The default rules, covering /proc/self/cwd, Bazel and Please, are
+// registered first.
+func RegisterPathRule(r PathRule) {
+	pathRulesMu.Lock()
+	defer pathRulesMu.Unlock()
+	pathRules = append(pathRules, r)
+}
+
+// PathRules returns a copy of the registered source path rewriting rules.
+func PathRules() []PathRule {
+	pathRulesMu.Lock()
+	defer pathRulesMu.Unlock()
+	return append([]PathRule(nil), pathRules...)
+}
+
+// Private stuff.
+
+var (
+	pathRulesMu sync.Mutex
+	pathRules   = []PathRule{
+		{
+			// Used by Bazel and others when the compiler is run with its current
+			// directory as the workspace root.
+			Name:    "proc-self-cwd",
+			Match:   regexp.MustCompile(`^/proc/self/cwd/(.+)$`),
+			Replace: "$1",
+		},
+		{
+			// Bazel, including sandboxed builds. Generated files are in
+			// bazel-out/<config>/bin/.
+			Name:    "bazel-execroot",
+			Match:   regexp.MustCompile(`^.*/execroot/[^/]+/(?:bazel-out/[^/]+/bin/)?(.+)$`),
+			Replace: "$1",
+		},
+		{
+			// Please builds in plz-out/tmp/<target>._build/ and generates files in
+			// plz-out/gen/.
+			Name:    "please",
+			Match:   regexp.MustCompile(`^(?:.*/)?plz-out/(?:tmp/.+?\._build|gen)/(.+)$`),
+			Replace: "$1",
+		},
+	}
+)
+
+// rewritePath applies the first matching registered rule to p.
+func rewritePath(p string) (string, bool) {
+	p = strings.Replace(p, "\\", "/", -1)
+	pathRulesMu.Lock()
+	defer pathRulesMu.Unlock()
+	for _, r := range pathRules {
+		if r.Match.MatchString(p) {
+			return r.Match.ReplaceAllString(p, r.Replace), true
+		}
+	}
+	return "", false
+}
+
+// applyPathRules sets RelSrcPath and LocalSrcPath when the source path was
+// generated by a build system, if they were not already found.
+//
+// workspace is the local workspace root, with "/" as path separator. It can
+// be empty.
+func (c *Call) applyPathRules(workspace string) {
+	if c.RelSrcPath != "" || c.SrcPath == "" {
+		return
+	}
+	if rel, ok := rewritePath(c.SrcPath); ok {
+		c.RelSrcPath = rel
+		if workspace != "" {
+			if p := pathJoin(workspace, rel); isFile(p) {
+				c.LocalSrcPath = p
+			}
+		}
+	}
+}
+
+func (s *Signature) applyPathRules(workspace string) {
+	s.CreatedBy.applyPathRules(workspace)
+	for i := range s.Stack.Calls {
+		s.Stack.Calls[i].applyPathRules(workspace)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRewritePath(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want string
+	}{
+		{"/proc/self/cwd/foo/bar.go", "foo/bar.go"},
+		{"/home/u/.cache/bazel/_bazel_u/0123/sandbox/linux-sandbox/12/execroot/ws/foo/bar.go", "foo/bar.go"},
+		{"/home/u/.cache/bazel/_bazel_u/0123/execroot/ws/bazel-out/k8-fastbuild/bin/foo/gen.go", "foo/gen.go"},
+		{"/home/u/repo/plz-out/tmp/foo/bar._build/foo/bar.go", "foo/bar.go"},
+		{"/home/u/repo/plz-out/gen/foo/gen.go", "foo/gen.go"},
+		{"/home/u/go/src/foo/bar.go", ""},
+	}
+	for i, line := range data {
+		got, _ := rewritePath(line.in)
+		if got != line.want {
+			t.Errorf("#%d: rewritePath(%q) = %q, want %q", i, line.in, got, line.want)
+		}
+	}
+	c := newCall("main.main", Args{}, "/proc/self/cwd/cmd/foo/main.go", 1)
+	compareString(t, "cmd/foo/main.go", c.ModuleSrcPath())
+}
+
+func TestRegisterPathRule(t *testing.T) {
+	t.Parallel()
+	RegisterPathRule(PathRule{
+		Name:    "test",
+		Match:   regexp.MustCompile(`^/custom-build-root/[0-9]+/(.+)$`),
+		Replace: "$1",
+	})
+	found := false
+	for _, r := range PathRules() {
+		found = found || r.Name == "test"
+	}
+	if !found {
+		t.Fatal("rule not registered")
+	}
+
+	// Use the workspace root to find the local file.
+	root, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "pkg", "main.go"), []byte("package main\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := "goroutine 1 [running]:\nmain.main()\n\t/custom-build-root/42/pkg/main.go:1 +0x1\n"
+	ctx, err := ParseDumpOpts(strings.NewReader(data), nil, &Opts{GuessPaths: true, WorkspaceRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ctx.Goroutines[0].Stack.Calls[0]
+	compareString(t, "pkg/main.go", c.RelSrcPath)
+	compareString(t, pathJoin(strings.Replace(root, "\\", "/", -1), "pkg", "main.go"), c.LocalSrcPath)
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pipeline runs the parsing stage of a crash ingestion pipeline: it
+// consumes raw stack dumps from a message queue like Kafka, parses and
+// fingerprints them, and produces structured results to another topic.
+//
+// The package doesn't depend on a Kafka client; Consumer and Producer are
+// small enough to be implemented over any of them. For example with
+// github.com/segmentio/kafka-go:
+//
+//	type consumer struct{ r *kafka.Reader }
+//
+//	func (c consumer) Fetch(ctx context.Context) (pipeline.Message, error) {
+//		m, err := c.r.FetchMessage(ctx)
+//		return pipeline.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset, Key: m.Key, Value: m.Value}, err
+//	}
+//
+//	func (c consumer) Commit(ctx context.Context, m pipeline.Message) error {
+//		return c.r.CommitMessages(ctx, kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset})
+//	}
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Message is a message consumed or produced.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// Consumer reads messages from a topic.
+type Consumer interface {
+	// Fetch blocks until a message is available and returns it. It must not
+	// commit the message.
+	Fetch(ctx context.Context) (Message, error)
+	// Commit marks the message, and all the previous ones in its partition,
+	// as processed.
+	Commit(ctx context.Context, m Message) error
+}
+
+// Producer writes messages to a topic.
+type Producer interface {
+	// Produce returns once the message is durably written.
+	Produce(ctx context.Context, m Message) error
+}
+
+// Result is the value of the messages produced for each parsed dump, encoded
+// as JSON.
+type Result struct {
+	// Topic, Partition and Offset identify the source message.
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	// Crash is the stack.CrashKind of the dump, e.g. "panic".
+	Crash string `json:"crash"`
+	// Fingerprint is the Signature.Fingerprint of the goroutine that printed
+	// the dump. It is also the key of the produced message, so the same crash
+	// always lands in the same partition.
+	Fingerprint string `json:"fingerprint"`
+	// Goroutines is the number of goroutines in the dump.
+	Goroutines int `json:"goroutines"`
+	// Buckets is the goroutines aggregated with stack.AnyPointer.
+	Buckets []*stack.Bucket `json:"buckets"`
+}
+
+// Processor parses the dumps read from Consumer and writes the results to
+// Producer.
+//
+// Delivery is at-least-once: a message is committed only after its result, or
+// its copy in DeadLetterTopic, was produced. A message that cannot be parsed
+// is a poison message; it is moved to DeadLetterTopic with the "error" header
+// set, and doesn't stop the processing.
+type Processor struct {
+	Consumer Consumer
+	Producer Producer
+	// Topic is the topic to write the results to. It is required.
+	Topic string
+	// DeadLetterTopic is the topic to move poison messages to. If empty,
+	// poison messages are logged and skipped.
+	DeadLetterTopic string
+	// Opts is passed to stack.ParseDumpOpts. OnGoroutine is not supported.
+	Opts *stack.Opts
+	// Logger receives the poison messages. Nil discards it.
+	Logger stack.Logger
+}
+
+// Run processes messages until ctx is canceled or an error that is not caused
+// by a message happens, e.g. the producer failed. The message being processed
+// is then not committed so it is redelivered on restart.
+func (p *Processor) Run(ctx context.Context) error {
+	if p.Topic == "" {
+		return errors.New("pipeline: Topic is required")
+	}
+	for {
+		m, err := p.Consumer.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := p.Process(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+// Process processes and commits a single message.
+func (p *Processor) Process(ctx context.Context, m Message) error {
+	out, perr := p.parse(m)
+	if perr != nil {
+		if p.Logger != nil {
+			p.Logger.Printf("pipeline: poison message %s/%d@%d: %v", m.Topic, m.Partition, m.Offset, perr)
+		}
+		if p.DeadLetterTopic != "" {
+			h := map[string]string{
+				"error":     perr.Error(),
+				"topic":     m.Topic,
+				"partition": strconv.Itoa(m.Partition),
+				"offset":    strconv.FormatInt(m.Offset, 10),
+			}
+			for k, v := range m.Headers {
+				if _, ok := h[k]; !ok {
+					h[k] = v
+				}
+			}
+			dl := Message{Topic: p.DeadLetterTopic, Key: m.Key, Value: m.Value, Headers: h}
+			if err := p.Producer.Produce(ctx, dl); err != nil {
+				return err
+			}
+		}
+	} else if err := p.Producer.Produce(ctx, out); err != nil {
+		return err
+	}
+	return p.Consumer.Commit(ctx, m)
+}
+
+// Private stuff.
+
+// parse returns the result message, or an error if m is a poison message.
+func (p *Processor) parse(m Message) (out Message, err error) {
+	defer func() {
+		// Never let a malformed input take down the pipeline.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parser panicked: %v", r)
+		}
+	}()
+	opts := stack.Opts{}
+	if p.Opts != nil {
+		opts = *p.Opts
+	}
+	opts.OnGoroutine = nil
+	c, err := stack.ParseDumpOpts(bytes.NewReader(m.Value), nil, &opts)
+	if err != nil {
+		return out, err
+	}
+	if c == nil {
+		return out, errors.New("no stack dump found")
+	}
+	g := c.Culprit()
+	r := Result{
+		Topic:       m.Topic,
+		Partition:   m.Partition,
+		Offset:      m.Offset,
+		Crash:       c.Crash.String(),
+		Fingerprint: g.Fingerprint(),
+		Goroutines:  len(c.Goroutines),
+		Buckets:     stack.Aggregate(c.Goroutines, stack.AnyPointer),
+	}
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return out, err
+	}
+	return Message{Topic: p.Topic, Key: []byte(r.Fingerprint), Value: b, Headers: m.Headers}, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestProcessorRun(t *testing.T) {
+	t.Parallel()
+	c := &fakeConsumer{msgs: []Message{
+		{Topic: "raw", Offset: 1, Value: []byte("panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n")},
+		{Topic: "raw", Offset: 2, Value: []byte("not a stack dump")},
+	}}
+	pr := &fakeProducer{}
+	p := &Processor{Consumer: c, Producer: pr, Topic: "parsed", DeadLetterTopic: "dead"}
+	if err := p.Run(context.Background()); err != errDone {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pr.msgs) != 2 {
+		t.Fatalf("want 2 messages, got %#v", pr.msgs)
+	}
+	var r Result
+	if err := json.Unmarshal(pr.msgs[0].Value, &r); err != nil {
+		t.Fatal(err)
+	}
+	if pr.msgs[0].Topic != "parsed" || r.Offset != 1 || r.Crash != "panic" || r.Goroutines != 1 || len(r.Buckets) != 1 || string(pr.msgs[0].Key) != r.Fingerprint {
+		t.Fatalf("unexpected result: %#v", r)
+	}
+	if d := pr.msgs[1]; d.Topic != "dead" || d.Headers["error"] != "no stack dump found" || d.Headers["offset"] != "2" {
+		t.Fatalf("unexpected dead letter: %#v", d)
+	}
+	if len(c.committed) != 2 || c.committed[1] != 2 {
+		t.Fatalf("unexpected commits: %v", c.committed)
+	}
+}
+
+func TestProcessorProduceFailure(t *testing.T) {
+	t.Parallel()
+	c := &fakeConsumer{msgs: []Message{{Topic: "raw", Offset: 1, Value: []byte("junk")}}}
+	p := &Processor{Consumer: c, Producer: &fakeProducer{err: errors.New("broker down")}, Topic: "parsed", DeadLetterTopic: "dead"}
+	if err := p.Run(context.Background()); err == nil || err.Error() != "broker down" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.committed) != 0 {
+		t.Fatalf("message must not be committed: %v", c.committed)
+	}
+}
+
+var errDone = errors.New("done")
+
+type fakeConsumer struct {
+	msgs      []Message
+	committed []int64
+}
+
+func (f *fakeConsumer) Fetch(ctx context.Context) (Message, error) {
+	if len(f.msgs) == 0 {
+		return Message{}, errDone
+	}
+	m := f.msgs[0]
+	f.msgs = f.msgs[1:]
+	return m, nil
+}
+
+func (f *fakeConsumer) Commit(ctx context.Context, m Message) error {
+	f.committed = append(f.committed, m.Offset)
+	return nil
+}
+
+type fakeProducer struct {
+	msgs []Message
+	err  error
+}
+
+func (f *fakeProducer) Produce(ctx context.Context, m Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, m)
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pprof imports goroutine profiles, as served by
+// net/http/pprof at /debug/pprof/goroutine, into the types of package stack.
+// Both the protocol buffer format (debug=0) and the text format (debug=1) are
+// supported. With debug=2, the profile is a regular stack dump to parse with
+// stack.ParseDump.
+//
+// Goroutine profiles are pre-aggregated: there is no goroutine ID nor state,
+// only a count per call stack. Each entry becomes a stack.Bucket with Count
+// set, so they flow in the same formatting and diffing code as parsed stack
+// dumps.
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Profile is a goroutine profile.
+type Profile struct {
+	// Total is the number of goroutines.
+	Total int
+	// Samples is each distinct call stack, largest first.
+	Samples []Sample
+}
+
+// Sample is a group of goroutines with the same call stack.
+type Sample struct {
+	// Bucket is the call stack, with Bucket.Count set. IDs and State are not
+	// available.
+	Bucket *stack.Bucket
+	// Labels is the profiler labels set with runtime/pprof.Do, if any.
+	Labels map[string]string
+}
+
+// Buckets returns the buckets of all the samples, largest first.
+func (p *Profile) Buckets() []*stack.Bucket {
+	out := make([]*stack.Bucket, len(p.Samples))
+	for i := range p.Samples {
+		out[i] = p.Samples[i].Bucket
+	}
+	return out
+}
+
+// ParseProto parses a goroutine profile in the protocol buffer format, as
+// returned with debug=0. The data may be gzip compressed.
+//
+// The function names are kept as recorded by the profiler, which doesn't
+// escape the dots in import paths like stack traces do, e.g. "gopkg.in/yaml.v2"
+// instead of "gopkg.in/yaml%2ev2".
+func ParseProto(r io.Reader) (*Profile, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b {
+		z, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = ioutil.ReadAll(z); err != nil {
+			return nil, err
+		}
+	}
+	d := &decoded{lines: map[uint64][]line{}, functions: map[uint64]function{}}
+	if err := decodeFields(b, d.profile); err != nil {
+		return nil, err
+	}
+	return d.build()
+}
+
+// Private stuff.
+
+// Field numbers in profile.proto.
+const (
+	profileSample      = 2
+	profileLocation    = 4
+	profileFunction    = 5
+	profileStringTable = 6
+
+	sampleLocationID = 1
+	sampleValue      = 2
+	sampleLabel      = 3
+
+	labelKey = 1
+	labelStr = 2
+	labelNum = 3
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID       = 1
+	functionName     = 2
+	functionFilename = 4
+)
+
+type function struct {
+	name, filename uint64
+}
+
+type line struct {
+	function uint64
+	line     int
+}
+
+type rawSample struct {
+	locations []uint64
+	values    []uint64
+	labels    [][2]uint64
+}
+
+// decoded accumulates the messages of the profile. Strings are indexes in
+// the string table, which can come last.
+type decoded struct {
+	strings   []string
+	samples   []rawSample
+	lines     map[uint64][]line
+	functions map[uint64]function
+}
+
+func (d *decoded) profile(p *protoField) error {
+	switch p.num {
+	case profileSample:
+		s := rawSample{}
+		err := decodeFields(p.b, func(f *protoField) error {
+			var err error
+			switch f.num {
+			case sampleLocationID:
+				s.locations, err = f.uints(s.locations)
+			case sampleValue:
+				s.values, err = f.uints(s.values)
+			case sampleLabel:
+				var l [2]uint64
+				hasStr := false
+				err = decodeFields(f.b, func(g *protoField) error {
+					switch g.num {
+					case labelKey:
+						l[0] = g.u
+					case labelStr:
+						l[1] = g.u
+						hasStr = true
+					}
+					return nil
+				})
+				if hasStr {
+					s.labels = append(s.labels, l)
+				}
+			}
+			return err
+		})
+		d.samples = append(d.samples, s)
+		return err
+	case profileLocation:
+		var id uint64
+		var lines []line
+		err := decodeFields(p.b, func(f *protoField) error {
+			switch f.num {
+			case locationID:
+				id = f.u
+			case locationLine:
+				l := line{}
+				err := decodeFields(f.b, func(g *protoField) error {
+					switch g.num {
+					case lineFunctionID:
+						l.function = g.u
+					case lineLine:
+						l.line = int(g.u)
+					}
+					return nil
+				})
+				lines = append(lines, l)
+				return err
+			}
+			return nil
+		})
+		d.lines[id] = lines
+		return err
+	case profileFunction:
+		var id uint64
+		f := function{}
+		err := decodeFields(p.b, func(g *protoField) error {
+			switch g.num {
+			case functionID:
+				id = g.u
+			case functionName:
+				f.name = g.u
+			case functionFilename:
+				f.filename = g.u
+			}
+			return nil
+		})
+		d.functions[id] = f
+		return err
+	case profileStringTable:
+		d.strings = append(d.strings, string(p.b))
+	}
+	return nil
+}
+
+func (d *decoded) str(i uint64) (string, error) {
+	if i >= uint64(len(d.strings)) {
+		return "", fmt.Errorf("pprof: invalid string index %d", i)
+	}
+	return d.strings[i], nil
+}
+
+// build converts the decoded messages.
+func (d *decoded) build() (*Profile, error) {
+	p := &Profile{}
+	for _, s := range d.samples {
+		n := 0
+		if len(s.values) != 0 {
+			n = int(s.values[0])
+		}
+		b := &stack.Bucket{Count: n}
+		// Locations are innermost first, and so are the inlined lines within a
+		// location.
+		for _, id := range s.locations {
+			for _, l := range d.lines[id] {
+				f := d.functions[l.function]
+				name, err := d.str(f.name)
+				if err != nil {
+					return nil, err
+				}
+				file, err := d.str(f.filename)
+				if err != nil {
+					return nil, err
+				}
+				b.Stack.Calls = append(b.Stack.Calls, stack.Call{Func: stack.NewFunc(name), SrcPath: file, Line: l.line})
+			}
+		}
+		sample := Sample{Bucket: b}
+		for _, l := range s.labels {
+			k, err := d.str(l[0])
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.str(l[1])
+			if err != nil {
+				return nil, err
+			}
+			if sample.Labels == nil {
+				sample.Labels = map[string]string{}
+			}
+			sample.Labels[k] = v
+		}
+		p.Total += n
+		p.Samples = append(p.Samples, sample)
+	}
+	sort.SliceStable(p.Samples, func(i, j int) bool {
+		return p.Samples[i].Bucket.Count > p.Samples[j].Bucket.Count
+	})
+	return p, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestParseProto(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	_, _ = z.Write(testProfile())
+	_ = z.Close()
+	p, err := ParseProto(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 12 || len(p.Samples) != 2 {
+		t.Fatalf("unexpected profile: %#v", p)
+	}
+	b := p.Buckets()
+	if b[0].Size() != 10 || len(b[0].Stack.Calls) != 3 {
+		t.Fatalf("unexpected bucket: %#v", b[0])
+	}
+	// Inlined function first.
+	c := b[0].Stack.Calls
+	if c[0].Func.Raw != "main.inlined" || c[1].Func.Raw != "main.worker" || c[1].Line != 20 || c[2].SrcPath != "/src/main.go" {
+		t.Fatalf("unexpected calls: %#v", c)
+	}
+	if p.Samples[0].Labels["handler"] != "/api" || p.Samples[1].Labels != nil {
+		t.Fatalf("unexpected labels")
+	}
+	if _, err := ParseProto(bytes.NewReader([]byte{0x12, 0x10})); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// testProfile returns a profile with two samples.
+func testProfile() []byte {
+	// Strings.
+	const (
+		sEmpty = iota
+		sMain
+		sInlined
+		sWorker
+		sFile
+		sHandler
+		sAPI
+	)
+	var p []byte
+	p = appendMsg(p, profileSample, msg(packed(sampleLocationID, 1, 2), packed(sampleValue, 10), bytesField(sampleLabel, msg(varint(labelKey, sHandler), varint(labelStr, sAPI)))))
+	p = appendMsg(p, profileSample, msg(packed(sampleLocationID, 2), packed(sampleValue, 2)))
+	p = appendMsg(p, profileLocation, msg(varint(locationID, 1), bytesField(locationLine, msg(varint(lineFunctionID, 2), varint(lineLine, 5))), bytesField(locationLine, msg(varint(lineFunctionID, 3), varint(lineLine, 20)))))
+	p = appendMsg(p, profileLocation, msg(varint(locationID, 2), bytesField(locationLine, msg(varint(lineFunctionID, 1), varint(lineLine, 10)))))
+	for id, name := range []uint64{sMain, sInlined, sWorker} {
+		p = appendMsg(p, profileFunction, msg(varint(functionID, uint64(id+1)), varint(functionName, name), varint(functionFilename, sFile)))
+	}
+	for _, s := range []string{"", "main.main", "main.inlined", "main.worker", "/src/main.go", "handler", "/api"} {
+		p = appendMsg(p, profileStringTable, []byte(s))
+	}
+	return p
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func varint(num int, v uint64) []byte {
+	return appendVarint(appendVarint(nil, uint64(num)<<3|wireVarint), v)
+}
+
+func bytesField(num int, v []byte) []byte {
+	return append(appendVarint(appendVarint(nil, uint64(num)<<3|wireBytes), uint64(len(v))), v...)
+}
+
+func appendMsg(b []byte, num int, v []byte) []byte {
+	return append(b, bytesField(num, v)...)
+}
+
+func packed(num int, v ...uint64) []byte {
+	var b []byte
+	for _, i := range v {
+		b = appendVarint(b, i)
+	}
+	return bytesField(num, b)
+}
+
+func msg(fields ...[]byte) []byte {
+	return bytes.Join(fields, nil)
+}
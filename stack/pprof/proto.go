@@ -0,0 +1,111 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Minimal protocol buffers wire format decoder, sufficient for profile.proto.
+// See https://developers.google.com/protocol-buffers/docs/encoding.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("pprof: truncated protobuf")
+
+// protoField is a decoded field.
+type protoField struct {
+	num  int
+	wire int
+	// u is the value for wireVarint, wireFixed64 and wireFixed32.
+	u uint64
+	// b is the value for wireBytes.
+	b []byte
+}
+
+// decodeFields calls f for each field of a message.
+func decodeFields(b []byte, f func(p *protoField) error) error {
+	for len(b) != 0 {
+		key, n := decodeVarint(b)
+		if n == 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		p := protoField{num: int(key >> 3), wire: int(key & 7)}
+		switch p.wire {
+		case wireVarint:
+			if p.u, n = decodeVarint(b); n == 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			for i := 7; i >= 0; i-- {
+				p.u = p.u<<8 | uint64(b[i])
+			}
+			b = b[8:]
+		case wireBytes:
+			l, n := decodeVarint(b)
+			if n == 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			p.b = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			for i := 3; i >= 0; i-- {
+				p.u = p.u<<8 | uint64(b[i])
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("pprof: unsupported wire type %d", p.wire)
+		}
+		if err := f(&p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeVarint returns the value and the number of bytes read, 0 on error.
+func decodeVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// uints decodes a repeated integer field, either packed or not.
+func (p *protoField) uints(out []uint64) ([]uint64, error) {
+	if p.wire == wireVarint {
+		return append(out, p.u), nil
+	}
+	if p.wire != wireBytes {
+		return nil, fmt.Errorf("pprof: unexpected wire type %d for field %d", p.wire, p.num)
+	}
+	for b := p.b; len(b) != 0; {
+		v, n := decodeVarint(b)
+		if n == 0 {
+			return nil, errTruncated
+		}
+		out = append(out, v)
+		b = b[n:]
+	}
+	return out, nil
+}
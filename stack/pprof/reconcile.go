@@ -0,0 +1,153 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// Reconciliation matches the samples of a goroutine profile with the buckets
+// of a stack dump captured at about the same time, e.g. with debug=1 and
+// debug=2.
+type Reconciliation struct {
+	// Matches is the samples with at least one matching bucket, in the order
+	// of Profile.Samples.
+	Matches []Match
+	// ProfileOnly is the samples without a matching bucket.
+	ProfileOnly []*Sample
+	// DumpOnly is the buckets without a matching sample, in their original
+	// order.
+	DumpOnly []*stack.Bucket
+}
+
+// Match is a sample and the buckets of the dump with the same call stack.
+//
+// The buckets enrich the sample with what the profile doesn't record: the
+// goroutine IDs, states, wait durations and call arguments.
+type Match struct {
+	Sample *Sample
+	// Buckets is the matching buckets. There can be more than one as a dump
+	// distinguishes the arguments and the states.
+	Buckets []*stack.Bucket
+	// Goroutines is the number of goroutines in Buckets.
+	Goroutines int
+	// Delta is Sample.Bucket.Count minus Goroutines: the goroutines started
+	// (positive) or exited (negative) between the two captures.
+	Delta int
+}
+
+// Exact returns true if every sample and bucket matched with the same
+// number of goroutines, i.e. both captures are equivalent.
+func (r *Reconciliation) Exact() bool {
+	if len(r.ProfileOnly) != 0 || len(r.DumpOnly) != 0 {
+		return false
+	}
+	for i := range r.Matches {
+		if r.Matches[i].Delta != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reconcile matches the samples of p with buckets.
+//
+// A bucket matches a sample when they have the same function, file and line
+// for each call, ignoring runtime.goexit which is only in profiles. A prefix
+// is sufficient when the longest one was truncated: the dump stack was elided
+// or the profile stack reached maxProfileDepth frames. Each bucket is matched
+// with at most one sample, preferring an exact match.
+func Reconcile(p *Profile, buckets []*stack.Bucket) *Reconciliation {
+	samples := make([][]frame, len(p.Samples))
+	for i := range p.Samples {
+		samples[i] = frames(p.Samples[i].Bucket.Stack.Calls)
+	}
+	matched := make([][]*stack.Bucket, len(p.Samples))
+	out := &Reconciliation{}
+	for _, b := range buckets {
+		f := frames(b.Stack.Calls)
+		best := -1
+		for i, s := range samples {
+			if equalFrames(s, f) {
+				best = i
+				break
+			}
+			if best == -1 && prefixFrames(s, f, b.Stack.Elided) {
+				best = i
+			}
+		}
+		if best == -1 {
+			out.DumpOnly = append(out.DumpOnly, b)
+			continue
+		}
+		matched[best] = append(matched[best], b)
+	}
+	for i := range p.Samples {
+		s := &p.Samples[i]
+		if len(matched[i]) == 0 {
+			out.ProfileOnly = append(out.ProfileOnly, s)
+			continue
+		}
+		m := Match{Sample: s, Buckets: matched[i]}
+		for _, b := range matched[i] {
+			m.Goroutines += b.Size()
+		}
+		m.Delta = s.Bucket.Size() - m.Goroutines
+		out.Matches = append(out.Matches, m)
+	}
+	return out
+}
+
+// Private stuff.
+
+// maxProfileDepth is the number of frames recorded per goroutine by the
+// runtime before Go 1.23. Newer versions record more, but a stack this deep
+// may still have been truncated.
+const maxProfileDepth = 32
+
+// frame identifies a call independently of the format.
+type frame struct {
+	fn   string
+	file string
+	line int
+}
+
+// frames returns the identity of the calls. Func.String() is used since
+// profiles don't escape the dots in import paths.
+func frames(calls []stack.Call) []frame {
+	out := make([]frame, 0, len(calls))
+	for i := range calls {
+		if calls[i].Func.Raw == "runtime.goexit" {
+			continue
+		}
+		out = append(out, frame{fn: calls[i].Func.String(), file: calls[i].SrcPath, line: calls[i].Line})
+	}
+	return out
+}
+
+func equalFrames(a, b []frame) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixFrames returns true if the shorter of the profile stack s and dump
+// stack d is a prefix of the other and the longer one was truncated.
+func prefixFrames(s, d []frame, elided bool) bool {
+	switch {
+	case len(s) < len(d):
+		return len(s) >= maxProfileDepth && equalFrames(s, d[:len(s)])
+	case len(d) < len(s):
+		return elided && equalFrames(d, s[:len(d)])
+	default:
+		return false
+	}
+}
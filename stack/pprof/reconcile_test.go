@@ -0,0 +1,83 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	profile := strings.Join([]string{
+		"goroutine profile: total 4",
+		"3 @ 0x1 0x2 0x3 0x4",
+		"#\t0x1\truntime.gopark+0xd5\t/goroot/src/runtime/proc.go:381",
+		"#\t0x2\truntime.chanrecv1+0x1c\t/goroot/src/runtime/chan.go:442",
+		"#\t0x3\tgopkg.in/yaml.v2.worker+0x44\t/src/yaml.go:20",
+		"#\t0x4\truntime.goexit+0x1\t/goroot/src/runtime/asm_amd64.s:1598",
+		"",
+		"1 @ 0x5 0x4",
+		"#\t0x5\tmain.idle+0x10\t/src/main.go:30",
+		"#\t0x4\truntime.goexit+0x1\t/goroot/src/runtime/asm_amd64.s:1598",
+		"",
+	}, "\n")
+	p, err := ParseText(strings.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := ""
+	for i, arg := range []string{"0x1", "0x1", "0x2"} {
+		dump += "goroutine " + strconv.Itoa(i+1) + " [chan receive]:\n" +
+			"runtime.gopark(0x0)\n\t/goroot/src/runtime/proc.go:381 +0xd6\n" +
+			"runtime.chanrecv1(0x0)\n\t/goroot/src/runtime/chan.go:442 +0x1d\n" +
+			"gopkg.in/yaml%2ev2.worker(" + arg + ")\n\t/src/yaml.go:20 +0x45\n\n"
+	}
+	dump += "goroutine 9 [running]:\nmain.main()\n\t/src/main.go:10 +0x25\n"
+	c, err := stack.ParseDump(strings.NewReader(dump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Reconcile(p, stack.Aggregate(c.Goroutines, stack.ExactLines))
+	if len(r.Matches) != 1 || len(r.ProfileOnly) != 1 || len(r.DumpOnly) != 1 {
+		t.Fatalf("unexpected reconciliation %#v", r)
+	}
+	m := r.Matches[0]
+	if m.Sample != &p.Samples[0] || len(m.Buckets) != 2 || m.Goroutines != 3 || m.Delta != 0 {
+		t.Fatalf("unexpected match %#v", m)
+	}
+	if r.ProfileOnly[0] != &p.Samples[1] || r.DumpOnly[0].Stack.Calls[0].Func.Raw != "main.main" {
+		t.Fatalf("unexpected unmatched %#v", r)
+	}
+	if r.Exact() {
+		t.Fatal("unexpected exact reconciliation")
+	}
+	r = Reconcile(&Profile{Total: 3, Samples: p.Samples[:1]}, stack.Aggregate(c.Goroutines[:3], stack.AnyValue))
+	if !r.Exact() {
+		t.Fatalf("expected exact reconciliation %#v", r)
+	}
+}
+
+func TestReconcileTruncated(t *testing.T) {
+	t.Parallel()
+	var long []stack.Call
+	for i := 0; i < maxProfileDepth+2; i++ {
+		long = append(long, stack.Call{Func: stack.NewFunc("main.recurse"), SrcPath: "/src/main.go", Line: i + 1})
+	}
+	p := &Profile{Total: 2, Samples: []Sample{{Bucket: &stack.Bucket{Signature: stack.Signature{Stack: stack.Stack{Calls: long[:maxProfileDepth]}}, Count: 2}}}}
+	b := &stack.Bucket{Signature: stack.Signature{Stack: stack.Stack{Calls: long}}, IDs: []int{1}}
+	r := Reconcile(p, []*stack.Bucket{b})
+	if len(r.Matches) != 1 || r.Matches[0].Delta != 1 {
+		t.Fatalf("unexpected reconciliation %#v", r)
+	}
+	// A shorter stack that wasn't truncated doesn't match.
+	p.Samples[0].Bucket.Stack.Calls = long[:3]
+	if r := Reconcile(p, []*stack.Bucket{b}); len(r.Matches) != 0 {
+		t.Fatalf("unexpected reconciliation %#v", r)
+	}
+}
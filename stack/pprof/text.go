@@ -0,0 +1,140 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// ParseText parses a goroutine profile in the text format returned with
+// debug=1, which starts with "goroutine profile: total N".
+//
+// Each entry is a count and the program counters, followed by the frames
+// symbolized by the runtime:
+//
+//	10 @ 0x43a1c5 0x4710a4 0x46c0a1
+//	# labels: {"handler":"/api"}
+//	#	0x4710a4	main.worker+0x44	/src/main.go:20
+//
+// Program counters without a symbolized frame are ignored. The function
+// names are kept as printed, like ParseProto.
+func ParseText(r io.Reader) (*Profile, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	p := &Profile{}
+	header := false
+	var cur *Sample
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimRight(s.Text(), "\r")
+		if !header {
+			if l == "" {
+				continue
+			}
+			m := reTextHeader.FindStringSubmatch(l)
+			if m == nil {
+				return nil, errors.New("pprof: not a goroutine profile in the debug=1 format")
+			}
+			p.Total, _ = strconv.Atoi(m[1])
+			header = true
+			continue
+		}
+		switch {
+		case l == "":
+			cur = nil
+		case strings.HasPrefix(l, "# labels: "):
+			if cur == nil {
+				return nil, fmt.Errorf("pprof: line %d: labels without an entry", n)
+			}
+			cur.Labels = parseTextLabels(l[len("# labels: "):])
+		case strings.HasPrefix(l, "#\t"):
+			if cur == nil {
+				return nil, fmt.Errorf("pprof: line %d: frame without an entry", n)
+			}
+			if reTextPC.MatchString(l) {
+				// A program counter the runtime couldn't symbolize.
+				continue
+			}
+			c, err := parseTextFrame(l)
+			if err != nil {
+				return nil, fmt.Errorf("pprof: line %d: %v", n, err)
+			}
+			cur.Bucket.Stack.Calls = append(cur.Bucket.Stack.Calls, c)
+		default:
+			m := reTextEntry.FindStringSubmatch(l)
+			if m == nil {
+				return nil, fmt.Errorf("pprof: line %d: unexpected %q", n, l)
+			}
+			count, _ := strconv.Atoi(m[1])
+			p.Samples = append(p.Samples, Sample{Bucket: &stack.Bucket{Count: count}})
+			cur = &p.Samples[len(p.Samples)-1]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, errors.New("pprof: empty profile")
+	}
+	sort.SliceStable(p.Samples, func(i, j int) bool {
+		return p.Samples[i].Bucket.Count > p.Samples[j].Bucket.Count
+	})
+	return p, nil
+}
+
+// Private stuff.
+
+var (
+	// reTextHeader matches the first line of a profile with debug=1.
+	reTextHeader = regexp.MustCompile(`^goroutine profile: total (\d+)$`)
+	// reTextEntry matches the count and program counters of an entry.
+	reTextEntry = regexp.MustCompile(`^(\d+) @(?: 0x[0-9a-f]+)*$`)
+	// reTextFrame matches a symbolized frame, e.g.
+	// "#	0x4710a4	main.worker+0x44	/src/main.go:20".
+	reTextFrame = regexp.MustCompile(`^#\t0x[0-9a-f]+\t(.+)\+0x[0-9a-f]+\t(.+):(\d+)$`)
+	// reTextPC matches a frame without symbol, e.g. "#	0x43a1c5".
+	reTextPC = regexp.MustCompile(`^#\t0x[0-9a-f]+$`)
+	// reTextLabel matches a key value pair, both quoted with %q.
+	reTextLabel = regexp.MustCompile(`("(?:[^"\\]|\\.)*"):("(?:[^"\\]|\\.)*")`)
+)
+
+// parseTextFrame decodes a frame line.
+func parseTextFrame(l string) (stack.Call, error) {
+	m := reTextFrame.FindStringSubmatch(l)
+	if m == nil {
+		return stack.Call{}, fmt.Errorf("invalid frame %q", l)
+	}
+	line, err := strconv.Atoi(m[3])
+	if err != nil {
+		return stack.Call{}, err
+	}
+	return stack.Call{Func: stack.NewFunc(m[1]), SrcPath: m[2], Line: line}, nil
+}
+
+// parseTextLabels decodes the labels as printed by runtime/pprof, e.g.
+// {"handler":"/api", "id":"1"}.
+func parseTextLabels(s string) map[string]string {
+	var out map[string]string
+	for _, m := range reTextLabel.FindAllStringSubmatch(s, -1) {
+		k, err1 := strconv.Unquote(m[1])
+		v, err2 := strconv.Unquote(m[2])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tchinmai7/panicparse/stack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseText(t *testing.T) {
+	t.Parallel()
+	data := strings.Join([]string{
+		"goroutine profile: total 12",
+		"2 @ 0x43a1c5 0x46c0a1",
+		"#\t0x43a1c4\tmain.main+0x24\t/src/main.go:10",
+		"",
+		"10 @ 0x43a1c5 0x4710a4 0x46c0a1",
+		`# labels: {"handler":"/api", "q":"a\"b"}`,
+		"#\t0x4710a3\tmain.inlined+0x43\t/src/main.go:5",
+		"#\t0x4710a4\tmain.worker+0x44\t/src/main.go:20",
+		"#\t0x4710f0",
+		"#\t0x46c0a0\truntime.goexit+0x1\t/goroot/src/runtime/asm_amd64.s:1371",
+		"",
+	}, "\r\n")
+	p, err := ParseText(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Profile{
+		Total: 12,
+		Samples: []Sample{
+			{
+				Bucket: &stack.Bucket{
+					Signature: stack.Signature{Stack: stack.Stack{Calls: []stack.Call{
+						{Func: stack.NewFunc("main.inlined"), SrcPath: "/src/main.go", Line: 5},
+						{Func: stack.NewFunc("main.worker"), SrcPath: "/src/main.go", Line: 20},
+						{Func: stack.NewFunc("runtime.goexit"), SrcPath: "/goroot/src/runtime/asm_amd64.s", Line: 1371},
+					}}},
+					Count: 10,
+				},
+				Labels: map[string]string{"handler": "/api", "q": `a"b`},
+			},
+			{
+				Bucket: &stack.Bucket{
+					Signature: stack.Signature{Stack: stack.Stack{Calls: []stack.Call{
+						{Func: stack.NewFunc("main.main"), SrcPath: "/src/main.go", Line: 10},
+					}}},
+					Count: 2,
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Fatalf("ParseText mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseTextError(t *testing.T) {
+	t.Parallel()
+	for _, in := range []string{
+		"",
+		"goroutine 1 [running]:\n",
+		"goroutine profile: total 1\n#\t0x1\tmain.main+0x1\t/src/main.go:1\n",
+		"goroutine profile: total 1\n1 @ 0x1\n#\tgarbage\n",
+		"goroutine profile: total 1\nnope\n",
+	} {
+		if _, err := ParseText(strings.NewReader(in)); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Process is the identity of the process that printed the dump, guessed from
+// the log lines around it.
+//
+// It helps attributing dumps in logs mixing several processes. The values are
+// hints, not guarantees.
+type Process struct {
+	// Binary is the executable name, e.g. "server". It is found in the temporary
+	// path of "go run" and "go test", e.g. "/tmp/go-build123/b001/exe/server",
+	// or in a syslog style prefix, e.g. "server[1234]: ". Empty if not found.
+	Binary string
+	// PID is the process ID. It is found in a syslog style prefix, after
+	// "pid:" or "pid=", or as a bracketed number prefixing the line, e.g.
+	// "[1234] exited". 0 if not found.
+	PID int
+}
+
+// Private stuff.
+
+// maxProcessLines is the number of lines searched before and after the
+// goroutines.
+const maxProcessLines = 10
+
+var (
+	// reSyslogPrefix matches the prefix added by syslog, journald and most
+	// supervisors, e.g. "server[1234]: ".
+	reSyslogPrefix = regexp.MustCompile(`(?:^|\s)([A-Za-z_][\w.-]*)\[(\d+)\]: `)
+	// reGoBuildBinary matches the temporary binary of "go run" and "go test".
+	reGoBuildBinary = regexp.MustCompile(`go-build\d+/b\d+/(?:exe/)?([^\s/:"']+)`)
+	// rePID matches an explicit PID, e.g. "pid: 1234" or "pid=1234".
+	rePID = regexp.MustCompile(`(?i)\bpid[:=]\s*(\d+)\b`)
+	// reBracketedNumber matches a bracketed number prefixing a line, e.g.
+	// "[1234] exited". Elsewhere it is more likely an index, e.g. in "index
+	// out of range [5] with length 3".
+	reBracketedNumber = regexp.MustCompile(`^\[(\d+)\]\s`)
+)
+
+// findProcess returns the process hints found in the lines printed closest
+// to the goroutines, before the first one then after the n goroutines.
+func findProcess(segments []Segment, n int) *Process {
+	var lines []string
+	for _, s := range segments {
+		if s.Before == 0 {
+			l := splitSegment(s.Text)
+			for i := len(l) - 1; i >= 0 && len(l)-i <= maxProcessLines; i-- {
+				lines = append(lines, l[i])
+			}
+		}
+	}
+	for _, s := range segments {
+		if s.Before == n && n != 0 {
+			l := splitSegment(s.Text)
+			if len(l) > maxProcessLines {
+				l = l[:maxProcessLines]
+			}
+			lines = append(lines, l...)
+		}
+	}
+	out := &Process{}
+	for _, l := range lines {
+		if m := reSyslogPrefix.FindStringSubmatch(l); m != nil {
+			if out.Binary == "" {
+				out.Binary = m[1]
+			}
+			if out.PID == 0 {
+				out.PID, _ = strconv.Atoi(m[2])
+			}
+		}
+		if m := reGoBuildBinary.FindStringSubmatch(l); m != nil && out.Binary == "" {
+			out.Binary = m[1]
+		}
+		if out.PID == 0 {
+			if m := rePID.FindStringSubmatch(l); m != nil {
+				out.PID, _ = strconv.Atoi(m[1])
+			} else if m := reBracketedNumber.FindStringSubmatch(l); m != nil {
+				out.PID, _ = strconv.Atoi(m[1])
+			}
+		}
+	}
+	if out.Binary == "" && out.PID == 0 {
+		return nil
+	}
+	return out
+}
+
+// splitSegment returns the non-empty lines of a segment.
+func splitSegment(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimRight(l, "\r"); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
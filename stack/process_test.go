@@ -0,0 +1,74 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDumpProcess(t *testing.T) {
+	t.Parallel()
+	goroutine := "\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:10 +0x45\n"
+	data := []struct {
+		name   string
+		before string
+		after  string
+		want   *Process
+	}{
+		{"none", "panic: boom\n", "exit status 2\n", nil},
+		{
+			"go build path",
+			"starting /tmp/go-build1234/b001/exe/server\npanic: boom\n",
+			"",
+			&Process{Binary: "server"},
+		},
+		{
+			"syslog",
+			"Jan 02 15:04:05 host server[1234]: listening\npanic: boom\n",
+			"",
+			&Process{Binary: "server", PID: 1234},
+		},
+		{
+			"pid",
+			"starting with pid: 42\npanic: boom\n",
+			"",
+			&Process{PID: 42},
+		},
+		{
+			"bracketed after",
+			"panic: boom\n",
+			"exit status 2\n[4321] exited\n",
+			&Process{PID: 4321},
+		},
+		{
+			"index",
+			"panic: runtime error: index out of range [5] with length 3\n",
+			"exit status 2\n",
+			nil,
+		},
+		{
+			"closest wins",
+			"[1] first\n[2] second\npanic: boom\n",
+			"",
+			&Process{PID: 2},
+		},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := ParseDump(strings.NewReader(line.before+goroutine+line.after), nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(line.want, c.Process); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
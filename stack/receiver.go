@@ -0,0 +1,106 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"sort"
+	"strings"
+)
+
+// ReceiverType returns the package qualified type of the receiver when the
+// call is a method, e.g. "*net.conn". It returns an empty string otherwise.
+//
+// Pointer receivers are identified from the function name. Value receivers
+// can't be distinguished from nested functions by name, so they are only
+// identified once the sources were processed, see Args.Receiver.
+func (c *Call) ReceiverType() string {
+	pkg := c.Func.PkgName()
+	if r := c.Args.Receiver; r != nil && r.Type != "" && !strings.Contains(r.Type, "<unknown>") {
+		t := r.Type
+		ptr := strings.HasPrefix(t, "*")
+		t = strings.TrimPrefix(t, "*")
+		if pkg != "" {
+			t = pkg + "." + t
+		}
+		if ptr {
+			t = "*" + t
+		}
+		return t
+	}
+	name := c.Func.Name()
+	if !strings.HasPrefix(name, "(*") {
+		return ""
+	}
+	i := strings.Index(name, ").")
+	// Closures in a method are not methods.
+	if i == -1 || strings.IndexByte(name[i+2:], '.') != -1 {
+		return ""
+	}
+	if pkg == "" {
+		return "*" + name[2:i]
+	}
+	return "*" + pkg + "." + name[2:i]
+}
+
+// ReceiverType returns the receiver type of the innermost method call outside
+// the standard library, e.g. "*main.Conn". It falls back to the innermost
+// method call of the standard library and returns an empty string if there's
+// no method call.
+func (s *Signature) ReceiverType() string {
+	std := ""
+	for i := range s.Stack.Calls {
+		c := &s.Stack.Calls[i]
+		t := c.ReceiverType()
+		if t == "" {
+			continue
+		}
+		if !c.IsStdlib {
+			return t
+		}
+		if std == "" {
+			std = t
+		}
+	}
+	return std
+}
+
+// ReceiverGroup is the buckets whose goroutines are in a method of the same
+// receiver type, e.g. all the goroutines stuck in a (*Conn) method.
+type ReceiverGroup struct {
+	// Receiver is the receiver type as returned by Signature.ReceiverType. It
+	// is empty for the buckets without a method call.
+	Receiver string
+	// Buckets is the buckets, in their original order.
+	Buckets []*Bucket
+	// Goroutines is the number of goroutines in Buckets.
+	Goroutines int
+}
+
+// GroupByReceiver groups the buckets by receiver type.
+//
+// The groups are ordered by decreasing number of goroutines, the buckets
+// without a method call are last.
+func GroupByReceiver(buckets []*Bucket) []ReceiverGroup {
+	var out []ReceiverGroup
+	index := map[string]int{}
+	for _, b := range buckets {
+		r := b.ReceiverType()
+		i, ok := index[r]
+		if !ok {
+			i = len(out)
+			index[r] = i
+			out = append(out, ReceiverGroup{Receiver: r})
+		}
+		out[i].Buckets = append(out[i].Buckets, b)
+		out[i].Goroutines += b.Size()
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if (out[i].Receiver == "") != (out[j].Receiver == "") {
+			return out[j].Receiver == ""
+		}
+		return out[i].Goroutines > out[j].Goroutines
+	})
+	return out
+}
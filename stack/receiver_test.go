@@ -0,0 +1,59 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCallReceiverType(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		call Call
+		want string
+	}{
+		{Call{Func: newFunc("main.(*Conn).Read")}, "*main.Conn"},
+		{Call{Func: newFunc("net/http.(*conn).serve")}, "*http.conn"},
+		{Call{Func: newFunc("main.(*Conn).Read.func1")}, ""},
+		{Call{Func: newFunc("main.main")}, ""},
+		{Call{Func: newFunc("main.T.String")}, ""},
+		{Call{Func: newFunc("main.T.String"), Args: Args{Receiver: &LabeledArg{Name: "t", Type: "T", Elided: true}}}, "main.T"},
+		{Call{Func: newFunc("main.(*T).f"), Args: Args{Receiver: &LabeledArg{Type: "*T", Value: "0x1"}}}, "*main.T"},
+	}
+	for i, line := range data {
+		if got := line.call.ReceiverType(); got != line.want {
+			t.Errorf("#%d: want %q, got %q", i, line.want, got)
+		}
+	}
+}
+
+func TestGroupByReceiver(t *testing.T) {
+	t.Parallel()
+	bucket := func(ids []int, raws ...string) *Bucket {
+		b := &Bucket{IDs: ids}
+		for _, r := range raws {
+			c := Call{Func: newFunc(r)}
+			c.IsStdlib = !strings.HasPrefix(r, "main.")
+			b.Stack.Calls = append(b.Stack.Calls, c)
+		}
+		return b
+	}
+	read := bucket([]int{1}, "internal/poll.(*FD).Read", "main.(*Conn).Read", "main.main")
+	write := bucket([]int{2, 3}, "internal/poll.(*FD).Write", "main.(*Conn).Write")
+	none := bucket([]int{4, 5, 6, 7}, "runtime.gopark", "main.main")
+	mu := bucket([]int{8}, "sync.(*Mutex).Lock", "main.f")
+	got := GroupByReceiver([]*Bucket{none, read, mu, write})
+	want := []ReceiverGroup{
+		{Receiver: "*main.Conn", Buckets: []*Bucket{read, write}, Goroutines: 3},
+		{Receiver: "*sync.Mutex", Buckets: []*Bucket{mu}, Goroutines: 1},
+		{Buckets: []*Bucket{none}, Goroutines: 4},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+}
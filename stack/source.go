@@ -14,7 +14,6 @@ import (
 	"go/parser"
 	"go/token"
 	"io/ioutil"
-	"log"
 	"math"
 	"strings"
 )
@@ -23,24 +22,63 @@ import (
 type cache struct {
 	files  map[string][]byte
 	parsed map[string]*parsedFile
+	logger Logger
 }
 
 // Augment processes source files to improve calls to be more descriptive.
 //
-// It modifies goroutines in place. It requires calling ParseDump() with
-// guesspaths set to true to work properly.
+// It modifies goroutines in place; use Context.Clone first to keep the
+// original. It requires calling ParseDump() with guesspaths set to true to
+// work properly.
+//
+// Failures to load source files are logged with package log.
 func Augment(goroutines []*Goroutine) {
-	c := &cache{}
+	AugmentOpts(goroutines, &Opts{Logger: stdLogger{}})
+}
+
+// AugmentOpts is the same as Augment but with options.
+//
+// Only opts.Logger is used. opts can be nil.
+func AugmentOpts(goroutines []*Goroutine, opts *Opts) {
+	a := NewAugmenter(opts)
 	for _, g := range goroutines {
-		c.augmentGoroutine(g)
+		a.Augment(&g.Signature)
 	}
 }
 
-// augmentGoroutine processes source files to improve call to be more
+// Augmenter processes source files on demand to improve calls to be more
 // descriptive.
 //
-// It modifies the routine.
-func (c *cache) augmentGoroutine(goroutine *Goroutine) {
+// Contrary to Augment, it can be used on the buckets actually rendered, after
+// filtering. The source files are loaded and parsed once per Augmenter.
+type Augmenter struct {
+	c cache
+}
+
+// NewAugmenter returns an Augmenter.
+//
+// Only opts.Logger is used. opts can be nil.
+func NewAugmenter(opts *Opts) *Augmenter {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	return &Augmenter{c: cache{logger: getLogger(opts.Logger)}}
+}
+
+// Augment processes the calls of a signature, e.g. &Goroutine.Signature or
+// &Bucket.Signature.
+//
+// It modifies s in place. It requires calling ParseDump() with guesspaths set
+// to true to work properly.
+func (a *Augmenter) Augment(s *Signature) {
+	a.c.augmentStack(&s.Stack)
+}
+
+// augmentStack processes source files to improve call to be more
+// descriptive.
+//
+// It modifies the stack.
+func (c *cache) augmentStack(stack *Stack) {
 	if c.files == nil {
 		c.files = map[string][]byte{}
 	}
@@ -49,15 +87,23 @@ func (c *cache) augmentGoroutine(goroutine *Goroutine) {
 	}
 	// For each call site, look at the next call and populate it. Then we can
 	// walk back and reformat things.
-	for i := range goroutine.Stack.Calls {
-		c.load(goroutine.Stack.Calls[i].LocalSrcPath)
+	for i := range stack.Calls {
+		c.load(stack.Calls[i].LocalSrcPath)
 	}
 
 	// Once all loaded, we can look at the next call when available.
-	for i := 0; i < len(goroutine.Stack.Calls)-1; i++ {
+	for i := 0; i < len(stack.Calls)-1; i++ {
 		// Get the AST from the previous call and process the call line with it.
-		if f := c.getFuncAST(&goroutine.Stack.Calls[i]); f != nil {
-			processCall(&goroutine.Stack.Calls[i], f)
+		if f := c.getFuncAST(&stack.Calls[i]); f != nil {
+			processCall(&stack.Calls[i], f)
+		}
+	}
+
+	// The caller of runtime.selectgo is blocked on the select statement at its
+	// call line.
+	for i := 1; i < len(stack.Calls); i++ {
+		if stack.Calls[i-1].Func.Raw == "runtime.selectgo" {
+			stack.Calls[i].SelectCases = c.getSelectCases(&stack.Calls[i])
 		}
 	}
 }
@@ -78,11 +124,10 @@ func (c *cache) load(fileName string) {
 		c.files[fileName] = nil
 		return
 	}
-	//log.Printf("load(%s)", fileName)
 	if _, ok := c.files[fileName]; !ok {
 		var err error
 		if c.files[fileName], err = ioutil.ReadFile(fileName); err != nil {
-			log.Printf("Failed to read %s: %s", fileName, err)
+			c.logger.Printf("Failed to read %s: %s", fileName, err)
 			c.files[fileName] = nil
 			return
 		}
@@ -91,7 +136,7 @@ func (c *cache) load(fileName string) {
 	src := c.files[fileName]
 	parsed, err := parser.ParseFile(fset, fileName, src, 0)
 	if err != nil {
-		log.Printf("Failed to parse %s: %s", fileName, err)
+		c.logger.Printf("Failed to parse %s: %s", fileName, err)
 		return
 	}
 	// Convert the line number into raw file offset.
@@ -111,6 +156,15 @@ func (c *cache) getFuncAST(call *Call) *ast.FuncDecl {
 	return nil
 }
 
+// getSelectCases returns the operation of each case of the select statement
+// at the call line.
+func (c *cache) getSelectCases(call *Call) []string {
+	if p := c.parsed[call.LocalSrcPath]; p != nil {
+		return p.getSelectCases(c.files[call.LocalSrcPath], call.Line)
+	}
+	return nil
+}
+
 type parsedFile struct {
 	lineToByteOffset []int
 	parsed           *ast.File
@@ -167,6 +221,52 @@ func (p *parsedFile) getFuncAST(f string, l int) (d *ast.FuncDecl) {
 	return
 }
 
+// getSelectCases returns the operation of each case of the select statement
+// starting at line l, in source order.
+func (p *parsedFile) getSelectCases(src []byte, l int) []string {
+	if l <= 0 || len(p.lineToByteOffset) <= l {
+		return nil
+	}
+	start := p.lineToByteOffset[l]
+	end := len(src)
+	if l+1 < len(p.lineToByteOffset) {
+		end = p.lineToByteOffset[l+1]
+	}
+	// The file is alone in its token.FileSet, so the base is 1.
+	text := func(n ast.Node) string {
+		return strings.Join(strings.Fields(string(src[n.Pos()-1:n.End()-1])), " ")
+	}
+	var out []string
+	ast.Inspect(p.parsed, func(n ast.Node) bool {
+		if out != nil || n == nil {
+			return false
+		}
+		if int(n.End())-1 <= start || int(n.Pos())-1 >= end {
+			// Neither the node nor its children are on the line.
+			return false
+		}
+		sel, ok := n.(*ast.SelectStmt)
+		if !ok || int(sel.Pos())-1 < start {
+			return true
+		}
+		out = []string{}
+		for _, s := range sel.Body.List {
+			switch c := s.(*ast.CommClause).Comm.(type) {
+			case nil:
+				out = append(out, "default")
+			case *ast.SendStmt:
+				out = append(out, text(c.Chan)+"<-")
+			case *ast.ExprStmt:
+				out = append(out, text(c.X))
+			case *ast.AssignStmt:
+				out = append(out, text(c.Rhs[0]))
+			}
+		}
+		return false
+	})
+	return out
+}
+
 func name(n ast.Node) string {
 	switch t := n.(type) {
 	case *ast.InterfaceType:
@@ -210,8 +310,9 @@ func fieldToType(f *ast.Field) (string, bool) {
 	}
 }
 
-// extractArgumentsType returns the name of the type of each input argument.
-func extractArgumentsType(f *ast.FuncDecl) ([]string, bool) {
+// extractArgumentsType returns the name of the type and the name of each
+// input argument. The name is empty for unnamed arguments.
+func extractArgumentsType(f *ast.FuncDecl) ([]string, []string, bool) {
 	var fields []*ast.Field
 	if f.Recv != nil {
 		if len(f.Recv.List) != 1 {
@@ -223,25 +324,31 @@ func extractArgumentsType(f *ast.FuncDecl) ([]string, bool) {
 			fields = append(fields, f.Recv.List[0])
 		}
 	}
-	var types []string
+	var types, names []string
 	extra := false
 	for _, arg := range append(fields, f.Type.Params.List...) {
 		// Assert that extra is only set on the last item of fields?
 		var t string
 		t, extra = fieldToType(arg)
-		mult := len(arg.Names)
-		if mult == 0 {
-			mult = 1
+		if len(arg.Names) == 0 {
+			types = append(types, t)
+			names = append(names, "")
+			continue
 		}
-		for i := 0; i < mult; i++ {
+		for _, n := range arg.Names {
 			types = append(types, t)
+			names = append(names, n.Name)
 		}
 	}
-	return types, extra
+	return types, names, extra
 }
 
 // processCall walks the function and populate call accordingly.
 func processCall(call *Call, f *ast.FuncDecl) {
+	// Reset in case the call is processed again.
+	call.Args.Processed = nil
+	call.Args.Labeled = nil
+	call.Args.Receiver = nil
 	values := make([]uint64, len(call.Args.Values))
 	for i := range call.Args.Values {
 		values[i] = call.Args.Values[i].Value
@@ -265,42 +372,90 @@ func processCall(call *Call, f *ast.FuncDecl) {
 		return n
 	}
 
-	types, extra := extractArgumentsType(f)
-	for i := 0; len(values) != 0; i++ {
-		var t string
+	types, names, extra := extractArgumentsType(f)
+	i := 0
+	for ; len(values) != 0; i++ {
+		var t, n string
 		if i >= len(types) {
 			if !extra {
 				// These are unexpected value! Print them as hex.
-				call.Args.Processed = append(call.Args.Processed, popName())
+				v := popName()
+				call.Args.Processed = append(call.Args.Processed, v)
+				call.Args.Labeled = append(call.Args.Labeled, LabeledArg{Value: v})
 				continue
 			}
 			t = types[len(types)-1]
+			n = names[len(names)-1]
 		} else {
 			t = types[i]
+			n = names[i]
 		}
+		// p is the processed form, v the value of the labeled form.
+		var p, v string
 		switch t {
 		case "float32":
-			call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%g", math.Float32frombits(uint32(pop()))))
+			p = fmt.Sprintf("%g", math.Float32frombits(uint32(pop())))
+			v = p
 		case "float64":
-			call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%g", math.Float64frombits(pop())))
+			p = fmt.Sprintf("%g", math.Float64frombits(pop()))
+			v = p
 		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
-			call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%d", pop()))
+			p = fmt.Sprintf("%d", pop())
+			v = p
 		case "string":
-			call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%s(%s, len=%d)", t, popName(), pop()))
+			ptr := popName()
+			l := pop()
+			p = fmt.Sprintf("%s(%s, len=%d)", t, ptr, l)
+			v = fmt.Sprintf("ptr=%s, len=%d", ptr, l)
 		default:
 			if strings.HasPrefix(t, "*") {
-				call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%s(%s)", t, popName()))
+				v = popName()
+				p = fmt.Sprintf("%s(%s)", t, v)
 			} else if strings.HasPrefix(t, "[]") {
-				call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%s(%s len=%d cap=%d)", t, popName(), pop(), pop()))
+				ptr := popName()
+				l := pop()
+				c := pop()
+				p = fmt.Sprintf("%s(%s len=%d cap=%d)", t, ptr, l, c)
+				v = fmt.Sprintf("ptr=%s, len=%d, cap=%d", ptr, l, c)
 			} else {
 				// Assumes it's an interface. For now, discard the object value, which
 				// is probably not a good idea.
-				call.Args.Processed = append(call.Args.Processed, fmt.Sprintf("%s(%s)", t, popName()))
-				pop()
+				typ := popName()
+				p = fmt.Sprintf("%s(%s)", t, typ)
+				v = fmt.Sprintf("type=%s, data=0x%x", typ, pop())
 			}
 		}
+		call.Args.Processed = append(call.Args.Processed, p)
+		call.Args.Labeled = append(call.Args.Labeled, LabeledArg{Name: n, Type: t, Value: v})
 		if len(values) == 0 && call.Args.Elided {
-			return
+			i++
+			break
+		}
+	}
+	// Label the arguments whose value was not printed.
+	for ; i < len(types); i++ {
+		call.Args.Labeled = append(call.Args.Labeled, LabeledArg{Name: names[i], Type: types[i], Elided: true})
+	}
+	if f.Recv != nil {
+		call.Args.Receiver = labelReceiver(f.Recv.List[0], &call.Args)
+	}
+}
+
+// labelReceiver returns the receiver and removes it from a.Labeled when it
+// is a pointer, as it is the first value.
+func labelReceiver(r *ast.Field, a *Args) *LabeledArg {
+	if _, ok := r.Type.(*ast.StarExpr); ok {
+		if len(a.Labeled) == 0 {
+			return nil
 		}
+		l := a.Labeled[0]
+		a.Labeled = a.Labeled[1:]
+		return &l
+	}
+	t, _ := fieldToType(r)
+	out := &LabeledArg{Type: t, Elided: true}
+	if len(r.Names) != 0 {
+		out.Name = r.Names[0].Name
 	}
+	return out
 }
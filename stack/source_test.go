@@ -516,11 +516,127 @@ func TestAugmentDummy(t *testing.T) {
 	Augment(goroutines)
 }
 
+func TestAugmenter(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc f(a int, b string) {\n\tpanic(a)\n}\n\nfunc main() {\n\tf(1, \"ab\")\n}\n"
+	if err := ioutil.WriteFile(main, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := &Bucket{
+		Signature: Signature{
+			Stack: Stack{
+				Calls: []Call{
+					{Func: newFunc("main.f"), Args: Args{Values: []Arg{{Value: 1}, {Value: 0x4c1a20}, {Value: 2}}}, SrcPath: main, LocalSrcPath: main, Line: 4},
+					{Func: newFunc("main.main"), SrcPath: main, LocalSrcPath: main, Line: 8},
+				},
+			},
+		},
+	}
+	a := NewAugmenter(nil)
+	// Processing twice is fine.
+	a.Augment(&b.Signature)
+	a.Augment(&b.Signature)
+	want := []string{"1", "string(0x4c1a20, len=2)"}
+	if diff := cmp.Diff(want, b.Stack.Calls[0].Args.Processed); diff != "" {
+		t.Fatalf("Processed mismatch (-want +got):\n%s", diff)
+	}
+	wantLabeled := []LabeledArg{
+		{Name: "a", Type: "int", Value: "1"},
+		{Name: "b", Type: "string", Value: "ptr=0x4c1a20, len=2"},
+	}
+	if diff := cmp.Diff(wantLabeled, b.Stack.Calls[0].Args.Labeled); diff != "" {
+		t.Fatalf("Labeled mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAugmenterLabeledElided(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := "package main\n\ntype S struct{}\n\nfunc (s *S) f(id int, name string, v []byte) {\n\tpanic(id)\n}\n\nfunc main() {\n\t(&S{}).f(42, \"abc\", nil)\n}\n"
+	if err := ioutil.WriteFile(main, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := &Bucket{
+		Signature: Signature{
+			Stack: Stack{
+				Calls: []Call{
+					{Func: newFunc("main.(*S).f"), Args: Args{Values: []Arg{{Value: 0xc000010000}, {Value: 42}, {Value: 0x4c1a20}, {Value: 3}}, Elided: true}, SrcPath: main, LocalSrcPath: main, Line: 6},
+					{Func: newFunc("main.main"), SrcPath: main, LocalSrcPath: main, Line: 10},
+				},
+			},
+		},
+	}
+	NewAugmenter(nil).Augment(&b.Signature)
+	if diff := cmp.Diff(&LabeledArg{Name: "s", Type: "*S", Value: "0xc000010000"}, b.Stack.Calls[0].Args.Receiver); diff != "" {
+		t.Fatalf("Receiver mismatch (-want +got):\n%s", diff)
+	}
+	want := []LabeledArg{
+		{Name: "id", Type: "int", Value: "42"},
+		{Name: "name", Type: "string", Value: "ptr=0x4c1a20, len=3"},
+		{Name: "v", Type: "[]byte", Elided: true},
+	}
+	if diff := cmp.Diff(want, b.Stack.Calls[0].Args.Labeled); diff != "" {
+		t.Fatalf("Labeled mismatch (-want +got):\n%s", diff)
+	}
+	got := make([]string, 0, len(want))
+	for i := range want {
+		got = append(got, want[i].String())
+	}
+	wantStr := "id int=42, name string(ptr=0x4c1a20, len=3), v []byte=..."
+	if s := strings.Join(got, ", "); s != wantStr {
+		t.Fatalf("want %q, got %q", wantStr, s)
+	}
+}
+
+func TestAugmenterSelectCases(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "panicparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc f(done <-chan struct{}, c <-chan int, res chan<- int) {\n\tfor {\n\t\tselect {\n\t\tcase <-done:\n\t\t\treturn\n\t\tcase v, ok := <-c:\n\t\t\t_, _ = v, ok\n\t\tcase res <- 1:\n\t\tdefault:\n\t\t}\n\t}\n}\n"
+	if err := ioutil.WriteFile(main, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := &Signature{
+		State: "select",
+		Stack: Stack{
+			Calls: []Call{
+				{Func: newFunc("runtime.selectgo"), SrcPath: "/goroot/src/runtime/select.go", Line: 327},
+				{Func: newFunc("main.f"), SrcPath: main, LocalSrcPath: main, Line: 5},
+			},
+		},
+	}
+	NewAugmenter(nil).Augment(s)
+	want := []string{"<-done", "<-c", "res<-", "default"}
+	if diff := cmp.Diff(want, s.Stack.Calls[1].SelectCases); diff != "" {
+		t.Fatalf("SelectCases mismatch (-want +got):\n%s", diff)
+	}
+	if s.Stack.Calls[0].SelectCases != nil {
+		t.Fatalf("unexpected SelectCases: %v", s.Stack.Calls[0].SelectCases)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	t.Parallel()
+	l := &recordingLogger{}
 	c := &cache{
 		files:  map[string][]byte{"bad.go": []byte("bad content")},
 		parsed: map[string]*parsedFile{},
+		logger: l,
 	}
 	c.load("foo.asm")
 	c.load("bad.go")
@@ -528,6 +644,10 @@ func TestLoad(t *testing.T) {
 	if l := len(c.parsed); l != 3 {
 		t.Fatalf("want 3, got %d", l)
 	}
+	// Only the failures are logged, since Augment logs to package log.
+	if len(l.lines) != 2 || !strings.HasPrefix(l.lines[0], "Failed to parse bad.go") || !strings.HasPrefix(l.lines[1], "Failed to read doesnt_exist.go") {
+		t.Fatalf("unexpected logs: %q", l.lines)
+	}
 	if c.parsed["foo.asm"] != nil {
 		t.Fatalf("foo.asm is not present; should not have been loaded")
 	}
@@ -632,3 +752,12 @@ func zapPaths(s *Stack) {
 		s.Calls[j].LocalSrcPath = ""
 	}
 }
+
+// recordingLogger is a Logger that keeps the lines logged.
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) Printf(format string, v ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, v...))
+}
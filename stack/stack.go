@@ -9,9 +9,11 @@
 package stack
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -26,8 +28,42 @@ import (
 //
 // The main caveat is that for calls in package main, the package import URL is
 // left out.
+//
+// Build it with NewFunc so the names derived from Raw are computed once, and
+// use NewFunc again to change Raw.
 type Func struct {
 	Raw string
+	// Names is the names derived from Raw. It is set by NewFunc and by the
+	// parser; when nil, the methods derive the names on each call.
+	Names *FuncNames `json:"-"`
+}
+
+// FuncNames is the names derived from Func.Raw, see the Func methods of the
+// same name.
+type FuncNames struct {
+	String     string
+	Name       string
+	PkgName    string
+	PkgDotName string
+	Normalized string
+	Display    string
+}
+
+// NewFunc returns the Func for the raw function name as printed in a stack
+// trace, e.g. "github.com/foo/bar%2ev2.(*Server).Serve".
+func NewFunc(raw string) Func {
+	return Func{Raw: raw, Names: newFuncNames(raw)}
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so the names are derived once.
+func (f *Func) UnmarshalJSON(b []byte) error {
+	// A distinct type to not recurse.
+	var v struct{ Raw string }
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = NewFunc(v.Raw)
+	return nil
 }
 
 // String return the fully qualified package import path dot function/method
@@ -35,21 +71,14 @@ type Func struct {
 //
 // It returns the unmangled form of .Raw.
 func (f *Func) String() string {
-	s, _ := url.QueryUnescape(f.Raw)
-	return s
+	return f.names().String
 }
 
 // Name returns the function name.
 //
 // Methods are fully qualified, including the struct type.
 func (f *Func) Name() string {
-	// This works even on Windows as filepath.Base() splits also on "/".
-	// TODO(Tchinmai7): This code will fail on a source file with a dot in its name.
-	parts := strings.SplitN(filepath.Base(f.Raw), ".", 2)
-	if len(parts) == 1 {
-		return parts[0]
-	}
-	return parts[1]
+	return f.names().Name
 }
 
 // importPath returns the fully qualified package import URL as a guess from
@@ -76,12 +105,7 @@ func (f *Func) importPath() string {
 // is incorrect when there's a mismatch between the directory name containing
 // the package and the package name.
 func (f *Func) PkgName() string {
-	parts := strings.SplitN(filepath.Base(f.Raw), ".", 2)
-	if len(parts) == 1 {
-		return ""
-	}
-	s, _ := url.QueryUnescape(parts[0])
-	return s
+	return f.names().PkgName
 }
 
 // PkgDotName returns "<package>.<func>" format.
@@ -90,15 +114,7 @@ func (f *Func) PkgName() string {
 // is incorrect when there's a mismatch between the directory name containing
 // the package and the package name.
 func (f *Func) PkgDotName() string {
-	parts := strings.SplitN(filepath.Base(f.Raw), ".", 2)
-	s, _ := url.QueryUnescape(parts[0])
-	if len(parts) == 1 {
-		return parts[0]
-	}
-	if s != "" || parts[1] != "" {
-		return s + "." + parts[1]
-	}
-	return ""
+	return f.names().PkgDotName
 }
 
 // IsExported returns true if the function is exported.
@@ -115,6 +131,19 @@ func (f *Func) IsExported() bool {
 	return f.PkgName() == "main" && name == "main"
 }
 
+// equal returns true if both functions have the same normalized name.
+func (f *Func) equal(r *Func) bool {
+	return f.Raw == r.Raw || f.Normalized() == r.Normalized()
+}
+
+// names returns the derived names of f.
+func (f *Func) names() *FuncNames {
+	if f.Names != nil {
+		return f.Names
+	}
+	return newFuncNames(f.Raw)
+}
+
 // Arg is an argument on a Call.
 type Arg struct {
 	Value uint64 // Value is the raw value as found in the stack trace
@@ -170,6 +199,14 @@ type Args struct {
 	// Processed is the arguments generated from processing the source files. It
 	// can have a length lower than Values.
 	Processed []string
+	// Labeled is the arguments labeled with the name and the type of the
+	// parameters as declared in the source files. Unlike Processed, it
+	// includes the parameters whose value was elided.
+	Labeled []LabeledArg
+	// Receiver is the receiver of a method, labeled from the source files. It
+	// is not part of Labeled. Elided is set for value receivers, whose value is
+	// not printed.
+	Receiver *LabeledArg
 	// Elided when set means there was a trailing ", ...".
 	Elided bool
 }
@@ -190,6 +227,39 @@ func (a *Args) String() string {
 	return strings.Join(v, ", ")
 }
 
+// LabeledArg is an argument value labeled with its parameter.
+type LabeledArg struct {
+	// Name is the name of the parameter or the receiver. It is empty for
+	// unnamed parameters and for the values that do not match any parameter.
+	Name string
+	// Type is the type of the parameter as written in the source, e.g.
+	// "*Server". It is empty for the values that do not match any parameter.
+	Type string
+	// Value is the decoded value, e.g. "42" or "ptr=0xc000010000, len=3" for a
+	// string.
+	Value string
+	// Elided is set when the value was not printed by the runtime.
+	Elided bool
+}
+
+// String returns the label followed by the value, e.g. "id int=42" or
+// "name string(ptr=0xc000010000, len=3)".
+func (l *LabeledArg) String() string {
+	label := strings.TrimSpace(l.Name + " " + l.Type)
+	v := l.Value
+	if l.Elided {
+		v = "..."
+	}
+	switch {
+	case label == "":
+		return v
+	case strings.Contains(v, "="):
+		return label + "(" + v + ")"
+	default:
+		return label + "=" + v
+	}
+}
+
 // equal returns true only if both arguments are exactly equal.
 func (a *Args) equal(r *Args) bool {
 	if a.Elided != r.Elided || len(a.Values) != len(r.Values) {
@@ -255,17 +325,27 @@ type Call struct {
 	// RelSrcPath is the relative path to GOROOT or GOPATH. Only set when
 	// Augment() is called.
 	RelSrcPath string
+	// SelectCases is the operation of each case of the select statement the
+	// call is blocked in, e.g. "<-ctx.Done()", "resCh<-" or "default". Only set
+	// by Augment() on the caller of runtime.selectgo.
+	SelectCases []string `json:",omitempty"`
+	// IsCgo is true for a C frame of a cgo call. The runtime prints them as
+	// "non-Go function" with only their program counter unless a cgo
+	// symbolizer is registered, see SymbolizeCgo.
+	IsCgo bool `json:",omitempty"`
+	// PC is the program counter of a C frame, when printed.
+	PC uint64 `json:",omitempty"`
 }
 
 // equal returns true only if both calls are exactly equal.
 func (c *Call) equal(r *Call) bool {
-	return c.SrcPath == r.SrcPath && c.Line == r.Line && c.Func == r.Func && c.Args.equal(&r.Args)
+	return c.SrcPath == r.SrcPath && c.Line == r.Line && c.PC == r.PC && c.Func.equal(&r.Func) && c.Args.equal(&r.Args)
 }
 
 // similar returns true if the two Call are equal or almost but not quite
 // equal.
 func (c *Call) similar(r *Call, similar Similarity) bool {
-	return c.SrcPath == r.SrcPath && c.Line == r.Line && c.Func == r.Func && c.Args.similar(&r.Args, similar)
+	return c.SrcPath == r.SrcPath && c.Line == r.Line && c.PC == r.PC && c.Func.equal(&r.Func) && c.Args.similar(&r.Args, similar)
 }
 
 // merge merges two similar Call, zapping out differences.
@@ -278,6 +358,9 @@ func (c *Call) merge(r *Call) Call {
 		Args:         c.Args.merge(&r.Args),
 		IsStdlib:     c.IsStdlib,
 		RelSrcPath:   c.RelSrcPath,
+		SelectCases:  c.SelectCases,
+		IsCgo:        c.IsCgo,
+		PC:           c.PC,
 	}
 }
 
@@ -336,6 +419,62 @@ func (c *Call) ImportPath() string {
 	return ""
 }
 
+// IsTrimmed returns true if the source path is relative, which happens when
+// the binary was built with -trimpath.
+func (c *Call) IsTrimmed() bool {
+	switch c.SrcPath {
+	case "", "??", "<autogenerated>", "<unavailable>":
+		return false
+	}
+	return !isAbsPath(c.SrcPath)
+}
+
+// ModuleSrcPath returns the source path relative to the root of all modules,
+// e.g. "github.com/foo/bar/baz.go" or "runtime/proc.go" for the standard
+// library.
+//
+// It returns the same value whether the binary was built with -trimpath or
+// not, and whatever the GOPATH or module cache location was on the builder.
+// The module version is stripped. This makes it suitable for display and to
+// compare call sites across builders.
+//
+// For package main built without -trimpath, the module path cannot be
+// deduced from the stack trace so only the file name is returned unless
+// guesspaths=true was used with ParseDump().
+func (c *Call) ModuleSrcPath() string {
+	p := strings.Replace(c.SrcPath, "\\", "/", -1)
+	switch p {
+	case "", "??", "<autogenerated>", "<unavailable>":
+		return p
+	}
+	if !isAbsPath(p) {
+		// -trimpath.
+		return stripModVersion(p)
+	}
+	if i := strings.LastIndex(p, "/pkg/mod/"); i != -1 {
+		return stripModVersion(p[i+len("/pkg/mod/"):])
+	}
+	if !c.IsPkgMain() {
+		if i := c.Func.importPath(); i != "" {
+			return i + "/" + path.Base(p)
+		}
+	}
+	if c.RelSrcPath != "" {
+		return stripModVersion(c.RelSrcPath)
+	}
+	if rel, ok := rewritePath(p); ok {
+		// Build system synthetic path, it is relative to the workspace root.
+		return rel
+	}
+	// Standard library packages without a "/" in their import path.
+	if pkg := c.Func.PkgName(); pkg != "" && !c.IsPkgMain() {
+		if strings.HasSuffix(p, "/src/"+pkg+"/"+path.Base(p)) {
+			return pkg + "/" + path.Base(p)
+		}
+	}
+	return path.Base(p)
+}
+
 const testMainSrc = "_test" + string(os.PathSeparator) + "_testmain.go"
 
 // updateLocations initializes LocalSrcPath, RelSrcPath and IsStdlib.
@@ -356,8 +495,10 @@ func (c *Call) updateLocations(goroot, localgoroot string, gopaths map[string]st
 			goto done
 		}
 	}
-	// TODO(Tchinmai7): Sort for deterministic behavior?
-	for prefix, dest := range gopaths {
+	// Iterate in a stable order, most specific prefix first, so the result
+	// doesn't depend on map iteration order.
+	for _, prefix := range sortedPrefixes(gopaths) {
+		dest := gopaths[prefix]
 		if p := prefix + "/src/"; strings.HasPrefix(c.SrcPath, p) {
 			c.RelSrcPath = c.SrcPath[len(p):]
 			c.LocalSrcPath = pathJoin(dest, "src", c.RelSrcPath)
@@ -370,6 +511,18 @@ func (c *Call) updateLocations(goroot, localgoroot string, gopaths map[string]st
 			goto done
 		}
 	}
+	if c.IsTrimmed() {
+		// With -trimpath, standard library paths are relative to GOROOT/src.
+		// The first path element of a module path usually is a domain name, but
+		// not always, e.g. "example/foo", so look for the package in GOROOT.
+		p := stripModVersion(c.SrcPath)
+		if first := strings.SplitN(p, "/", 2)[0]; !strings.Contains(first, ".") && first != "command-line-arguments" && isDir(pathJoin(localgoroot, "src", path.Dir(p))) {
+			c.RelSrcPath = p
+			c.LocalSrcPath = pathJoin(localgoroot, "src", p)
+			c.IsStdlib = true
+			goto done
+		}
+	}
 done:
 	if !c.IsStdlib {
 		// Consider _test/_testmain.go as stdlib since it's injected by "go test".
@@ -387,6 +540,15 @@ type Stack struct {
 	Elided bool
 }
 
+// Depth returns the number of frames between the goroutine creation and the
+// leaf function.
+//
+// When the stack was elided by the runtime, the returned value is a lower
+// bound.
+func (s *Stack) Depth() int {
+	return len(s.Calls)
+}
+
 // equal returns true on if both call stacks are exactly equal.
 func (s *Stack) equal(r *Stack) bool {
 	if len(s.Calls) != len(r.Calls) || s.Elided != r.Elided {
@@ -522,6 +684,9 @@ type Signature struct {
 	State string
 	// Createdby is the goroutine which created this one, if applicable.
 	CreatedBy Call
+	// CreatedByID is the ID of the goroutine which created this one, if
+	// applicable. It is only printed by Go 1.21 and later.
+	CreatedByID int
 	// SleepMin is the wait time in minutes, if applicable.
 	SleepMin int
 	// SleepMax is the wait time in minutes, if applicable.
@@ -534,7 +699,7 @@ type Signature struct {
 
 // equal returns true only if both signatures are exactly equal.
 func (s *Signature) equal(r *Signature) bool {
-	if s.State != r.State || !s.CreatedBy.equal(&r.CreatedBy) || s.Locked != r.Locked || s.SleepMin != r.SleepMin || s.SleepMax != r.SleepMax {
+	if s.State != r.State || !s.CreatedBy.equal(&r.CreatedBy) || s.CreatedByID != r.CreatedByID || s.Locked != r.Locked || s.SleepMin != r.SleepMin || s.SleepMax != r.SleepMax {
 		return false
 	}
 	return s.Stack.equal(&r.Stack)
@@ -562,13 +727,19 @@ func (s *Signature) merge(r *Signature) *Signature {
 	if r.SleepMax > max {
 		max = r.SleepMax
 	}
+	id := s.CreatedByID
+	if r.CreatedByID != id {
+		// Created by different goroutines.
+		id = 0
+	}
 	return &Signature{
-		State:     s.State,     // Drop right side.
-		CreatedBy: s.CreatedBy, // Drop right side.
-		SleepMin:  min,
-		SleepMax:  max,
-		Stack:     *s.Stack.merge(&r.Stack),
-		Locked:    s.Locked || r.Locked, // TODO(Tchinmai7): This is weirdo.
+		State:       s.State,     // Drop right side.
+		CreatedBy:   s.CreatedBy, // Drop right side.
+		CreatedByID: id,
+		SleepMin:    min,
+		SleepMax:    max,
+		Stack:       *s.Stack.merge(&r.Stack),
+		Locked:      s.Locked || r.Locked, // TODO(Tchinmai7): This is weirdo.
 	}
 }
 
@@ -641,13 +812,84 @@ type Goroutine struct {
 	// created it, etc.
 	Signature
 	// ID is the goroutine id.
+	//
+	// It is 0 for the pseudo goroutine with State "runtime stack", which is
+	// the system stack of the thread that called runtime.throw.
 	ID int
 	// First is the goroutine first printed, normally the one that crashed.
+	// When the input contains several dumps, it is set on the first goroutine
+	// of each dump, see Dump.
+	//
+	// It is never set on the "runtime stack" pseudo goroutine, which is
+	// printed before the goroutine that threw.
 	First bool
+	// Index is the 0-based position of the goroutine in the dump, e.g. 2 for
+	// the third goroutine printed. It is stable across filtering and
+	// aggregation. Like ID, it is only unique within a Source.
+	Index int
+	// Dump is the 0-based index of the dump this goroutine was printed in,
+	// when the input contains the dumps of several processes, e.g. aggregated
+	// logs. A crash header printed after goroutines, e.g. "panic: ", starts a
+	// new dump. Each dump is analyzed separately by ParseDump.
+	Dump int
+
+	// Span is the lines of the input this goroutine was parsed from, from the
+	// goroutine header to the last stack line.
+	Span Span
+	// CallSpans is the lines of the input each item in Stack.Calls was parsed
+	// from. It has the same length as Stack.Calls.
+	CallSpans []Span
+	// CreatedBySpan is the lines of the input CreatedBy was parsed from, if
+	// any.
+	CreatedBySpan Span
+	// Thread is the scheduling details of this goroutine, when present in the
+	// dump. It is printed with GOTRACEBACK=system or higher, or with
+	// GODEBUG=scheddetail=1.
+	Thread *ThreadInfo
+	// Raw is the verbatim text this goroutine was parsed from.
+	//
+	// Only set when Opts.KeepRaw is used.
+	Raw string
+	// Source is the label of the dump this goroutine comes from. It is only
+	// set by Merge.
+	//
+	// Goroutine IDs are only unique within a Source.
+	Source string
+	// Incomplete is true when a line inside this goroutine could not be
+	// parsed, so the stack may be truncated. See Context.FormatErrors.
+	Incomplete bool
 }
 
 // Private stuff.
 
+// newFuncNames derives the names from the raw function name.
+func newFuncNames(raw string) *FuncNames {
+	n := &FuncNames{}
+	n.String, _ = url.QueryUnescape(raw)
+	// The names are derived from the normalized symbol so they are the same
+	// across Go versions. The rewrites don't touch the escaped dots.
+	raw = normalizeSymbol(raw)
+	n.Normalized, _ = url.QueryUnescape(raw)
+	n.Display = displaySymbol(n.Normalized)
+	if typeAlgNames(n, n.Normalized) {
+		return n
+	}
+	// This works even on Windows as filepath.Base() splits also on "/".
+	// TODO(Tchinmai7): This code will fail on a source file with a dot in its name.
+	parts := strings.SplitN(filepath.Base(raw), ".", 2)
+	if len(parts) == 1 {
+		n.Name = parts[0]
+		n.PkgDotName = parts[0]
+		return n
+	}
+	n.Name = parts[1]
+	n.PkgName, _ = url.QueryUnescape(parts[0])
+	if n.PkgName != "" || parts[1] != "" {
+		n.PkgDotName = n.PkgName + "." + parts[1]
+	}
+	return n
+}
+
 // nameArguments is a post-processing step where Args are 'named' with numbers.
 func nameArguments(goroutines []*Goroutine) {
 	// Set a name for any pointer occurring more than once.
@@ -706,10 +948,49 @@ func nameArguments(goroutines []*Goroutine) {
 	}
 }
 
+// sortedPrefixes returns the keys of gopaths, longest first.
+func sortedPrefixes(gopaths map[string]string) []string {
+	out := make([]string, 0, len(gopaths))
+	for k := range gopaths {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
 func pathJoin(s ...string) string {
 	return strings.Join(s, "/")
 }
 
+// isAbsPath returns true if p is an absolute path, either POSIX or Windows
+// style, independent of the current OS.
+func isAbsPath(p string) bool {
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, "\\") {
+		return true
+	}
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '/' || p[2] == '\\')
+}
+
+// stripModVersion removes the "@version" suffix of module path elements, as
+// found in the module cache.
+func stripModVersion(p string) string {
+	if !strings.Contains(p, "@") {
+		return p
+	}
+	parts := strings.Split(p, "/")
+	for i, e := range parts {
+		if j := strings.IndexByte(e, '@'); j != -1 {
+			parts[i] = e[:j]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
 type uint64Slice []uint64
 
 func (a uint64Slice) Len() int           { return len(a) }
@@ -306,6 +306,33 @@ func TestArgs(t *testing.T) {
 	compareString(t, "yo", a.String())
 }
 
+func TestStackDepth(t *testing.T) {
+	t.Parallel()
+	calls := []Call{{Func: NewFunc("main.c")}, {Func: NewFunc("main.b")}, {Func: NewFunc("main.a")}}
+	data := []struct {
+		name string
+		s    Stack
+		want int
+	}{
+		{"empty", Stack{}, 0},
+		{"one", Stack{Calls: calls[:1]}, 1},
+		{"three", Stack{Calls: calls}, 3},
+		// The runtime elided the frames beyond the ones printed, so the depth
+		// is a lower bound.
+		{"elided", Stack{Calls: calls[:2], Elided: true}, 2},
+		{"elided_empty", Stack{Elided: true}, 0},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			if got := line.s.Depth(); got != line.want {
+				t.Fatalf("want %d, got %d", line.want, got)
+			}
+		})
+	}
+}
+
 func TestFuncAnonymous(t *testing.T) {
 	t.Parallel()
 	f := Func{Raw: "main.func·001"}
@@ -386,7 +413,7 @@ func TestSignature_Less(t *testing.T) {
 //
 
 func newFunc(s string) Func {
-	return Func{Raw: s}
+	return NewFunc(s)
 }
 
 func newCall(f string, a Args, s string, l int) Call {
@@ -576,6 +603,36 @@ func getSignature() *Signature {
 	}
 }
 
+// BenchmarkFuncNames measures the methods of a Func built with NewFunc, as
+// done by the parser, and BenchmarkFuncNamesDerived the same methods when
+// the names are derived on each call. Compare them to see the benefit of
+// precomputing the names.
+func BenchmarkFuncNames(b *testing.B) {
+	benchmarkFuncNames(b, NewFunc)
+}
+
+func BenchmarkFuncNamesDerived(b *testing.B) {
+	benchmarkFuncNames(b, func(raw string) Func { return Func{Raw: raw} })
+}
+
+func benchmarkFuncNames(b *testing.B, newF func(string) Func) {
+	b.ReportAllocs()
+	f := []Func{
+		newF("github.com/maruel/panicparse/cmd/panic/internal/%c3%b9tf8.(*Strùct).Pànic"),
+		newF("main.main"),
+		newF("sync.(*WaitGroup).Wait"),
+		newF("gopkg.in/yaml%2ev2.handleErr"),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range f {
+			if f[j].Name() == "" || f[j].PkgName() == "" || f[j].PkgDotName() == "" {
+				b.Fatal("unexpected empty name")
+			}
+		}
+	}
+}
+
 func compareCalls(t *testing.T, want, got *Call) {
 	helper(t)()
 	if diff := cmp.Diff(want, got); diff != "" {
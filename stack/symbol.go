@@ -0,0 +1,120 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Normalized returns String() rewritten in the spelling of the latest Go
+// version for the compiler generated and assembly symbols whose name changed
+// across versions, e.g. "type..eq.main.T" becomes "type:.eq.main.T" and the
+// ABI wrapper suffix ".abi0" is removed.
+//
+// Calls are compared by their normalized function name, so the same frame
+// buckets together in dumps from different Go versions.
+func (f *Func) Normalized() string {
+	return f.names().Normalized
+}
+
+// DisplayName returns a human readable name for the compiler generated and
+// assembly symbols, e.g. "== on main.T" for "type:.eq.main.T" or
+// "runtime.duffcopy (block copy)". It is Normalized() for other functions.
+func (f *Func) DisplayName() string {
+	return f.names().Display
+}
+
+// Private stuff.
+
+var (
+	// reSymbolRewrites rewrites the symbols renamed across Go versions to their
+	// latest spelling.
+	reSymbolRewrites = []struct {
+		re   *regexp.Regexp
+		repl string
+	}{
+		// Go 1.21 renamed the compiler generated type algorithms.
+		{regexp.MustCompile(`^type\.\.`), "type:."},
+		// Go 1.21 renamed the closures of package level variables.
+		{regexp.MustCompile(`\.glob\.\.func(\d+)`), ".init.func$1"},
+		// The write barrier was split per register before Go 1.17 and per
+		// number of pointers since Go 1.21.
+		{regexp.MustCompile(`^runtime\.gcWriteBarrier(?:\d|[A-Z0-9]{2})?$`), "runtime.gcWriteBarrier"},
+		// ABI wrappers, Go 1.17+, have the name of the wrapped function.
+		{regexp.MustCompile(`\.(?:abi0|abiinternal)$`), ""},
+	}
+	// reTypeAlg matches a compiler generated type algorithm, e.g.
+	// "type:.eq.main.T" or "type:.hash.[2]interface {}".
+	reTypeAlg = regexp.MustCompile(`^type:\.(eq|hash)\.(.+)$`)
+)
+
+// symbolDisplay is the description of runtime assembly helpers.
+var symbolDisplay = map[string]string{
+	"runtime.duffcopy":             "runtime.duffcopy (block copy)",
+	"runtime.duffzero":             "runtime.duffzero (block zero)",
+	"runtime.gcWriteBarrier":       "runtime.gcWriteBarrier (write barrier)",
+	"runtime.memmove":              "runtime.memmove (memory copy)",
+	"runtime.memclrNoHeapPointers": "runtime.memclrNoHeapPointers (memory clear)",
+	"runtime.morestack":            "runtime.morestack (stack growth)",
+	"runtime.morestack_noctxt":     "runtime.morestack_noctxt (stack growth)",
+}
+
+// normalizeSymbol rewrites the unmangled symbol s to the latest spelling.
+func normalizeSymbol(s string) string {
+	// Assembly symbols use a middle dot as package separator. Don't touch the
+	// closures of Go 1.4 and earlier, e.g. "main.func·001".
+	base := s[strings.LastIndexByte(s, '/')+1:]
+	if i := strings.Index(base, "·"); i != -1 && strings.IndexByte(base[:i], '.') == -1 {
+		s = s[:len(s)-len(base)] + base[:i] + "." + base[i+len("·"):]
+	}
+	for _, r := range reSymbolRewrites {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+	return s
+}
+
+// displaySymbol returns the human readable name of the normalized symbol s.
+func displaySymbol(s string) string {
+	if d := symbolDisplay[s]; d != "" {
+		return d
+	}
+	if m := reTypeAlg.FindStringSubmatch(s); m != nil {
+		if m[1] == "eq" {
+			return "== on " + m[2]
+		}
+		return "hash of " + m[2]
+	}
+	if strings.HasSuffix(s, "-fm") {
+		return s[:len(s)-3] + " (method value)"
+	}
+	return s
+}
+
+// typeAlgNames sets the package and function names of a type algorithm to the
+// ones of the type, so the frame is attributed to the package defining it.
+//
+// Returns false if s is not a type algorithm.
+func typeAlgNames(n *FuncNames, s string) bool {
+	m := reTypeAlg.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	typ := m[2]
+	n.Name = "type:." + m[1] + "." + typ
+	n.PkgName = ""
+	n.PkgDotName = n.Name
+	// Only named types have a package, e.g. not "[2]interface {}".
+	if i := strings.IndexAny(typ, "[]{} *("); i == -1 {
+		parts := strings.SplitN(filepath.Base(typ), ".", 2)
+		if len(parts) == 2 {
+			n.PkgName = parts[0]
+			n.Name = "type:." + m[1] + "." + parts[1]
+			n.PkgDotName = n.PkgName + "." + n.Name
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFuncSymbols(t *testing.T) {
+	t.Parallel()
+	type names struct {
+		Normalized string
+		Display    string
+		PkgName    string
+		Name       string
+		PkgDotName string
+	}
+	data := []struct {
+		raw  string
+		want names
+	}{
+		{
+			"type..eq.main.T",
+			names{"type:.eq.main.T", "== on main.T", "main", "type:.eq.T", "main.type:.eq.T"},
+		},
+		{
+			"type:.eq.github.com/foo/bar.T",
+			names{"type:.eq.github.com/foo/bar.T", "== on github.com/foo/bar.T", "bar", "type:.eq.T", "bar.type:.eq.T"},
+		},
+		{
+			"type..hash.[2]interface {}",
+			names{"type:.hash.[2]interface {}", "hash of [2]interface {}", "", "type:.hash.[2]interface {}", "type:.hash.[2]interface {}"},
+		},
+		{
+			"runtime.gcWriteBarrierDX",
+			names{"runtime.gcWriteBarrier", "runtime.gcWriteBarrier (write barrier)", "runtime", "gcWriteBarrier", "runtime.gcWriteBarrier"},
+		},
+		{
+			"runtime.gcWriteBarrier2",
+			names{"runtime.gcWriteBarrier", "runtime.gcWriteBarrier (write barrier)", "runtime", "gcWriteBarrier", "runtime.gcWriteBarrier"},
+		},
+		{
+			"runtime.duffcopy",
+			names{"runtime.duffcopy", "runtime.duffcopy (block copy)", "runtime", "duffcopy", "runtime.duffcopy"},
+		},
+		{
+			"runtime·memmove",
+			names{"runtime.memmove", "runtime.memmove (memory copy)", "runtime", "memmove", "runtime.memmove"},
+		},
+		{
+			"runtime.morestack_noctxt.abi0",
+			names{"runtime.morestack_noctxt", "runtime.morestack_noctxt (stack growth)", "runtime", "morestack_noctxt", "runtime.morestack_noctxt"},
+		},
+		{
+			"main.(*T).M-fm",
+			names{"main.(*T).M-fm", "main.(*T).M (method value)", "main", "(*T).M-fm", "main.(*T).M-fm"},
+		},
+		{
+			"gopkg.in/yaml%2ev2.glob..func1",
+			names{"gopkg.in/yaml.v2.init.func1", "gopkg.in/yaml.v2.init.func1", "yaml.v2", "init.func1", "yaml.v2.init.func1"},
+		},
+	}
+	for i, line := range data {
+		f := Func{Raw: line.raw}
+		got := names{f.Normalized(), f.DisplayName(), f.PkgName(), f.Name(), f.PkgDotName()}
+		if diff := cmp.Diff(line.want, got); diff != "" {
+			t.Errorf("#%d: %q mismatch (-want +got):\n%s", i, line.raw, diff)
+		}
+	}
+}
+
+func TestAggregateAcrossGoVersions(t *testing.T) {
+	t.Parallel()
+	g := func(id int, fn string) *Goroutine {
+		return &Goroutine{
+			Signature: Signature{
+				State: "running",
+				Stack: Stack{Calls: []Call{newCall(fn, Args{}, "<autogenerated>", 1)}},
+			},
+			ID: id,
+		}
+	}
+	b := Aggregate([]*Goroutine{g(1, "type..eq.main.T"), g(2, "type:.eq.main.T")}, AnyPointer)
+	if len(b) != 1 || len(b[0].IDs) != 2 {
+		t.Fatalf("expected a single bucket, got %#v", b)
+	}
+}
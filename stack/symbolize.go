@@ -0,0 +1,193 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SymbolizedFrame is a source location resolved from a program counter.
+type SymbolizedFrame struct {
+	// Func is the function name, e.g. "crash" or "ns::Foo::bar(int)".
+	Func string
+	// SrcPath is the source file, e.g. "/src/crash.c".
+	SrcPath string
+	// Line is the line number.
+	Line int
+}
+
+// Symbolizer resolves program counters to source locations.
+type Symbolizer interface {
+	// Symbolize returns the frames at pc, the innermost inlined one first. It
+	// returns no frame if the program counter can't be resolved.
+	Symbolize(pc uint64) ([]SymbolizedFrame, error)
+}
+
+// SymbolizeCgo resolves the C frames printed with only their program
+// counter, see Call.IsCgo, with s. Each resolved call is replaced by its
+// frames, which keep IsCgo and PC. The frames that can't be resolved are
+// left as is.
+//
+// The dump must come from the binary and shared libraries known to s.
+func SymbolizeCgo(c *Context, s Symbolizer) error {
+	cache := map[uint64][]SymbolizedFrame{}
+	for _, g := range c.Goroutines {
+		if !hasUnresolvedCgo(g.Stack.Calls) {
+			continue
+		}
+		calls := make([]Call, 0, len(g.Stack.Calls))
+		var spans []Span
+		for i := range g.Stack.Calls {
+			call := &g.Stack.Calls[i]
+			var frames []SymbolizedFrame
+			if call.IsCgo && call.PC != 0 && call.Func.Raw == nonGoFunction {
+				var ok bool
+				if frames, ok = cache[call.PC]; !ok {
+					var err error
+					if frames, err = s.Symbolize(call.PC); err != nil {
+						return err
+					}
+					cache[call.PC] = frames
+				}
+			}
+			if len(frames) == 0 {
+				calls = append(calls, *call)
+				if i < len(g.CallSpans) {
+					spans = append(spans, g.CallSpans[i])
+				}
+				continue
+			}
+			for _, f := range frames {
+				calls = append(calls, Call{Func: NewFunc(f.Func), SrcPath: f.SrcPath, Line: f.Line, IsCgo: true, PC: call.PC})
+				if i < len(g.CallSpans) {
+					spans = append(spans, g.CallSpans[i])
+				}
+			}
+		}
+		g.Stack.Calls = calls
+		if g.CallSpans != nil {
+			g.CallSpans = spans
+		}
+	}
+	return nil
+}
+
+// Module is a binary or shared library mapped in the address space of the
+// process.
+type Module struct {
+	// Path is the file, e.g. "/usr/lib/libfoo.so".
+	Path string
+	// Start and End is the range of addresses where it is mapped.
+	Start uint64
+	End   uint64
+	// Offset is the file offset mapped at Start, as in /proc/<pid>/maps.
+	Offset uint64
+}
+
+// ExecSymbolizer is a Symbolizer running addr2line or llvm-symbolizer.
+type ExecSymbolizer struct {
+	// Tool is "addr2line", "llvm-symbolizer" or the path to one of them. The
+	// output format is determined by its base name. Defaults to "addr2line".
+	Tool string
+	// Binary is the executable that crashed. The program counters outside of
+	// Modules are resolved in it as is, which is correct for an executable
+	// that is not position independent.
+	Binary string
+	// Modules is the shared libraries, and the executable when position
+	// independent, with the addresses where they were loaded.
+	Modules []Module
+}
+
+// Symbolize implements Symbolizer.
+func (e *ExecSymbolizer) Symbolize(pc uint64) ([]SymbolizedFrame, error) {
+	file, addr := e.Binary, pc
+	for _, m := range e.Modules {
+		if pc >= m.Start && pc < m.End {
+			file, addr = m.Path, pc-m.Start+m.Offset
+			break
+		}
+	}
+	if file == "" {
+		return nil, nil
+	}
+	tool := e.Tool
+	if tool == "" {
+		tool = "addr2line"
+	}
+	a := "0x" + strconv.FormatUint(addr, 16)
+	var args []string
+	llvm := strings.Contains(filepath.Base(tool), "llvm-symbolizer")
+	if llvm {
+		args = []string{"--inlining", "--demangle", "--obj=" + file, a}
+	} else {
+		args = []string{"-f", "-C", "-i", "-e", file, a}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(tool, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", tool, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseSymbolizerOutput(stdout.String()), nil
+}
+
+// Private stuff.
+
+func hasUnresolvedCgo(calls []Call) bool {
+	for i := range calls {
+		if calls[i].IsCgo && calls[i].Func.Raw == nonGoFunction {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSymbolizerOutput parses the pairs of function and location lines
+// printed by addr2line -f and llvm-symbolizer, e.g. "crash" followed by
+// "/src/crash.c:12" or "/src/crash.c:12:3". Unknown values are printed as
+// "??".
+func parseSymbolizerOutput(out string) []SymbolizedFrame {
+	var lines []string
+	for _, l := range strings.Split(out, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	var frames []SymbolizedFrame
+	for i := 0; i+1 < len(lines); i += 2 {
+		f := SymbolizedFrame{Func: lines[i], SrcPath: lines[i+1]}
+		// addr2line appends " (discriminator N)".
+		if j := strings.Index(f.SrcPath, " ("); j != -1 {
+			f.SrcPath = f.SrcPath[:j]
+		}
+		// Strip the column then the line, if present.
+		for k := 0; k < 2; k++ {
+			j := strings.LastIndexByte(f.SrcPath, ':')
+			if j == -1 {
+				break
+			}
+			n, err := strconv.Atoi(f.SrcPath[j+1:])
+			if err != nil {
+				if f.SrcPath[j+1:] == "?" {
+					f.SrcPath = f.SrcPath[:j]
+				}
+				break
+			}
+			f.Line = n
+			f.SrcPath = f.SrcPath[:j]
+		}
+		if f.Func == "??" && f.SrcPath == "??" {
+			continue
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const cgoDump = "SIGSEGV: segmentation violation\n" +
+	"PC=0x7f3b2c1d4e5f m=0 sigcode=1\n" +
+	"signal arrived during cgo execution\n" +
+	"\n" +
+	"goroutine 1 [syscall]:\n" +
+	"non-Go function\n" +
+	"\tpc=0x7f3b2c1d4e5f\n" +
+	"non-Go function\n" +
+	"\tpc=0x7f3b2c1d4000\n" +
+	"runtime.cgocall(0x4a1b20, 0xc000057f60)\n" +
+	"\t/usr/local/go/src/runtime/cgocall.go:157 +0x4b fp=0xc000057f38 sp=0xc000057f00 pc=0x40652b\n" +
+	"main._Cfunc_crash()\n" +
+	"\t_cgo_gotypes.go:39 +0x45\n" +
+	"main.main()\n" +
+	"\t/home/user/src/foo/main.go:10 +0x17\n"
+
+func TestParseDumpCgo(t *testing.T) {
+	t.Parallel()
+	c, err := ParseDump(strings.NewReader(cgoDump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 1 || len(c.FormatErrors) != 0 {
+		t.Fatalf("unexpected context: %#v", c)
+	}
+	calls := c.Goroutines[0].Stack.Calls
+	if len(calls) != 5 {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+	want := Call{Func: newFunc("non-Go function"), SrcPath: "??", IsCgo: true, PC: 0x7f3b2c1d4e5f}
+	if diff := cmp.Diff(want, calls[0]); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if calls[1].PC != 0x7f3b2c1d4000 || calls[2].Func.Raw != "runtime.cgocall" || calls[2].IsCgo {
+		t.Fatalf("unexpected calls: %#v", calls)
+	}
+}
+
+func TestSymbolizeCgo(t *testing.T) {
+	t.Parallel()
+	c, err := ParseDump(strings.NewReader(cgoDump), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := fakeSymbolizer{
+		0x7f3b2c1d4e5f: {{Func: "inner", SrcPath: "/src/crash.c", Line: 5}, {Func: "crash", SrcPath: "/src/crash.c", Line: 12}},
+	}
+	if err := SymbolizeCgo(c, s); err != nil {
+		t.Fatal(err)
+	}
+	g := c.Goroutines[0]
+	var got []string
+	for _, call := range g.Stack.Calls {
+		got = append(got, call.Func.Raw+" "+call.SrcPath)
+	}
+	want := []string{
+		"inner /src/crash.c",
+		"crash /src/crash.c",
+		"non-Go function ??",
+		"runtime.cgocall /usr/local/go/src/runtime/cgocall.go",
+		"main._Cfunc_crash _cgo_gotypes.go",
+		"main.main /home/user/src/foo/main.go",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if len(g.CallSpans) != len(g.Stack.Calls) || g.CallSpans[0] != g.CallSpans[1] || !g.Stack.Calls[1].IsCgo {
+		t.Fatalf("unexpected goroutine: %#v", g)
+	}
+}
+
+func TestParseSymbolizerOutput(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want []SymbolizedFrame
+	}{
+		{"crash\n/src/crash.c:12\n", []SymbolizedFrame{{"crash", "/src/crash.c", 12}}},
+		{"inner\n/src/crash.c:5:3\ncrash\n/src/crash.c:12:1\n\n", []SymbolizedFrame{{"inner", "/src/crash.c", 5}, {"crash", "/src/crash.c", 12}}},
+		{"crash\n/src/crash.c:12 (discriminator 2)\n", []SymbolizedFrame{{"crash", "/src/crash.c", 12}}},
+		{"crash\n??:?\n", []SymbolizedFrame{{"crash", "??", 0}}},
+		{"??\n??:0\n", nil},
+	}
+	for i, line := range data {
+		if diff := cmp.Diff(line.want, parseSymbolizerOutput(line.in)); diff != "" {
+			t.Errorf("#%d: mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestExecSymbolizer(t *testing.T) {
+	t.Parallel()
+	bin, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := uint64(reflect.ValueOf(symbolizeTarget).Pointer())
+	for _, tool := range []string{"addr2line", "llvm-symbolizer"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Logf("%s not found", tool)
+			continue
+		}
+		s := &ExecSymbolizer{Tool: tool, Binary: bin}
+		frames, err := s.Symbolize(pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) == 0 {
+			// The test binary may be stripped or position independent.
+			t.Logf("%s: not resolved", tool)
+			continue
+		}
+		// The line is not checked, the debug information may be partial.
+		if f := frames[len(frames)-1]; !strings.HasSuffix(f.Func, "symbolizeTarget") {
+			t.Fatalf("%s: unexpected frames: %#v", tool, frames)
+		}
+		// A module mapping translates the address.
+		s.Modules = []Module{{Path: bin, Start: 0x100000000000, End: 0x200000000000}}
+		if frames, err = s.Symbolize(0x100000000000 + pc); err != nil || len(frames) == 0 || !strings.HasSuffix(frames[len(frames)-1].Func, "symbolizeTarget") {
+			t.Fatalf("%s: unexpected frames: %#v, %v", tool, frames, err)
+		}
+	}
+}
+
+type fakeSymbolizer map[uint64][]SymbolizedFrame
+
+func (f fakeSymbolizer) Symbolize(pc uint64) ([]SymbolizedFrame, error) {
+	return f[pc], nil
+}
+
+//go:noinline
+func symbolizeTarget() int {
+	return 42
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ThreadInfo is the scheduling details of a goroutine: the OS thread (M) and
+// processor (P) it was running on.
+//
+// Fields that were not printed in the dump are set to -1 or 0.
+type ThreadInfo struct {
+	// GP is the address of the runtime g struct of the goroutine.
+	GP uint64
+	// M is the ID of the OS thread running the goroutine, -1 if none.
+	M int
+	// MP is the address of the runtime m struct of the OS thread.
+	MP uint64
+	// P is the ID of the processor held by the OS thread, -1 if none.
+	P int
+	// LockedM is the ID of the OS thread the goroutine is locked to via
+	// runtime.LockOSThread(), -1 if none.
+	LockedM int
+}
+
+// Private stuff.
+
+var (
+	// "G1: status=4(chan receive) m=-1 lockedm=-1"
+	reSchedG = regexp.MustCompile(`^\s*G(\d+): status=\d+\([^)]*\)((?: [a-z]+=-?\d+)*)`)
+	// "M0: p=0 curg=1 mallocing=0 throwing=0 preemptoff= locks=0 dying=0 spinningthreads=0 lockedg=-1"
+	reSchedM = regexp.MustCompile(`^\s*M(\d+): p=(-?\d+) curg=(-?\d+)`)
+)
+
+func newThreadInfo() *ThreadInfo {
+	return &ThreadInfo{M: -1, P: -1, LockedM: -1}
+}
+
+// parseThreadHeader parses the " gp=0x123 m=0 mp=0x456" part of a goroutine
+// header.
+func parseThreadHeader(s string) *ThreadInfo {
+	t := newThreadInfo()
+	for _, kv := range strings.Fields(s) {
+		i := strings.IndexByte(kv, '=')
+		if i == -1 {
+			continue
+		}
+		k, v := kv[:i], kv[i+1:]
+		switch k {
+		case "gp":
+			t.GP, _ = strconv.ParseUint(v, 0, 64)
+		case "m":
+			if m, err := strconv.Atoi(v); err == nil {
+				t.M = m
+			}
+		case "mp":
+			t.MP, _ = strconv.ParseUint(v, 0, 64)
+		case "lockedm":
+			if m, err := strconv.Atoi(v); err == nil {
+				t.LockedM = m
+			}
+		}
+	}
+	return t
+}
+
+// parseSchedDetail looks for the GODEBUG=scheddetail=1 output in the segments
+// and attaches the thread information to the corresponding goroutines.
+func parseSchedDetail(goroutines []*Goroutine, segments []Segment) {
+	byID := map[int]*Goroutine{}
+	for _, g := range goroutines {
+		byID[g.ID] = g
+	}
+	get := func(id int) *ThreadInfo {
+		g := byID[id]
+		if g == nil {
+			return nil
+		}
+		if g.Thread == nil {
+			g.Thread = newThreadInfo()
+		}
+		return g.Thread
+	}
+	for _, seg := range segments {
+		if !strings.Contains(seg.Text, "status=") && !strings.Contains(seg.Text, "curg=") {
+			continue
+		}
+		for _, line := range strings.Split(seg.Text, "\n") {
+			if m := reSchedG.FindStringSubmatch(line); m != nil {
+				id, _ := strconv.Atoi(m[1])
+				if t := get(id); t != nil {
+					for _, kv := range strings.Fields(m[2]) {
+						i := strings.IndexByte(kv, '=')
+						v, _ := strconv.Atoi(kv[i+1:])
+						switch kv[:i] {
+						case "m":
+							t.M = v
+						case "lockedm":
+							t.LockedM = v
+						}
+					}
+				}
+			} else if m := reSchedM.FindStringSubmatch(line); m != nil {
+				id, _ := strconv.Atoi(m[1])
+				p, _ := strconv.Atoi(m[2])
+				curg, _ := strconv.Atoi(m[3])
+				if t := get(curg); t != nil {
+					t.M = id
+					t.P = p
+				}
+			}
+		}
+	}
+}
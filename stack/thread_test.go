@@ -0,0 +1,55 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDumpThreadInfo(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"SCHED 0ms: gomaxprocs=8 idleprocs=7 threads=5 spinningthreads=0 idlethreads=2 runqueue=0 gcwaiting=false nmidlelocked=0 stopwait=0 sysmonwait=false",
+		"  P0: status=1 schedtick=0 syscalltick=0 m=0 runqsize=0 gfreecnt=0 timerslen=0",
+		"  M0: p=0 curg=1 mallocing=0 throwing=0 preemptoff= locks=0 dying=0 spinningthreads=0 lockedg=-1",
+		"  G1: status=2(chan receive) m=0 lockedm=-1",
+		"  G17: status=4(syscall) m=-1 lockedm=3",
+		"",
+		"goroutine 1 gp=0xc000002380 m=0 mp=0x5a3f40 [running]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"",
+		"goroutine 17 gp=0xc000102000 m=nil [syscall, locked to thread]:",
+		"main.f()",
+		"	/home/user/src/foo/main.go:20 +0x45",
+		"",
+		"goroutine 18 [chan receive]:",
+		"main.g()",
+		"	/home/user/src/foo/main.go:30 +0x45",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Goroutines) != 3 {
+		t.Fatalf("expected 3 goroutines, got %d", len(c.Goroutines))
+	}
+	want := []*ThreadInfo{
+		{GP: 0xc000002380, M: 0, MP: 0x5a3f40, P: 0, LockedM: -1},
+		{GP: 0xc000102000, M: -1, P: -1, LockedM: 3},
+		nil,
+	}
+	for i, g := range c.Goroutines {
+		if diff := cmp.Diff(want[i], g.Thread); diff != "" {
+			t.Errorf("#%d: ThreadInfo mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+	compareBool(t, true, c.Goroutines[1].Locked)
+	compareString(t, "syscall", c.Goroutines[1].State)
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"sort"
+)
+
+// TopK tracks the K largest buckets approximately, in bounded memory.
+//
+// It uses the space-saving algorithm: when a goroutine doesn't match any of
+// the K tracked buckets, the smallest bucket is evicted and replaced. The
+// replacement inherits the evicted count, which is recorded in
+// Bucket.Overcount. Buckets larger than Total()/K are guaranteed to be
+// tracked.
+//
+// Use it with Opts.OnGoroutine for dumps where even Aggregator would use too
+// much memory because of the number of distinct signatures.
+type TopK struct {
+	k       int
+	similar Similarity
+	total   int
+	entries []*topEntry
+}
+
+// NewTopK returns a TopK tracking k buckets merged at the specified
+// similarity level.
+func NewTopK(k int, similar Similarity) *TopK {
+	if k < 1 {
+		k = 1
+	}
+	return &TopK{k: k, similar: similar, entries: make([]*topEntry, 0, k)}
+}
+
+// Add counts a goroutine.
+//
+// The goroutine is not retained.
+func (t *TopK) Add(routine *Goroutine) {
+	t.total++
+	for _, e := range t.entries {
+		if e.key.similar(&routine.Signature, t.similar) {
+			e.count++
+			e.first = e.first || routine.First
+			e.addID(routine.ID)
+			if !e.key.equal(&routine.Signature) {
+				e.key = e.key.merge(&routine.Signature)
+			}
+			return
+		}
+	}
+	// Create a deep copy of the Signature, like Aggregator, so the goroutine
+	// is never mutated via the buckets.
+	key := routine.Signature.Clone()
+	if len(t.entries) < t.k {
+		t.entries = append(t.entries, &topEntry{key: key, count: 1, first: routine.First, ids: []int{routine.ID}})
+		return
+	}
+	// Evict the smallest bucket.
+	min := t.entries[0]
+	for _, e := range t.entries[1:] {
+		if e.count < min.count {
+			min = e
+		}
+	}
+	*min = topEntry{key: key, count: min.count + 1, over: min.count, first: routine.First, ids: []int{routine.ID}}
+}
+
+// Total returns the number of goroutines added, including the ones in buckets
+// that were evicted.
+func (t *TopK) Total() int {
+	return t.total
+}
+
+// Buckets returns the tracked buckets, largest first.
+//
+// Bucket.Count is set, and IDs is a sample of the goroutines in each bucket.
+func (t *TopK) Buckets() []*Bucket {
+	out := make([]*Bucket, 0, len(t.entries))
+	for _, e := range t.entries {
+		ids := make([]int, len(e.ids))
+		copy(ids, e.ids)
+		sort.Ints(ids)
+		out = append(out, &Bucket{Signature: *e.key.Clone(), IDs: ids, Count: e.count, Overcount: e.over, First: e.first})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Count > out[j].Count
+	})
+	return out
+}
+
+// Private stuff.
+
+// maxTopKIDs is the number of goroutine IDs kept per bucket by TopK.
+const maxTopKIDs = 10
+
+type topEntry struct {
+	key   *Signature
+	count int
+	over  int
+	first bool
+	ids   []int
+}
+
+func (e *topEntry) addID(id int) {
+	if len(e.ids) < maxTopKIDs {
+		e.ids = append(e.ids, id)
+	}
+}
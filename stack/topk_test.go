@@ -0,0 +1,70 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	t.Parallel()
+	newG := func(id int, f string) *Goroutine {
+		return &Goroutine{
+			Signature: Signature{
+				State: "chan receive",
+				Stack: Stack{Calls: []Call{{Func: newFunc(f), SrcPath: "/gopath/src/foo/main.go", Line: 10}}},
+			},
+			ID: id,
+		}
+	}
+	k := NewTopK(2, AnyPointer)
+	id := 1
+	add := func(f string, n int) {
+		for i := 0; i < n; i++ {
+			k.Add(newG(id, f))
+			id++
+		}
+	}
+	add("main.a", 20)
+	add("main.b", 1)
+	add("main.c", 1)
+	add("main.d", 15)
+	if k.Total() != 37 {
+		t.Fatalf("want 37, got %d", k.Total())
+	}
+	b := k.Buckets()
+	if len(b) != 2 {
+		t.Fatalf("want 2 buckets, got %d", len(b))
+	}
+	if b[0].Stack.Calls[0].Func.Raw != "main.a" || b[0].Size() != 20 || b[0].Overcount != 0 || len(b[0].IDs) != maxTopKIDs {
+		t.Fatalf("unexpected bucket: %#v", b[0])
+	}
+	// main.d replaced main.c, which replaced main.b.
+	if b[1].Stack.Calls[0].Func.Raw != "main.d" || b[1].Size() != 17 || b[1].Overcount != 2 {
+		t.Fatalf("unexpected bucket: %#v", b[1])
+	}
+}
+
+func TestTopKNoAliasing(t *testing.T) {
+	t.Parallel()
+	g := &Goroutine{
+		Signature: Signature{
+			State: "running",
+			Stack: Stack{Calls: []Call{{Func: newFunc("main.a"), SrcPath: "/gopath/src/foo/main.go", Line: 10}}},
+		},
+		ID: 1,
+	}
+	k := NewTopK(1, AnyPointer)
+	k.Add(g)
+	b := k.Buckets()
+	b[0].Stack.Calls[0].Line = 42
+	if g.Stack.Calls[0].Line != 10 {
+		t.Fatal("the goroutine was mutated via the bucket")
+	}
+	g.Stack.Calls[0].Line = 43
+	if l := k.Buckets()[0].Stack.Calls[0].Line; l != 10 {
+		t.Fatalf("the bucket was mutated via the goroutine or a previous bucket: %d", l)
+	}
+}
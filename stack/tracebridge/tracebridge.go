@@ -0,0 +1,220 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package tracebridge rebuilds goroutine snapshots from a runtime execution
+// trace, as recorded with runtime/trace, so the analyses of package stack can
+// run on them.
+//
+// This package doesn't read trace files by itself yet: it only builds the
+// snapshots from events. Decoding the binary trace format is left to
+// golang.org/x/exp/trace, which tracks the format across Go versions and is
+// not a dependency of this module; the caller converts each goroutine
+// transition it reads into an Event:
+//
+//	for {
+//		ev, err := r.ReadEvent()
+//		...
+//		if ev.Kind() != trace.EventStateTransition {
+//			continue
+//		}
+//		st := ev.StateTransition()
+//		if st.Resource.Kind != trace.ResourceGoroutine {
+//			continue
+//		}
+//		from, to := st.Goroutine()
+//		b.Add(tracebridge.Event{Time: time.Duration(ev.Time()), ...})
+//	}
+package tracebridge
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+// State is the state of a goroutine in the trace.
+type State int
+
+const (
+	// NotExist is a goroutine that was not created yet or that exited.
+	NotExist State = iota
+	// Runnable is a goroutine waiting for a CPU.
+	Runnable
+	// Running is a goroutine executing.
+	Running
+	// Waiting is a goroutine blocked, see Event.Reason.
+	Waiting
+	// Syscall is a goroutine in a system call.
+	Syscall
+)
+
+// Frame is a stack frame as recorded in the trace.
+type Frame struct {
+	Func string
+	File string
+	Line int
+}
+
+// Event is a goroutine state transition.
+type Event struct {
+	// Time is the time of the event since the start of the trace.
+	Time time.Duration
+	// ID is the goroutine ID.
+	ID int
+	// From and To is the state transition. A transition from NotExist is a
+	// goroutine creation, a transition to NotExist is the goroutine exiting.
+	From State
+	To   State
+	// Reason is the reason for blocking when To is Waiting, e.g. "chan
+	// receive".
+	Reason string
+	// Stack is the stack of the goroutine at the time of the transition,
+	// innermost first. It may be empty.
+	Stack []Frame
+	// Creator is the ID of the goroutine that created this one, when From is
+	// NotExist.
+	Creator int
+	// CreatorStack is the stack of the creator at the creation, innermost
+	// first.
+	CreatorStack []Frame
+}
+
+// Snapshot is the goroutines at a point in time.
+type Snapshot struct {
+	Time    time.Duration
+	Context *stack.Context
+}
+
+// Builder tracks the goroutines from the events.
+type Builder struct {
+	now        time.Duration
+	goroutines map[int]*goroutine
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{goroutines: map[int]*goroutine{}}
+}
+
+// Add applies an event. Events must be added in time order.
+func (b *Builder) Add(e Event) {
+	b.now = e.Time
+	if e.To == NotExist {
+		delete(b.goroutines, e.ID)
+		return
+	}
+	g := b.goroutines[e.ID]
+	if g == nil {
+		g = &goroutine{id: e.ID, first: len(b.goroutines) == 0 && e.ID == 1}
+		b.goroutines[e.ID] = g
+	}
+	if e.From == NotExist {
+		g.creator = e.Creator
+		if len(e.CreatorStack) != 0 {
+			g.createdBy = toCall(e.CreatorStack[0])
+		}
+	}
+	g.state = e.To
+	g.reason = e.Reason
+	g.since = e.Time
+	if len(e.Stack) != 0 {
+		g.stack = e.Stack
+	}
+}
+
+// Snapshot returns the goroutines alive after the events added so far.
+//
+// Returns nil if there is no goroutine.
+func (b *Builder) Snapshot() *Snapshot {
+	if len(b.goroutines) == 0 {
+		return nil
+	}
+	c := &stack.Context{}
+	for _, g := range b.goroutines {
+		c.Goroutines = append(c.Goroutines, g.toGoroutine(b.now))
+	}
+	sort.Slice(c.Goroutines, func(i, j int) bool {
+		return c.Goroutines[i].ID < c.Goroutines[j].ID
+	})
+	return &Snapshot{Time: b.now, Context: c}
+}
+
+// Snapshots replays the events and returns a snapshot every interval.
+func Snapshots(events []Event, interval time.Duration) []Snapshot {
+	var out []Snapshot
+	b := NewBuilder()
+	next := interval
+	for _, e := range events {
+		for interval > 0 && e.Time >= next {
+			if s := b.Snapshot(); s != nil {
+				s.Time = next
+				out = append(out, *s)
+			}
+			next += interval
+		}
+		b.Add(e)
+	}
+	if s := b.Snapshot(); s != nil {
+		out = append(out, *s)
+	}
+	return out
+}
+
+// Private stuff.
+
+type goroutine struct {
+	id        int
+	first     bool
+	state     State
+	reason    string
+	since     time.Duration
+	stack     []Frame
+	creator   int
+	createdBy stack.Call
+}
+
+func (g *goroutine) toGoroutine(now time.Duration) *stack.Goroutine {
+	out := &stack.Goroutine{
+		Signature: stack.Signature{
+			State:       g.stateString(),
+			CreatedBy:   g.createdBy,
+			CreatedByID: g.creator,
+		},
+		ID:    g.id,
+		First: g.first,
+	}
+	if g.state == Waiting || g.state == Syscall {
+		// Like the runtime, only report full minutes.
+		out.SleepMin = int((now - g.since) / time.Minute)
+		out.SleepMax = out.SleepMin
+	}
+	for _, f := range g.stack {
+		out.Stack.Calls = append(out.Stack.Calls, toCall(f))
+	}
+	return out
+}
+
+// stateString returns the state as printed by the runtime in a stack dump.
+func (g *goroutine) stateString() string {
+	switch g.state {
+	case Runnable:
+		return "runnable"
+	case Running:
+		return "running"
+	case Syscall:
+		return "syscall"
+	case Waiting:
+		if g.reason != "" {
+			return g.reason
+		}
+		return "waiting"
+	default:
+		return ""
+	}
+}
+
+func toCall(f Frame) stack.Call {
+	return stack.Call{Func: stack.NewFunc(f.Func), SrcPath: f.File, Line: f.Line}
+}
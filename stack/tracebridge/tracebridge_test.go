@@ -0,0 +1,42 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package tracebridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tchinmai7/panicparse/stack"
+)
+
+func TestSnapshots(t *testing.T) {
+	t.Parallel()
+	main := []Frame{{Func: "main.main", File: "/src/main.go", Line: 10}}
+	worker := []Frame{{Func: "main.worker", File: "/src/main.go", Line: 20}}
+	events := []Event{
+		{Time: 0, ID: 1, From: NotExist, To: Running, Stack: main},
+		{Time: time.Second, ID: 6, From: NotExist, To: Runnable, Creator: 1, CreatorStack: main},
+		{Time: 2 * time.Second, ID: 6, From: Runnable, To: Waiting, Reason: "chan receive", Stack: worker},
+		{Time: 3 * time.Minute, ID: 7, From: NotExist, To: Runnable, Creator: 1, CreatorStack: main},
+		{Time: 4 * time.Minute, ID: 7, From: Runnable, To: NotExist},
+	}
+	s := Snapshots(events, 2*time.Minute)
+	if len(s) != 3 {
+		t.Fatalf("want 3 snapshots, got %d", len(s))
+	}
+	if s[0].Time != 2*time.Minute || len(s[0].Context.Goroutines) != 2 {
+		t.Fatalf("unexpected snapshot: %#v", s[0])
+	}
+	g := s[2].Context.Goroutines[1]
+	if g.ID != 6 || g.WaitReason() != stack.WaitChanReceive || g.SleepMax != 3 || g.CreatedByID != 1 || g.CreatedBy.Func.Raw != "main.main" {
+		t.Fatalf("unexpected goroutine: %#v", g)
+	}
+	if len(s[1].Context.Goroutines) != 3 || len(s[2].Context.Goroutines) != 2 {
+		t.Fatal("unexpected goroutines count")
+	}
+	if c := s[2].Context; c.Creator(g) != c.Goroutines[0] || !c.Goroutines[0].First {
+		t.Fatal("unexpected creation tree")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// Creator returns the goroutine that created g.
+//
+// Returns nil if the creator is not known, which is the case before Go 1.21,
+// or if it exited before the snapshot was taken.
+func (c *Context) Creator(g *Goroutine) *Goroutine {
+	if g.CreatedByID == 0 {
+		return nil
+	}
+	for _, r := range c.Goroutines {
+		if r.ID == g.CreatedByID && r.Source == g.Source {
+			return r
+		}
+	}
+	return nil
+}
+
+// Children returns the goroutines created by g, in the order they were
+// printed.
+//
+// It only works with Go 1.21 and later, which print the creator goroutine ID.
+func (c *Context) Children(g *Goroutine) []*Goroutine {
+	var out []*Goroutine
+	for _, r := range c.Goroutines {
+		if r.CreatedByID == g.ID && g.ID != 0 && r.Source == g.Source {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Roots returns the goroutines whose creator is not in the snapshot, in the
+// order they were printed. Combined with Children, this allows walking the
+// creation tree.
+func (c *Context) Roots() []*Goroutine {
+	type key struct {
+		source string
+		id     int
+	}
+	ids := make(map[key]bool, len(c.Goroutines))
+	for _, g := range c.Goroutines {
+		ids[key{g.Source, g.ID}] = true
+	}
+	var out []*Goroutine
+	for _, g := range c.Goroutines {
+		if g.CreatedByID == 0 || !ids[key{g.Source, g.CreatedByID}] {
+			out = append(out, g)
+		}
+	}
+	return out
+}
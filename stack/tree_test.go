@@ -0,0 +1,64 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreationTree(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.worker()",
+		"	/home/user/src/foo/main.go:20 +0x45",
+		"created by main.main in goroutine 1",
+		"	/home/user/src/foo/main.go:9 +0x45",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.worker()",
+		"	/home/user/src/foo/main.go:20 +0x45",
+		"created by main.spawn in goroutine 6",
+		"	/home/user/src/foo/main.go:30 +0x45",
+		"",
+		"goroutine 8 [select]:",
+		"main.other()",
+		"	/home/user/src/foo/main.go:40 +0x45",
+		"created by main.gone in goroutine 5",
+		"	/home/user/src/foo/main.go:50 +0x45",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := c.Goroutines
+	compareString(t, "main.main", g[1].CreatedBy.Func.Raw)
+	if g[1].CreatedByID != 1 || g[2].CreatedByID != 6 {
+		t.Fatalf("unexpected CreatedByID: %d, %d", g[1].CreatedByID, g[2].CreatedByID)
+	}
+	if c.Creator(g[2]) != g[1] || c.Creator(g[0]) != nil || c.Creator(g[3]) != nil {
+		t.Fatal("unexpected Creator")
+	}
+	if ch := c.Children(g[0]); len(ch) != 1 || ch[0] != g[1] {
+		t.Fatalf("unexpected Children: %v", ch)
+	}
+	if r := c.Roots(); len(r) != 2 || r[0] != g[0] || r[1] != g[3] {
+		t.Fatalf("unexpected Roots: %v", r)
+	}
+	// Goroutines 6 and 7 are in the same bucket but were created by different
+	// goroutines.
+	b := Aggregate(g, AnyPointer)
+	for _, bucket := range b {
+		if len(bucket.IDs) == 2 && bucket.CreatedByID != 0 {
+			t.Fatalf("unexpected CreatedByID %d", bucket.CreatedByID)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+)
+
+// WaitReason is the typed form of the goroutine state as printed in the
+// goroutine header, e.g. "chan receive".
+//
+// The strings printed by the runtime are the waitReason values in
+// runtime/runtime2.go, plus the goroutine status for goroutines that are not
+// waiting. The raw string is always available in Signature.State.
+type WaitReason int
+
+const (
+	// WaitUnknown is a state not recognized, use Signature.State.
+	WaitUnknown WaitReason = iota
+
+	// Not waiting.
+	WaitRunning
+	WaitRunnable
+	WaitSyscall
+	WaitIdle
+	WaitDead
+	WaitCopyStack
+	WaitPreempted
+
+	// Channels.
+	WaitChanReceive
+	WaitChanSend
+	WaitChanReceiveNilChan
+	WaitChanSendNilChan
+	WaitSelect
+	WaitSelectNoCases
+
+	// Synchronization.
+	WaitSemacquire
+	WaitSyncMutexLock
+	WaitSyncRWMutexRLock
+	WaitSyncRWMutexLock
+	WaitSyncCondWait
+	WaitSyncWaitGroupWait
+
+	// Timers and I/O.
+	WaitSleep
+	WaitIOWait
+	WaitTimerGoroutineIdle
+
+	// Garbage collector and runtime internals.
+	WaitGCAssistMarking
+	WaitGCAssistWait
+	WaitGCSweepWait
+	WaitGCScavengeWait
+	WaitGCWorkerIdle
+	WaitGCMarkTermination
+	WaitForceGCIdle
+	WaitFinalizerWait
+	WaitGarbageCollection
+	WaitPanicWait
+	WaitTraceReaderBlocked
+	WaitDebugCall
+	WaitStoppingTheWorld
+	WaitPreemptedWait
+)
+
+// ParseWaitReason converts a goroutine state as printed by the runtime.
+//
+// It returns WaitUnknown for unrecognized states.
+func ParseWaitReason(state string) WaitReason {
+	if w, ok := waitReasons[state]; ok {
+		return w
+	}
+	// GC stack scanning states are prefixed with "scan", e.g. "scanrunnable".
+	if strings.HasPrefix(state, "scan") {
+		return waitReasons[state[len("scan"):]]
+	}
+	return WaitUnknown
+}
+
+// String returns the canonical string printed by the runtime for this state.
+func (w WaitReason) String() string {
+	if w <= WaitUnknown || int(w) >= len(waitReasonNames) {
+		return "unknown"
+	}
+	return waitReasonNames[w]
+}
+
+// WaitReason returns the typed form of State.
+func (s *Signature) WaitReason() WaitReason {
+	return ParseWaitReason(s.State)
+}
+
+// Private stuff.
+
+// waitReasonNames is the canonical string for each WaitReason.
+var waitReasonNames = [...]string{
+	WaitUnknown:            "unknown",
+	WaitRunning:            "running",
+	WaitRunnable:           "runnable",
+	WaitSyscall:            "syscall",
+	WaitIdle:               "idle",
+	WaitDead:               "dead",
+	WaitCopyStack:          "copystack",
+	WaitPreempted:          "preempted",
+	WaitChanReceive:        "chan receive",
+	WaitChanSend:           "chan send",
+	WaitChanReceiveNilChan: "chan receive (nil chan)",
+	WaitChanSendNilChan:    "chan send (nil chan)",
+	WaitSelect:             "select",
+	WaitSelectNoCases:      "select (no cases)",
+	WaitSemacquire:         "semacquire",
+	WaitSyncMutexLock:      "sync.Mutex.Lock",
+	WaitSyncRWMutexRLock:   "sync.RWMutex.RLock",
+	WaitSyncRWMutexLock:    "sync.RWMutex.Lock",
+	WaitSyncCondWait:       "sync.Cond.Wait",
+	WaitSyncWaitGroupWait:  "sync.WaitGroup.Wait",
+	WaitSleep:              "sleep",
+	WaitIOWait:             "IO wait",
+	WaitTimerGoroutineIdle: "timer goroutine (idle)",
+	WaitGCAssistMarking:    "GC assist marking",
+	WaitGCAssistWait:       "GC assist wait",
+	WaitGCSweepWait:        "GC sweep wait",
+	WaitGCScavengeWait:     "GC scavenge wait",
+	WaitGCWorkerIdle:       "GC worker (idle)",
+	WaitGCMarkTermination:  "GC mark termination",
+	WaitForceGCIdle:        "force gc (idle)",
+	WaitFinalizerWait:      "finalizer wait",
+	WaitGarbageCollection:  "garbage collection",
+	WaitPanicWait:          "panicwait",
+	WaitTraceReaderBlocked: "trace reader (blocked)",
+	WaitDebugCall:          "debug call",
+	WaitStoppingTheWorld:   "stopping the world",
+	WaitPreemptedWait:      "preempted (wait)",
+}
+
+// waitReasons maps all the strings known to have been printed by the runtime
+// across Go versions.
+var waitReasons = map[string]WaitReason{
+	"running":   WaitRunning,
+	"runnable":  WaitRunnable,
+	"syscall":   WaitSyscall,
+	"idle":      WaitIdle,
+	"dead":      WaitDead,
+	"copystack": WaitCopyStack,
+	"preempted": WaitPreempted,
+
+	"chan receive":            WaitChanReceive,
+	"chan send":               WaitChanSend,
+	"chan receive (nil chan)": WaitChanReceiveNilChan,
+	"chan send (nil chan)":    WaitChanSendNilChan,
+	"select":                  WaitSelect,
+	"select (no cases)":       WaitSelectNoCases,
+
+	"semacquire":          WaitSemacquire,
+	"sync.Mutex.Lock":     WaitSyncMutexLock,
+	"sync.RWMutex.RLock":  WaitSyncRWMutexRLock,
+	"sync.RWMutex.Lock":   WaitSyncRWMutexLock,
+	"sync.Cond.Wait":      WaitSyncCondWait,
+	"sync.WaitGroup.Wait": WaitSyncWaitGroupWait,
+
+	"sleep":                  WaitSleep,
+	"IO wait":                WaitIOWait,
+	"timer goroutine (idle)": WaitTimerGoroutineIdle,
+
+	"GC assist marking":      WaitGCAssistMarking,
+	"GC assist wait":         WaitGCAssistWait,
+	"GC sweep wait":          WaitGCSweepWait,
+	"GC scavenge wait":       WaitGCScavengeWait,
+	"GC worker (idle)":       WaitGCWorkerIdle,
+	"mark wait (idle)":       WaitGCWorkerIdle,
+	"GC mark termination":    WaitGCMarkTermination,
+	"force gc (idle)":        WaitForceGCIdle,
+	"finalizer wait":         WaitFinalizerWait,
+	"garbage collection":     WaitGarbageCollection,
+	"Concurrent GC wait":     WaitGarbageCollection,
+	"panicwait":              WaitPanicWait,
+	"trace reader (blocked)": WaitTraceReaderBlocked,
+	"debug call":             WaitDebugCall,
+	"stopping the world":     WaitStoppingTheWorld,
+	"preempted (wait)":       WaitPreemptedWait,
+
+	// The "runtime stack:" pseudo goroutine is the thread that threw.
+	runtimeStack: WaitRunning,
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+)
+
+func TestParseWaitReason(t *testing.T) {
+	t.Parallel()
+	data := map[string]WaitReason{
+		"chan receive":      WaitChanReceive,
+		"select":            WaitSelect,
+		"IO wait":           WaitIOWait,
+		"GC assist wait":    WaitGCAssistWait,
+		"semacquire":        WaitSemacquire,
+		"mark wait (idle)":  WaitGCWorkerIdle,
+		"scanrunnable":      WaitRunnable,
+		"something new":     WaitUnknown,
+		"select (no cases)": WaitSelectNoCases,
+	}
+	for state, want := range data {
+		if got := ParseWaitReason(state); got != want {
+			t.Errorf("%q: got %s, want %s", state, got, want)
+		}
+	}
+	// Round trip.
+	for w := WaitRunning; int(w) < len(waitReasonNames); w++ {
+		if got := ParseWaitReason(w.String()); got != w {
+			t.Errorf("%d: %q round tripped to %s", w, w.String(), got)
+		}
+	}
+	compareString(t, "unknown", WaitReason(-1).String())
+	s := Signature{State: "sync.WaitGroup.Wait"}
+	if s.WaitReason() != WaitSyncWaitGroupWait {
+		t.Fatal("expected sync.WaitGroup.Wait")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// WalkAction tells Walk what to do after visiting a goroutine or a call.
+type WalkAction int
+
+const (
+	// WalkContinue keeps the item. For a goroutine, its calls are visited
+	// next.
+	WalkContinue WalkAction = iota
+	// WalkSkip keeps the item. For a goroutine, its calls are not visited.
+	// For a call, it is the same as WalkContinue.
+	WalkSkip
+	// WalkRemove removes the goroutine from Context.Goroutines or the call
+	// from Stack.Calls. Removing Signature.CreatedBy resets it to the zero
+	// Call.
+	WalkRemove
+	// WalkStop keeps the item and stops walking.
+	WalkStop
+)
+
+// Visitor is called by Walk for each goroutine and each of its calls.
+//
+// The visitor can rewrite the items in place, e.g. to redact arguments or to
+// map paths, and decide to skip or remove them by the returned WalkAction.
+type Visitor interface {
+	// VisitGoroutine is called for each goroutine, before its calls.
+	VisitGoroutine(g *Goroutine) WalkAction
+	// VisitCall is called for each call of g.Stack.Calls, from the innermost
+	// one, then for g.CreatedBy if set. Use c == &g.CreatedBy to tell them
+	// apart.
+	VisitCall(g *Goroutine, c *Call) WalkAction
+}
+
+// VisitorFuncs is a Visitor calling the functions that are set.
+type VisitorFuncs struct {
+	Goroutine func(g *Goroutine) WalkAction
+	Call      func(g *Goroutine, c *Call) WalkAction
+}
+
+// VisitGoroutine implements Visitor.
+func (v *VisitorFuncs) VisitGoroutine(g *Goroutine) WalkAction {
+	if v.Goroutine == nil {
+		return WalkContinue
+	}
+	return v.Goroutine(g)
+}
+
+// VisitCall implements Visitor.
+func (v *VisitorFuncs) VisitCall(g *Goroutine, c *Call) WalkAction {
+	if v.Call == nil {
+		return WalkContinue
+	}
+	return v.Call(g, c)
+}
+
+// Walk visits the goroutines in order, then their calls.
+//
+// It modifies the Context in place; use Clone first to keep the original.
+// When items are removed, new slices are allocated so slices shared with
+// other Context or Goroutine are not modified. Goroutine.CallSpans is kept in
+// sync with the calls.
+func (c *Context) Walk(v Visitor) {
+	var kept []*Goroutine
+	removed := false
+	stop := false
+	for i, g := range c.Goroutines {
+		a := WalkContinue
+		if !stop {
+			a = v.VisitGoroutine(g)
+			switch a {
+			case WalkContinue:
+				stop = g.walkCalls(v)
+			case WalkStop:
+				stop = true
+			}
+		}
+		if a == WalkRemove {
+			if !removed {
+				kept = append(make([]*Goroutine, 0, len(c.Goroutines)), c.Goroutines[:i]...)
+				removed = true
+			}
+			continue
+		}
+		if removed {
+			kept = append(kept, g)
+		}
+	}
+	if removed {
+		c.Goroutines = kept
+	}
+}
+
+// Private stuff.
+
+// walkCalls visits the calls of g. Returns true if the walk must stop.
+func (g *Goroutine) walkCalls(v Visitor) bool {
+	calls := g.Stack.Calls
+	spans := len(g.CallSpans) == len(calls)
+	var kept []Call
+	var keptSpans []Span
+	removed := false
+	stop := false
+	for i := range calls {
+		a := WalkContinue
+		if !stop {
+			a = v.VisitCall(g, &calls[i])
+			stop = a == WalkStop
+		}
+		if a == WalkRemove {
+			if !removed {
+				kept = append(make([]Call, 0, len(calls)), calls[:i]...)
+				if spans {
+					keptSpans = append(make([]Span, 0, len(calls)), g.CallSpans[:i]...)
+				}
+				removed = true
+			}
+			continue
+		}
+		if removed {
+			kept = append(kept, calls[i])
+			if spans {
+				keptSpans = append(keptSpans, g.CallSpans[i])
+			}
+		}
+	}
+	if removed {
+		g.Stack.Calls = kept
+		if spans {
+			g.CallSpans = keptSpans
+		}
+	}
+	if !stop && g.CreatedBy.Func.Raw != "" {
+		switch v.VisitCall(g, &g.CreatedBy) {
+		case WalkRemove:
+			g.CreatedBy = Call{}
+			g.CreatedBySpan = Span{}
+		case WalkStop:
+			stop = true
+		}
+	}
+	return stop
+}
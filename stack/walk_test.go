@@ -0,0 +1,106 @@
+// Copyright 2018 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [running]:",
+		"main.secret(0x1234)",
+		"	/home/user/src/foo/main.go:10 +0x45",
+		"main.main()",
+		"	/home/user/src/foo/main.go:20 +0x25",
+		"",
+		"goroutine 6 [chan receive]:",
+		"runtime.gopark(0x0)",
+		"	/goroot/src/runtime/proc.go:398 +0xce",
+		"main.worker(0x5678)",
+		"	/home/user/src/foo/main.go:30 +0x12",
+		"created by main.main in goroutine 1",
+		"	/home/user/src/foo/main.go:19 +0x45",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.worker(0x9abc)",
+		"	/home/user/src/foo/main.go:30 +0x12",
+		"",
+	}
+	c, err := ParseDump(strings.NewReader(strings.Join(data, "\n")), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := c.Clone()
+	var created []string
+	c.Walk(&VisitorFuncs{
+		Goroutine: func(g *Goroutine) WalkAction {
+			if g.ID == 7 {
+				return WalkRemove
+			}
+			return WalkContinue
+		},
+		Call: func(g *Goroutine, call *Call) WalkAction {
+			if call == &g.CreatedBy {
+				created = append(created, call.Func.Raw)
+				return WalkContinue
+			}
+			if call.Func.Raw == "runtime.gopark" {
+				return WalkRemove
+			}
+			// Redact the arguments.
+			call.Args = Args{Elided: len(call.Args.Values) != 0}
+			return WalkContinue
+		},
+	})
+	var got []string
+	for _, g := range c.Goroutines {
+		for i := range g.Stack.Calls {
+			got = append(got, g.Stack.Calls[i].Func.Raw+"("+g.Stack.Calls[i].Args.String()+")")
+		}
+		if len(g.CallSpans) != len(g.Stack.Calls) {
+			t.Fatalf("goroutine %d: %d spans for %d calls", g.ID, len(g.CallSpans), len(g.Stack.Calls))
+		}
+	}
+	want := []string{"main.secret(...)", "main.main()", "main.worker(...)"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Calls mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"main.main"}, created); diff != "" {
+		t.Fatalf("CreatedBy mismatch (-want +got):\n%s", diff)
+	}
+	if c.Goroutines[1].CallSpans[0].First != 10 {
+		t.Fatalf("unexpected span: %#v", c.Goroutines[1].CallSpans[0])
+	}
+	if len(orig.Goroutines) != 3 || len(orig.Goroutines[1].Stack.Calls) != 2 {
+		t.Fatal("the clone was modified")
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	t.Parallel()
+	data := "goroutine 1 [running]:\nmain.a()\n\t/src/main.go:1 +0x1\nmain.b()\n\t/src/main.go:2 +0x1\n\ngoroutine 2 [running]:\nmain.c()\n\t/src/main.go:3 +0x1\n\n"
+	c, err := ParseDump(strings.NewReader(data), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	c.Walk(&VisitorFuncs{
+		Call: func(g *Goroutine, call *Call) WalkAction {
+			visited = append(visited, call.Func.Raw)
+			return WalkStop
+		},
+	})
+	if diff := cmp.Diff([]string{"main.a"}, visited); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if len(c.Goroutines) != 2 || len(c.Goroutines[0].Stack.Calls) != 2 {
+		t.Fatal("unexpected removal")
+	}
+}